@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"vayer-electric-backend/db"
+	"vayer-electric-backend/media"
+	"vayer-electric-backend/storage"
+
+	"go.uber.org/zap"
+)
+
+// Admin commands run in place of the server, like `vayer-electric-backend gc-media -dry-run`.
+// Returns the exit code.
+func runCommand(ctx context.Context, args []string) int {
+	switch args[0] {
+	case "gc-media":
+		return gcMedia(ctx, args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %s, available commands: gc-media\n", args[0])
+		return 2
+	}
+}
+
+// Sweeps orphaned images once and prints the report as json
+func gcMedia(ctx context.Context, args []string) int {
+	config := media.SweepConfigFromEnv()
+
+	flags := flag.NewFlagSet("gc-media", flag.ContinueOnError)
+	flags.BoolVar(&config.DryRun, "dry-run", config.DryRun, "report orphaned images without removing them")
+	flags.DurationVar(&config.Grace, "grace", config.Grace, "leave images younger than this alone")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	store := db.GetStore()
+	defer store.Close()
+
+	report, err := media.NewSweeper(store, storage.GetBlobStore(), config).Sweep(ctx)
+
+	if err != nil {
+		log.Error("media sweep failed", zap.Error(err))
+		return 1
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(report)
+
+	if len(report.Failed) > 0 {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,717 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"vayer-electric-backend/structs"
+	"vayer-electric-backend/units"
+
+	"github.com/lib/pq"
+)
+
+var attributeCodePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// Filters products on the value of an attribute, matched by code across subcategories.
+// Values match any of the given values, Min and Max bound numeric attributes.
+// Numbers are given in Unit, or in the unit of each definition when it's empty, and values may carry
+// their own unit like "12 AWG". They're compared in canonical units so 2.5 mm2 matches 13 AWG ranges.
+type AttributeFilter struct {
+	Code   string
+	Values []string
+	Min    *float64
+	Max    *float64
+	Unit   string
+
+	conditions []attributeCondition
+}
+
+// attributeCondition is an AttributeFilter resolved against one definition, numbers in canonical units
+type attributeCondition struct {
+	attributeId int64
+	texts       []string
+	numbers     []float64
+	booleans    []bool
+	min         *float64
+	max         *float64
+}
+
+// Checks a definition before it's stored and cleans up its fields
+func validateAttributeDefinition(def *structs.AttributeDefinition) error {
+	def.Code = strings.TrimSpace(def.Code)
+	def.Name = strings.TrimSpace(def.Name)
+	def.Unit = strings.TrimSpace(def.Unit)
+
+	if !attributeCodePattern.MatchString(def.Code) {
+		return ValidationError("code must be lowercase letters, digits or underscores and start with a letter")
+	}
+
+	if def.Name == "" {
+		return ValidationError("name is required")
+	}
+
+	switch def.Type {
+	case structs.AttributeNumber:
+		if len(def.AllowedValues) > 0 {
+			return ValidationError("allowed_values only apply to enum attributes")
+		}
+		if def.Unit != "" {
+			u, err := units.Lookup(def.Unit)
+			if err != nil {
+				return ValidationError(fmt.Sprintf("unknown unit %s", def.Unit))
+			}
+			def.Unit = u.Symbol
+		}
+	case structs.AttributeEnum, structs.AttributeMultiEnum:
+		if len(def.AllowedValues) == 0 {
+			return ValidationError("enum attributes need allowed_values")
+		}
+		if def.Unit != "" {
+			return ValidationError("unit only applies to number attributes")
+		}
+	case structs.AttributeBoolean:
+		if len(def.AllowedValues) > 0 || def.Unit != "" {
+			return ValidationError("boolean attributes take no unit or allowed_values")
+		}
+	default:
+		return ValidationError("type must be one of number, enum, multi_enum or boolean")
+	}
+
+	for i, v := range def.AllowedValues {
+		if def.AllowedValues[i] = strings.TrimSpace(v); def.AllowedValues[i] == "" {
+			return ValidationError("allowed_values can't be blank")
+		}
+	}
+
+	return nil
+}
+
+// Returns the allowed value equal to v ignoring case, so "ip65" is stored as "IP65"
+func allowedValue(def structs.AttributeDefinition, v string) (string, bool) {
+	for _, allowed := range def.AllowedValues {
+		if strings.EqualFold(allowed, strings.TrimSpace(v)) {
+			return allowed, true
+		}
+	}
+	return "", false
+}
+
+// Validates the raw attribute values sent for a product against the definitions of its subcategory.
+// values is keyed by attribute code, numbers may be sent bare in the definition unit, as {"value": 2.5, "unit": "mm2"}
+// or as a string like "12 AWG", and come back in the canonical unit of their dimension.
+// Null values are treated as missing.
+func validateAttributeValues(defs []structs.AttributeDefinition, values map[string]interface{}) ([]structs.ProductAttribute, error) {
+	byCode := make(map[string]structs.AttributeDefinition)
+	for _, def := range defs {
+		byCode[def.Code] = def
+	}
+
+	for code := range values {
+		if _, ok := byCode[code]; !ok {
+			return nil, ValidationError(fmt.Sprintf("unknown attribute %s for this subcategory", code))
+		}
+	}
+
+	attributes := make([]structs.ProductAttribute, 0, len(values))
+
+	for _, def := range defs {
+		raw, ok := values[def.Code]
+
+		if !ok || raw == nil {
+			if def.Required {
+				return nil, ValidationError(fmt.Sprintf("attribute %s is required", def.Code))
+			}
+			continue
+		}
+
+		value, err := attributeValue(def, raw)
+		if err != nil {
+			return nil, err
+		}
+
+		attributes = append(attributes, structs.ProductAttribute{
+			AttributeId: def.Id,
+			Code:        def.Code,
+			Name:        def.Name,
+			Type:        def.Type,
+			Value:       value,
+			Unit:        def.Unit,
+		})
+	}
+
+	return attributes, nil
+}
+
+// Converts a decoded json value into the typed value of def
+func attributeValue(def structs.AttributeDefinition, raw interface{}) (interface{}, error) {
+	switch def.Type {
+	case structs.AttributeNumber:
+		number, unit := raw, ""
+
+		if object, ok := raw.(map[string]interface{}); ok {
+			number = object["value"]
+			if u, ok := object["unit"].(string); ok {
+				unit = strings.TrimSpace(u)
+			}
+		}
+
+		var n float64
+		var given *units.Unit
+
+		switch v := number.(type) {
+		case float64:
+			n = v
+		case string:
+			if unit != "" {
+				// {"value": "4/0", "unit": "AWG"}
+				v += " " + unit
+			}
+			parsed, u, err := units.Parse(v)
+			if err != nil {
+				return nil, ValidationError(fmt.Sprintf("attribute %s: %s", def.Code, err.Error()))
+			}
+			n, given, unit = parsed, u, ""
+		default:
+			return nil, ValidationError(fmt.Sprintf("attribute %s must be a number", def.Code))
+		}
+
+		if unit != "" {
+			u, err := units.Lookup(unit)
+			if err != nil {
+				return nil, ValidationError(fmt.Sprintf("attribute %s: %s", def.Code, err.Error()))
+			}
+			given = &u
+		}
+
+		canonical, err := canonicalNumber(def, n, given)
+		if err != nil {
+			return nil, err
+		}
+
+		return canonical, nil
+
+	case structs.AttributeEnum:
+		s, ok := raw.(string)
+		if !ok {
+			return nil, ValidationError(fmt.Sprintf("attribute %s must be a string", def.Code))
+		}
+
+		v, ok := allowedValue(def, s)
+		if !ok {
+			return nil, ValidationError(fmt.Sprintf("attribute %s must be one of %s", def.Code, strings.Join(def.AllowedValues, ", ")))
+		}
+
+		return v, nil
+
+	case structs.AttributeMultiEnum:
+		list, ok := raw.([]interface{})
+		if !ok {
+			return nil, ValidationError(fmt.Sprintf("attribute %s must be a list", def.Code))
+		}
+
+		seen := make(map[string]bool)
+		selected := make([]string, 0, len(list))
+
+		for _, item := range list {
+			s, _ := item.(string)
+
+			v, ok := allowedValue(def, s)
+			if !ok {
+				return nil, ValidationError(fmt.Sprintf("attribute %s values must be among %s", def.Code, strings.Join(def.AllowedValues, ", ")))
+			}
+
+			if !seen[v] {
+				seen[v] = true
+				selected = append(selected, v)
+			}
+		}
+
+		sort.Strings(selected)
+
+		return selected, nil
+
+	case structs.AttributeBoolean:
+		b, ok := raw.(bool)
+		if !ok {
+			return nil, ValidationError(fmt.Sprintf("attribute %s must be a boolean", def.Code))
+		}
+
+		return b, nil
+	}
+
+	return nil, ValidationError(fmt.Sprintf("attribute %s has an unknown type", def.Code))
+}
+
+// Converts a number given in unit, or in the definition unit when it's nil, to the canonical unit it's stored in.
+// Definitions without a known unit store numbers as given.
+func canonicalNumber(def structs.AttributeDefinition, n float64, unit *units.Unit) (float64, error) {
+	defUnit, err := units.Lookup(def.Unit)
+
+	if def.Unit == "" || err != nil {
+		if unit != nil && unit.Symbol != def.Unit {
+			return 0, ValidationError(fmt.Sprintf("attribute %s can't be given in %s", def.Code, unit.Symbol))
+		}
+		return n, nil
+	}
+
+	if unit == nil {
+		return defUnit.ToCanonical(n), nil
+	}
+
+	if unit.Dimension != defUnit.Dimension {
+		return 0, ValidationError(fmt.Sprintf("attribute %s is a %s, it can't be given in %s", def.Code, defUnit.Dimension, unit.Symbol))
+	}
+
+	return unit.ToCanonical(n), nil
+}
+
+// Turns a number read in its canonical unit into the unit of its definition, keeping the canonical value
+// so it can be converted again
+func presentAttribute(a structs.ProductAttribute) structs.ProductAttribute {
+	n, ok := a.Value.(float64)
+	if !ok {
+		return a
+	}
+
+	u, err := units.Lookup(a.Unit)
+	if err != nil {
+		return a
+	}
+
+	a.SIValue = &n
+	a.Value = units.Present(u.FromCanonical(n), u)
+
+	return a
+}
+
+// Resolves the filters against the definitions sharing their codes, the way values were stored for each
+func resolveAttributeFilters(filters []AttributeFilter, defs []structs.AttributeDefinition) ([]AttributeFilter, error) {
+	resolved := make([]AttributeFilter, 0, len(filters))
+
+	for _, f := range filters {
+		var unit *units.Unit
+
+		if f.Unit != "" {
+			u, err := units.Lookup(f.Unit)
+			if err != nil {
+				return nil, ValidationError(err.Error())
+			}
+			unit = &u
+		}
+
+		f.conditions = make([]attributeCondition, 0)
+
+		for _, def := range defs {
+			if def.Code != f.Code {
+				continue
+			}
+
+			c, ok, err := resolveAttributeCondition(f, def, unit)
+			if err != nil {
+				return nil, err
+			}
+
+			if ok {
+				f.conditions = append(f.conditions, c)
+			}
+		}
+
+		resolved = append(resolved, f)
+	}
+
+	return resolved, nil
+}
+
+// Returns false when no value of def can match the filter, like a range on an enum or a power bound on a length.
+// A range with its min above its max is a ValidationError, except in wire gauges where either order is taken.
+func resolveAttributeCondition(f AttributeFilter, def structs.AttributeDefinition, unit *units.Unit) (attributeCondition, bool, error) {
+	c := attributeCondition{attributeId: def.Id}
+
+	if def.Type != structs.AttributeNumber {
+		if f.Min != nil || f.Max != nil {
+			return c, false, nil
+		}
+
+		for _, v := range f.Values {
+			if def.Type == structs.AttributeBoolean {
+				if b, err := strconv.ParseBool(v); err == nil {
+					c.booleans = append(c.booleans, b)
+				}
+			} else {
+				c.texts = append(c.texts, v)
+			}
+		}
+
+		return c, len(c.texts) > 0 || len(c.booleans) > 0, nil
+	}
+
+	bound := func(v *float64) (*float64, bool) {
+		if v == nil {
+			return nil, true
+		}
+		n, err := canonicalNumber(def, *v, unit)
+		return &n, err == nil
+	}
+
+	var ok bool
+	if c.min, ok = bound(f.Min); !ok {
+		return c, false, nil
+	}
+	if c.max, ok = bound(f.Max); !ok {
+		return c, false, nil
+	}
+
+	if c.min != nil && c.max != nil && *c.min > *c.max {
+		symbol := def.Unit
+		if unit != nil {
+			symbol = unit.Symbol
+		}
+
+		// Wire gauges grow as the cross section shrinks, a range of gauges is the reverse range of areas
+		if symbol != "AWG" {
+			return c, false, ValidationError(fmt.Sprintf("attr.%s.min can't be above attr.%s.max", f.Code, f.Code))
+		}
+
+		c.min, c.max = c.max, c.min
+	}
+
+	for _, v := range f.Values {
+		n, valueUnit, err := units.Parse(v)
+		if err != nil {
+			continue
+		}
+
+		if valueUnit == nil {
+			valueUnit = unit
+		}
+
+		if n, err = canonicalNumber(def, n, valueUnit); err == nil {
+			c.numbers = append(c.numbers, n)
+		}
+	}
+
+	return c, len(f.Values) == 0 || len(c.numbers) > 0, nil
+}
+
+// Builds the EXISTS condition matching products that carry the filtered attribute value,
+// with one alternative per definition the filter was resolved against
+func attributeFilterSQL(f AttributeFilter, args *sqlArgs) string {
+	alternatives := make([]string, 0, len(f.conditions))
+
+	for _, c := range f.conditions {
+		conditions := []string{fmt.Sprintf("pa.attribute_id = %s", args.add(c.attributeId))}
+
+		if len(c.texts) > 0 {
+			values := make([]string, 0, len(c.texts))
+			for _, v := range c.texts {
+				values = append(values, fmt.Sprintf("lower(%s)", args.add(v)))
+			}
+			conditions = append(conditions, fmt.Sprintf("lower(pa.text_value) IN (%s)", strings.Join(values, ", ")))
+		}
+
+		if len(c.numbers) > 0 {
+			conditions = append(conditions, fmt.Sprintf("pa.number_value = ANY(%s::numeric[])", args.add(pq.Array(c.numbers))))
+		}
+
+		if len(c.booleans) > 0 {
+			conditions = append(conditions, fmt.Sprintf("pa.bool_value = ANY(%s::boolean[])", args.add(pq.Array(c.booleans))))
+		}
+
+		if c.min != nil {
+			conditions = append(conditions, fmt.Sprintf("pa.number_value >= %s", args.add(*c.min)))
+		}
+
+		if c.max != nil {
+			conditions = append(conditions, fmt.Sprintf("pa.number_value <= %s", args.add(*c.max)))
+		}
+
+		alternatives = append(alternatives, "("+strings.Join(conditions, " AND ")+")")
+	}
+
+	if len(alternatives) == 0 {
+		return "FALSE"
+	}
+
+	return "EXISTS (SELECT 1 FROM product_attribute pa WHERE pa.product_id = p.id AND (" + strings.Join(alternatives, " OR ") + "))"
+}
+
+// Loads the definitions the filters of q refer to and resolves them
+func (s DbSource) resolveAttributeFilters(ctx context.Context, q *ProductQuery) error {
+	if len(q.Attributes) == 0 {
+		return nil
+	}
+
+	codes := make([]string, 0, len(q.Attributes))
+	for _, f := range q.Attributes {
+		codes = append(codes, f.Code)
+	}
+
+	rows, err := s.conn.QueryContext(ctx, "SELECT "+attributeDefinitionColumns+" FROM attribute_definition WHERE code = ANY($1)", pq.Array(codes))
+
+	if err != nil {
+		log.Error(err.Error())
+		return translateError(err, "attribute_definition")
+	}
+
+	defer rows.Close()
+
+	defs := make([]structs.AttributeDefinition, 0)
+
+	for rows.Next() {
+		def, err := scanAttributeDefinition(rows.Scan)
+
+		if err != nil {
+			log.Error(err.Error())
+			return translateError(err, "attribute_definition")
+		}
+
+		defs = append(defs, def)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error(err.Error())
+		return translateError(err, "attribute_definition")
+	}
+
+	q.Attributes, err = resolveAttributeFilters(q.Attributes, defs)
+
+	return err
+}
+
+func scanAttributeDefinition(scan func(dest ...interface{}) error) (structs.AttributeDefinition, error) {
+	var def structs.AttributeDefinition
+	var unit sql.NullString
+	var allowedValues pq.StringArray
+
+	err := scan(&def.Id, &def.SubcategoryId, &def.Code, &def.Name, &def.Type, &unit, &allowedValues, &def.Required, &def.CreatedAt)
+
+	def.Unit = unit.String
+	def.AllowedValues = allowedValues
+
+	return def, err
+}
+
+const attributeDefinitionColumns = "id, subcategory_id, code, name, type, unit, allowed_values, required, created_at"
+
+func (s DbSource) InsertAttributeDefinition(ctx context.Context, def structs.AttributeDefinition) (structs.AttributeDefinition, error) {
+	if err := validateAttributeDefinition(&def); err != nil {
+		return structs.AttributeDefinition{}, err
+	}
+
+	row := s.conn.QueryRowContext(ctx, "INSERT INTO attribute_definition (subcategory_id, code, name, type, unit, allowed_values, required, created_at) VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8) RETURNING "+attributeDefinitionColumns,
+		def.SubcategoryId, def.Code, def.Name, def.Type, def.Unit, pq.StringArray(def.AllowedValues), def.Required, time.Now())
+
+	created, err := scanAttributeDefinition(row.Scan)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.AttributeDefinition{}, translateError(err, "attribute_definition")
+	}
+
+	return created, nil
+}
+
+func (s DbSource) DeleteAttributeDefinition(ctx context.Context, subcategoryId int, id int) error {
+	result, err := s.conn.ExecContext(ctx, "DELETE FROM attribute_definition WHERE id = $1 AND subcategory_id = $2", id, subcategoryId)
+	return translateExecResult(result, err, "attribute_definition")
+}
+
+func (s DbSource) GetAttributeDefinitions(ctx context.Context, subcategoryId int) ([]structs.AttributeDefinition, error) {
+	return s.attributeDefinitions(ctx, s.conn, subcategoryId)
+}
+
+// querier is the subset of *sql.DB and *sql.Tx the helpers shared by plain calls and transactions need
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func (s DbSource) attributeDefinitions(ctx context.Context, q querier, subcategoryId int) ([]structs.AttributeDefinition, error) {
+	rows, err := q.QueryContext(ctx, "SELECT "+attributeDefinitionColumns+" FROM attribute_definition WHERE subcategory_id = $1 ORDER BY code", subcategoryId)
+
+	if err != nil {
+		log.Error(err.Error())
+		return nil, translateError(err, "attribute_definition")
+	}
+
+	defer rows.Close()
+
+	defs := make([]structs.AttributeDefinition, 0)
+
+	for rows.Next() {
+		def, err := scanAttributeDefinition(rows.Scan)
+
+		if err != nil {
+			log.Error(err.Error())
+			return nil, translateError(err, "attribute_definition")
+		}
+
+		defs = append(defs, def)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error(err.Error())
+		return nil, translateError(err, "attribute_definition")
+	}
+
+	return defs, nil
+}
+
+// Replaces every attribute value of a product after validating them against its subcategory definitions
+func (s DbSource) SetProductAttributes(ctx context.Context, productId int, values map[string]interface{}) error {
+	tx, err := s.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		log.Error(err.Error())
+		return err
+	}
+
+	defer tx.Rollback()
+
+	var subcategoryId int
+	err = tx.QueryRowContext(ctx, "SELECT subcategory_id FROM product WHERE id = $1 FOR UPDATE", productId).Scan(&subcategoryId)
+
+	if err != nil {
+		return translateError(err, "product")
+	}
+
+	defs, err := s.attributeDefinitions(ctx, tx, subcategoryId)
+
+	if err != nil {
+		return err
+	}
+
+	attributes, err := validateAttributeValues(defs, values)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM product_attribute WHERE product_id = $1", productId); err != nil {
+		log.Error(err.Error())
+		return translateError(err, "product_attribute")
+	}
+
+	for _, a := range attributes {
+		for _, v := range attributeColumnValues(a) {
+			_, err := tx.ExecContext(ctx, "INSERT INTO product_attribute (product_id, attribute_id, number_value, text_value, bool_value) VALUES ($1, $2, $3, $4, $5)",
+				productId, a.AttributeId, v.number, v.text, v.boolean)
+
+			if err != nil {
+				log.Error(err.Error())
+				return translateError(err, "product_attribute")
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+type attributeColumns struct {
+	number  sql.NullFloat64
+	text    sql.NullString
+	boolean sql.NullBool
+}
+
+// Spreads a typed value over the product_attribute value columns, one row per multi enum value
+func attributeColumnValues(a structs.ProductAttribute) []attributeColumns {
+	switch v := a.Value.(type) {
+	case float64:
+		return []attributeColumns{{number: sql.NullFloat64{Float64: v, Valid: true}}}
+	case string:
+		return []attributeColumns{{text: sql.NullString{String: v, Valid: true}}}
+	case []string:
+		columns := make([]attributeColumns, 0, len(v))
+		for _, s := range v {
+			columns = append(columns, attributeColumns{text: sql.NullString{String: s, Valid: true}})
+		}
+		return columns
+	case bool:
+		return []attributeColumns{{boolean: sql.NullBool{Bool: v, Valid: true}}}
+	}
+	return nil
+}
+
+// Loads the attribute values of products in place
+func (s DbSource) loadProductAttributes(ctx context.Context, products []structs.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(products))
+	for _, p := range products {
+		ids = append(ids, p.Id)
+	}
+
+	rows, err := s.conn.QueryContext(ctx, `SELECT pa.product_id, ad.id, ad.code, ad.name, ad.type, coalesce(ad.unit, ''), pa.number_value, pa.text_value, pa.bool_value
+		FROM product_attribute pa JOIN attribute_definition ad ON ad.id = pa.attribute_id
+		WHERE pa.product_id = ANY($1)
+		ORDER BY pa.product_id, ad.code, pa.text_value`, pq.Array(ids))
+
+	if err != nil {
+		log.Error(err.Error())
+		return translateError(err, "product_attribute")
+	}
+
+	defer rows.Close()
+
+	byProduct := make(map[int64][]structs.ProductAttribute)
+
+	for rows.Next() {
+		var productId int64
+		var a structs.ProductAttribute
+		var v attributeColumns
+
+		if err := rows.Scan(&productId, &a.AttributeId, &a.Code, &a.Name, &a.Type, &a.Unit, &v.number, &v.text, &v.boolean); err != nil {
+			log.Error(err.Error())
+			return translateError(err, "product_attribute")
+		}
+
+		attributes := byProduct[productId]
+
+		// Multi enum values come in consecutive rows
+		if a.Type == structs.AttributeMultiEnum {
+			if n := len(attributes); n > 0 && attributes[n-1].AttributeId == a.AttributeId {
+				attributes[n-1].Value = append(attributes[n-1].Value.([]string), v.text.String)
+				continue
+			}
+			a.Value = []string{v.text.String}
+		} else {
+			a = presentAttribute(structs.ProductAttribute{AttributeId: a.AttributeId, Code: a.Code, Name: a.Name, Type: a.Type, Unit: a.Unit, Value: attributeColumnValue(a.Type, v)})
+		}
+
+		byProduct[productId] = append(attributes, a)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error(err.Error())
+		return translateError(err, "product_attribute")
+	}
+
+	for i := range products {
+		products[i].Attributes = byProduct[products[i].Id]
+		if products[i].Attributes == nil {
+			products[i].Attributes = make([]structs.ProductAttribute, 0)
+		}
+	}
+
+	return nil
+}
+
+func attributeColumnValue(attributeType string, v attributeColumns) interface{} {
+	switch attributeType {
+	case structs.AttributeNumber:
+		return v.number.Float64
+	case structs.AttributeBoolean:
+		return v.boolean.Bool
+	default:
+		return v.text.String
+	}
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"errors"
+	"testing"
+
+	"vayer-electric-backend/structs"
+	"vayer-electric-backend/units"
+)
+
+func TestResolveAttributeConditionRange(t *testing.T) {
+	def := structs.AttributeDefinition{Id: 1, Code: "cross_section", Type: structs.AttributeNumber, Unit: "mm2"}
+
+	tests := []struct {
+		unit     string
+		min, max float64
+		err      error
+		// Expected bounds, in unit
+		from, to float64
+	}{
+		{"mm2", 1.5, 2.5, nil, 1.5, 2.5},
+		{"mm2", 2.5, 1.5, ErrValidation, 0, 0},
+		// 14 AWG is the thinner wire, it bounds the areas from below whichever order the gauges come in
+		{"AWG", 10, 14, nil, 14, 10},
+		{"AWG", 14, 10, nil, 14, 10},
+	}
+
+	for _, tt := range tests {
+		unit, err := units.Lookup(tt.unit)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		min, max := tt.min, tt.max
+		c, ok, err := resolveAttributeCondition(AttributeFilter{Code: def.Code, Min: &min, Max: &max, Unit: tt.unit}, def, &unit)
+
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%v..%v %s: error = %v, want %v", tt.min, tt.max, tt.unit, err, tt.err)
+			continue
+		}
+
+		if err != nil {
+			continue
+		}
+
+		if !ok {
+			t.Errorf("%v..%v %s: expected a condition", tt.min, tt.max, tt.unit)
+			continue
+		}
+
+		if *c.min != unit.ToCanonical(tt.from) || *c.max != unit.ToCanonical(tt.to) {
+			t.Errorf("%v..%v %s: got %v..%v, want %v..%v", tt.min, tt.max, tt.unit, *c.min, *c.max, unit.ToCanonical(tt.from), unit.ToCanonical(tt.to))
+		}
+	}
+}
+
+// Bounds without a unit are read in the unit of the definition
+func TestResolveAttributeConditionRangeInDefinitionUnit(t *testing.T) {
+	min, max := 32.0, 10.0
+
+	_, _, err := resolveAttributeCondition(AttributeFilter{Code: "amperage", Min: &min, Max: &max}, structs.AttributeDefinition{Id: 1, Code: "amperage", Type: structs.AttributeNumber, Unit: "A"}, nil)
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("32..10 A: error = %v, want ErrValidation", err)
+	}
+
+	_, ok, err := resolveAttributeCondition(AttributeFilter{Code: "gauge", Min: &max, Max: &min}, structs.AttributeDefinition{Id: 2, Code: "gauge", Type: structs.AttributeNumber, Unit: "AWG"}, nil)
+	if err != nil || !ok {
+		t.Errorf("10..32 AWG: ok = %v, error = %v", ok, err)
+	}
+}
@@ -0,0 +1,465 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"time"
+
+	"vayer-electric-backend/structs"
+	"vayer-electric-backend/tax"
+)
+
+// Cart and order tokens are all it takes to reach an anonymous cart or order, 128 random bits
+func newAccessToken() (string, error) {
+	b := make([]byte, 16)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+func validateCartQuantity(quantity int64) error {
+	if quantity <= 0 {
+		return ValidationError("quantity must be positive")
+	}
+
+	return nil
+}
+
+// Totals past what an amount holds, only quantities nobody orders get a cart there
+func cartTotalOutOfRange() error {
+	return ValidationError("the cart total is out of range")
+}
+
+// Sums up the lines of a cart, the lines come priced and taxed
+func totalCart(cart *structs.Cart) error {
+	cart.ItemCount = 0
+	cart.Subtotal = 0
+	cart.TaxTotal = 0
+	cart.Available = true
+
+	var err error
+
+	for i := range cart.Lines {
+		line := &cart.Lines[i]
+		line.InStock = line.Quantity <= line.Available
+
+		if cart.Subtotal, err = cart.Subtotal.Add(line.LineTotal); err != nil {
+			return cartTotalOutOfRange()
+		}
+
+		if cart.TaxTotal, err = cart.TaxTotal.Add(line.Tax.Tax); err != nil {
+			return cartTotalOutOfRange()
+		}
+
+		cart.ItemCount += line.Quantity
+		cart.Available = cart.Available && line.InStock
+	}
+
+	if cart.Total, err = cart.Subtotal.Add(cart.TaxTotal); err != nil {
+		return cartTotalOutOfRange()
+	}
+
+	return nil
+}
+
+// Prices a cart line from the unit price of its product and taxes it in the region of the cart
+func priceCartLine(line *structs.CartLine, table tax.Table, taxClassId int64, subcategoryId int64, region string) error {
+	var err error
+
+	if line.LineTotal, err = line.UnitPrice.Mul(line.Quantity); err != nil {
+		return cartTotalOutOfRange()
+	}
+
+	if line.Tax, err = table.Tax(taxClassId, subcategoryId, region, line.LineTotal); err != nil {
+		return cartTotalOutOfRange()
+	}
+
+	return nil
+}
+
+// A cart is reached by its token, a customer cart only by its customer as well
+const cartLookup = "FROM cart WHERE token = $1 AND (customer_id IS NULL OR customer_id = $2)"
+
+const cartColumns = "id, token, coalesce(customer_id, ''), region, created_at, updated_at"
+
+func scanCart(scan func(dest ...interface{}) error) (structs.Cart, error) {
+	var c structs.Cart
+	err := scan(&c.Id, &c.Token, &c.CustomerId, &c.Region, &c.CreatedAt, &c.UpdatedAt)
+	return c, err
+}
+
+// Finds a cart and locks it so its lines are changed one request at a time
+func lockCart(ctx context.Context, tx *sql.Tx, token string, customerId string) (structs.Cart, error) {
+	cart, err := scanCart(tx.QueryRowContext(ctx, "SELECT "+cartColumns+" "+cartLookup+" FOR UPDATE", token, customerId).Scan)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.Cart{}, translateError(err, "cart")
+	}
+
+	return cart, nil
+}
+
+func touchCart(ctx context.Context, tx *sql.Tx, cartId int64) error {
+	if _, err := tx.ExecContext(ctx, "UPDATE cart SET updated_at = $1 WHERE id = $2", time.Now(), cartId); err != nil {
+		log.Error(err.Error())
+		return translateError(err, "cart")
+	}
+
+	return nil
+}
+
+// Checks a product or one of its variants can cover quantity, carts don't hold stock so this only keeps
+// hopeless lines out
+func checkCartStock(ctx context.Context, q querier, productId int64, variantId int64, quantity int64) error {
+	var available int64
+	var err error
+
+	if variantId != 0 {
+		available, err = lockedVariantAvailableStock(ctx, q, productId, variantId, time.Now())
+	} else {
+		available, err = lockedAvailableStock(ctx, q, int(productId))
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if quantity > available {
+		return insufficientAvailable(available)
+	}
+
+	return nil
+}
+
+// Loads the lines of a cart priced from product.price, or the price of their variant, and taxed in the region
+// of the cart, with the available stock of their products or variants
+func loadCartLines(ctx context.Context, q querier, cart *structs.Cart) error {
+	table, err := loadTaxTable(ctx, q)
+
+	if err != nil {
+		return err
+	}
+
+	rows, err := q.QueryContext(ctx, `SELECT l.id, l.product_id, coalesce(l.variant_id, 0), p.name, coalesce(v.sku, p.sku), coalesce(v.image_url, p.image_url), l.quantity,
+			coalesce(v.price, p.price), coalesce(v.current_inventory, p.current_inventory), coalesce(vr.reserved, r.reserved, 0), coalesce(p.tax_class_id, 0), p.subcategory_id
+		FROM cart_line l
+		JOIN product p ON p.id = l.product_id
+		LEFT JOIN product_variant v ON v.id = l.variant_id
+		LEFT JOIN (SELECT product_id, sum(quantity) AS reserved FROM stock_reservation WHERE variant_id IS NULL AND expires_at > $2 GROUP BY product_id) r ON r.product_id = l.product_id AND l.variant_id IS NULL
+		LEFT JOIN (SELECT variant_id, sum(quantity) AS reserved FROM stock_reservation WHERE variant_id IS NOT NULL AND expires_at > $2 GROUP BY variant_id) vr ON vr.variant_id = l.variant_id
+		WHERE l.cart_id = $1
+		ORDER BY l.id`, cart.Id, time.Now())
+
+	if err != nil {
+		log.Error(err.Error())
+		return translateError(err, "cart_line")
+	}
+
+	defer rows.Close()
+
+	cart.Lines = make([]structs.CartLine, 0)
+
+	for rows.Next() {
+		var line structs.CartLine
+		var onHand, reserved, taxClassId, subcategoryId int64
+
+		if err := rows.Scan(&line.Id, &line.ProductId, &line.VariantId, &line.Name, &line.Sku, &line.ImageUrl, &line.Quantity, &line.UnitPrice, &onHand, &reserved, &taxClassId, &subcategoryId); err != nil {
+			log.Error(err.Error())
+			return translateError(err, "cart_line")
+		}
+
+		if err := priceCartLine(&line, table, taxClassId, subcategoryId, cart.Region); err != nil {
+			return err
+		}
+
+		line.Available = availableStock(onHand, reserved)
+		cart.Lines = append(cart.Lines, line)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error(err.Error())
+		return translateError(err, "cart_line")
+	}
+
+	return totalCart(cart)
+}
+
+// Loads the lines of a cart changed in tx and commits
+func commitCart(ctx context.Context, tx *sql.Tx, cart structs.Cart) (structs.Cart, error) {
+	if err := touchCart(ctx, tx, cart.Id); err != nil {
+		return structs.Cart{}, err
+	}
+
+	if err := loadCartLines(ctx, tx, &cart); err != nil {
+		return structs.Cart{}, err
+	}
+
+	return cart, tx.Commit()
+}
+
+// Creates an anonymous cart taxed in region, or returns the cart of a customer creating it the first time
+func (s DbSource) InsertCart(ctx context.Context, customerId string, region string) (structs.Cart, error) {
+	region, err := validateRegion(region)
+
+	if err != nil {
+		return structs.Cart{}, err
+	}
+
+	token, err := newAccessToken()
+
+	if err != nil {
+		return structs.Cart{}, err
+	}
+
+	now := time.Now()
+
+	row := s.conn.QueryRowContext(ctx, "INSERT INTO cart (token, customer_id, region, created_at, updated_at) VALUES ($1, NULLIF($2, ''), $3, $4, $4) ON CONFLICT (customer_id) DO UPDATE SET updated_at = cart.updated_at RETURNING "+cartColumns,
+		token, customerId, region, now)
+
+	cart, err := scanCart(row.Scan)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.Cart{}, translateError(err, "cart")
+	}
+
+	if err := loadCartLines(ctx, s.conn, &cart); err != nil {
+		return structs.Cart{}, err
+	}
+
+	return cart, nil
+}
+
+func (s DbSource) GetCart(ctx context.Context, token string, customerId string) (structs.Cart, error) {
+	cart, err := scanCart(s.conn.QueryRowContext(ctx, "SELECT "+cartColumns+" "+cartLookup, token, customerId).Scan)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.Cart{}, translateError(err, "cart")
+	}
+
+	if err := loadCartLines(ctx, s.conn, &cart); err != nil {
+		return structs.Cart{}, err
+	}
+
+	return cart, nil
+}
+
+// Sets the region a cart is taxed in, like the country it ships to
+func (s DbSource) SetCartRegion(ctx context.Context, token string, customerId string, region string) (structs.Cart, error) {
+	region, err := validateRegion(region)
+
+	if err != nil {
+		return structs.Cart{}, err
+	}
+
+	tx, err := s.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.Cart{}, err
+	}
+
+	defer tx.Rollback()
+
+	cart, err := lockCart(ctx, tx, token, customerId)
+
+	if err != nil {
+		return structs.Cart{}, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE cart SET region = $1 WHERE id = $2", region, cart.Id); err != nil {
+		log.Error(err.Error())
+		return structs.Cart{}, translateError(err, "cart")
+	}
+
+	cart.Region = region
+
+	return commitCart(ctx, tx, cart)
+}
+
+// Adds quantity of a product, or of one of its variants when variantId isn't 0, to a cart on top of the line
+// it already has for them
+func (s DbSource) AddCartLine(ctx context.Context, token string, customerId string, productId int64, variantId int64, quantity int64) (structs.Cart, error) {
+	if err := validateCartQuantity(quantity); err != nil {
+		return structs.Cart{}, err
+	}
+
+	tx, err := s.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.Cart{}, err
+	}
+
+	defer tx.Rollback()
+
+	cart, err := lockCart(ctx, tx, token, customerId)
+
+	if err != nil {
+		return structs.Cart{}, err
+	}
+
+	var current int64
+	err = tx.QueryRowContext(ctx, "SELECT coalesce((SELECT quantity FROM cart_line WHERE cart_id = $1 AND product_id = $2 AND coalesce(variant_id, 0) = $3), 0)", cart.Id, productId, variantId).Scan(&current)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.Cart{}, translateError(err, "cart_line")
+	}
+
+	if err := checkCartStock(ctx, tx, productId, variantId, current+quantity); err != nil {
+		return structs.Cart{}, err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO cart_line (cart_id, product_id, variant_id, quantity, created_at) VALUES ($1, $2, NULLIF($3, 0), $4, $5) ON CONFLICT (cart_id, product_id, coalesce(variant_id, 0)) DO UPDATE SET quantity = cart_line.quantity + EXCLUDED.quantity",
+		cart.Id, productId, variantId, quantity, time.Now())
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.Cart{}, translateError(err, "cart_line")
+	}
+
+	return commitCart(ctx, tx, cart)
+}
+
+// Sets the quantity of a cart line
+func (s DbSource) UpdateCartLine(ctx context.Context, token string, customerId string, lineId int, quantity int64) (structs.Cart, error) {
+	if err := validateCartQuantity(quantity); err != nil {
+		return structs.Cart{}, err
+	}
+
+	tx, err := s.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.Cart{}, err
+	}
+
+	defer tx.Rollback()
+
+	cart, err := lockCart(ctx, tx, token, customerId)
+
+	if err != nil {
+		return structs.Cart{}, err
+	}
+
+	var productId, variantId int64
+	err = tx.QueryRowContext(ctx, "SELECT product_id, coalesce(variant_id, 0) FROM cart_line WHERE id = $1 AND cart_id = $2", lineId, cart.Id).Scan(&productId, &variantId)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.Cart{}, translateError(err, "cart_line")
+	}
+
+	if err := checkCartStock(ctx, tx, productId, variantId, quantity); err != nil {
+		return structs.Cart{}, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE cart_line SET quantity = $1 WHERE id = $2", quantity, lineId); err != nil {
+		log.Error(err.Error())
+		return structs.Cart{}, translateError(err, "cart_line")
+	}
+
+	return commitCart(ctx, tx, cart)
+}
+
+func (s DbSource) DeleteCartLine(ctx context.Context, token string, customerId string, lineId int) (structs.Cart, error) {
+	tx, err := s.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.Cart{}, err
+	}
+
+	defer tx.Rollback()
+
+	cart, err := lockCart(ctx, tx, token, customerId)
+
+	if err != nil {
+		return structs.Cart{}, err
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM cart_line WHERE id = $1 AND cart_id = $2", lineId, cart.Id)
+
+	if err := translateExecResult(result, err, "cart_line"); err != nil {
+		return structs.Cart{}, err
+	}
+
+	return commitCart(ctx, tx, cart)
+}
+
+// Moves the lines of an anonymous cart into the cart of a customer when they log in, quantities of a product
+// or variant in both add up and the customer cart takes the region and the reservations of the anonymous one.
+// The anonymous cart is deleted.
+// Merged lines aren't checked against stock, the cart reports the lines stock no longer covers.
+func (s DbSource) MergeCart(ctx context.Context, token string, customerId string) (structs.Cart, error) {
+	if customerId == "" {
+		return structs.Cart{}, ValidationError("only a customer can merge a cart into theirs")
+	}
+
+	tx, err := s.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.Cart{}, err
+	}
+
+	defer tx.Rollback()
+
+	source, err := lockCart(ctx, tx, token, customerId)
+
+	if err != nil {
+		return structs.Cart{}, err
+	}
+
+	// Already the customer's cart, a retried merge lands here
+	if source.CustomerId == customerId {
+		return commitCart(ctx, tx, source)
+	}
+
+	target, err := newAccessToken()
+
+	if err != nil {
+		return structs.Cart{}, err
+	}
+
+	now := time.Now()
+
+	row := tx.QueryRowContext(ctx, "INSERT INTO cart (token, customer_id, region, created_at, updated_at) VALUES ($1, $2, $3, $4, $4) ON CONFLICT (customer_id) DO UPDATE SET region = EXCLUDED.region, updated_at = EXCLUDED.updated_at RETURNING "+cartColumns,
+		target, customerId, source.Region, now)
+
+	cart, err := scanCart(row.Scan)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.Cart{}, translateError(err, "cart")
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO cart_line (cart_id, product_id, variant_id, quantity, created_at)
+		SELECT $1, product_id, variant_id, quantity, created_at FROM cart_line WHERE cart_id = $2
+		ON CONFLICT (cart_id, product_id, coalesce(variant_id, 0)) DO UPDATE SET quantity = cart_line.quantity + EXCLUDED.quantity`, cart.Id, source.Id)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.Cart{}, translateError(err, "cart_line")
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE stock_reservation SET cart_id = $1 WHERE cart_id = $2", cart.Id, source.Id); err != nil {
+		log.Error(err.Error())
+		return structs.Cart{}, translateError(err, "stock_reservation")
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM cart WHERE id = $1", source.Id); err != nil {
+		log.Error(err.Error())
+		return structs.Cart{}, translateError(err, "cart")
+	}
+
+	return commitCart(ctx, tx, cart)
+}
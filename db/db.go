@@ -1,12 +1,14 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
 
 	"vayer-electric-backend/env"
 	"vayer-electric-backend/logging"
+	"vayer-electric-backend/money"
 	"vayer-electric-backend/structs"
 
 	"github.com/DavidHuie/gomigrate"
@@ -19,22 +21,36 @@ type DbSource struct {
 
 var log = logging.GetLogger()
 
-func CreateDbSource(dsn string) (DbSource, error) {
+// Connection pool settings applied to the shared *sql.DB
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+func PoolConfigFromEnv() PoolConfig {
+	return PoolConfig{
+		MaxOpenConns:    env.DB_MAX_OPEN_CONNS,
+		MaxIdleConns:    env.DB_MAX_IDLE_CONNS,
+		ConnMaxLifetime: env.DB_CONN_MAX_LIFETIME,
+		ConnMaxIdleTime: env.DB_CONN_MAX_IDLE_TIME,
+	}
+}
+
+// Opens the connection pool. It's meant to be called once per process and shared,
+// database/sql already reconnects and recycles connections on its own.
+func CreateDbSource(dsn string, pool PoolConfig) (DbSource, error) {
 	d, err := sql.Open("postgres", dsn)
 
 	if err != nil {
 		return DbSource{}, err
 	}
 
-	go func() {
-		for {
-			d.Ping()
-			time.Sleep(time.Second * 30)
-		}
-	}()
-
-	d.SetMaxOpenConns(6)
-	d.SetMaxIdleConns(2)
+	d.SetMaxOpenConns(pool.MaxOpenConns)
+	d.SetMaxIdleConns(pool.MaxIdleConns)
+	d.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	d.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
 
 	return DbSource{
 		conn: d,
@@ -49,7 +65,7 @@ func GetDbSource() DbSource {
 		env.DB_PASSWORD,
 		env.DB_NAME,
 	)
-	src, err := CreateDbSource(dsn)
+	src, err := CreateDbSource(dsn, PoolConfigFromEnv())
 
 	if err != nil {
 		panic(err)
@@ -58,40 +74,125 @@ func GetDbSource() DbSource {
 	return src
 }
 
-func (s DbSource) ValidateConnection() bool {
-	return s.conn.Ping() == nil
+// Closes the pool, waiting for in-flight queries to finish
+func (s DbSource) Close() error {
+	return s.conn.Close()
+}
+
+func (s DbSource) Stats() sql.DBStats {
+	return s.conn.Stats()
+}
+
+func (s DbSource) ValidateConnection(ctx context.Context) bool {
+	return s.conn.PingContext(ctx) == nil
 }
 
 func (s DbSource) Migrate(path string) error {
 	migrator, _ := gomigrate.NewMigrator(s.conn, gomigrate.Postgres{}, path)
-	defer s.conn.Close()
 	return migrator.Migrate()
 }
 
-func (s DbSource) InsertProduct(name string, description string, subcategory_id int, price float64, currentInventory int, imageUrl string, brand string, sku string) error {
-	_, err := s.conn.Exec("INSERT INTO product (name, description, subcategory_id, price, current_inventory, image_url, brand, sku, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)", name, description, subcategory_id, price, currentInventory, imageUrl, brand, sku, time.Now())
-	defer s.conn.Close()
-	return err
+// Creates a product with its image as the first image of its gallery,
+// its initial stock is recorded as the opening balance of its stock ledger
+func (s DbSource) InsertProduct(ctx context.Context, name string, description string, subcategory_id int, price money.Amount, currentInventory int, imageUrl string, brand string, sku string) error {
+	if currentInventory < 0 {
+		return ValidationError("current_inventory can't be negative")
+	}
+
+	tx, err := s.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		log.Error(err.Error())
+		return err
+	}
+
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRowContext(ctx, "INSERT INTO product (name, description, subcategory_id, price, current_inventory, image_url, brand, sku, created_at) VALUES ($1, $2, $3, $4, 0, $5, $6, $7, $8) RETURNING id", name, description, subcategory_id, price, imageUrl, brand, sku, time.Now()).Scan(&id)
+
+	if err != nil {
+		return translateError(err, "product")
+	}
+
+	if currentInventory > 0 {
+		opening := structs.StockMovement{ProductId: id, Reason: "adjustment", Quantity: int64(currentInventory), Reference: openingBalanceReference}
+		if _, err := insertStockMovement(ctx, tx, opening); err != nil {
+			return err
+		}
+	}
+
+	if imageUrl != "" {
+		if _, err := insertProductImage(ctx, tx, structs.ProductImage{ProductId: id, ImageUrl: imageUrl, Primary: true}); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
-func (s DbSource) UpdateProduct(id int, name string, price float64, currentInventory int) error {
-	_, err := s.conn.Exec("UPDATE product SET name = $1, price = $2, current_inventory = $3 WHERE id = $4", name, price, currentInventory, id)
-	defer s.conn.Close()
-	return err
+// Updates a product, a change of current_inventory is recorded in the stock ledger as an adjustment
+// and may raise a reorder alert. Added stock goes to the default location, removed stock comes off
+// the locations in the order checkout takes it.
+func (s DbSource) UpdateProduct(ctx context.Context, id int, name string, price money.Amount, currentInventory int) error {
+	if currentInventory < 0 {
+		return ValidationError("current_inventory can't be negative")
+	}
+
+	tx, err := s.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		log.Error(err.Error())
+		return err
+	}
+
+	defer tx.Rollback()
+
+	var current int64
+	err = tx.QueryRowContext(ctx, "UPDATE product SET name = $1, price = $2 WHERE id = $3 RETURNING current_inventory", name, price, id).Scan(&current)
+
+	if err != nil {
+		log.Error(err.Error())
+		return translateError(err, "product")
+	}
+
+	if int64(currentInventory) != current {
+		before, err := lockedAvailableStock(ctx, tx, id)
+
+		if err != nil {
+			return err
+		}
+
+		if quantity := int64(currentInventory) - current; quantity < 0 {
+			if err := allocateStock(ctx, tx, int64(id), -quantity, "adjustment", productUpdateReference, ""); err != nil {
+				return err
+			}
+		} else {
+			adjustment := structs.StockMovement{ProductId: int64(id), Reason: "adjustment", Quantity: quantity, Reference: productUpdateReference}
+			if _, err := insertStockMovement(ctx, tx, adjustment); err != nil {
+				return err
+			}
+		}
+
+		if err := recordStockAlert(ctx, tx, id, before); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
-func (s DbSource) DeleteProduct(id int) error {
-	_, err := s.conn.Exec("DELETE FROM product WHERE id = $1", id)
-	defer s.conn.Close()
-	return err
+func (s DbSource) DeleteProduct(ctx context.Context, id int) error {
+	result, err := s.conn.ExecContext(ctx, "DELETE FROM product WHERE id = $1", id)
+	return translateExecResult(result, err, "product")
 }
 
-func (s DbSource) GetProducts() ([]structs.Product, error) {
-	rows, err := s.conn.Query("SELECT * FROM product ORDER BY created_at DESC")
+func (s DbSource) GetProducts(ctx context.Context) ([]structs.Product, error) {
+	rows, err := s.conn.QueryContext(ctx, "SELECT "+productColumns+" FROM product p ORDER BY created_at DESC")
 
 	if err != nil {
 		log.Error(err.Error())
-		return nil, err
+		return nil, translateError(err, "product")
 	}
 
 	defer rows.Close()
@@ -100,11 +201,11 @@ func (s DbSource) GetProducts() ([]structs.Product, error) {
 
 	for rows.Next() {
 		var product structs.Product
-		err := rows.Scan(&product.Id, &product.Name, &product.Description, &product.CreatedAt, &product.SubcategoryId, &product.Price, &product.CurrentInventory, &product.ImageUrl, &product.Brand, &product.Sku)
+		err := rows.Scan(&product.Id, &product.Name, &product.Description, &product.CreatedAt, &product.SubcategoryId, &product.Price, &product.CurrentInventory, &product.ImageUrl, &product.Brand, &product.Sku, &product.ReorderPoint, &product.ReorderQuantity, &product.TaxClassId)
 
 		if err != nil {
 			log.Error(err.Error())
-			return nil, err
+			return nil, translateError(err, "product")
 		}
 
 		products = append(products, product)
@@ -112,49 +213,89 @@ func (s DbSource) GetProducts() ([]structs.Product, error) {
 
 	if err = rows.Err(); err != nil {
 		log.Error(err.Error())
-		return nil, err
+		return nil, translateError(err, "product")
 	}
 
-	defer s.conn.Close()
-
 	return products, nil
 }
 
-func (s DbSource) GetProductById(id int) (structs.Product, error) {
+func (s DbSource) GetProductById(ctx context.Context, id int) (structs.Product, error) {
 	var product structs.Product
-	err := s.conn.QueryRow("SELECT * FROM product WHERE id = $1", id).Scan(&product.Id, &product.Name, &product.Description, &product.CreatedAt, &product.SubcategoryId, &product.Price, &product.CurrentInventory, &product.ImageUrl, &product.Brand, &product.Sku)
+	err := s.conn.QueryRowContext(ctx, "SELECT "+productColumns+" FROM product p WHERE id = $1", id).Scan(&product.Id, &product.Name, &product.Description, &product.CreatedAt, &product.SubcategoryId, &product.Price, &product.CurrentInventory, &product.ImageUrl, &product.Brand, &product.Sku, &product.ReorderPoint, &product.ReorderQuantity, &product.TaxClassId)
 
 	if err != nil {
 		log.Error(err.Error())
+		return structs.Product{}, translateError(err, "product")
+	}
+
+	products := []structs.Product{product}
+	if err := s.loadProductAttributes(ctx, products); err != nil {
+		return structs.Product{}, err
+	}
+
+	if err := s.loadProductImages(ctx, products); err != nil {
+		return structs.Product{}, err
+	}
+
+	variants, err := s.variantMatrix(ctx, s.conn, int(product.Id))
+
+	if err != nil {
 		return structs.Product{}, err
 	}
 
-	defer s.conn.Close()
+	products[0].Variants = &variants
+
+	products[0].Stock, err = s.productStock(ctx, int(product.Id))
+
+	if err != nil {
+		return structs.Product{}, err
+	}
 
-	return product, nil
+	return products[0], nil
 }
 
-func (s DbSource) GetProductByName(name string) (structs.Product, error) {
+func (s DbSource) GetProductByName(ctx context.Context, name string) (structs.Product, error) {
 	var product structs.Product
-	err := s.conn.QueryRow("SELECT * FROM product WHERE name = $1", name).Scan(&product.Id, &product.Name, &product.Description, &product.CreatedAt, &product.SubcategoryId, &product.Price, &product.CurrentInventory, &product.ImageUrl, &product.Brand, &product.Sku)
+	err := s.conn.QueryRowContext(ctx, "SELECT "+productColumns+" FROM product p WHERE name = $1", name).Scan(&product.Id, &product.Name, &product.Description, &product.CreatedAt, &product.SubcategoryId, &product.Price, &product.CurrentInventory, &product.ImageUrl, &product.Brand, &product.Sku, &product.ReorderPoint, &product.ReorderQuantity, &product.TaxClassId)
 
 	if err != nil {
 		log.Error(err.Error())
+		return structs.Product{}, translateError(err, "product")
+	}
+
+	products := []structs.Product{product}
+	if err := s.loadProductAttributes(ctx, products); err != nil {
 		return structs.Product{}, err
 	}
 
-	defer s.conn.Close()
+	if err := s.loadProductImages(ctx, products); err != nil {
+		return structs.Product{}, err
+	}
 
-	return product, nil
+	variants, err := s.variantMatrix(ctx, s.conn, int(product.Id))
+
+	if err != nil {
+		return structs.Product{}, err
+	}
+
+	products[0].Variants = &variants
+
+	products[0].Stock, err = s.productStock(ctx, int(product.Id))
+
+	if err != nil {
+		return structs.Product{}, err
+	}
+
+	return products[0], nil
 
 }
 
-func (s DbSource) GetProductsBySubcategoryId(subcategory_id int) ([]structs.Product, error) {
-	rows, err := s.conn.Query("SELECT * FROM product WHERE subcategory_id = $1", subcategory_id)
+func (s DbSource) GetProductsBySubcategoryId(ctx context.Context, subcategory_id int) ([]structs.Product, error) {
+	rows, err := s.conn.QueryContext(ctx, "SELECT "+productColumns+" FROM product p WHERE subcategory_id = $1", subcategory_id)
 
 	if err != nil {
 		log.Error(err.Error())
-		return nil, err
+		return nil, translateError(err, "product")
 	}
 
 	defer rows.Close()
@@ -163,11 +304,11 @@ func (s DbSource) GetProductsBySubcategoryId(subcategory_id int) ([]structs.Prod
 
 	for rows.Next() {
 		var product structs.Product
-		err := rows.Scan(&product.Id, &product.Name, &product.Description, &product.CreatedAt, &product.SubcategoryId, &product.Price, &product.CurrentInventory, &product.ImageUrl, &product.Brand, &product.Sku)
+		err := rows.Scan(&product.Id, &product.Name, &product.Description, &product.CreatedAt, &product.SubcategoryId, &product.Price, &product.CurrentInventory, &product.ImageUrl, &product.Brand, &product.Sku, &product.ReorderPoint, &product.ReorderQuantity, &product.TaxClassId)
 
 		if err != nil {
 			log.Error(err.Error())
-			return nil, err
+			return nil, translateError(err, "product")
 		}
 
 		products = append(products, product)
@@ -175,20 +316,18 @@ func (s DbSource) GetProductsBySubcategoryId(subcategory_id int) ([]structs.Prod
 
 	if err = rows.Err(); err != nil {
 		log.Error(err.Error())
-		return nil, err
+		return nil, translateError(err, "product")
 	}
 
-	defer s.conn.Close()
-
 	return products, nil
 }
 
-func (s DbSource) GetProductsByCategoryId(categoryId int) ([]structs.Product, error) {
-	rows, err := s.conn.Query("SELECT * FROM product WHERE subcategory_id IN (SELECT id FROM subcategory WHERE category_id = $1)", categoryId)
+func (s DbSource) GetProductsByCategoryId(ctx context.Context, categoryId int) ([]structs.Product, error) {
+	rows, err := s.conn.QueryContext(ctx, "SELECT "+productColumns+" FROM product p WHERE subcategory_id IN (SELECT id FROM subcategory WHERE category_id = $1)", categoryId)
 
 	if err != nil {
 		log.Error(err.Error())
-		return nil, err
+		return nil, translateError(err, "product")
 	}
 
 	defer rows.Close()
@@ -197,11 +336,11 @@ func (s DbSource) GetProductsByCategoryId(categoryId int) ([]structs.Product, er
 
 	for rows.Next() {
 		var product structs.Product
-		err := rows.Scan(&product.Id, &product.Name, &product.Description, &product.CreatedAt, &product.SubcategoryId, &product.Price, &product.CurrentInventory, &product.ImageUrl, &product.Brand, &product.Sku)
+		err := rows.Scan(&product.Id, &product.Name, &product.Description, &product.CreatedAt, &product.SubcategoryId, &product.Price, &product.CurrentInventory, &product.ImageUrl, &product.Brand, &product.Sku, &product.ReorderPoint, &product.ReorderQuantity, &product.TaxClassId)
 
 		if err != nil {
 			log.Error(err.Error())
-			return nil, err
+			return nil, translateError(err, "product")
 		}
 
 		products = append(products, product)
@@ -209,20 +348,18 @@ func (s DbSource) GetProductsByCategoryId(categoryId int) ([]structs.Product, er
 
 	if err = rows.Err(); err != nil {
 		log.Error(err.Error())
-		return nil, err
+		return nil, translateError(err, "product")
 	}
 
-	defer s.conn.Close()
-
 	return products, nil
 }
 
-func (s DbSource) GetProductsByCategoryName(categoryName string) ([]structs.Product, error) {
-	rows, err := s.conn.Query("SELECT * FROM product WHERE subcategory_id IN (SELECT id FROM subcategory WHERE category_id = (SELECT id FROM category WHERE name = $1))", categoryName)
+func (s DbSource) GetProductsByCategoryName(ctx context.Context, categoryName string) ([]structs.Product, error) {
+	rows, err := s.conn.QueryContext(ctx, "SELECT "+productColumns+" FROM product p WHERE subcategory_id IN (SELECT id FROM subcategory WHERE category_id = (SELECT id FROM category WHERE name = $1))", categoryName)
 
 	if err != nil {
 		log.Error(err.Error())
-		return nil, err
+		return nil, translateError(err, "product")
 	}
 
 	defer rows.Close()
@@ -231,11 +368,11 @@ func (s DbSource) GetProductsByCategoryName(categoryName string) ([]structs.Prod
 
 	for rows.Next() {
 		var product structs.Product
-		err := rows.Scan(&product.Id, &product.Name, &product.Description, &product.CreatedAt, &product.SubcategoryId, &product.Price, &product.CurrentInventory, &product.ImageUrl, &product.Brand, &product.Sku)
+		err := rows.Scan(&product.Id, &product.Name, &product.Description, &product.CreatedAt, &product.SubcategoryId, &product.Price, &product.CurrentInventory, &product.ImageUrl, &product.Brand, &product.Sku, &product.ReorderPoint, &product.ReorderQuantity, &product.TaxClassId)
 
 		if err != nil {
 			log.Error(err.Error())
-			return nil, err
+			return nil, translateError(err, "product")
 		}
 
 		products = append(products, product)
@@ -244,44 +381,38 @@ func (s DbSource) GetProductsByCategoryName(categoryName string) ([]structs.Prod
 
 	if err = rows.Err(); err != nil {
 		log.Error(err.Error())
-		return nil, err
+		return nil, translateError(err, "product")
 	}
 
-	defer s.conn.Close()
-
 	return products, nil
 }
 
-func (s DbSource) InsertSubcategory(name string, description string, category_id int, image_url string) error {
-	_, err := s.conn.Exec("INSERT INTO subcategory (name, description, category_id, created_at, image_url) VALUES ($1, $2, $3, $4, $5)", name, description, category_id, time.Now(), image_url)
+const subcategoryColumns = "id, name, description, created_at, category_id, image_url, coalesce(tax_class_id, 0)"
 
-	defer s.conn.Close()
+func (s DbSource) InsertSubcategory(ctx context.Context, name string, description string, category_id int, image_url string) error {
+	_, err := s.conn.ExecContext(ctx, "INSERT INTO subcategory (name, description, category_id, created_at, image_url) VALUES ($1, $2, $3, $4, $5)", name, description, category_id, time.Now(), image_url)
 
-	return err
+	return translateError(err, "subcategory")
 }
 
-func (s DbSource) UpdateSubcategory(id int, name string, description string, category_id int, image_url string) error {
-	_, err := s.conn.Exec("UPDATE subcategory SET name = $1, description = $2, category_id = $3, updated_at = $4, image_url = $5 WHERE id = $6", name, description, category_id, time.Now(), image_url, id)
-
-	defer s.conn.Close()
+func (s DbSource) UpdateSubcategory(ctx context.Context, id int, name string, description string, category_id int, image_url string) error {
+	result, err := s.conn.ExecContext(ctx, "UPDATE subcategory SET name = $1, description = $2, category_id = $3, updated_at = $4, image_url = $5 WHERE id = $6", name, description, category_id, time.Now(), image_url, id)
 
-	return err
+	return translateExecResult(result, err, "subcategory")
 }
 
-func (s DbSource) DeleteSubcategory(id int) error {
-	_, err := s.conn.Exec("DELETE FROM subcategory WHERE id = $1", id)
-
-	defer s.conn.Close()
+func (s DbSource) DeleteSubcategory(ctx context.Context, id int) error {
+	result, err := s.conn.ExecContext(ctx, "DELETE FROM subcategory WHERE id = $1", id)
 
-	return err
+	return translateExecResult(result, err, "subcategory")
 }
 
-func (s DbSource) GetSubcategories() ([]structs.Subcategory, error) {
-	rows, err := s.conn.Query("SELECT * FROM subcategory")
+func (s DbSource) GetSubcategories(ctx context.Context) ([]structs.Subcategory, error) {
+	rows, err := s.conn.QueryContext(ctx, "SELECT "+subcategoryColumns+" FROM subcategory")
 
 	if err != nil {
 		log.Error(err.Error())
-		return nil, err
+		return nil, translateError(err, "subcategory")
 	}
 
 	defer rows.Close()
@@ -290,11 +421,11 @@ func (s DbSource) GetSubcategories() ([]structs.Subcategory, error) {
 
 	for rows.Next() {
 		var subcategory structs.Subcategory
-		err := rows.Scan(&subcategory.Id, &subcategory.Name, &subcategory.Description, &subcategory.CreatedAt, &subcategory.CategoryId, &subcategory.ImageUrl)
+		err := rows.Scan(&subcategory.Id, &subcategory.Name, &subcategory.Description, &subcategory.CreatedAt, &subcategory.CategoryId, &subcategory.ImageUrl, &subcategory.TaxClassId)
 
 		if err != nil {
 			log.Error(err.Error())
-			return nil, err
+			return nil, translateError(err, "subcategory")
 		}
 
 		subcategories = append(subcategories, subcategory)
@@ -302,69 +433,62 @@ func (s DbSource) GetSubcategories() ([]structs.Subcategory, error) {
 
 	if err = rows.Err(); err != nil {
 		log.Error(err.Error())
-		return nil, err
+		return nil, translateError(err, "subcategory")
 	}
 
-	defer s.conn.Close()
-
 	return subcategories, nil
 }
 
-func (s DbSource) GetSubcategoryById(id int) (structs.Subcategory, error) {
+func (s DbSource) GetSubcategoryById(ctx context.Context, id int) (structs.Subcategory, error) {
 	var subcategory structs.Subcategory
-	err := s.conn.QueryRow("SELECT * FROM subcategory WHERE id = $1", id).Scan(&subcategory.Id, &subcategory.Name, &subcategory.Description, &subcategory.CreatedAt, &subcategory.CategoryId, &subcategory.ImageUrl)
+	err := s.conn.QueryRowContext(ctx, "SELECT "+subcategoryColumns+" FROM subcategory WHERE id = $1", id).Scan(&subcategory.Id, &subcategory.Name, &subcategory.Description, &subcategory.CreatedAt, &subcategory.CategoryId, &subcategory.ImageUrl, &subcategory.TaxClassId)
 
 	if err != nil {
 		log.Error(err.Error())
-		return structs.Subcategory{}, err
+		return structs.Subcategory{}, translateError(err, "subcategory")
 	}
 
-	defer s.conn.Close()
-
 	return subcategory, nil
 }
 
-func (s DbSource) GetSubcategoryByName(name string) (structs.Subcategory, error) {
+func (s DbSource) GetSubcategoryByName(ctx context.Context, name string) (structs.Subcategory, error) {
 	var subcategory structs.Subcategory
-	err := s.conn.QueryRow("SELECT * FROM subcategory WHERE name = $1", name).Scan(&subcategory.Id, &subcategory.Name, &subcategory.Description, &subcategory.CreatedAt, &subcategory.CategoryId, &subcategory.ImageUrl)
+	err := s.conn.QueryRowContext(ctx, "SELECT "+subcategoryColumns+" FROM subcategory WHERE name = $1", name).Scan(&subcategory.Id, &subcategory.Name, &subcategory.Description, &subcategory.CreatedAt, &subcategory.CategoryId, &subcategory.ImageUrl, &subcategory.TaxClassId)
 
 	if err != nil {
 		log.Error(err.Error())
-		return structs.Subcategory{}, err
+		return structs.Subcategory{}, translateError(err, "subcategory")
 	}
 
-	defer s.conn.Close()
-
 	return subcategory, nil
 }
 
-func (s DbSource) InsertCategory(name string, description string, image_url string) error {
-	_, err := s.conn.Exec("INSERT INTO category (name, description, created_at, image_url) VALUES ($1, $2, $3, $4)", name, description, time.Now(), image_url)
-	defer s.conn.Close()
+const categoryColumns = "id, name, description, created_at, image_url, coalesce(tax_class_id, 0)"
 
-	return err
+func (s DbSource) InsertCategory(ctx context.Context, name string, description string, image_url string) error {
+	_, err := s.conn.ExecContext(ctx, "INSERT INTO category (name, description, created_at, image_url) VALUES ($1, $2, $3, $4)", name, description, time.Now(), image_url)
+
+	return translateError(err, "category")
 }
 
-func (s DbSource) UpdateCategory(id int, name string, description string, image_url string) error {
-	_, err := s.conn.Exec("UPDATE category SET name = $1, description = $2, updated_at = $3, image_url = $4 WHERE id = $5", name, description, time.Now(), image_url, id)
-	defer s.conn.Close()
+func (s DbSource) UpdateCategory(ctx context.Context, id int, name string, description string, image_url string) error {
+	result, err := s.conn.ExecContext(ctx, "UPDATE category SET name = $1, description = $2, updated_at = $3, image_url = $4 WHERE id = $5", name, description, time.Now(), image_url, id)
 
-	return err
+	return translateExecResult(result, err, "category")
 }
 
-func (s DbSource) DeleteCategory(id int) error {
-	_, err := s.conn.Exec("DELETE FROM category WHERE id = $1", id)
-	defer s.conn.Close()
+func (s DbSource) DeleteCategory(ctx context.Context, id int) error {
+	result, err := s.conn.ExecContext(ctx, "DELETE FROM category WHERE id = $1", id)
 
-	return err
+	return translateExecResult(result, err, "category")
 }
 
-func (s DbSource) GetCategories() ([]structs.Category, error) {
-	rows, err := s.conn.Query("SELECT * FROM category")
+func (s DbSource) GetCategories(ctx context.Context) ([]structs.Category, error) {
+	rows, err := s.conn.QueryContext(ctx, "SELECT "+categoryColumns+" FROM category")
 
 	if err != nil {
 		log.Error(err.Error())
-		return nil, err
+		return nil, translateError(err, "category")
 	}
 
 	defer rows.Close()
@@ -373,11 +497,11 @@ func (s DbSource) GetCategories() ([]structs.Category, error) {
 
 	for rows.Next() {
 		var category structs.Category
-		err := rows.Scan(&category.Id, &category.Name, &category.Description, &category.CreatedAt, &category.ImageUrl)
+		err := rows.Scan(&category.Id, &category.Name, &category.Description, &category.CreatedAt, &category.ImageUrl, &category.TaxClassId)
 
 		if err != nil {
 			log.Error(err.Error())
-			return nil, err
+			return nil, translateError(err, "category")
 		}
 
 		categories = append(categories, category)
@@ -385,48 +509,42 @@ func (s DbSource) GetCategories() ([]structs.Category, error) {
 
 	if err = rows.Err(); err != nil {
 		log.Error(err.Error())
-		return nil, err
+		return nil, translateError(err, "category")
 	}
 
-	defer s.conn.Close()
-
 	return categories, nil
 }
 
-func (s DbSource) GetCategoryById(id int) (structs.Category, error) {
+func (s DbSource) GetCategoryById(ctx context.Context, id int) (structs.Category, error) {
 	var category structs.Category
-	err := s.conn.QueryRow("SELECT * FROM category WHERE id = $1", id).Scan(&category.Id, &category.Name, &category.Description, &category.CreatedAt, &category.ImageUrl)
+	err := s.conn.QueryRowContext(ctx, "SELECT "+categoryColumns+" FROM category WHERE id = $1", id).Scan(&category.Id, &category.Name, &category.Description, &category.CreatedAt, &category.ImageUrl, &category.TaxClassId)
 
 	if err != nil {
 		log.Error(err.Error())
-		return structs.Category{}, err
+		return structs.Category{}, translateError(err, "category")
 	}
 
-	defer s.conn.Close()
-
 	return category, nil
 }
 
-func (s DbSource) GetCategoryByName(name string) (structs.Category, error) {
+func (s DbSource) GetCategoryByName(ctx context.Context, name string) (structs.Category, error) {
 	var category structs.Category
-	err := s.conn.QueryRow("SELECT * FROM category WHERE name = $1", name).Scan(&category.Id, &category.Name, &category.Description, &category.CreatedAt, &category.ImageUrl)
+	err := s.conn.QueryRowContext(ctx, "SELECT "+categoryColumns+" FROM category WHERE name = $1", name).Scan(&category.Id, &category.Name, &category.Description, &category.CreatedAt, &category.ImageUrl, &category.TaxClassId)
 
 	if err != nil {
 		log.Error(err.Error())
-		return structs.Category{}, err
+		return structs.Category{}, translateError(err, "category")
 	}
 
-	defer s.conn.Close()
-
 	return category, nil
 }
 
-func (s DbSource) GetSubcategoriesByCategoryId(category_id int) ([]structs.Subcategory, error) {
-	rows, err := s.conn.Query("SELECT * FROM subcategory WHERE category_id = $1", category_id)
+func (s DbSource) GetSubcategoriesByCategoryId(ctx context.Context, category_id int) ([]structs.Subcategory, error) {
+	rows, err := s.conn.QueryContext(ctx, "SELECT "+subcategoryColumns+" FROM subcategory WHERE category_id = $1", category_id)
 
 	if err != nil {
 		log.Error(err.Error())
-		return nil, err
+		return nil, translateError(err, "subcategory")
 	}
 
 	defer rows.Close()
@@ -435,11 +553,11 @@ func (s DbSource) GetSubcategoriesByCategoryId(category_id int) ([]structs.Subca
 
 	for rows.Next() {
 		var subcategory structs.Subcategory
-		err := rows.Scan(&subcategory.Id, &subcategory.Name, &subcategory.Description, &subcategory.CreatedAt, &subcategory.CategoryId, &subcategory.ImageUrl)
+		err := rows.Scan(&subcategory.Id, &subcategory.Name, &subcategory.Description, &subcategory.CreatedAt, &subcategory.CategoryId, &subcategory.ImageUrl, &subcategory.TaxClassId)
 
 		if err != nil {
 			log.Error(err.Error())
-			return nil, err
+			return nil, translateError(err, "subcategory")
 		}
 
 		subcategories = append(subcategories, subcategory)
@@ -447,10 +565,8 @@ func (s DbSource) GetSubcategoriesByCategoryId(category_id int) ([]structs.Subca
 
 	if err = rows.Err(); err != nil {
 		log.Error(err.Error())
-		return nil, err
+		return nil, translateError(err, "subcategory")
 	}
 
-	defer s.conn.Close()
-
 	return subcategories, nil
 }
@@ -0,0 +1,118 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Domain errors returned by every Store implementation, check them with errors.Is
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrConflict   = errors.New("conflict")
+	ErrForeignKey = errors.New("foreign key violation")
+	ErrValidation = errors.New("validation failed")
+)
+
+// Error carries one of the domain errors along with a message that is safe to show to clients.
+// The underlying driver error is kept for logging only.
+type Error struct {
+	Kind    error
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Message, e.Err.Error())
+}
+
+func (e *Error) Is(target error) bool {
+	return e.Kind == target
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func NotFound(entity string) error {
+	return &Error{Kind: ErrNotFound, Message: fmt.Sprintf("%s not found", entity)}
+}
+
+func ValidationError(message string) error {
+	return &Error{Kind: ErrValidation, Message: message}
+}
+
+func Conflict(message string) error {
+	return &Error{Kind: ErrConflict, Message: message}
+}
+
+// Translates sql and pq errors into domain errors, entity is the table the statement targets.
+// Errors that don't map to a domain error (connection issues, canceled contexts..) are returned as is.
+func translateError(err error, entity string) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return &Error{Kind: ErrNotFound, Message: fmt.Sprintf("%s not found", entity), Err: err}
+	}
+
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+
+	switch pqErr.Code {
+	// unique_violation
+	case "23505":
+		return &Error{Kind: ErrConflict, Message: fmt.Sprintf("%s already exists", entity), Err: err}
+
+	// foreign_key_violation
+	case "23503":
+		// The row being written points to a missing parent
+		if pqErr.Table == entity {
+			column := strings.TrimSuffix(strings.TrimPrefix(pqErr.Constraint, pqErr.Table+"_"), "_fkey")
+			return &Error{Kind: ErrForeignKey, Message: fmt.Sprintf("%s does not reference an existing row", column), Err: err}
+		}
+		// The row being deleted is still referenced by a child
+		return &Error{Kind: ErrForeignKey, Message: fmt.Sprintf("%s is still referenced by %s", entity, pqErr.Table), Err: err}
+
+	// not_null_violation
+	case "23502":
+		return &Error{Kind: ErrValidation, Message: fmt.Sprintf("%s is required", pqErr.Column), Err: err}
+
+	// check_violation
+	case "23514":
+		return &Error{Kind: ErrValidation, Message: fmt.Sprintf("%s has an invalid value", entity), Err: err}
+
+	// string_data_right_truncation, numeric_value_out_of_range, invalid_text_representation, invalid_datetime_format
+	case "22001", "22003", "22P02", "22007":
+		return &Error{Kind: ErrValidation, Message: "value is out of range or malformed", Err: err}
+	}
+
+	return err
+}
+
+// Translates the error of an UPDATE or DELETE by id, a statement that touched no rows means the id doesn't exist
+func translateExecResult(result sql.Result, err error, entity string) error {
+	if err != nil {
+		return translateError(err, entity)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return NotFound(entity)
+	}
+
+	return nil
+}
@@ -0,0 +1,348 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"vayer-electric-backend/structs"
+
+	"github.com/lib/pq"
+)
+
+const productImageColumns = "id, product_id, image_url, alt_text, position, is_primary, created_at"
+
+func scanProductImage(scan func(dest ...interface{}) error) (structs.ProductImage, error) {
+	var img structs.ProductImage
+	err := scan(&img.Id, &img.ProductId, &img.ImageUrl, &img.AltText, &img.Position, &img.Primary, &img.CreatedAt)
+	return img, err
+}
+
+// Checks every image of a product is listed exactly once in a new order
+func validateImageOrder(images []structs.ProductImage, ids []int64) error {
+	if len(ids) != len(images) {
+		return ValidationError("ids must list every image of the product once")
+	}
+
+	listed := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		listed[id] = true
+	}
+
+	for _, img := range images {
+		if !listed[img.Id] {
+			return ValidationError("ids must list every image of the product once")
+		}
+	}
+
+	return nil
+}
+
+func productExists(ctx context.Context, q querier, productId int) error {
+	var exists bool
+	err := q.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM product WHERE id = $1)", productId).Scan(&exists)
+
+	if err != nil {
+		log.Error(err.Error())
+		return translateError(err, "product")
+	}
+
+	if !exists {
+		return NotFound("product")
+	}
+
+	return nil
+}
+
+func (s DbSource) productImages(ctx context.Context, q querier, productId int) ([]structs.ProductImage, error) {
+	rows, err := q.QueryContext(ctx, "SELECT "+productImageColumns+" FROM product_image WHERE product_id = $1 ORDER BY position, id", productId)
+
+	if err != nil {
+		log.Error(err.Error())
+		return nil, translateError(err, "product_image")
+	}
+
+	defer rows.Close()
+
+	images := make([]structs.ProductImage, 0)
+
+	for rows.Next() {
+		img, err := scanProductImage(rows.Scan)
+
+		if err != nil {
+			log.Error(err.Error())
+			return nil, translateError(err, "product_image")
+		}
+
+		images = append(images, img)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error(err.Error())
+		return nil, translateError(err, "product_image")
+	}
+
+	return images, nil
+}
+
+// Points product.image_url at the primary image, or clears it when the gallery is empty
+func syncPrimaryImage(ctx context.Context, tx *sql.Tx, productId int) error {
+	_, err := tx.ExecContext(ctx, "UPDATE product SET image_url = coalesce((SELECT image_url FROM product_image WHERE product_id = $1 AND is_primary), '') WHERE id = $1", productId)
+
+	if err != nil {
+		log.Error(err.Error())
+		return translateError(err, "product")
+	}
+
+	return nil
+}
+
+func (s DbSource) GetProductImages(ctx context.Context, productId int) ([]structs.ProductImage, error) {
+	if err := productExists(ctx, s.conn, productId); err != nil {
+		return nil, err
+	}
+
+	return s.productImages(ctx, s.conn, productId)
+}
+
+// Appends an image to the gallery of a product, the first image of a product becomes its primary image
+func (s DbSource) InsertProductImage(ctx context.Context, img structs.ProductImage) (structs.ProductImage, error) {
+	tx, err := s.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.ProductImage{}, err
+	}
+
+	defer tx.Rollback()
+
+	created, err := insertProductImage(ctx, tx, img)
+
+	if err != nil {
+		return structs.ProductImage{}, err
+	}
+
+	return created, tx.Commit()
+}
+
+func insertProductImage(ctx context.Context, tx *sql.Tx, img structs.ProductImage) (structs.ProductImage, error) {
+	if err := lockProduct(ctx, tx, int(img.ProductId)); err != nil {
+		return structs.ProductImage{}, err
+	}
+
+	var position int
+	var hasPrimary bool
+	err := tx.QueryRowContext(ctx, "SELECT coalesce(max(position) + 1, 0), coalesce(bool_or(is_primary), false) FROM product_image WHERE product_id = $1", img.ProductId).Scan(&position, &hasPrimary)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.ProductImage{}, translateError(err, "product_image")
+	}
+
+	primary := img.Primary || !hasPrimary
+
+	if primary {
+		if _, err := tx.ExecContext(ctx, "UPDATE product_image SET is_primary = false WHERE product_id = $1 AND is_primary", img.ProductId); err != nil {
+			log.Error(err.Error())
+			return structs.ProductImage{}, translateError(err, "product_image")
+		}
+	}
+
+	row := tx.QueryRowContext(ctx, "INSERT INTO product_image (product_id, image_url, alt_text, position, is_primary, created_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING "+productImageColumns,
+		img.ProductId, img.ImageUrl, strings.TrimSpace(img.AltText), position, primary, time.Now())
+
+	created, err := scanProductImage(row.Scan)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.ProductImage{}, translateError(err, "product_image")
+	}
+
+	if primary {
+		if err := syncPrimaryImage(ctx, tx, int(img.ProductId)); err != nil {
+			return structs.ProductImage{}, err
+		}
+	}
+
+	return created, nil
+}
+
+// Updates the alt text of an image and makes it the primary image when Primary is set.
+// The primary image only changes by promoting another one.
+func (s DbSource) UpdateProductImage(ctx context.Context, img structs.ProductImage) (structs.ProductImage, error) {
+	tx, err := s.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.ProductImage{}, err
+	}
+
+	defer tx.Rollback()
+
+	if err := lockProduct(ctx, tx, int(img.ProductId)); err != nil {
+		return structs.ProductImage{}, err
+	}
+
+	if img.Primary {
+		if _, err := tx.ExecContext(ctx, "UPDATE product_image SET is_primary = false WHERE product_id = $1 AND is_primary AND id <> $2", img.ProductId, img.Id); err != nil {
+			log.Error(err.Error())
+			return structs.ProductImage{}, translateError(err, "product_image")
+		}
+	}
+
+	row := tx.QueryRowContext(ctx, "UPDATE product_image SET alt_text = $1, is_primary = is_primary OR $2 WHERE id = $3 AND product_id = $4 RETURNING "+productImageColumns,
+		strings.TrimSpace(img.AltText), img.Primary, img.Id, img.ProductId)
+
+	updated, err := scanProductImage(row.Scan)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.ProductImage{}, translateError(err, "product_image")
+	}
+
+	if err := syncPrimaryImage(ctx, tx, int(img.ProductId)); err != nil {
+		return structs.ProductImage{}, err
+	}
+
+	return updated, tx.Commit()
+}
+
+// Orders the gallery of a product as ids lists its images
+func (s DbSource) ReorderProductImages(ctx context.Context, productId int, ids []int64) ([]structs.ProductImage, error) {
+	tx, err := s.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	if err := lockProduct(ctx, tx, productId); err != nil {
+		return nil, err
+	}
+
+	images, err := s.productImages(ctx, tx, productId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateImageOrder(images, ids); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE product_image SET position = array_position($1::int[], id) - 1 WHERE product_id = $2", pq.Array(ids), productId)
+
+	if err != nil {
+		log.Error(err.Error())
+		return nil, translateError(err, "product_image")
+	}
+
+	images, err = s.productImages(ctx, tx, productId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return images, tx.Commit()
+}
+
+// Removes an image from the gallery, the next image in order takes over when the primary one is deleted
+func (s DbSource) DeleteProductImage(ctx context.Context, productId int, imageId int) error {
+	tx, err := s.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		log.Error(err.Error())
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if err := lockProduct(ctx, tx, productId); err != nil {
+		return err
+	}
+
+	var position int
+	var primary bool
+	err = tx.QueryRowContext(ctx, "DELETE FROM product_image WHERE id = $1 AND product_id = $2 RETURNING position, is_primary", imageId, productId).Scan(&position, &primary)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return NotFound("product_image")
+	}
+
+	if err != nil {
+		log.Error(err.Error())
+		return translateError(err, "product_image")
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE product_image SET position = position - 1 WHERE product_id = $1 AND position > $2", productId, position); err != nil {
+		log.Error(err.Error())
+		return translateError(err, "product_image")
+	}
+
+	if primary {
+		_, err := tx.ExecContext(ctx, "UPDATE product_image SET is_primary = true WHERE id = (SELECT id FROM product_image WHERE product_id = $1 ORDER BY position, id LIMIT 1)", productId)
+
+		if err != nil {
+			log.Error(err.Error())
+			return translateError(err, "product_image")
+		}
+
+		if err := syncPrimaryImage(ctx, tx, productId); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Loads the galleries of products in place
+func (s DbSource) loadProductImages(ctx context.Context, products []structs.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(products))
+	for _, p := range products {
+		ids = append(ids, p.Id)
+	}
+
+	rows, err := s.conn.QueryContext(ctx, "SELECT "+productImageColumns+" FROM product_image WHERE product_id = ANY($1) ORDER BY product_id, position, id", pq.Array(ids))
+
+	if err != nil {
+		log.Error(err.Error())
+		return translateError(err, "product_image")
+	}
+
+	defer rows.Close()
+
+	byProduct := make(map[int64][]structs.ProductImage)
+
+	for rows.Next() {
+		img, err := scanProductImage(rows.Scan)
+
+		if err != nil {
+			log.Error(err.Error())
+			return translateError(err, "product_image")
+		}
+
+		byProduct[img.ProductId] = append(byProduct[img.ProductId], img)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error(err.Error())
+		return translateError(err, "product_image")
+	}
+
+	for i := range products {
+		products[i].Images = byProduct[products[i].Id]
+		if products[i].Images == nil {
+			products[i].Images = make([]structs.ProductImage, 0)
+		}
+	}
+
+	return nil
+}
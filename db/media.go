@@ -0,0 +1,98 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Sets the image of a category and returns the one it replaces, empty when it had none
+func (s DbSource) SetCategoryImage(ctx context.Context, id int, imageUrl string) (string, error) {
+	var previous string
+	err := s.conn.QueryRowContext(ctx, "UPDATE category c SET image_url = $1, updated_at = $2 FROM (SELECT id, image_url FROM category WHERE id = $3 FOR UPDATE) old WHERE c.id = old.id RETURNING coalesce(old.image_url, '')",
+		imageUrl, time.Now(), id).Scan(&previous)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", NotFound("category")
+	}
+
+	if err != nil {
+		log.Error(err.Error())
+		return "", translateError(err, "category")
+	}
+
+	return previous, nil
+}
+
+// Sets the image of a subcategory and returns the one it replaces, empty when it had none
+func (s DbSource) SetSubcategoryImage(ctx context.Context, id int, imageUrl string) (string, error) {
+	var previous string
+	err := s.conn.QueryRowContext(ctx, "UPDATE subcategory c SET image_url = $1, updated_at = $2 FROM (SELECT id, image_url FROM subcategory WHERE id = $3 FOR UPDATE) old WHERE c.id = old.id RETURNING coalesce(old.image_url, '')",
+		imageUrl, time.Now(), id).Scan(&previous)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", NotFound("subcategory")
+	}
+
+	if err != nil {
+		log.Error(err.Error())
+		return "", translateError(err, "subcategory")
+	}
+
+	return previous, nil
+}
+
+// Reports whether any row still points at a stored image, images are named after their content so rows can share one
+func (s DbSource) ImageReferenced(ctx context.Context, imageUrl string) (bool, error) {
+	var referenced bool
+	err := s.conn.QueryRowContext(ctx, `SELECT
+		EXISTS (SELECT 1 FROM product WHERE image_url = $1) OR
+		EXISTS (SELECT 1 FROM product_image WHERE image_url = $1) OR
+		EXISTS (SELECT 1 FROM product_variant WHERE image_url = $1) OR
+		EXISTS (SELECT 1 FROM category WHERE image_url = $1) OR
+		EXISTS (SELECT 1 FROM subcategory WHERE image_url = $1)`, imageUrl).Scan(&referenced)
+
+	if err != nil {
+		log.Error(err.Error())
+		return false, translateError(err, "")
+	}
+
+	return referenced, nil
+}
+
+// Returns every image name rows point at, used to find stored images nothing references anymore
+func (s DbSource) ImageReferences(ctx context.Context) (map[string]bool, error) {
+	rows, err := s.conn.QueryContext(ctx, `SELECT image_url FROM product WHERE image_url <> ''
+		UNION SELECT image_url FROM product_image
+		UNION SELECT image_url FROM product_variant WHERE image_url IS NOT NULL
+		UNION SELECT image_url FROM category WHERE image_url IS NOT NULL
+		UNION SELECT image_url FROM subcategory WHERE image_url IS NOT NULL`)
+
+	if err != nil {
+		log.Error(err.Error())
+		return nil, translateError(err, "")
+	}
+
+	defer rows.Close()
+
+	references := make(map[string]bool)
+
+	for rows.Next() {
+		var imageUrl string
+
+		if err := rows.Scan(&imageUrl); err != nil {
+			log.Error(err.Error())
+			return nil, translateError(err, "")
+		}
+
+		references[imageUrl] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error(err.Error())
+		return nil, translateError(err, "")
+	}
+
+	return references, nil
+}
@@ -0,0 +1,927 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"vayer-electric-backend/money"
+	"vayer-electric-backend/structs"
+
+	"github.com/lib/pq"
+)
+
+// MemoryStore is an in-process Store meant for handler tests and local demos.
+// It mirrors the Postgres schema: ids are serial and foreign keys are enforced,
+// failures are reported with the same domain errors DbSource translates pq errors into.
+type MemoryStore struct {
+	mu sync.RWMutex
+
+	categories    []memoryCategory
+	subcategories []memorySubcategory
+	products      []memoryProduct
+
+	attributeDefinitions []structs.AttributeDefinition
+	productAttributes    map[int64][]structs.ProductAttribute
+
+	variantAxes map[int64][]string
+	variants    []structs.ProductVariant
+
+	productImages map[int64][]structs.ProductImage
+
+	stockMovements []structs.StockMovement
+
+	warehouses   []structs.Warehouse
+	locations    []structs.Location
+	productStock map[int64]map[int64]int64
+
+	reservations []memoryReservation
+	stockAlerts  []memoryStockAlert
+
+	carts []memoryCart
+
+	orders []memoryOrder
+
+	payments      []structs.Payment
+	paymentEvents map[string]bool
+
+	// Rates are kept in the class they belong to
+	taxClasses []structs.TaxClass
+
+	nextCategoryId            int64
+	nextSubcategoryId         int64
+	nextProductId             int64
+	nextAttributeDefinitionId int64
+	nextVariantId             int64
+	nextImageId               int64
+	nextStockMovementId       int64
+	nextWarehouseId           int64
+	nextLocationId            int64
+	nextReservationId         int64
+	nextStockAlertId          int64
+	nextCartId                int64
+	nextCartLineId            int64
+	nextOrderId               int64
+	nextOrderLineId           int64
+	nextOrderTransitionId     int64
+	nextPaymentId             int64
+	nextTaxClassId            int64
+	nextTaxRateId             int64
+}
+
+type memoryCategory struct {
+	structs.Category
+	createdAt time.Time
+}
+
+type memorySubcategory struct {
+	structs.Subcategory
+	createdAt time.Time
+}
+
+type memoryProduct struct {
+	structs.Product
+	createdAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{}
+
+	// Seeded like migration 10, stock that doesn't name a location goes to the default one
+	now := formatTimestamp(time.Now())
+	s.nextWarehouseId++
+	s.warehouses = append(s.warehouses, structs.Warehouse{Id: s.nextWarehouseId, Name: "Main warehouse", CreatedAt: now})
+	s.nextLocationId++
+	s.locations = append(s.locations, structs.Location{Id: s.nextLocationId, WarehouseId: s.nextWarehouseId, Name: "Main", Default: true, CreatedAt: now})
+
+	// Seeded like migration 16
+	s.nextTaxClassId++
+	s.taxClasses = append(s.taxClasses, structs.TaxClass{Id: s.nextTaxClassId, Name: "Standard", Default: true, Rates: make([]structs.TaxRate, 0), CreatedAt: now})
+
+	return s
+}
+
+func foreignKeyViolation(table string, constraint string) error {
+	return translateError(&pq.Error{
+		Severity:   "ERROR",
+		Code:       "23503",
+		Message:    fmt.Sprintf("insert or update on table \"%s\" violates foreign key constraint \"%s\"", table, constraint),
+		Table:      table,
+		Constraint: constraint,
+	}, table)
+}
+
+func foreignKeyReferenced(table string, referencing string, constraint string) error {
+	return translateError(&pq.Error{
+		Severity:   "ERROR",
+		Code:       "23503",
+		Message:    fmt.Sprintf("update or delete on table \"%s\" violates foreign key constraint \"%s\" on table \"%s\"", table, constraint, referencing),
+		Table:      referencing,
+		Constraint: constraint,
+	}, table)
+}
+
+// Same limits Postgres enforces on varchar(255) and numeric(10,2) columns
+func checkColumns(price money.Amount, values ...string) error {
+	if price > money.Max || price < -money.Max {
+		return translateError(&pq.Error{Code: "22003", Message: "numeric field overflow"}, "")
+	}
+
+	for _, v := range values {
+		if len([]rune(v)) > 255 {
+			return translateError(&pq.Error{Code: "22001", Message: "value too long for type character varying(255)"}, "")
+		}
+	}
+
+	return nil
+}
+
+func formatTimestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func (s *MemoryStore) ValidateConnection(ctx context.Context) bool {
+	return ctx.Err() == nil
+}
+
+func (s *MemoryStore) Migrate(path string) error {
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// There is no pool behind the memory store
+func (s *MemoryStore) Stats() sql.DBStats {
+	return sql.DBStats{}
+}
+
+func (s *MemoryStore) categoryIndex(id int64) int {
+	for i := range s.categories {
+		if s.categories[i].Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *MemoryStore) subcategoryIndex(id int64) int {
+	for i := range s.subcategories {
+		if s.subcategories[i].Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *MemoryStore) productIndex(id int64) int {
+	for i := range s.products {
+		if s.products[i].Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *MemoryStore) InsertProduct(ctx context.Context, name string, description string, subcategory_id int, price money.Amount, currentInventory int, imageUrl string, brand string, sku string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if currentInventory < 0 {
+		return ValidationError("current_inventory can't be negative")
+	}
+
+	if err := checkColumns(price, name, description, imageUrl, brand, sku); err != nil {
+		return err
+	}
+
+	if s.subcategoryIndex(int64(subcategory_id)) < 0 {
+		return foreignKeyViolation("product", "product_subcategory_id_fkey")
+	}
+
+	now := time.Now()
+	s.nextProductId++
+	s.products = append(s.products, memoryProduct{
+		Product: structs.Product{
+			Id:            s.nextProductId,
+			Name:          name,
+			Description:   description,
+			SubcategoryId: int64(subcategory_id),
+			Price:         price,
+			ImageUrl:      imageUrl,
+			Brand:         brand,
+			Sku:           sku,
+			CreatedAt:     formatTimestamp(now),
+		},
+		createdAt: now,
+	})
+
+	if currentInventory > 0 {
+		s.applyStockMovement(structs.StockMovement{ProductId: s.nextProductId, Reason: "adjustment", Quantity: int64(currentInventory), Reference: openingBalanceReference})
+	}
+
+	if imageUrl != "" {
+		s.appendProductImage(structs.ProductImage{ProductId: s.nextProductId, ImageUrl: imageUrl, Primary: true})
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) UpdateProduct(ctx context.Context, id int, name string, price money.Amount, currentInventory int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if currentInventory < 0 {
+		return ValidationError("current_inventory can't be negative")
+	}
+
+	if err := checkColumns(price, name); err != nil {
+		return err
+	}
+
+	i := s.productIndex(int64(id))
+	if i < 0 {
+		return NotFound("product")
+	}
+
+	s.products[i].Name = name
+	s.products[i].Price = price
+
+	if current := s.products[i].CurrentInventory; int64(currentInventory) != current {
+		before := s.availableStock(i)
+
+		if quantity := int64(currentInventory) - current; quantity < 0 {
+			if err := s.allocateStock(int64(id), -quantity, "adjustment", productUpdateReference, ""); err != nil {
+				return err
+			}
+		} else {
+			adjustment := structs.StockMovement{ProductId: int64(id), Reason: "adjustment", Quantity: quantity, Reference: productUpdateReference}
+			if _, err := s.applyStockMovement(adjustment); err != nil {
+				return err
+			}
+		}
+
+		s.recordStockAlert(i, before)
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) DeleteProduct(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	i := s.productIndex(int64(id))
+	if i < 0 {
+		return NotFound("product")
+	}
+
+	s.products = append(s.products[:i], s.products[i+1:]...)
+	delete(s.productAttributes, int64(id))
+	delete(s.variantAxes, int64(id))
+	s.deleteVariants(int64(id))
+	delete(s.productImages, int64(id))
+	delete(s.productStock, int64(id))
+	s.deleteReservations(int64(id))
+	s.deleteStockAlerts(int64(id))
+	s.deleteCartLines(int64(id))
+	s.unlinkOrderLines(int64(id))
+
+	return nil
+}
+
+func (s *MemoryStore) filterProducts(keep func(p structs.Product) bool) []structs.Product {
+	products := make([]structs.Product, 0)
+
+	for _, p := range s.products {
+		if keep(p.Product) {
+			products = append(products, p.Product)
+		}
+	}
+
+	return products
+}
+
+func (s *MemoryStore) GetProducts(ctx context.Context) ([]structs.Product, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sorted := make([]memoryProduct, len(s.products))
+	copy(sorted, s.products)
+
+	// ORDER BY created_at DESC
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].createdAt.After(sorted[j].createdAt)
+	})
+
+	products := make([]structs.Product, 0, len(sorted))
+	for _, p := range sorted {
+		products = append(products, p.Product)
+	}
+
+	return products, nil
+}
+
+func (s *MemoryStore) GetProductById(ctx context.Context, id int) (structs.Product, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.Product{}, err
+	}
+
+	i := s.productIndex(int64(id))
+	if i < 0 {
+		return structs.Product{}, NotFound("product")
+	}
+
+	return s.withStock(s.withVariants(s.withImages(s.withAttributes(s.products[i].Product)))), nil
+}
+
+func (s *MemoryStore) GetProductByName(ctx context.Context, name string) (structs.Product, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.Product{}, err
+	}
+
+	for _, p := range s.products {
+		if p.Name == name {
+			return s.withStock(s.withVariants(s.withImages(s.withAttributes(p.Product)))), nil
+		}
+	}
+
+	return structs.Product{}, NotFound("product")
+}
+
+func (s *MemoryStore) GetProductsBySubcategoryId(ctx context.Context, subcategory_id int) ([]structs.Product, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return s.filterProducts(func(p structs.Product) bool {
+		return p.SubcategoryId == int64(subcategory_id)
+	}), nil
+}
+
+func (s *MemoryStore) GetProductsByCategoryId(ctx context.Context, categoryId int) ([]structs.Product, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return s.productsInCategory(int64(categoryId)), nil
+}
+
+func (s *MemoryStore) GetProductsByCategoryName(ctx context.Context, categoryName string) ([]structs.Product, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, c := range s.categories {
+		if c.Name == categoryName {
+			return s.productsInCategory(c.Id), nil
+		}
+	}
+
+	return make([]structs.Product, 0), nil
+}
+
+func (s *MemoryStore) productsInCategory(categoryId int64) []structs.Product {
+	subcategoryIds := make(map[int64]bool)
+	for _, sc := range s.subcategories {
+		if sc.CategoryId == categoryId {
+			subcategoryIds[sc.Id] = true
+		}
+	}
+
+	return s.filterProducts(func(p structs.Product) bool {
+		return subcategoryIds[p.SubcategoryId]
+	})
+}
+
+func (s *MemoryStore) InsertSubcategory(ctx context.Context, name string, description string, category_id int, image_url string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := checkColumns(0, name, description, image_url); err != nil {
+		return err
+	}
+
+	if s.categoryIndex(int64(category_id)) < 0 {
+		return foreignKeyViolation("subcategory", "subcategory_category_id_fkey")
+	}
+
+	now := time.Now()
+	s.nextSubcategoryId++
+	s.subcategories = append(s.subcategories, memorySubcategory{
+		Subcategory: structs.Subcategory{
+			Id:          s.nextSubcategoryId,
+			Name:        name,
+			Description: description,
+			CategoryId:  int64(category_id),
+			CreatedAt:   formatTimestamp(now),
+			ImageUrl:    image_url,
+		},
+		createdAt: now,
+	})
+
+	return nil
+}
+
+func (s *MemoryStore) UpdateSubcategory(ctx context.Context, id int, name string, description string, category_id int, image_url string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := checkColumns(0, name, description, image_url); err != nil {
+		return err
+	}
+
+	i := s.subcategoryIndex(int64(id))
+	if i < 0 {
+		return NotFound("subcategory")
+	}
+
+	if s.categoryIndex(int64(category_id)) < 0 {
+		return foreignKeyViolation("subcategory", "subcategory_category_id_fkey")
+	}
+
+	s.subcategories[i].Name = name
+	s.subcategories[i].Description = description
+	s.subcategories[i].CategoryId = int64(category_id)
+	s.subcategories[i].ImageUrl = image_url
+
+	return nil
+}
+
+func (s *MemoryStore) DeleteSubcategory(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	i := s.subcategoryIndex(int64(id))
+	if i < 0 {
+		return NotFound("subcategory")
+	}
+
+	for _, p := range s.products {
+		if p.SubcategoryId == int64(id) {
+			return foreignKeyReferenced("subcategory", "product", "product_subcategory_id_fkey")
+		}
+	}
+
+	s.subcategories = append(s.subcategories[:i], s.subcategories[i+1:]...)
+
+	kept := make([]structs.AttributeDefinition, 0, len(s.attributeDefinitions))
+	for _, def := range s.attributeDefinitions {
+		if def.SubcategoryId != int64(id) {
+			kept = append(kept, def)
+		}
+	}
+	s.attributeDefinitions = kept
+
+	return nil
+}
+
+func (s *MemoryStore) GetSubcategories(ctx context.Context) ([]structs.Subcategory, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	subcategories := make([]structs.Subcategory, 0, len(s.subcategories))
+	for _, sc := range s.subcategories {
+		subcategories = append(subcategories, sc.Subcategory)
+	}
+
+	return subcategories, nil
+}
+
+func (s *MemoryStore) GetSubcategoryById(ctx context.Context, id int) (structs.Subcategory, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.Subcategory{}, err
+	}
+
+	i := s.subcategoryIndex(int64(id))
+	if i < 0 {
+		return structs.Subcategory{}, NotFound("subcategory")
+	}
+
+	return s.subcategories[i].Subcategory, nil
+}
+
+func (s *MemoryStore) GetSubcategoryByName(ctx context.Context, name string) (structs.Subcategory, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.Subcategory{}, err
+	}
+
+	for _, sc := range s.subcategories {
+		if sc.Name == name {
+			return sc.Subcategory, nil
+		}
+	}
+
+	return structs.Subcategory{}, NotFound("subcategory")
+}
+
+func (s *MemoryStore) GetSubcategoriesByCategoryId(ctx context.Context, category_id int) ([]structs.Subcategory, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	subcategories := make([]structs.Subcategory, 0)
+	for _, sc := range s.subcategories {
+		if sc.CategoryId == int64(category_id) {
+			subcategories = append(subcategories, sc.Subcategory)
+		}
+	}
+
+	return subcategories, nil
+}
+
+func (s *MemoryStore) InsertCategory(ctx context.Context, name string, description string, image_url string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := checkColumns(0, name, description, image_url); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	s.nextCategoryId++
+	s.categories = append(s.categories, memoryCategory{
+		Category: structs.Category{
+			Id:          s.nextCategoryId,
+			Name:        name,
+			Description: description,
+			CreatedAt:   formatTimestamp(now),
+			ImageUrl:    image_url,
+		},
+		createdAt: now,
+	})
+
+	return nil
+}
+
+func (s *MemoryStore) UpdateCategory(ctx context.Context, id int, name string, description string, image_url string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := checkColumns(0, name, description, image_url); err != nil {
+		return err
+	}
+
+	i := s.categoryIndex(int64(id))
+	if i < 0 {
+		return NotFound("category")
+	}
+
+	s.categories[i].Name = name
+	s.categories[i].Description = description
+	s.categories[i].ImageUrl = image_url
+
+	return nil
+}
+
+func (s *MemoryStore) DeleteCategory(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	i := s.categoryIndex(int64(id))
+	if i < 0 {
+		return NotFound("category")
+	}
+
+	for _, sc := range s.subcategories {
+		if sc.CategoryId == int64(id) {
+			return foreignKeyReferenced("category", "subcategory", "subcategory_category_id_fkey")
+		}
+	}
+
+	s.categories = append(s.categories[:i], s.categories[i+1:]...)
+
+	return nil
+}
+
+func (s *MemoryStore) GetCategories(ctx context.Context) ([]structs.Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	categories := make([]structs.Category, 0, len(s.categories))
+	for _, c := range s.categories {
+		categories = append(categories, c.Category)
+	}
+
+	return categories, nil
+}
+
+func (s *MemoryStore) GetCategoryById(ctx context.Context, id int) (structs.Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.Category{}, err
+	}
+
+	i := s.categoryIndex(int64(id))
+	if i < 0 {
+		return structs.Category{}, NotFound("category")
+	}
+
+	return s.categories[i].Category, nil
+}
+
+func (s *MemoryStore) GetCategoryByName(ctx context.Context, name string) (structs.Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.Category{}, err
+	}
+
+	for _, c := range s.categories {
+		if c.Name == name {
+			return c.Category, nil
+		}
+	}
+
+	return structs.Category{}, NotFound("category")
+}
+
+func (s *MemoryStore) ListProducts(ctx context.Context, q ProductQuery) (structs.ProductPage, error) {
+	if err := q.normalize(); err != nil {
+		return structs.ProductPage{}, err
+	}
+
+	cursor, err := decodeProductCursor(q)
+	if err != nil {
+		return structs.ProductPage{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.ProductPage{}, err
+	}
+
+	if q.Attributes, err = resolveAttributeFilters(q.Attributes, s.attributeDefinitions); err != nil {
+		return structs.ProductPage{}, err
+	}
+
+	matched := s.matchProducts(q)
+	total := int64(len(matched))
+
+	var facets *structs.ProductFacets
+	if q.Facets {
+		facets = s.productFacets(matched)
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		c := compareProductKey(matched[i], q.Sort, productSortValue(matched[j].Product, q.Sort), matched[j].Id)
+		if q.Descending {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	products := make([]structs.Product, 0, q.Limit+1)
+
+	for _, p := range matched {
+		if cursor != nil {
+			c := compareProductKey(p, q.Sort, cursor.Value, cursor.Id)
+			if (q.Descending && c >= 0) || (!q.Descending && c <= 0) {
+				continue
+			}
+		}
+
+		products = append(products, s.withImages(s.withAttributes(p.Product)))
+
+		if len(products) > q.Limit {
+			break
+		}
+	}
+
+	page := newProductPage(q, products, total)
+	page.Facets = facets
+
+	return page, nil
+}
+
+func (s *MemoryStore) productFacets(matched []memoryProduct) *structs.ProductFacets {
+	facets := newProductFacets()
+
+	brands := make(map[string]int64)
+	subcategories := make(map[int64]int64)
+
+	now := time.Now()
+
+	for _, p := range matched {
+		brands[p.Brand]++
+		subcategories[p.SubcategoryId]++
+		facets.PriceBuckets[priceBucket(p.Price)].Count++
+
+		if availableStock(p.CurrentInventory, s.reservedStock(p.Id, now)) > 0 {
+			facets.Availability.InStock++
+		} else {
+			facets.Availability.OutOfStock++
+		}
+	}
+
+	for brand, count := range brands {
+		facets.Brands = append(facets.Brands, structs.FacetCount{Value: brand, Count: count})
+	}
+
+	for id, count := range subcategories {
+		name := ""
+		if i := s.subcategoryIndex(id); i >= 0 {
+			name = s.subcategories[i].Name
+		}
+		facets.Subcategories = append(facets.Subcategories, structs.SubcategoryFacet{Id: id, Name: name, Count: count})
+	}
+
+	sortFacets(facets)
+
+	return facets
+}
+
+// Returns the products matching the filters of q, same conditions as productFilterSQL
+func (s *MemoryStore) matchProducts(q ProductQuery) []memoryProduct {
+	brands := make(map[string]bool)
+	for _, b := range q.Brands {
+		brands[b] = true
+	}
+
+	subcategoryIds := make(map[int64]bool)
+	for _, sc := range s.subcategories {
+		if sc.CategoryId == int64(q.CategoryId) {
+			subcategoryIds[sc.Id] = true
+		}
+	}
+
+	search := parseMemorySearch(q.Search)
+	now := time.Now()
+
+	matched := make([]memoryProduct, 0)
+
+	for _, p := range s.products {
+		if q.Search != "" {
+			if p.Match = search.match(p.Product); p.Match == nil {
+				continue
+			}
+		}
+		if len(brands) > 0 && !brands[p.Brand] {
+			continue
+		}
+		if q.SubcategoryId != 0 && p.SubcategoryId != int64(q.SubcategoryId) {
+			continue
+		}
+		if q.CategoryId != 0 && !subcategoryIds[p.SubcategoryId] {
+			continue
+		}
+		if q.MinPrice != nil && p.Price < *q.MinPrice {
+			continue
+		}
+		if q.MaxPrice != nil && p.Price > *q.MaxPrice {
+			continue
+		}
+		if q.InStockOnly && availableStock(p.CurrentInventory, s.reservedStock(p.Id, now)) <= 0 {
+			continue
+		}
+		if !s.matchesAttributeFilters(p.Id, q.Attributes) {
+			continue
+		}
+
+		matched = append(matched, p)
+	}
+
+	return matched
+}
+
+func (s *MemoryStore) matchesAttributeFilters(productId int64, filters []AttributeFilter) bool {
+	for _, f := range filters {
+		if !s.matchesAttributeFilter(productId, f) {
+			return false
+		}
+	}
+	return true
+}
+
+// Compares the (sort key, id) tuple of a product against a position given as a cursor value and id,
+// the way Postgres compares row values
+func compareProductKey(p memoryProduct, sort string, value string, id int64) int {
+	c := 0
+
+	switch sort {
+	case "relevance":
+		v, _ := strconv.ParseFloat(value, 64)
+		rank := 0.0
+		if p.Match != nil {
+			rank = p.Match.Rank
+		}
+		c = compareFloat(rank, v)
+	case "price":
+		v, _ := money.Parse(value)
+		c = compareInt(p.Price.Minor(), v.Minor())
+	case "name":
+		c = strings.Compare(p.Name, value)
+	default:
+		t, _ := time.Parse(time.RFC3339Nano, value)
+		c = compareInt(p.createdAt.UnixNano(), t.UnixNano())
+	}
+
+	if c != 0 {
+		return c
+	}
+
+	return compareInt(p.Id, id)
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
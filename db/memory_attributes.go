@@ -0,0 +1,193 @@
+package db
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"vayer-electric-backend/structs"
+
+	"github.com/lib/pq"
+)
+
+func (s *MemoryStore) InsertAttributeDefinition(ctx context.Context, def structs.AttributeDefinition) (structs.AttributeDefinition, error) {
+	if err := validateAttributeDefinition(&def); err != nil {
+		return structs.AttributeDefinition{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.AttributeDefinition{}, err
+	}
+
+	if s.subcategoryIndex(def.SubcategoryId) < 0 {
+		return structs.AttributeDefinition{}, foreignKeyViolation("attribute_definition", "attribute_definition_subcategory_id_fkey")
+	}
+
+	for _, existing := range s.attributeDefinitions {
+		if existing.SubcategoryId == def.SubcategoryId && existing.Code == def.Code {
+			return structs.AttributeDefinition{}, translateError(&pq.Error{Code: "23505", Table: "attribute_definition"}, "attribute_definition")
+		}
+	}
+
+	s.nextAttributeDefinitionId++
+	def.Id = s.nextAttributeDefinitionId
+	def.CreatedAt = formatTimestamp(time.Now())
+	def.AllowedValues = append([]string(nil), def.AllowedValues...)
+
+	s.attributeDefinitions = append(s.attributeDefinitions, def)
+
+	return def, nil
+}
+
+func (s *MemoryStore) DeleteAttributeDefinition(ctx context.Context, subcategoryId int, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	for i, def := range s.attributeDefinitions {
+		if def.Id == int64(id) && def.SubcategoryId == int64(subcategoryId) {
+			s.attributeDefinitions = append(s.attributeDefinitions[:i], s.attributeDefinitions[i+1:]...)
+			s.deleteAttributeValues(def.Id)
+			return nil
+		}
+	}
+
+	return NotFound("attribute_definition")
+}
+
+// ON DELETE CASCADE from attribute_definition to product_attribute
+func (s *MemoryStore) deleteAttributeValues(attributeId int64) {
+	for productId, attributes := range s.productAttributes {
+		kept := make([]structs.ProductAttribute, 0, len(attributes))
+		for _, a := range attributes {
+			if a.AttributeId != attributeId {
+				kept = append(kept, a)
+			}
+		}
+		s.productAttributes[productId] = kept
+	}
+}
+
+func (s *MemoryStore) GetAttributeDefinitions(ctx context.Context, subcategoryId int) ([]structs.AttributeDefinition, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return s.subcategoryAttributeDefinitions(int64(subcategoryId)), nil
+}
+
+func (s *MemoryStore) subcategoryAttributeDefinitions(subcategoryId int64) []structs.AttributeDefinition {
+	defs := make([]structs.AttributeDefinition, 0)
+	for _, def := range s.attributeDefinitions {
+		if def.SubcategoryId == subcategoryId {
+			defs = append(defs, def)
+		}
+	}
+
+	sort.Slice(defs, func(i, j int) bool {
+		return defs[i].Code < defs[j].Code
+	})
+
+	return defs
+}
+
+func (s *MemoryStore) SetProductAttributes(ctx context.Context, productId int, values map[string]interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	i := s.productIndex(int64(productId))
+	if i < 0 {
+		return NotFound("product")
+	}
+
+	attributes, err := validateAttributeValues(s.subcategoryAttributeDefinitions(s.products[i].SubcategoryId), values)
+	if err != nil {
+		return err
+	}
+
+	if s.productAttributes == nil {
+		s.productAttributes = make(map[int64][]structs.ProductAttribute)
+	}
+	s.productAttributes[int64(productId)] = attributes
+
+	return nil
+}
+
+// Returns a copy of p carrying its attribute values, numbers presented in their definition unit
+func (s *MemoryStore) withAttributes(p structs.Product) structs.Product {
+	p.Attributes = make([]structs.ProductAttribute, 0, len(s.productAttributes[p.Id]))
+	for _, a := range s.productAttributes[p.Id] {
+		p.Attributes = append(p.Attributes, presentAttribute(a))
+	}
+	return p
+}
+
+// Same matching as attributeFilterSQL, values are kept in canonical units
+func (s *MemoryStore) matchesAttributeFilter(productId int64, f AttributeFilter) bool {
+	for _, a := range s.productAttributes[productId] {
+		for _, c := range f.conditions {
+			if c.attributeId == a.AttributeId && attributeConditionMatches(c, a.Value) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func attributeConditionMatches(c attributeCondition, value interface{}) bool {
+	switch v := value.(type) {
+	case string:
+		return containsFold(c.texts, v)
+	case []string:
+		for _, item := range v {
+			if containsFold(c.texts, item) {
+				return true
+			}
+		}
+		return false
+	case bool:
+		for _, b := range c.booleans {
+			if b == v {
+				return true
+			}
+		}
+		return false
+	case float64:
+		if len(c.numbers) > 0 {
+			found := false
+			for _, n := range c.numbers {
+				found = found || n == v
+			}
+			if !found {
+				return false
+			}
+		}
+		return (c.min == nil || v >= *c.min) && (c.max == nil || v <= *c.max)
+	}
+
+	return false
+}
+
+func containsFold(values []string, v string) bool {
+	for _, item := range values {
+		if strings.EqualFold(item, v) {
+			return true
+		}
+	}
+	return false
+}
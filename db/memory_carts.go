@@ -0,0 +1,443 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"vayer-electric-backend/structs"
+)
+
+type memoryCart struct {
+	structs.Cart
+	lines []memoryCartLine
+}
+
+type memoryCartLine struct {
+	id        int64
+	productId int64
+	variantId int64
+	quantity  int64
+}
+
+// Same as cartLookup, the caller holds the lock
+func (s *MemoryStore) cartIndex(token string, customerId string) int {
+	for i, c := range s.carts {
+		if c.Token == token && (c.CustomerId == "" || c.CustomerId == customerId) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *MemoryStore) customerCartIndex(customerId string) int {
+	for i, c := range s.carts {
+		if c.CustomerId != "" && c.CustomerId == customerId {
+			return i
+		}
+	}
+	return -1
+}
+
+func (c *memoryCart) lineIndex(productId int64, variantId int64) int {
+	for i, line := range c.lines {
+		if line.productId == productId && line.variantId == variantId {
+			return i
+		}
+	}
+	return -1
+}
+
+// Copies the cart so its lines can be changed without changing c
+func (c memoryCart) clone() memoryCart {
+	c.lines = append([]memoryCartLine(nil), c.lines...)
+	return c
+}
+
+// Same as commitCart, the changed cart replaces cart only when it can be priced, like the rollback would
+func (s *MemoryStore) commitCart(cart *memoryCart, changed memoryCart) (structs.Cart, error) {
+	changed.UpdatedAt = formatTimestamp(time.Now())
+	priced, err := s.pricedCart(changed)
+
+	if err != nil {
+		return structs.Cart{}, err
+	}
+
+	*cart = changed
+	return priced, nil
+}
+
+// Same as loadCartLines
+func (s *MemoryStore) pricedCart(c memoryCart) (structs.Cart, error) {
+	cart := c.Cart
+	cart.Lines = make([]structs.CartLine, 0, len(c.lines))
+	table := s.taxTable()
+
+	for _, line := range c.lines {
+		i := s.productIndex(line.productId)
+		p := s.products[i]
+
+		priced := structs.CartLine{
+			Id:        line.id,
+			ProductId: p.Id,
+			Name:      p.Name,
+			Sku:       p.Sku,
+			ImageUrl:  p.ImageUrl,
+			Quantity:  line.quantity,
+			UnitPrice: p.Price,
+			Available: s.availableStock(i),
+		}
+
+		if line.variantId != 0 {
+			j := s.variantIndex(p.Id, line.variantId)
+			v := s.variants[j]
+
+			priced.VariantId = v.Id
+			priced.Sku = v.Sku
+			priced.UnitPrice = v.Price
+			priced.Available = s.variantAvailableStock(j, time.Now())
+
+			if v.ImageUrl != "" {
+				priced.ImageUrl = v.ImageUrl
+			}
+		}
+
+		if err := priceCartLine(&priced, table, p.TaxClassId, p.SubcategoryId, cart.Region); err != nil {
+			return structs.Cart{}, err
+		}
+
+		cart.Lines = append(cart.Lines, priced)
+	}
+
+	if err := totalCart(&cart); err != nil {
+		return structs.Cart{}, err
+	}
+
+	return cart, nil
+}
+
+// Same as checkCartStock
+func (s *MemoryStore) checkCartStock(productId int64, variantId int64, quantity int64) error {
+	i := s.productIndex(productId)
+	if i < 0 {
+		return NotFound("product")
+	}
+
+	available := s.availableStock(i)
+
+	if variantId != 0 {
+		j := s.variantIndex(productId, variantId)
+		if j < 0 {
+			return NotFound("product_variant")
+		}
+
+		available = s.variantAvailableStock(j, time.Now())
+	}
+
+	if quantity > available {
+		return insufficientAvailable(available)
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) newCart(customerId string, region string, now time.Time) (memoryCart, error) {
+	token, err := newAccessToken()
+
+	if err != nil {
+		return memoryCart{}, err
+	}
+
+	s.nextCartId++
+
+	return memoryCart{Cart: structs.Cart{
+		Id:         s.nextCartId,
+		Token:      token,
+		CustomerId: customerId,
+		Region:     region,
+		CreatedAt:  formatTimestamp(now),
+		UpdatedAt:  formatTimestamp(now),
+	}}, nil
+}
+
+// ON DELETE CASCADE of cart_line.product_id
+func (s *MemoryStore) deleteCartLines(productId int64) {
+	for i := range s.carts {
+		kept := s.carts[i].lines[:0]
+		for _, line := range s.carts[i].lines {
+			if line.productId != productId {
+				kept = append(kept, line)
+			}
+		}
+		s.carts[i].lines = kept
+	}
+}
+
+// ON DELETE CASCADE of cart_line.variant_id
+func (s *MemoryStore) deleteVariantCartLines(variantId int64) {
+	for i := range s.carts {
+		kept := s.carts[i].lines[:0]
+		for _, line := range s.carts[i].lines {
+			if line.variantId != variantId {
+				kept = append(kept, line)
+			}
+		}
+		s.carts[i].lines = kept
+	}
+}
+
+func (s *MemoryStore) InsertCart(ctx context.Context, customerId string, region string) (structs.Cart, error) {
+	region, err := validateRegion(region)
+
+	if err != nil {
+		return structs.Cart{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.Cart{}, err
+	}
+
+	if err := checkColumns(0, customerId); err != nil {
+		return structs.Cart{}, err
+	}
+
+	if i := s.customerCartIndex(customerId); i >= 0 {
+		return s.pricedCart(s.carts[i])
+	}
+
+	cart, err := s.newCart(customerId, region, time.Now())
+
+	if err != nil {
+		return structs.Cart{}, err
+	}
+
+	s.carts = append(s.carts, cart)
+
+	return s.pricedCart(cart)
+}
+
+func (s *MemoryStore) GetCart(ctx context.Context, token string, customerId string) (structs.Cart, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.Cart{}, err
+	}
+
+	i := s.cartIndex(token, customerId)
+	if i < 0 {
+		return structs.Cart{}, NotFound("cart")
+	}
+
+	return s.pricedCart(s.carts[i])
+}
+
+func (s *MemoryStore) SetCartRegion(ctx context.Context, token string, customerId string, region string) (structs.Cart, error) {
+	region, err := validateRegion(region)
+
+	if err != nil {
+		return structs.Cart{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.Cart{}, err
+	}
+
+	i := s.cartIndex(token, customerId)
+	if i < 0 {
+		return structs.Cart{}, NotFound("cart")
+	}
+
+	changed := s.carts[i].clone()
+	changed.Region = region
+
+	return s.commitCart(&s.carts[i], changed)
+}
+
+func (s *MemoryStore) AddCartLine(ctx context.Context, token string, customerId string, productId int64, variantId int64, quantity int64) (structs.Cart, error) {
+	if err := validateCartQuantity(quantity); err != nil {
+		return structs.Cart{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.Cart{}, err
+	}
+
+	i := s.cartIndex(token, customerId)
+	if i < 0 {
+		return structs.Cart{}, NotFound("cart")
+	}
+
+	cart := &s.carts[i]
+	j := cart.lineIndex(productId, variantId)
+
+	current := int64(0)
+	if j >= 0 {
+		current = cart.lines[j].quantity
+	}
+
+	if err := s.checkCartStock(productId, variantId, current+quantity); err != nil {
+		return structs.Cart{}, err
+	}
+
+	changed := cart.clone()
+
+	if j >= 0 {
+		changed.lines[j].quantity += quantity
+	} else {
+		s.nextCartLineId++
+		changed.lines = append(changed.lines, memoryCartLine{id: s.nextCartLineId, productId: productId, variantId: variantId, quantity: quantity})
+	}
+
+	return s.commitCart(cart, changed)
+}
+
+func (s *MemoryStore) UpdateCartLine(ctx context.Context, token string, customerId string, lineId int, quantity int64) (structs.Cart, error) {
+	if err := validateCartQuantity(quantity); err != nil {
+		return structs.Cart{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.Cart{}, err
+	}
+
+	i := s.cartIndex(token, customerId)
+	if i < 0 {
+		return structs.Cart{}, NotFound("cart")
+	}
+
+	cart := &s.carts[i]
+
+	for j, line := range cart.lines {
+		if line.id != int64(lineId) {
+			continue
+		}
+
+		if err := s.checkCartStock(line.productId, line.variantId, quantity); err != nil {
+			return structs.Cart{}, err
+		}
+
+		changed := cart.clone()
+		changed.lines[j].quantity = quantity
+
+		return s.commitCart(cart, changed)
+	}
+
+	return structs.Cart{}, NotFound("cart_line")
+}
+
+func (s *MemoryStore) DeleteCartLine(ctx context.Context, token string, customerId string, lineId int) (structs.Cart, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.Cart{}, err
+	}
+
+	i := s.cartIndex(token, customerId)
+	if i < 0 {
+		return structs.Cart{}, NotFound("cart")
+	}
+
+	cart := &s.carts[i]
+
+	for j, line := range cart.lines {
+		if line.id == int64(lineId) {
+			cart.lines = append(cart.lines[:j], cart.lines[j+1:]...)
+			cart.UpdatedAt = formatTimestamp(time.Now())
+
+			return s.pricedCart(*cart)
+		}
+	}
+
+	return structs.Cart{}, NotFound("cart_line")
+}
+
+func (s *MemoryStore) MergeCart(ctx context.Context, token string, customerId string) (structs.Cart, error) {
+	if customerId == "" {
+		return structs.Cart{}, ValidationError("only a customer can merge a cart into theirs")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.Cart{}, err
+	}
+
+	if err := checkColumns(0, customerId); err != nil {
+		return structs.Cart{}, err
+	}
+
+	i := s.cartIndex(token, customerId)
+	if i < 0 {
+		return structs.Cart{}, NotFound("cart")
+	}
+
+	source := s.carts[i]
+	now := time.Now()
+
+	if source.CustomerId == customerId {
+		s.carts[i].UpdatedAt = formatTimestamp(now)
+		return s.pricedCart(s.carts[i])
+	}
+
+	// Lines are merged up front so nothing is merged when the merged cart can't be priced, like the rollback would
+	merged := memoryCart{Cart: source.Cart}
+	if j := s.customerCartIndex(customerId); j >= 0 {
+		merged = s.carts[j].clone()
+		merged.Region = source.Region
+	}
+
+	for _, line := range source.lines {
+		if k := merged.lineIndex(line.productId, line.variantId); k >= 0 {
+			merged.lines[k].quantity += line.quantity
+		} else {
+			s.nextCartLineId++
+			merged.lines = append(merged.lines, memoryCartLine{id: s.nextCartLineId, productId: line.productId, variantId: line.variantId, quantity: line.quantity})
+		}
+	}
+
+	if _, err := s.pricedCart(merged); err != nil {
+		return structs.Cart{}, err
+	}
+
+	s.carts = append(s.carts[:i], s.carts[i+1:]...)
+
+	j := s.customerCartIndex(customerId)
+	if j < 0 {
+		cart, err := s.newCart(customerId, source.Region, now)
+
+		if err != nil {
+			return structs.Cart{}, err
+		}
+
+		s.carts = append(s.carts, cart)
+		j = len(s.carts) - 1
+	}
+
+	target := &s.carts[j]
+	target.Region = source.Region
+
+	for k := range s.reservations {
+		if s.reservations[k].CartId == source.Id {
+			s.reservations[k].CartId = target.Id
+		}
+	}
+
+	target.lines = merged.lines
+	target.UpdatedAt = formatTimestamp(now)
+
+	return s.pricedCart(*target)
+}
@@ -0,0 +1,206 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"vayer-electric-backend/structs"
+)
+
+// Returns a copy of p carrying its gallery
+func (s *MemoryStore) withImages(p structs.Product) structs.Product {
+	p.Images = make([]structs.ProductImage, 0, len(s.productImages[p.Id]))
+	p.Images = append(p.Images, s.productImages[p.Id]...)
+	return p
+}
+
+// Appends an image to a gallery, same rules as insertProductImage
+func (s *MemoryStore) appendProductImage(img structs.ProductImage) structs.ProductImage {
+	if s.productImages == nil {
+		s.productImages = make(map[int64][]structs.ProductImage)
+	}
+
+	images := s.productImages[img.ProductId]
+
+	hasPrimary := false
+	for _, existing := range images {
+		hasPrimary = hasPrimary || existing.Primary
+	}
+
+	s.nextImageId++
+	img.Id = s.nextImageId
+	img.AltText = strings.TrimSpace(img.AltText)
+	img.Position = len(images)
+	img.Primary = img.Primary || !hasPrimary
+	img.CreatedAt = formatTimestamp(time.Now())
+
+	if img.Primary {
+		for i := range images {
+			images[i].Primary = false
+		}
+	}
+
+	s.productImages[img.ProductId] = append(images, img)
+	s.syncPrimaryImage(img.ProductId)
+
+	return img
+}
+
+// Same as syncPrimaryImage, product.image_url follows the primary image
+func (s *MemoryStore) syncPrimaryImage(productId int64) {
+	i := s.productIndex(productId)
+	if i < 0 {
+		return
+	}
+
+	s.products[i].ImageUrl = ""
+	for _, img := range s.productImages[productId] {
+		if img.Primary {
+			s.products[i].ImageUrl = img.ImageUrl
+		}
+	}
+}
+
+func (s *MemoryStore) productImageIndex(productId int64, id int64) int {
+	for i, img := range s.productImages[productId] {
+		if img.Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *MemoryStore) GetProductImages(ctx context.Context, productId int) ([]structs.ProductImage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if s.productIndex(int64(productId)) < 0 {
+		return nil, NotFound("product")
+	}
+
+	return append(make([]structs.ProductImage, 0), s.productImages[int64(productId)]...), nil
+}
+
+func (s *MemoryStore) InsertProductImage(ctx context.Context, img structs.ProductImage) (structs.ProductImage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.ProductImage{}, err
+	}
+
+	if s.productIndex(img.ProductId) < 0 {
+		return structs.ProductImage{}, NotFound("product")
+	}
+
+	if err := checkColumns(0, img.ImageUrl, img.AltText); err != nil {
+		return structs.ProductImage{}, err
+	}
+
+	return s.appendProductImage(img), nil
+}
+
+func (s *MemoryStore) UpdateProductImage(ctx context.Context, img structs.ProductImage) (structs.ProductImage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.ProductImage{}, err
+	}
+
+	if s.productIndex(img.ProductId) < 0 {
+		return structs.ProductImage{}, NotFound("product")
+	}
+
+	if err := checkColumns(0, img.AltText); err != nil {
+		return structs.ProductImage{}, err
+	}
+
+	i := s.productImageIndex(img.ProductId, img.Id)
+	if i < 0 {
+		return structs.ProductImage{}, NotFound("product_image")
+	}
+
+	images := s.productImages[img.ProductId]
+
+	if img.Primary {
+		for j := range images {
+			images[j].Primary = j == i
+		}
+	}
+
+	images[i].AltText = strings.TrimSpace(img.AltText)
+	s.syncPrimaryImage(img.ProductId)
+
+	return images[i], nil
+}
+
+func (s *MemoryStore) ReorderProductImages(ctx context.Context, productId int, ids []int64) ([]structs.ProductImage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if s.productIndex(int64(productId)) < 0 {
+		return nil, NotFound("product")
+	}
+
+	images := s.productImages[int64(productId)]
+
+	if err := validateImageOrder(images, ids); err != nil {
+		return nil, err
+	}
+
+	ordered := make([]structs.ProductImage, 0, len(images))
+	for position, id := range ids {
+		img := images[s.productImageIndex(int64(productId), id)]
+		img.Position = position
+		ordered = append(ordered, img)
+	}
+
+	s.productImages[int64(productId)] = ordered
+
+	return append(make([]structs.ProductImage, 0), ordered...), nil
+}
+
+func (s *MemoryStore) DeleteProductImage(ctx context.Context, productId int, imageId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if s.productIndex(int64(productId)) < 0 {
+		return NotFound("product")
+	}
+
+	i := s.productImageIndex(int64(productId), int64(imageId))
+	if i < 0 {
+		return NotFound("product_image")
+	}
+
+	images := s.productImages[int64(productId)]
+	deleted := images[i]
+
+	images = append(images[:i], images[i+1:]...)
+	for j := i; j < len(images); j++ {
+		images[j].Position--
+	}
+
+	if deleted.Primary && len(images) > 0 {
+		images[0].Primary = true
+	}
+
+	s.productImages[int64(productId)] = images
+	s.syncPrimaryImage(int64(productId))
+
+	return nil
+}
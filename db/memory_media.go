@@ -0,0 +1,131 @@
+package db
+
+import (
+	"context"
+)
+
+func (s *MemoryStore) SetCategoryImage(ctx context.Context, id int, imageUrl string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	if err := checkColumns(0, imageUrl); err != nil {
+		return "", err
+	}
+
+	i := s.categoryIndex(int64(id))
+	if i < 0 {
+		return "", NotFound("category")
+	}
+
+	previous := s.categories[i].ImageUrl
+	s.categories[i].ImageUrl = imageUrl
+
+	return previous, nil
+}
+
+func (s *MemoryStore) SetSubcategoryImage(ctx context.Context, id int, imageUrl string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	if err := checkColumns(0, imageUrl); err != nil {
+		return "", err
+	}
+
+	i := s.subcategoryIndex(int64(id))
+	if i < 0 {
+		return "", NotFound("subcategory")
+	}
+
+	previous := s.subcategories[i].ImageUrl
+	s.subcategories[i].ImageUrl = imageUrl
+
+	return previous, nil
+}
+
+func (s *MemoryStore) ImageReferenced(ctx context.Context, imageUrl string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	for _, p := range s.products {
+		if p.ImageUrl == imageUrl {
+			return true, nil
+		}
+	}
+
+	for _, images := range s.productImages {
+		for _, img := range images {
+			if img.ImageUrl == imageUrl {
+				return true, nil
+			}
+		}
+	}
+
+	for _, v := range s.variants {
+		if v.ImageUrl == imageUrl {
+			return true, nil
+		}
+	}
+
+	for _, c := range s.categories {
+		if c.ImageUrl == imageUrl {
+			return true, nil
+		}
+	}
+
+	for _, c := range s.subcategories {
+		if c.ImageUrl == imageUrl {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (s *MemoryStore) ImageReferences(ctx context.Context) (map[string]bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	references := make(map[string]bool)
+
+	for _, p := range s.products {
+		references[p.ImageUrl] = true
+	}
+
+	for _, images := range s.productImages {
+		for _, img := range images {
+			references[img.ImageUrl] = true
+		}
+	}
+
+	for _, v := range s.variants {
+		references[v.ImageUrl] = true
+	}
+
+	for _, c := range s.categories {
+		references[c.ImageUrl] = true
+	}
+
+	for _, c := range s.subcategories {
+		references[c.ImageUrl] = true
+	}
+
+	delete(references, "")
+
+	return references, nil
+}
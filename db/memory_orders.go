@@ -0,0 +1,415 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"vayer-electric-backend/money"
+	"vayer-electric-backend/structs"
+)
+
+type memoryOrder struct {
+	structs.Order
+	transitions []structs.OrderTransition
+}
+
+func (s *MemoryStore) orderIndex(id int64) int {
+	for i, o := range s.orders {
+		if o.Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Same as loadOrder
+func (o memoryOrder) withHistory() structs.Order {
+	order := o.Order
+	order.Lines = append(make([]structs.OrderLine, 0, len(o.Lines)), o.Lines...)
+	order.Transitions = append(make([]structs.OrderTransition, 0, len(o.transitions)), o.transitions...)
+	return order
+}
+
+func (s *MemoryStore) appendOrderTransition(o *memoryOrder, t structs.OrderTransition, now time.Time) {
+	s.nextOrderTransitionId++
+	t.Id = s.nextOrderTransitionId
+	t.CreatedAt = formatTimestamp(now)
+	o.transitions = append(o.transitions, t)
+}
+
+// Same as allocateStock, the caller checked there's enough stock on hand
+func (s *MemoryStore) allocateStock(productId int64, quantity int64, reason string, reference string, user string) error {
+	stock := s.productStockByLocation(productId)
+
+	// ORDER BY l.is_default DESC, l.id
+	locations := make([]structs.LocationStock, 0, len(stock))
+	for _, ls := range stock {
+		if ls.LocationId == s.defaultLocation().Id {
+			locations = append([]structs.LocationStock{ls}, locations...)
+		} else {
+			locations = append(locations, ls)
+		}
+	}
+
+	for _, ls := range locations {
+		if quantity == 0 {
+			break
+		}
+
+		take := ls.Quantity
+		if take > quantity {
+			take = quantity
+		}
+
+		out := structs.StockMovement{ProductId: productId, LocationId: ls.LocationId, Reason: reason, Quantity: -take, User: user, Reference: reference}
+		if _, err := s.applyStockMovement(out); err != nil {
+			return err
+		}
+
+		quantity -= take
+	}
+
+	if quantity > 0 {
+		return Conflict(fmt.Sprintf("insufficient stock, %d short", quantity))
+	}
+
+	return nil
+}
+
+// Same as restockOrder
+func (s *MemoryStore) restockOrder(orderId int64, status string, user string) error {
+	var sales []structs.StockMovement
+	for _, m := range s.stockMovements {
+		if m.Reason != "sale" || m.Reference != orderReference(orderId) || s.productIndex(m.ProductId) < 0 {
+			continue
+		}
+
+		if m.VariantId == 0 || s.variantIndex(m.ProductId, m.VariantId) >= 0 {
+			sales = append(sales, m)
+		}
+	}
+
+	for _, sale := range sales {
+		restock := structs.StockMovement{ProductId: sale.ProductId, VariantId: sale.VariantId, LocationId: sale.LocationId, Reason: "return", Quantity: -sale.Quantity, User: user, Reference: orderReference(orderId) + " " + status}
+		if _, err := s.applyStockMovement(restock); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Same as transitionOrder
+func (s *MemoryStore) transitionOrder(o *memoryOrder, t structs.OrderTransition) error {
+	if err := checkOrderTransition(o.Status, &t); err != nil {
+		return err
+	}
+
+	if err := checkColumns(0, t.User, t.Note); err != nil {
+		return err
+	}
+
+	if t.To == "cancelled" {
+		for _, p := range s.payments {
+			for _, status := range livePaymentStatuses {
+				if p.OrderId == o.Id && p.Status == status {
+					return Conflict("the order has a payment going through, it has to be refunded instead of cancelled")
+				}
+			}
+		}
+	}
+
+	if orderReturnsStock(t.From, t.To) {
+		if err := s.restockOrder(o.Id, t.To, t.User); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+
+	o.Status = t.To
+	o.UpdatedAt = formatTimestamp(now)
+	s.appendOrderTransition(o, t, now)
+
+	return nil
+}
+
+// ON DELETE SET NULL of order_line.product_id, the variants of the product go with it
+func (s *MemoryStore) unlinkOrderLines(productId int64) {
+	for i := range s.orders {
+		for j := range s.orders[i].Lines {
+			if s.orders[i].Lines[j].ProductId == productId {
+				s.orders[i].Lines[j].ProductId = 0
+				s.orders[i].Lines[j].VariantId = 0
+			}
+		}
+	}
+}
+
+// ON DELETE SET NULL of order_line.variant_id
+func (s *MemoryStore) unlinkVariantOrderLines(variantId int64) {
+	for i := range s.orders {
+		for j := range s.orders[i].Lines {
+			if s.orders[i].Lines[j].VariantId == variantId {
+				s.orders[i].Lines[j].VariantId = 0
+			}
+		}
+	}
+}
+
+func (s *MemoryStore) Checkout(ctx context.Context, token string, customerId string, reservationIds []int64) (structs.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.Order{}, err
+	}
+
+	c := s.cartIndex(token, customerId)
+	if c < 0 {
+		return structs.Order{}, NotFound("cart")
+	}
+
+	cart := &s.carts[c]
+
+	if len(cart.lines) == 0 {
+		return structs.Order{}, ValidationError("the cart is empty")
+	}
+
+	orderToken, err := newAccessToken()
+
+	if err != nil {
+		return structs.Order{}, err
+	}
+
+	for _, r := range s.reservations {
+		if containsId(reservationIds, r.Id) && r.CartId != cart.Id {
+			return structs.Order{}, foreignReservation(r.Id)
+		}
+	}
+
+	// Reservations are released up front and put back if the checkout fails, like the rollback would
+	held := s.reservations
+	s.reservations = make([]memoryReservation, 0, len(held))
+	for _, r := range held {
+		if !containsId(reservationIds, r.Id) {
+			s.reservations = append(s.reservations, r)
+		}
+	}
+
+	// ORDER BY product_id, variant_id NULLS FIRST
+	lines := append([]memoryCartLine(nil), cart.lines...)
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].productId != lines[j].productId {
+			return lines[i].productId < lines[j].productId
+		}
+		return lines[i].variantId < lines[j].variantId
+	})
+
+	// Stock is checked for every line before any is taken, the memory store has no rollback
+	for _, line := range lines {
+		i := s.productIndex(line.productId)
+		sku, available := s.products[i].Sku, s.availableStock(i)
+
+		if line.variantId != 0 {
+			j := s.variantIndex(line.productId, line.variantId)
+			sku, available = s.variants[j].Sku, s.variantAvailableStock(j, time.Now())
+		}
+
+		if line.quantity > available {
+			s.reservations = held
+			return structs.Order{}, Conflict(fmt.Sprintf("insufficient stock of %s, %d available", sku, available))
+		}
+	}
+
+	// Same goes for the totals, the columns of an order hold up to money.Max
+	if priced, err := s.pricedCart(*cart); err != nil || priced.Total > money.Max {
+		s.reservations = held
+		return structs.Order{}, cartTotalOutOfRange()
+	}
+
+	now := time.Now()
+
+	s.nextOrderId++
+	order := memoryOrder{Order: structs.Order{
+		Id:         s.nextOrderId,
+		Token:      orderToken,
+		CustomerId: customerId,
+		Status:     "pending",
+		Region:     cart.Region,
+		Lines:      make([]structs.OrderLine, 0, len(lines)),
+		CreatedAt:  formatTimestamp(now),
+		UpdatedAt:  formatTimestamp(now),
+	}}
+
+	table := s.taxTable()
+
+	for _, line := range lines {
+		i := s.productIndex(line.productId)
+		p := s.products[i]
+		sku, price := p.Sku, p.Price
+
+		// Variant stock has no locations and no reorder point
+		if line.variantId != 0 {
+			v := s.variants[s.variantIndex(p.Id, line.variantId)]
+			sku, price = v.Sku, v.Price
+
+			sale := structs.StockMovement{ProductId: p.Id, VariantId: v.Id, Reason: "sale", Quantity: -line.quantity, User: customerId, Reference: orderReference(order.Id)}
+			if _, err := s.applyStockMovement(sale); err != nil {
+				return structs.Order{}, err
+			}
+		} else {
+			before := s.availableStock(i)
+
+			if err := s.allocateStock(p.Id, line.quantity, "sale", orderReference(order.Id), customerId); err != nil {
+				return structs.Order{}, err
+			}
+
+			s.recordStockAlert(i, before)
+		}
+
+		priced := structs.CartLine{UnitPrice: price, Quantity: line.quantity}
+		if err := priceCartLine(&priced, table, p.TaxClassId, p.SubcategoryId, cart.Region); err != nil {
+			return structs.Order{}, err
+		}
+
+		if order.Subtotal, err = order.Subtotal.Add(priced.LineTotal); err != nil {
+			return structs.Order{}, cartTotalOutOfRange()
+		}
+
+		if order.TaxTotal, err = order.TaxTotal.Add(priced.Tax.Tax); err != nil {
+			return structs.Order{}, cartTotalOutOfRange()
+		}
+
+		s.nextOrderLineId++
+		order.Lines = append(order.Lines, structs.OrderLine{
+			Id:        s.nextOrderLineId,
+			ProductId: p.Id,
+			VariantId: line.variantId,
+			Name:      p.Name,
+			Sku:       sku,
+			UnitPrice: price,
+			Quantity:  line.quantity,
+			LineTotal: priced.LineTotal,
+			Tax:       priced.Tax,
+		})
+		order.ItemCount += line.quantity
+	}
+
+	if order.Total, err = order.Subtotal.Add(order.TaxTotal); err != nil {
+		return structs.Order{}, cartTotalOutOfRange()
+	}
+
+	s.appendOrderTransition(&order, structs.OrderTransition{To: "pending", User: customerId}, now)
+	s.orders = append(s.orders, order)
+
+	cart.lines = nil
+	cart.UpdatedAt = formatTimestamp(now)
+
+	return order.withHistory(), nil
+}
+
+func containsId(ids []int64, id int64) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *MemoryStore) GetOrderById(ctx context.Context, id int) (structs.Order, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.Order{}, err
+	}
+
+	i := s.orderIndex(int64(id))
+	if i < 0 {
+		return structs.Order{}, NotFound("order")
+	}
+
+	return s.orders[i].withHistory(), nil
+}
+
+func (s *MemoryStore) GetOrderByToken(ctx context.Context, token string, customerId string) (structs.Order, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.Order{}, err
+	}
+
+	for _, o := range s.orders {
+		if o.Token == token && (o.CustomerId == "" || o.CustomerId == customerId) {
+			return o.withHistory(), nil
+		}
+	}
+
+	return structs.Order{}, NotFound("order")
+}
+
+func (s *MemoryStore) GetOrders(ctx context.Context, customerId string, status string, limit int, cursor string) (structs.OrderPage, error) {
+	before, err := parseIdCursor(cursor)
+
+	if err != nil {
+		return structs.OrderPage{}, err
+	}
+
+	if status != "" {
+		if err := validateOrderStatus(status); err != nil {
+			return structs.OrderPage{}, err
+		}
+	}
+
+	if limit <= 0 || limit > MaxPageSize {
+		limit = DefaultPageSize
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.OrderPage{}, err
+	}
+
+	// ORDER BY id DESC LIMIT limit + 1, orders are appended in id order
+	orders := make([]structs.Order, 0, limit)
+	for i := len(s.orders) - 1; i >= 0 && len(orders) <= limit; i-- {
+		o := s.orders[i]
+
+		if (customerId == "" || o.CustomerId == customerId) && (status == "" || o.Status == status) && (before == 0 || o.Id < before) {
+			order := o.withHistory()
+			order.Transitions = nil
+			orders = append(orders, order)
+		}
+	}
+
+	return newOrderPage(orders, limit), nil
+}
+
+func (s *MemoryStore) TransitionOrder(ctx context.Context, id int, t structs.OrderTransition) (structs.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.Order{}, err
+	}
+
+	i := s.orderIndex(int64(id))
+	if i < 0 {
+		return structs.Order{}, NotFound("order")
+	}
+
+	o := &s.orders[i]
+
+	if err := s.transitionOrder(o, t); err != nil {
+		return structs.Order{}, err
+	}
+
+	return o.withHistory(), nil
+}
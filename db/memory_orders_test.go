@@ -0,0 +1,156 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"vayer-electric-backend/structs"
+)
+
+// Checks out a cart with quantity of product 1
+func checkoutTestOrder(t *testing.T, s *MemoryStore, quantity int64) structs.Order {
+	t.Helper()
+
+	ctx := context.Background()
+
+	cart, err := s.InsertCart(ctx, "", "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.AddCartLine(ctx, cart.Token, "", 1, 0, quantity); err != nil {
+		t.Fatal(err)
+	}
+
+	order, err := s.Checkout(ctx, cart.Token, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return order
+}
+
+func transitionTestOrder(t *testing.T, s *MemoryStore, order structs.Order, statuses ...string) {
+	t.Helper()
+
+	for _, status := range statuses {
+		if _, err := s.TransitionOrder(context.Background(), int(order.Id), structs.OrderTransition{To: status, User: "admin"}); err != nil {
+			t.Fatalf("moving order %d to %s: %s", order.Id, status, err)
+		}
+	}
+}
+
+func assertProductStock(t *testing.T, s *MemoryStore, want int64) {
+	t.Helper()
+
+	product, err := s.GetProductById(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if product.CurrentInventory != want {
+		t.Errorf("current_inventory = %d, want %d", product.CurrentInventory, want)
+	}
+}
+
+func TestTransitionOrderRestocks(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []string
+		stock    int64
+	}{
+		{"cancelled", []string{"cancelled"}, 10},
+		{"refunded when paid", []string{"paid", "refunded"}, 10},
+		{"refunded while picking", []string{"paid", "picking", "refunded"}, 10},
+		{"refunded after shipping", []string{"paid", "picking", "shipped", "refunded"}, 6},
+		{"shipped", []string{"paid", "picking", "shipped"}, 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestMemoryStore(t, "THHN-12")
+			order := checkoutTestOrder(t, s, 4)
+
+			assertProductStock(t, s, 6)
+			transitionTestOrder(t, s, order, tt.statuses...)
+			assertProductStock(t, s, tt.stock)
+		})
+	}
+}
+
+// A full refund reported by the provider refunds the order, which hasn't shipped so its stock goes back
+func TestPaymentRefundRestocks(t *testing.T) {
+	ctx := context.Background()
+	s := newTestMemoryStore(t, "THHN-12")
+	order := checkoutTestOrder(t, s, 4)
+
+	payment, err := s.InsertPayment(ctx, structs.Payment{OrderId: order.Id, Provider: "fake", Amount: order.Total})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, status := range []string{"authorized", "captured", "refunded"} {
+		if _, err := s.UpdatePayment(ctx, payment.Id, structs.PaymentEvent{Status: status}); err != nil {
+			t.Fatalf("payment %s: %s", status, err)
+		}
+	}
+
+	order, err = s.GetOrderById(ctx, int(order.Id))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if order.Status != "refunded" {
+		t.Errorf("order is %s, want refunded", order.Status)
+	}
+
+	assertProductStock(t, s, 10)
+}
+
+// Money taken or about to be taken has to go back through a refund, cancelling would keep it
+func TestCancelOrderWithLivePayment(t *testing.T) {
+	ctx := context.Background()
+
+	for _, statuses := range [][]string{{}, {"authorized"}, {"authorized", "captured"}} {
+		s := newTestMemoryStore(t, "THHN-12")
+		order := checkoutTestOrder(t, s, 4)
+
+		payment, err := s.InsertPayment(ctx, structs.Payment{OrderId: order.Id, Provider: "fake", Amount: order.Total})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, status := range statuses {
+			if _, err := s.UpdatePayment(ctx, payment.Id, structs.PaymentEvent{Status: status}); err != nil {
+				t.Fatalf("payment %s: %s", status, err)
+			}
+		}
+
+		_, err = s.TransitionOrder(ctx, int(order.Id), structs.OrderTransition{To: "cancelled", User: "admin"})
+		if !errors.Is(err, ErrConflict) {
+			t.Errorf("cancelling with a %v payment: error = %v, want a conflict", statuses, err)
+		}
+
+		assertProductStock(t, s, 6)
+	}
+}
+
+// A declined payment doesn't stand in the way
+func TestCancelOrderWithDeclinedPayment(t *testing.T) {
+	ctx := context.Background()
+	s := newTestMemoryStore(t, "THHN-12")
+	order := checkoutTestOrder(t, s, 4)
+
+	payment, err := s.InsertPayment(ctx, structs.Payment{OrderId: order.Id, Provider: "fake", Amount: order.Total})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.UpdatePayment(ctx, payment.Id, structs.PaymentEvent{Status: "declined", Message: "insufficient funds"}); err != nil {
+		t.Fatal(err)
+	}
+
+	transitionTestOrder(t, s, order, "cancelled")
+	assertProductStock(t, s, 10)
+}
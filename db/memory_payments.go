@@ -0,0 +1,231 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"vayer-electric-backend/structs"
+
+	"github.com/lib/pq"
+)
+
+func (s *MemoryStore) paymentIndex(id int64) int {
+	for i, p := range s.payments {
+		if p.Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// UNIQUE (provider, event_id) of payment_event
+func paymentEventKey(provider string, eventId string) string {
+	return provider + "\x00" + eventId
+}
+
+func (s *MemoryStore) recordPaymentEvent(provider string, eventId string) {
+	if s.paymentEvents == nil {
+		s.paymentEvents = make(map[string]bool)
+	}
+	s.paymentEvents[paymentEventKey(provider, eventId)] = true
+}
+
+// Same as updatePayment, the caller holds the write lock
+func (s *MemoryStore) updatePayment(i int, e structs.PaymentEvent) (structs.Payment, bool, error) {
+	p := s.payments[i]
+
+	if err := checkColumns(0, e.Id, e.Reference, e.Message); err != nil {
+		return structs.Payment{}, false, err
+	}
+
+	if e.Id != "" && s.paymentEvents[paymentEventKey(p.Provider, e.Id)] {
+		return p, false, nil
+	}
+
+	orderStatus, err := applyPaymentEvent(&p, e)
+
+	if err != nil {
+		return structs.Payment{}, false, err
+	}
+
+	if e.Reference != "" {
+		for _, other := range s.payments {
+			if other.Id != p.Id && other.Provider == p.Provider && other.Reference == e.Reference {
+				return structs.Payment{}, false, translateError(&pq.Error{Code: "23505", Table: "payment"}, "payment")
+			}
+		}
+	}
+
+	if orderStatus != "" {
+		o := &s.orders[s.orderIndex(p.OrderId)]
+
+		if orderTransitionAllowed(o.Status, orderStatus) {
+			t := structs.OrderTransition{To: orderStatus, User: p.Provider, Note: fmt.Sprintf("payment %d %s", p.Id, p.Status)}
+
+			if err := s.transitionOrder(o, t); err != nil {
+				return structs.Payment{}, false, err
+			}
+		} else {
+			log.Warn(fmt.Sprintf("payment %d is %s but order %d is %s", p.Id, p.Status, p.OrderId, o.Status))
+		}
+	}
+
+	if e.Id != "" {
+		s.recordPaymentEvent(p.Provider, e.Id)
+	}
+
+	p.UpdatedAt = formatTimestamp(time.Now())
+	s.payments[i] = p
+
+	return p, true, nil
+}
+
+func (s *MemoryStore) InsertPayment(ctx context.Context, p structs.Payment) (structs.Payment, error) {
+	if p.Amount <= 0 {
+		return structs.Payment{}, ValidationError("amount must be positive")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.Payment{}, err
+	}
+
+	if err := checkColumns(0, p.Provider); err != nil {
+		return structs.Payment{}, err
+	}
+
+	i := s.orderIndex(p.OrderId)
+	if i < 0 {
+		return structs.Payment{}, NotFound("order")
+	}
+
+	if status := s.orders[i].Status; status != "pending" {
+		return structs.Payment{}, Conflict(fmt.Sprintf("the order is %s, only a pending order can be paid", status))
+	}
+
+	for _, other := range s.payments {
+		for _, status := range livePaymentStatuses {
+			if other.OrderId == p.OrderId && other.Status == status {
+				return structs.Payment{}, Conflict("the order already has a payment going through")
+			}
+		}
+	}
+
+	now := formatTimestamp(time.Now())
+
+	s.nextPaymentId++
+	created := structs.Payment{
+		Id:        s.nextPaymentId,
+		OrderId:   p.OrderId,
+		Provider:  p.Provider,
+		Status:    "pending",
+		Amount:    p.Amount,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	s.payments = append(s.payments, created)
+
+	return created, nil
+}
+
+func (s *MemoryStore) GetPaymentById(ctx context.Context, id int) (structs.Payment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.Payment{}, err
+	}
+
+	i := s.paymentIndex(int64(id))
+	if i < 0 {
+		return structs.Payment{}, NotFound("payment")
+	}
+
+	return s.payments[i], nil
+}
+
+func (s *MemoryStore) GetPayments(ctx context.Context, orderId int) ([]structs.Payment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if s.orderIndex(int64(orderId)) < 0 {
+		return nil, NotFound("order")
+	}
+
+	payments := make([]structs.Payment, 0)
+	for _, p := range s.payments {
+		if p.OrderId == int64(orderId) {
+			payments = append(payments, p)
+		}
+	}
+
+	return payments, nil
+}
+
+func (s *MemoryStore) UpdatePayment(ctx context.Context, id int64, e structs.PaymentEvent) (structs.Payment, error) {
+	if err := validatePaymentEvent(&e); err != nil {
+		return structs.Payment{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.Payment{}, err
+	}
+
+	i := s.paymentIndex(id)
+	if i < 0 {
+		return structs.Payment{}, NotFound("payment")
+	}
+
+	p, _, err := s.updatePayment(i, e)
+
+	return p, err
+}
+
+func (s *MemoryStore) ApplyPaymentEvent(ctx context.Context, provider string, e structs.PaymentEvent) (structs.Payment, error) {
+	if err := validatePaymentEvent(&e); err != nil {
+		return structs.Payment{}, err
+	}
+
+	if e.Id == "" || e.Reference == "" {
+		return structs.Payment{}, ValidationError("a payment event needs an id and a reference")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.Payment{}, err
+	}
+
+	i := -1
+	for j, p := range s.payments {
+		if p.Provider == provider && p.Reference == e.Reference {
+			i = j
+		}
+	}
+
+	if i < 0 {
+		return structs.Payment{}, NotFound("payment")
+	}
+
+	p, _, err := s.updatePayment(i, e)
+
+	if errors.Is(err, ErrConflict) || errors.Is(err, ErrValidation) {
+		log.Warn(fmt.Sprintf("ignored payment event %s: %s", e.Id, err.Error()))
+		s.recordPaymentEvent(provider, e.Id)
+		return s.payments[i], nil
+	}
+
+	return p, err
+}
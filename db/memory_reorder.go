@@ -0,0 +1,189 @@
+package db
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"vayer-electric-backend/structs"
+)
+
+type memoryStockAlert struct {
+	structs.StockAlert
+	notified  bool
+	lastError string
+}
+
+// Same as lockedAvailableStock for the product at index i, the caller holds the lock
+func (s *MemoryStore) availableStock(i int) int64 {
+	p := s.products[i]
+	return availableStock(p.CurrentInventory, s.reservedStock(p.Id, time.Now()))
+}
+
+// Same as recordStockAlert for the product at index i
+func (s *MemoryStore) recordStockAlert(i int, before int64) {
+	p := s.products[i]
+	after := s.availableStock(i)
+
+	if !crossedReorderPoint(before, after, p.ReorderPoint) {
+		return
+	}
+
+	s.nextStockAlertId++
+	s.stockAlerts = append(s.stockAlerts, memoryStockAlert{StockAlert: structs.StockAlert{
+		Id:              s.nextStockAlertId,
+		ProductId:       p.Id,
+		Available:       after,
+		ReorderPoint:    p.ReorderPoint,
+		ReorderQuantity: p.ReorderQuantity,
+		CreatedAt:       formatTimestamp(time.Now()),
+	}})
+}
+
+// ON DELETE CASCADE of stock_alert.product_id
+func (s *MemoryStore) deleteStockAlerts(productId int64) {
+	kept := s.stockAlerts[:0]
+	for _, a := range s.stockAlerts {
+		if a.ProductId != productId {
+			kept = append(kept, a)
+		}
+	}
+	s.stockAlerts = kept
+}
+
+func (s *MemoryStore) stockAlertIndex(id int64) int {
+	for i := range s.stockAlerts {
+		if s.stockAlerts[i].Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *MemoryStore) SetReorderPoint(ctx context.Context, productId int, point int64, quantity int64) error {
+	if err := validateReorderPoint(point, quantity); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	i := s.productIndex(int64(productId))
+	if i < 0 {
+		return NotFound("product")
+	}
+
+	s.products[i].ReorderPoint = point
+	s.products[i].ReorderQuantity = quantity
+
+	return nil
+}
+
+func (s *MemoryStore) GetLowStockProducts(ctx context.Context) ([]structs.LowStockProduct, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	products := make([]structs.LowStockProduct, 0)
+	for _, p := range s.products {
+		reserved := s.reservedStock(p.Id, now)
+		available := availableStock(p.CurrentInventory, reserved)
+
+		if p.ReorderPoint > 0 && available < p.ReorderPoint {
+			products = append(products, structs.LowStockProduct{
+				ProductId:        p.Id,
+				Name:             p.Name,
+				Sku:              p.Sku,
+				CurrentInventory: p.CurrentInventory,
+				Reserved:         reserved,
+				Available:        available,
+				ReorderPoint:     p.ReorderPoint,
+				ReorderQuantity:  p.ReorderQuantity,
+			})
+		}
+	}
+
+	// ORDER BY available, id
+	sort.SliceStable(products, func(i, j int) bool {
+		if products[i].Available != products[j].Available {
+			return products[i].Available < products[j].Available
+		}
+		return products[i].ProductId < products[j].ProductId
+	})
+
+	return products, nil
+}
+
+func (s *MemoryStore) GetPendingStockAlerts(ctx context.Context, limit int, maxAttempts int) ([]structs.StockAlert, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	alerts := make([]structs.StockAlert, 0)
+	for _, a := range s.stockAlerts {
+		if len(alerts) == limit {
+			break
+		}
+
+		if a.notified || a.Attempts >= maxAttempts {
+			continue
+		}
+
+		p := s.products[s.productIndex(a.ProductId)]
+		a.ProductName = p.Name
+		a.Sku = p.Sku
+
+		alerts = append(alerts, a.StockAlert)
+	}
+
+	return alerts, nil
+}
+
+func (s *MemoryStore) MarkStockAlertNotified(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	i := s.stockAlertIndex(id)
+	if i < 0 {
+		return NotFound("stock_alert")
+	}
+
+	s.stockAlerts[i].notified = true
+
+	return nil
+}
+
+func (s *MemoryStore) MarkStockAlertFailed(ctx context.Context, id int64, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	i := s.stockAlertIndex(id)
+	if i < 0 {
+		return NotFound("stock_alert")
+	}
+
+	s.stockAlerts[i].Attempts++
+	s.stockAlerts[i].lastError = message
+
+	return nil
+}
@@ -0,0 +1,192 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"vayer-electric-backend/structs"
+)
+
+type memoryReservation struct {
+	structs.Reservation
+	expires time.Time
+}
+
+// Same as reservedStock, the caller holds the lock
+func (s *MemoryStore) reservedStock(productId int64, now time.Time) int64 {
+	var reserved int64
+	for _, r := range s.reservations {
+		if r.ProductId == productId && r.VariantId == 0 && r.expires.After(now) {
+			reserved += r.Quantity
+		}
+	}
+	return reserved
+}
+
+// Same as reservedVariantStock for the variant at index i
+func (s *MemoryStore) reservedVariantStock(i int, now time.Time) int64 {
+	var reserved int64
+	for _, r := range s.reservations {
+		if r.VariantId == s.variants[i].Id && r.expires.After(now) {
+			reserved += r.Quantity
+		}
+	}
+	return reserved
+}
+
+// Same as lockedVariantAvailableStock for the variant at index i
+func (s *MemoryStore) variantAvailableStock(i int, now time.Time) int64 {
+	return availableStock(s.variants[i].CurrentInventory, s.reservedVariantStock(i, now))
+}
+
+// ON DELETE CASCADE of stock_reservation.variant_id
+func (s *MemoryStore) deleteVariantReservations(variantId int64) {
+	kept := s.reservations[:0]
+	for _, r := range s.reservations {
+		if r.VariantId != variantId {
+			kept = append(kept, r)
+		}
+	}
+	s.reservations = kept
+}
+
+// ON DELETE CASCADE of stock_reservation.product_id
+func (s *MemoryStore) deleteReservations(productId int64) {
+	kept := s.reservations[:0]
+	for _, r := range s.reservations {
+		if r.ProductId != productId {
+			kept = append(kept, r)
+		}
+	}
+	s.reservations = kept
+}
+
+func (s *MemoryStore) InsertReservation(ctx context.Context, r structs.Reservation, cartToken string, customerId string, ttl time.Duration) (structs.Reservation, error) {
+	ttl, err := validateReservation(&r, ttl)
+
+	if err != nil {
+		return structs.Reservation{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.Reservation{}, err
+	}
+
+	r.CartId = 0
+	if cartToken != "" {
+		c := s.cartIndex(cartToken, customerId)
+		if c < 0 {
+			return structs.Reservation{}, NotFound("cart")
+		}
+
+		r.CartId = s.carts[c].Id
+	}
+
+	i := s.productIndex(r.ProductId)
+	if i < 0 {
+		return structs.Reservation{}, NotFound("product")
+	}
+
+	if err := checkColumns(0, r.Reference); err != nil {
+		return structs.Reservation{}, err
+	}
+
+	now := time.Now()
+
+	var available int64
+	if r.VariantId != 0 {
+		j := s.variantIndex(r.ProductId, r.VariantId)
+		if j < 0 {
+			return structs.Reservation{}, NotFound("product_variant")
+		}
+
+		available = s.variantAvailableStock(j, now)
+	} else {
+		available = s.availableStock(i)
+	}
+
+	if r.Quantity > available {
+		return structs.Reservation{}, insufficientAvailable(available)
+	}
+
+	s.nextReservationId++
+	r.Id = s.nextReservationId
+	r.ExpiresAt = formatTimestamp(now.Add(ttl))
+	r.CreatedAt = formatTimestamp(now)
+
+	s.reservations = append(s.reservations, memoryReservation{Reservation: r, expires: now.Add(ttl)})
+
+	if r.VariantId == 0 {
+		s.recordStockAlert(i, available)
+	}
+
+	return r, nil
+}
+
+func (s *MemoryStore) GetReservations(ctx context.Context, productId int) ([]structs.Reservation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if s.productIndex(int64(productId)) < 0 {
+		return nil, NotFound("product")
+	}
+
+	now := time.Now()
+
+	reservations := make([]structs.Reservation, 0)
+	for _, r := range s.reservations {
+		if r.ProductId == int64(productId) && r.expires.After(now) {
+			reservations = append(reservations, r.Reservation)
+		}
+	}
+
+	return reservations, nil
+}
+
+func (s *MemoryStore) DeleteReservation(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	for i, r := range s.reservations {
+		if r.Id == int64(id) {
+			s.reservations = append(s.reservations[:i], s.reservations[i+1:]...)
+			return nil
+		}
+	}
+
+	return NotFound("stock_reservation")
+}
+
+func (s *MemoryStore) DeleteExpiredReservations(ctx context.Context, now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	var released int64
+
+	kept := s.reservations[:0]
+	for _, r := range s.reservations {
+		if r.expires.After(now) {
+			kept = append(kept, r)
+		} else {
+			released++
+		}
+	}
+	s.reservations = kept
+
+	return released, nil
+}
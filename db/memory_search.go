@@ -0,0 +1,150 @@
+package db
+
+import (
+	"regexp"
+	"strings"
+
+	"vayer-electric-backend/structs"
+)
+
+// Words as the 'simple' text search configuration sees them
+var searchWordPattern = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// Weights Postgres gives to A, B and C labels in ts_rank
+const (
+	searchWeightA = 1.0
+	searchWeightB = 0.4
+	searchWeightC = 0.2
+)
+
+// Terms of a search in the subset of websearch syntax the memory store understands:
+// every word must match unless prefixed with -, in which case it must not.
+type memorySearch struct {
+	include []string
+	exclude []string
+}
+
+func parseMemorySearch(search string) memorySearch {
+	var ms memorySearch
+
+	for _, field := range strings.Fields(search) {
+		excluded := strings.HasPrefix(field, "-")
+
+		for _, word := range searchWordPattern.FindAllString(strings.ToLower(field), -1) {
+			if excluded {
+				ms.exclude = append(ms.exclude, word)
+			} else {
+				ms.include = append(ms.include, word)
+			}
+		}
+	}
+
+	return ms
+}
+
+func countWords(text string, words map[string]bool) int {
+	count := 0
+	for _, w := range searchWordPattern.FindAllString(strings.ToLower(text), -1) {
+		if words[w] {
+			count++
+		}
+	}
+	return count
+}
+
+// Returns how p matches the search, nil when it doesn't
+func (ms memorySearch) match(p structs.Product) *structs.ProductMatch {
+	if len(ms.include) == 0 {
+		return nil
+	}
+
+	document := make(map[string]bool)
+	for _, text := range []string{p.Name, p.Sku, p.Brand, p.Description} {
+		for _, w := range searchWordPattern.FindAllString(strings.ToLower(text), -1) {
+			document[w] = true
+		}
+	}
+
+	for _, w := range ms.exclude {
+		if document[w] {
+			return nil
+		}
+	}
+
+	for _, w := range ms.include {
+		if !document[w] {
+			return nil
+		}
+	}
+
+	terms := make(map[string]bool)
+	for _, w := range ms.include {
+		terms[w] = true
+	}
+
+	rank := searchWeightA*float64(countWords(p.Name, terms)+countWords(p.Sku, terms)) +
+		searchWeightB*float64(countWords(p.Brand, terms)) +
+		searchWeightC*float64(countWords(p.Description, terms))
+
+	return &structs.ProductMatch{
+		Rank:    rank,
+		Name:    highlight(p.Name, terms, 0),
+		Snippet: highlight(p.Description, terms, 20),
+	}
+}
+
+// Wraps the words in terms with <b></b> like ts_headline does.
+// When maxWords is set the text is cut to that many words starting a few words before the first match.
+func highlight(text string, terms map[string]bool, maxWords int) string {
+	locations := searchWordPattern.FindAllStringIndex(text, -1)
+
+	first, last := 0, len(locations)
+	if maxWords > 0 && len(locations) > maxWords {
+		for i, loc := range locations {
+			if terms[strings.ToLower(text[loc[0]:loc[1]])] {
+				first = i
+				break
+			}
+		}
+
+		if first -= 5; first < 0 {
+			first = 0
+		}
+
+		if last = first + maxWords; last > len(locations) {
+			last = len(locations)
+			first = last - maxWords
+		}
+	}
+
+	if len(locations) == 0 {
+		return text
+	}
+
+	var b strings.Builder
+
+	start, end := 0, len(text)
+	if first > 0 {
+		start = locations[first][0]
+	}
+	if last < len(locations) {
+		end = locations[last-1][1]
+	}
+
+	cursor := start
+	for _, loc := range locations[first:last] {
+		b.WriteString(text[cursor:loc[0]])
+
+		word := text[loc[0]:loc[1]]
+		if terms[strings.ToLower(word)] {
+			b.WriteString("<b>" + word + "</b>")
+		} else {
+			b.WriteString(word)
+		}
+
+		cursor = loc[1]
+	}
+	b.WriteString(text[cursor:end])
+
+	return b.String()
+}
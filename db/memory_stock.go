@@ -0,0 +1,147 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"vayer-electric-backend/structs"
+)
+
+// Same as insertStockMovement, the caller holds the write lock
+func (s *MemoryStore) applyStockMovement(m structs.StockMovement) (structs.StockMovement, error) {
+	i := s.productIndex(m.ProductId)
+	if i < 0 {
+		return structs.StockMovement{}, NotFound("product")
+	}
+
+	if err := checkColumns(0, m.User, m.Reference); err != nil {
+		return structs.StockMovement{}, err
+	}
+
+	if m.VariantId != 0 {
+		return s.applyVariantStockMovement(m)
+	}
+
+	if m.LocationId == 0 {
+		m.LocationId = s.defaultLocation().Id
+	}
+
+	current := s.productStock[m.ProductId][m.LocationId]
+
+	balance, err := stockBalance(current, m.Quantity)
+
+	if err != nil {
+		return structs.StockMovement{}, err
+	}
+
+	if s.locationIndex(m.LocationId) < 0 {
+		return structs.StockMovement{}, foreignKeyViolation("product_stock", "product_stock_location_id_fkey")
+	}
+
+	if s.productStock == nil {
+		s.productStock = make(map[int64]map[int64]int64)
+	}
+	if s.productStock[m.ProductId] == nil {
+		s.productStock[m.ProductId] = make(map[int64]int64)
+	}
+	s.productStock[m.ProductId][m.LocationId] = balance
+
+	s.nextStockMovementId++
+	m.Id = s.nextStockMovementId
+	m.Balance = balance
+	m.CreatedAt = formatTimestamp(time.Now())
+
+	s.products[i].CurrentInventory += m.Quantity
+	s.stockMovements = append(s.stockMovements, m)
+
+	return m, nil
+}
+
+// Same as insertVariantStockMovement
+func (s *MemoryStore) applyVariantStockMovement(m structs.StockMovement) (structs.StockMovement, error) {
+	i := s.variantIndex(m.ProductId, m.VariantId)
+	if i < 0 {
+		return structs.StockMovement{}, NotFound("product_variant")
+	}
+
+	balance, err := stockBalance(s.variants[i].CurrentInventory, m.Quantity)
+
+	if err != nil {
+		return structs.StockMovement{}, err
+	}
+
+	s.nextStockMovementId++
+	m.Id = s.nextStockMovementId
+	m.Balance = balance
+	m.CreatedAt = formatTimestamp(time.Now())
+
+	s.variants[i].CurrentInventory = balance
+	s.stockMovements = append(s.stockMovements, m)
+
+	return m, nil
+}
+
+func (s *MemoryStore) InsertStockMovement(ctx context.Context, m structs.StockMovement) (structs.StockMovement, error) {
+	if err := validateStockMovement(&m); err != nil {
+		return structs.StockMovement{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.StockMovement{}, err
+	}
+
+	i := s.productIndex(m.ProductId)
+	if i < 0 {
+		return structs.StockMovement{}, NotFound("product")
+	}
+
+	before := s.availableStock(i)
+
+	created, err := s.applyStockMovement(m)
+
+	if err != nil {
+		return structs.StockMovement{}, err
+	}
+
+	s.recordStockAlert(i, before)
+
+	return created, nil
+}
+
+func (s *MemoryStore) GetStockMovements(ctx context.Context, productId int, limit int, cursor string) (structs.StockMovementPage, error) {
+	before, err := parseIdCursor(cursor)
+
+	if err != nil {
+		return structs.StockMovementPage{}, err
+	}
+
+	if limit <= 0 || limit > MaxPageSize {
+		limit = DefaultPageSize
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.StockMovementPage{}, err
+	}
+
+	if s.productIndex(int64(productId)) < 0 {
+		return structs.StockMovementPage{}, NotFound("product")
+	}
+
+	// ORDER BY id DESC LIMIT limit + 1, movements are appended in id order
+	movements := make([]structs.StockMovement, 0, limit)
+	for i := len(s.stockMovements) - 1; i >= 0 && len(movements) <= limit; i-- {
+		m := s.stockMovements[i]
+
+		if m.ProductId == int64(productId) && (before == 0 || m.Id < before) {
+			movements = append(movements, m)
+		}
+	}
+
+	return newStockMovementPage(movements, limit), nil
+}
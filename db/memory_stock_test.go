@@ -0,0 +1,170 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"vayer-electric-backend/money"
+	"vayer-electric-backend/structs"
+)
+
+// Every stock change goes through the ledger and the product's stock follows its balance
+func TestStockLedger(t *testing.T) {
+	ctx := context.Background()
+	s := newTestMemoryStore(t, "THHN-12")
+
+	if _, err := s.InsertStockMovement(ctx, structs.StockMovement{ProductId: 1, Reason: "receipt", Quantity: 5, User: "ana", Reference: "PO-7"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.UpdateProduct(ctx, 1, "THHN 12", money.FromMinor(1250), 12); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := s.InsertStockMovement(ctx, structs.StockMovement{ProductId: 1, Reason: "damage", Quantity: -13})
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("taking more than on hand: error = %v, want a conflict", err)
+	}
+
+	page, err := s.GetStockMovements(ctx, 1, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		reason   string
+		quantity int64
+		balance  int64
+	}{
+		{"adjustment", -3, 12},
+		{"receipt", 5, 15},
+		{"adjustment", 10, 10},
+	}
+
+	if len(page.Items) != len(want) {
+		t.Fatalf("got %d movements, want %d", len(page.Items), len(want))
+	}
+
+	for i, w := range want {
+		m := page.Items[i]
+
+		if m.Reason != w.reason || m.Quantity != w.quantity || m.Balance != w.balance {
+			t.Errorf("movement %d is %s %d to %d, want %s %d to %d", i, m.Reason, m.Quantity, m.Balance, w.reason, w.quantity, w.balance)
+		}
+	}
+
+	product, err := s.GetProductById(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if product.CurrentInventory != 12 {
+		t.Errorf("current_inventory = %d, want the last balance 12", product.CurrentInventory)
+	}
+}
+
+func TestStockLedgerPages(t *testing.T) {
+	ctx := context.Background()
+	s := newTestMemoryStore(t, "THHN-12")
+
+	for i := 0; i < 4; i++ {
+		if _, err := s.InsertStockMovement(ctx, structs.StockMovement{ProductId: 1, Reason: "sale", Quantity: -1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	seen := 0
+	cursor := ""
+
+	for {
+		page, err := s.GetStockMovements(ctx, 1, 2, cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		seen += len(page.Items)
+
+		if page.NextCursor == "" {
+			break
+		}
+
+		cursor = page.NextCursor
+	}
+
+	if seen != 5 {
+		t.Errorf("paged through %d movements, want 5", seen)
+	}
+}
+
+func TestValidateStockMovement(t *testing.T) {
+	tests := []struct {
+		name string
+		m    structs.StockMovement
+		ok   bool
+	}{
+		{"receipt", structs.StockMovement{Reason: " Receipt ", Quantity: 3}, true},
+		{"sale", structs.StockMovement{Reason: "sale", Quantity: -3}, true},
+		{"adjustment down", structs.StockMovement{Reason: "adjustment", Quantity: -3}, true},
+		{"unknown reason", structs.StockMovement{Reason: "theft", Quantity: -3}, false},
+		{"transfer", structs.StockMovement{Reason: "transfer", Quantity: 3}, false},
+		{"zero", structs.StockMovement{Reason: "adjustment"}, false},
+		{"negative receipt", structs.StockMovement{Reason: "receipt", Quantity: -3}, false},
+		{"positive damage", structs.StockMovement{Reason: "damage", Quantity: 3}, false},
+	}
+
+	for _, tt := range tests {
+		err := validateStockMovement(&tt.m)
+
+		if (err == nil) != tt.ok || (err != nil && !errors.Is(err, ErrValidation)) {
+			t.Errorf("%s: error = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+// Lowering current_inventory takes stock from wherever it sits, not only from the default location
+func TestUpdateProductLowersStockAcrossLocations(t *testing.T) {
+	ctx := context.Background()
+	s := newTestMemoryStore(t, "THHN-12")
+
+	warehouse, err := s.InsertWarehouse(ctx, "North")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shelf, err := s.InsertLocation(ctx, structs.Location{WarehouseId: warehouse.Id, Name: "A1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stock, err := s.GetProductStock(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2 left at the default location, 8 on the shelf
+	if _, err := s.TransferStock(ctx, structs.StockTransfer{ProductId: 1, FromLocationId: stock.Locations[0].LocationId, ToLocationId: shelf.Id, Quantity: 8}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.UpdateProduct(ctx, 1, "THHN 12", money.FromMinor(1250), 3); err != nil {
+		t.Fatal(err)
+	}
+
+	stock, err = s.GetProductStock(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	total := int64(0)
+	for _, l := range stock.Locations {
+		if l.Quantity < 0 {
+			t.Errorf("location %s has %d", l.LocationName, l.Quantity)
+		}
+		total += l.Quantity
+	}
+
+	if stock.Total != 3 || total != 3 {
+		t.Errorf("stock is %d with %d across locations, want 3", stock.Total, total)
+	}
+}
@@ -0,0 +1,286 @@
+package db
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"vayer-electric-backend/structs"
+	"vayer-electric-backend/tax"
+
+	"github.com/lib/pq"
+)
+
+func (s *MemoryStore) taxClassIndex(id int64) int {
+	for i := range s.taxClasses {
+		if s.taxClasses[i].Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Same as loadTaxClasses, the default class is seeded first and can't be deleted so id order keeps it first
+func (s *MemoryStore) copyTaxClasses() []structs.TaxClass {
+	classes := make([]structs.TaxClass, 0, len(s.taxClasses))
+
+	for _, c := range s.taxClasses {
+		c.Rates = append(make([]structs.TaxRate, 0, len(c.Rates)), c.Rates...)
+		classes = append(classes, c)
+	}
+
+	return classes
+}
+
+// Same as loadTaxTable
+func (s *MemoryStore) taxTable() tax.Table {
+	assignments := make([]tax.Assignment, 0, len(s.subcategories))
+
+	for _, sub := range s.subcategories {
+		a := tax.Assignment{SubcategoryId: sub.Id, SubcategoryClassId: sub.TaxClassId}
+
+		if i := s.categoryIndex(sub.CategoryId); i >= 0 {
+			a.CategoryClassId = s.categories[i].TaxClassId
+		}
+
+		assignments = append(assignments, a)
+	}
+
+	return tax.NewTable(s.taxClasses, assignments)
+}
+
+func (s *MemoryStore) GetTaxClasses(ctx context.Context) ([]structs.TaxClass, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return s.copyTaxClasses(), nil
+}
+
+func (s *MemoryStore) GetTaxTable(ctx context.Context) (tax.Table, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return tax.Table{}, err
+	}
+
+	return s.taxTable(), nil
+}
+
+func (s *MemoryStore) InsertTaxClass(ctx context.Context, name string) (structs.TaxClass, error) {
+	name = strings.TrimSpace(name)
+
+	if name == "" {
+		return structs.TaxClass{}, ValidationError("name is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.TaxClass{}, err
+	}
+
+	if err := checkColumns(0, name); err != nil {
+		return structs.TaxClass{}, err
+	}
+
+	for _, c := range s.taxClasses {
+		if c.Name == name {
+			return structs.TaxClass{}, translateError(&pq.Error{Code: "23505", Table: "tax_class"}, "tax_class")
+		}
+	}
+
+	s.nextTaxClassId++
+	c := structs.TaxClass{Id: s.nextTaxClassId, Name: name, Rates: make([]structs.TaxRate, 0), CreatedAt: formatTimestamp(time.Now())}
+	s.taxClasses = append(s.taxClasses, c)
+
+	return c, nil
+}
+
+func (s *MemoryStore) DeleteTaxClass(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	i := s.taxClassIndex(int64(id))
+	if i < 0 {
+		return NotFound("tax_class")
+	}
+
+	if s.taxClasses[i].Default {
+		return Conflict("the default tax class can't be deleted")
+	}
+
+	// ON DELETE SET NULL of the tax_class_id columns, the rates go with the class
+	for j := range s.categories {
+		if s.categories[j].TaxClassId == int64(id) {
+			s.categories[j].TaxClassId = 0
+		}
+	}
+
+	for j := range s.subcategories {
+		if s.subcategories[j].TaxClassId == int64(id) {
+			s.subcategories[j].TaxClassId = 0
+		}
+	}
+
+	for j := range s.products {
+		if s.products[j].TaxClassId == int64(id) {
+			s.products[j].TaxClassId = 0
+		}
+	}
+
+	s.taxClasses = append(s.taxClasses[:i], s.taxClasses[i+1:]...)
+
+	return nil
+}
+
+func (s *MemoryStore) SetTaxRate(ctx context.Context, r structs.TaxRate) (structs.TaxRate, error) {
+	if err := validateTaxRate(&r); err != nil {
+		return structs.TaxRate{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.TaxRate{}, err
+	}
+
+	i := s.taxClassIndex(r.TaxClassId)
+	if i < 0 {
+		return structs.TaxRate{}, foreignKeyViolation("tax_rate", "tax_rate_tax_class_id_fkey")
+	}
+
+	c := &s.taxClasses[i]
+
+	// ON CONFLICT (tax_class_id, region) DO UPDATE SET rate = EXCLUDED.rate
+	for j := range c.Rates {
+		if c.Rates[j].Region == r.Region {
+			c.Rates[j].Rate = r.Rate
+			return c.Rates[j], nil
+		}
+	}
+
+	s.nextTaxRateId++
+	r.Id = s.nextTaxRateId
+	r.CreatedAt = formatTimestamp(time.Now())
+
+	// ORDER BY region
+	c.Rates = append(c.Rates, r)
+	sort.Slice(c.Rates, func(a, b int) bool { return c.Rates[a].Region < c.Rates[b].Region })
+
+	return r, nil
+}
+
+func (s *MemoryStore) DeleteTaxRate(ctx context.Context, taxClassId int, region string) error {
+	region, err := validateRegion(region)
+
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if i := s.taxClassIndex(int64(taxClassId)); i >= 0 {
+		c := &s.taxClasses[i]
+
+		for j := range c.Rates {
+			if c.Rates[j].Region == region {
+				c.Rates = append(c.Rates[:j], c.Rates[j+1:]...)
+				return nil
+			}
+		}
+	}
+
+	return NotFound("tax_rate")
+}
+
+// Same as setTaxClass, the caller holds the lock and found the row
+func (s *MemoryStore) checkTaxClass(table string, taxClassId int64) error {
+	if taxClassId != 0 && s.taxClassIndex(taxClassId) < 0 {
+		return foreignKeyViolation(table, table+"_tax_class_id_fkey")
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) SetCategoryTaxClass(ctx context.Context, id int, taxClassId int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	i := s.categoryIndex(int64(id))
+	if i < 0 {
+		return NotFound("category")
+	}
+
+	if err := s.checkTaxClass("category", taxClassId); err != nil {
+		return err
+	}
+
+	s.categories[i].TaxClassId = taxClassId
+
+	return nil
+}
+
+func (s *MemoryStore) SetSubcategoryTaxClass(ctx context.Context, id int, taxClassId int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	i := s.subcategoryIndex(int64(id))
+	if i < 0 {
+		return NotFound("subcategory")
+	}
+
+	if err := s.checkTaxClass("subcategory", taxClassId); err != nil {
+		return err
+	}
+
+	s.subcategories[i].TaxClassId = taxClassId
+
+	return nil
+}
+
+func (s *MemoryStore) SetProductTaxClass(ctx context.Context, id int, taxClassId int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	i := s.productIndex(int64(id))
+	if i < 0 {
+		return NotFound("product")
+	}
+
+	if err := s.checkTaxClass("product", taxClassId); err != nil {
+		return err
+	}
+
+	s.products[i].TaxClassId = taxClassId
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"vayer-electric-backend/money"
+	"vayer-electric-backend/structs"
+)
+
+// Memory store with a category, a subcategory and one product per sku, each priced 12.50 with 10 in stock
+func newTestMemoryStore(t *testing.T, skus ...string) *MemoryStore {
+	t.Helper()
+
+	ctx := context.Background()
+	s := NewMemoryStore()
+
+	if err := s.InsertCategory(ctx, "Cables", "Cables and wires", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.InsertSubcategory(ctx, "Wire", "Building wire", 1, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sku := range skus {
+		if err := s.InsertProduct(ctx, sku, "Copper wire", 1, money.FromMinor(1250), 10, sku+".jpg", "Southwire", sku); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return s
+}
+
+// A product whose whole stock is held by reservations isn't in stock
+func TestListProductsInStockHoldsBackReservations(t *testing.T) {
+	ctx := context.Background()
+	s := newTestMemoryStore(t, "THHN-12", "THHN-14")
+
+	cart, err := s.InsertCart(ctx, "", "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.InsertReservation(ctx, structs.Reservation{ProductId: 1, Quantity: 10}, cart.Token, "", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := s.ListProducts(ctx, ProductQuery{InStockOnly: true, Facets: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(page.Items) != 1 || page.Items[0].Sku != "THHN-14" {
+		t.Errorf("got %d products, want only THHN-14", len(page.Items))
+	}
+
+	page, err = s.ListProducts(ctx, ProductQuery{Facets: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if page.Facets.Availability.InStock != 1 || page.Facets.Availability.OutOfStock != 1 {
+		t.Errorf("availability is %d in stock and %d out, want 1 and 1", page.Facets.Availability.InStock, page.Facets.Availability.OutOfStock)
+	}
+}
@@ -0,0 +1,263 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"vayer-electric-backend/structs"
+
+	"github.com/lib/pq"
+)
+
+// Returns a copy of p carrying its variant matrix
+func (s *MemoryStore) withVariants(p structs.Product) structs.Product {
+	matrix := s.variantMatrix(p.Id)
+	p.Variants = &matrix
+	return p
+}
+
+func (s *MemoryStore) variantMatrix(productId int64) structs.VariantMatrix {
+	variants := make([]structs.ProductVariant, 0)
+	for _, v := range s.variants {
+		if v.ProductId == productId {
+			variants = append(variants, copyVariant(v))
+		}
+	}
+
+	return newVariantMatrix(append([]string{}, s.variantAxes[productId]...), variants)
+}
+
+func copyVariant(v structs.ProductVariant) structs.ProductVariant {
+	options := make(map[string]string, len(v.Options))
+	for axis, value := range v.Options {
+		options[axis] = value
+	}
+	v.Options = options
+	return v
+}
+
+// ON DELETE CASCADE from product to product_variant
+func (s *MemoryStore) deleteVariants(productId int64) {
+	kept := make([]structs.ProductVariant, 0, len(s.variants))
+	for _, v := range s.variants {
+		if v.ProductId != productId {
+			kept = append(kept, v)
+		}
+	}
+	s.variants = kept
+}
+
+func (s *MemoryStore) variantIndex(productId int64, id int64) int {
+	for i, v := range s.variants {
+		if v.Id == id && v.ProductId == productId {
+			return i
+		}
+	}
+	return -1
+}
+
+// Enforces the UNIQUE (sku) and UNIQUE (product_id, options) constraints of product_variant
+func (s *MemoryStore) checkVariantUnique(v structs.ProductVariant) error {
+	for _, existing := range s.variants {
+		if existing.Id == v.Id {
+			continue
+		}
+
+		if existing.Sku == v.Sku || (existing.ProductId == v.ProductId && sameOptions(existing.Options, v.Options)) {
+			return translateError(&pq.Error{Code: "23505", Table: "product_variant"}, "product_variant")
+		}
+	}
+
+	return nil
+}
+
+func sameOptions(a map[string]string, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for axis, value := range a {
+		if b[axis] != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s *MemoryStore) GetVariantMatrix(ctx context.Context, productId int) (structs.VariantMatrix, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.VariantMatrix{}, err
+	}
+
+	if s.productIndex(int64(productId)) < 0 {
+		return structs.VariantMatrix{}, NotFound("product")
+	}
+
+	return s.variantMatrix(int64(productId)), nil
+}
+
+func (s *MemoryStore) SetVariantAxes(ctx context.Context, productId int, axes []string) error {
+	axes, err := validateVariantAxes(axes)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if s.productIndex(int64(productId)) < 0 {
+		return NotFound("product")
+	}
+
+	if !sameAxes(s.variantAxes[int64(productId)], axes) && len(s.variantMatrix(int64(productId)).Items) > 0 {
+		return Conflict("the axes of a product with variants can't change, delete its variants first")
+	}
+
+	if s.variantAxes == nil {
+		s.variantAxes = make(map[int64][]string)
+	}
+	s.variantAxes[int64(productId)] = axes
+
+	return nil
+}
+
+func (s *MemoryStore) InsertProductVariant(ctx context.Context, v structs.ProductVariant) (structs.ProductVariant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.ProductVariant{}, err
+	}
+
+	if s.productIndex(v.ProductId) < 0 {
+		return structs.ProductVariant{}, NotFound("product")
+	}
+
+	if err := validateVariant(&v, s.variantAxes[v.ProductId]); err != nil {
+		return structs.ProductVariant{}, err
+	}
+
+	if err := checkColumns(v.Price, v.Sku, v.ImageUrl); err != nil {
+		return structs.ProductVariant{}, err
+	}
+
+	v.Id = 0
+	if err := s.checkVariantUnique(v); err != nil {
+		return structs.ProductVariant{}, err
+	}
+
+	s.nextVariantId++
+	v.Id = s.nextVariantId
+	v.CreatedAt = formatTimestamp(time.Now())
+
+	currentInventory := v.CurrentInventory
+	v.CurrentInventory = 0
+	s.variants = append(s.variants, v)
+
+	if currentInventory > 0 {
+		opening := structs.StockMovement{ProductId: v.ProductId, VariantId: v.Id, Reason: "adjustment", Quantity: currentInventory, Reference: openingBalanceReference}
+		if _, err := s.applyStockMovement(opening); err != nil {
+			return structs.ProductVariant{}, err
+		}
+	}
+
+	return copyVariant(s.variants[len(s.variants)-1]), nil
+}
+
+func (s *MemoryStore) UpdateProductVariant(ctx context.Context, v structs.ProductVariant) (structs.ProductVariant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.ProductVariant{}, err
+	}
+
+	if s.productIndex(v.ProductId) < 0 {
+		return structs.ProductVariant{}, NotFound("product")
+	}
+
+	if err := validateVariant(&v, s.variantAxes[v.ProductId]); err != nil {
+		return structs.ProductVariant{}, err
+	}
+
+	if err := checkColumns(v.Price, v.Sku); err != nil {
+		return structs.ProductVariant{}, err
+	}
+
+	i := s.variantIndex(v.ProductId, v.Id)
+	if i < 0 {
+		return structs.ProductVariant{}, NotFound("product_variant")
+	}
+
+	if err := s.checkVariantUnique(v); err != nil {
+		return structs.ProductVariant{}, err
+	}
+
+	if current := s.variants[i].CurrentInventory; v.CurrentInventory != current {
+		if reserved := s.reservedVariantStock(i, time.Now()); v.CurrentInventory < reserved {
+			return structs.ProductVariant{}, belowReservedStock(v.Sku, reserved)
+		}
+
+		adjustment := structs.StockMovement{ProductId: v.ProductId, VariantId: v.Id, Reason: "adjustment", Quantity: v.CurrentInventory - current, Reference: variantUpdateReference}
+		if _, err := s.applyStockMovement(adjustment); err != nil {
+			return structs.ProductVariant{}, err
+		}
+	}
+
+	s.variants[i].Sku = v.Sku
+	s.variants[i].Price = v.Price
+	s.variants[i].Options = v.Options
+
+	return copyVariant(s.variants[i]), nil
+}
+
+func (s *MemoryStore) SetProductVariantImage(ctx context.Context, productId int, variantId int, imageUrl string) (structs.ProductVariant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.ProductVariant{}, err
+	}
+
+	if err := checkColumns(0, imageUrl); err != nil {
+		return structs.ProductVariant{}, err
+	}
+
+	i := s.variantIndex(int64(productId), int64(variantId))
+	if i < 0 {
+		return structs.ProductVariant{}, NotFound("product_variant")
+	}
+
+	s.variants[i].ImageUrl = imageUrl
+
+	return copyVariant(s.variants[i]), nil
+}
+
+func (s *MemoryStore) DeleteProductVariant(ctx context.Context, productId int, variantId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	i := s.variantIndex(int64(productId), int64(variantId))
+	if i < 0 {
+		return NotFound("product_variant")
+	}
+
+	s.variants = append(s.variants[:i], s.variants[i+1:]...)
+	s.deleteVariantReservations(int64(variantId))
+	s.deleteVariantCartLines(int64(variantId))
+	s.unlinkVariantOrderLines(int64(variantId))
+
+	return nil
+}
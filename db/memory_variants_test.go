@@ -0,0 +1,54 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"vayer-electric-backend/money"
+	"vayer-electric-backend/structs"
+)
+
+func TestUpdateProductVariantKeepsReservedStock(t *testing.T) {
+	ctx := context.Background()
+	s := newTestMemoryStore(t, "THHN-12")
+
+	if err := s.SetVariantAxes(ctx, 1, []string{"color"}); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := s.InsertProductVariant(ctx, structs.ProductVariant{ProductId: 1, Sku: "THHN-12-RED", Price: money.FromMinor(1300), CurrentInventory: 5, Options: map[string]string{"color": "red"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cart, err := s.InsertCart(ctx, "", "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.InsertReservation(ctx, structs.Reservation{ProductId: 1, VariantId: v.Id, Quantity: 3}, cart.Token, "", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	v.CurrentInventory = 2
+	if _, err := s.UpdateProductVariant(ctx, v); !errors.Is(err, ErrConflict) {
+		t.Fatalf("stock 2 under 3 reserved: error = %v, want ErrConflict", err)
+	}
+
+	// A rejected update changes nothing
+	matrix, err := s.GetVariantMatrix(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := matrix.Items[0].CurrentInventory; got != 5 {
+		t.Errorf("stock = %d after a rejected update, want 5", got)
+	}
+
+	v.CurrentInventory = 3
+	if updated, err := s.UpdateProductVariant(ctx, v); err != nil || updated.CurrentInventory != 3 {
+		t.Errorf("stock 3 with 3 reserved: %d, %v", updated.CurrentInventory, err)
+	}
+}
@@ -0,0 +1,294 @@
+package db
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"vayer-electric-backend/structs"
+
+	"github.com/lib/pq"
+)
+
+func (s *MemoryStore) warehouseIndex(id int64) int {
+	for i := range s.warehouses {
+		if s.warehouses[i].Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *MemoryStore) locationIndex(id int64) int {
+	for i := range s.locations {
+		if s.locations[i].Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *MemoryStore) defaultLocation() structs.Location {
+	for _, l := range s.locations {
+		if l.Default {
+			return l
+		}
+	}
+	return structs.Location{}
+}
+
+// Returns a copy of p carrying its stock per location
+func (s *MemoryStore) withStock(p structs.Product) structs.Product {
+	p.Stock = s.productStockByLocation(p.Id)
+	return p
+}
+
+func (s *MemoryStore) productStockByLocation(productId int64) []structs.LocationStock {
+	stock := make([]structs.LocationStock, 0)
+
+	for locationId, quantity := range s.productStock[productId] {
+		if quantity <= 0 {
+			continue
+		}
+
+		l := s.locations[s.locationIndex(locationId)]
+		w := s.warehouses[s.warehouseIndex(l.WarehouseId)]
+
+		stock = append(stock, structs.LocationStock{
+			LocationId:    l.Id,
+			LocationName:  l.Name,
+			WarehouseId:   w.Id,
+			WarehouseName: w.Name,
+			Quantity:      quantity,
+		})
+	}
+
+	// ORDER BY w.id, l.id
+	sort.Slice(stock, func(i, j int) bool {
+		if stock[i].WarehouseId != stock[j].WarehouseId {
+			return stock[i].WarehouseId < stock[j].WarehouseId
+		}
+		return stock[i].LocationId < stock[j].LocationId
+	})
+
+	return stock
+}
+
+// Whether a location ever held stock, the ledger keeps it referenced
+func (s *MemoryStore) locationReferenced(id int64) bool {
+	for _, byLocation := range s.productStock {
+		if _, ok := byLocation[id]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *MemoryStore) GetWarehouses(ctx context.Context) ([]structs.Warehouse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	warehouses := make([]structs.Warehouse, 0, len(s.warehouses))
+
+	for _, w := range s.warehouses {
+		w.Locations = make([]structs.Location, 0)
+		for _, l := range s.locations {
+			if l.WarehouseId == w.Id {
+				w.Locations = append(w.Locations, l)
+			}
+		}
+		warehouses = append(warehouses, w)
+	}
+
+	return warehouses, nil
+}
+
+func (s *MemoryStore) InsertWarehouse(ctx context.Context, name string) (structs.Warehouse, error) {
+	name = strings.TrimSpace(name)
+
+	if name == "" {
+		return structs.Warehouse{}, ValidationError("name is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.Warehouse{}, err
+	}
+
+	if err := checkColumns(0, name); err != nil {
+		return structs.Warehouse{}, err
+	}
+
+	for _, w := range s.warehouses {
+		if w.Name == name {
+			return structs.Warehouse{}, translateError(&pq.Error{Code: "23505", Table: "warehouse"}, "warehouse")
+		}
+	}
+
+	s.nextWarehouseId++
+	w := structs.Warehouse{Id: s.nextWarehouseId, Name: name, CreatedAt: formatTimestamp(time.Now())}
+	s.warehouses = append(s.warehouses, w)
+
+	w.Locations = make([]structs.Location, 0)
+
+	return w, nil
+}
+
+func (s *MemoryStore) DeleteWarehouse(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	i := s.warehouseIndex(int64(id))
+	if i < 0 {
+		return NotFound("warehouse")
+	}
+
+	for _, l := range s.locations {
+		if l.WarehouseId == int64(id) {
+			return foreignKeyReferenced("warehouse", "location", "location_warehouse_id_fkey")
+		}
+	}
+
+	s.warehouses = append(s.warehouses[:i], s.warehouses[i+1:]...)
+
+	return nil
+}
+
+func (s *MemoryStore) InsertLocation(ctx context.Context, l structs.Location) (structs.Location, error) {
+	l.Name = strings.TrimSpace(l.Name)
+
+	if l.Name == "" {
+		return structs.Location{}, ValidationError("name is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.Location{}, err
+	}
+
+	if err := checkColumns(0, l.Name); err != nil {
+		return structs.Location{}, err
+	}
+
+	if s.warehouseIndex(l.WarehouseId) < 0 {
+		return structs.Location{}, foreignKeyViolation("location", "location_warehouse_id_fkey")
+	}
+
+	for _, existing := range s.locations {
+		if existing.WarehouseId == l.WarehouseId && existing.Name == l.Name {
+			return structs.Location{}, translateError(&pq.Error{Code: "23505", Table: "location"}, "location")
+		}
+	}
+
+	s.nextLocationId++
+	l.Id = s.nextLocationId
+	l.Default = false
+	l.CreatedAt = formatTimestamp(time.Now())
+	s.locations = append(s.locations, l)
+
+	return l, nil
+}
+
+func (s *MemoryStore) DeleteLocation(ctx context.Context, warehouseId int, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	i := s.locationIndex(int64(id))
+	if i < 0 || s.locations[i].WarehouseId != int64(warehouseId) {
+		return NotFound("location")
+	}
+
+	if s.locations[i].Default {
+		return Conflict("the default location can't be deleted")
+	}
+
+	if s.locationReferenced(int64(id)) {
+		return foreignKeyReferenced("location", "product_stock", "product_stock_location_id_fkey")
+	}
+
+	// Movements of deleted products are still in the ledger
+	for _, m := range s.stockMovements {
+		if m.LocationId == int64(id) {
+			return foreignKeyReferenced("location", "stock_movement", "stock_movement_location_id_fkey")
+		}
+	}
+
+	s.locations = append(s.locations[:i], s.locations[i+1:]...)
+
+	return nil
+}
+
+func (s *MemoryStore) GetProductStock(ctx context.Context, productId int) (structs.ProductStock, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return structs.ProductStock{}, err
+	}
+
+	i := s.productIndex(int64(productId))
+	if i < 0 {
+		return structs.ProductStock{}, NotFound("product")
+	}
+
+	reserved := s.reservedStock(int64(productId), time.Now())
+
+	return structs.ProductStock{
+		ProductId: int64(productId),
+		Total:     s.products[i].CurrentInventory,
+		Reserved:  reserved,
+		Available: availableStock(s.products[i].CurrentInventory, reserved),
+		Locations: s.productStockByLocation(int64(productId)),
+	}, nil
+}
+
+func (s *MemoryStore) TransferStock(ctx context.Context, t structs.StockTransfer) ([]structs.StockMovement, error) {
+	if err := validateStockTransfer(&t); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Both locations are checked first, the pair is applied whole or not at all
+	if s.locationIndex(t.ToLocationId) < 0 {
+		return nil, foreignKeyViolation("product_stock", "product_stock_location_id_fkey")
+	}
+
+	out, in := transferMovements(t)
+
+	out, err := s.applyStockMovement(out)
+
+	if err != nil {
+		return nil, err
+	}
+
+	in, err = s.applyStockMovement(in)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return []structs.StockMovement{out, in}, nil
+}
@@ -0,0 +1,672 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"vayer-electric-backend/money"
+	"vayer-electric-backend/structs"
+
+	"github.com/lib/pq"
+)
+
+// Statuses an order can move to from each status, cancelled and refunded are final
+var orderTransitions = map[string][]string{
+	"pending":   {"paid", "cancelled"},
+	"paid":      {"picking", "cancelled", "refunded"},
+	"picking":   {"shipped", "cancelled", "refunded"},
+	"shipped":   {"delivered", "refunded"},
+	"delivered": {"refunded"},
+	"cancelled": {},
+	"refunded":  {},
+}
+
+func validateOrderStatus(status string) error {
+	if _, ok := orderTransitions[status]; !ok {
+		return ValidationError("status must be one of pending, paid, picking, shipped, delivered, cancelled or refunded")
+	}
+
+	return nil
+}
+
+func orderTransitionAllowed(from string, to string) bool {
+	for _, next := range orderTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks an order can go from current to t.To. t.From is the status the caller expects the order
+// to be in, when set a transition made concurrently by someone else fails instead of stacking up.
+func checkOrderTransition(current string, t *structs.OrderTransition) error {
+	t.To = strings.ToLower(strings.TrimSpace(t.To))
+	t.From = strings.ToLower(strings.TrimSpace(t.From))
+	t.User = strings.TrimSpace(t.User)
+	t.Note = strings.TrimSpace(t.Note)
+
+	if err := validateOrderStatus(t.To); err != nil {
+		return err
+	}
+
+	if t.From != "" && t.From != current {
+		return Conflict(fmt.Sprintf("the order is %s, not %s", current, t.From))
+	}
+
+	if orderTransitionAllowed(current, t.To) {
+		t.From = current
+		return nil
+	}
+
+	return Conflict(fmt.Sprintf("an order can't go from %s to %s", current, t.To))
+}
+
+// Stock movements of an order carry this reference, cancelling the order reverses them
+func orderReference(orderId int64) string {
+	return fmt.Sprintf("order %d", orderId)
+}
+
+// An order called off before it shipped puts its stock back, goods refunded after shipping
+// come back through a return stock movement once they're received
+func orderReturnsStock(from string, to string) bool {
+	return to == "cancelled" || (to == "refunded" && (from == "paid" || from == "picking"))
+}
+
+func newOrderPage(orders []structs.Order, limit int) structs.OrderPage {
+	page := structs.OrderPage{Items: orders}
+
+	if len(orders) > limit {
+		page.Items = orders[:limit]
+		page.NextCursor = strconv.FormatInt(page.Items[limit-1].Id, 10)
+	}
+
+	return page
+}
+
+const orderColumns = "id, token, coalesce(customer_id, ''), status, region, subtotal, tax_total, total, created_at, updated_at"
+
+func scanOrder(scan func(dest ...interface{}) error) (structs.Order, error) {
+	var o structs.Order
+	err := scan(&o.Id, &o.Token, &o.CustomerId, &o.Status, &o.Region, &o.Subtotal, &o.TaxTotal, &o.Total, &o.CreatedAt, &o.UpdatedAt)
+	return o, err
+}
+
+// Takes quantity of a product out of its locations, the default location first and then the others in order
+func allocateStock(ctx context.Context, tx *sql.Tx, productId int64, quantity int64, reason string, reference string, user string) error {
+	rows, err := tx.QueryContext(ctx, `SELECT ps.location_id, ps.quantity FROM product_stock ps
+		JOIN location l ON l.id = ps.location_id
+		WHERE ps.product_id = $1 AND ps.quantity > 0
+		ORDER BY l.is_default DESC, l.id`, productId)
+
+	if err != nil {
+		log.Error(err.Error())
+		return translateError(err, "product_stock")
+	}
+
+	var stock []structs.LocationStock
+
+	for rows.Next() {
+		var ls structs.LocationStock
+
+		if err := rows.Scan(&ls.LocationId, &ls.Quantity); err != nil {
+			rows.Close()
+			log.Error(err.Error())
+			return translateError(err, "product_stock")
+		}
+
+		stock = append(stock, ls)
+	}
+
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		log.Error(err.Error())
+		return translateError(err, "product_stock")
+	}
+
+	for _, ls := range stock {
+		if quantity == 0 {
+			break
+		}
+
+		take := ls.Quantity
+		if take > quantity {
+			take = quantity
+		}
+
+		out := structs.StockMovement{ProductId: productId, LocationId: ls.LocationId, Reason: reason, Quantity: -take, User: user, Reference: reference}
+		if _, err := insertStockMovement(ctx, tx, out); err != nil {
+			return err
+		}
+
+		quantity -= take
+	}
+
+	if quantity > 0 {
+		return Conflict(fmt.Sprintf("insufficient stock, %d short", quantity))
+	}
+
+	return nil
+}
+
+// Puts the stock an order took back where it came from as it goes to status, products and variants
+// deleted since have no stock to put back
+func restockOrder(ctx context.Context, tx *sql.Tx, orderId int64, status string, user string) error {
+	rows, err := tx.QueryContext(ctx, `SELECT product_id, coalesce(variant_id, 0), coalesce(location_id, 0), quantity FROM stock_movement
+		WHERE reason = 'sale' AND reference = $1 AND product_id IN (SELECT id FROM product) AND (variant_id IS NULL OR variant_id IN (SELECT id FROM product_variant))
+		ORDER BY product_id, id`, orderReference(orderId))
+
+	if err != nil {
+		log.Error(err.Error())
+		return translateError(err, "stock_movement")
+	}
+
+	var sales []structs.StockMovement
+
+	for rows.Next() {
+		var m structs.StockMovement
+
+		if err := rows.Scan(&m.ProductId, &m.VariantId, &m.LocationId, &m.Quantity); err != nil {
+			rows.Close()
+			log.Error(err.Error())
+			return translateError(err, "stock_movement")
+		}
+
+		sales = append(sales, m)
+	}
+
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		log.Error(err.Error())
+		return translateError(err, "stock_movement")
+	}
+
+	for _, sale := range sales {
+		restock := structs.StockMovement{ProductId: sale.ProductId, VariantId: sale.VariantId, LocationId: sale.LocationId, Reason: "return", Quantity: -sale.Quantity, User: user, Reference: orderReference(orderId) + " " + status}
+		if _, err := insertStockMovement(ctx, tx, restock); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func insertOrderTransition(ctx context.Context, tx *sql.Tx, orderId int64, t structs.OrderTransition, now time.Time) error {
+	_, err := tx.ExecContext(ctx, "INSERT INTO order_transition (order_id, from_status, to_status, created_by, note, created_at) VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6)",
+		orderId, t.From, t.To, t.User, t.Note, now)
+
+	if err != nil {
+		log.Error(err.Error())
+		return translateError(err, "order_transition")
+	}
+
+	return nil
+}
+
+// Loads the lines of orders in place
+func loadOrderLines(ctx context.Context, q querier, orders []structs.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(orders))
+	for _, o := range orders {
+		ids = append(ids, o.Id)
+	}
+
+	rows, err := q.QueryContext(ctx, "SELECT id, order_id, coalesce(product_id, 0), coalesce(variant_id, 0), name, sku, unit_price, quantity, line_total, tax_class, tax_rate, tax_amount FROM order_line WHERE order_id = ANY($1) ORDER BY order_id, id", pq.Array(ids))
+
+	if err != nil {
+		log.Error(err.Error())
+		return translateError(err, "order_line")
+	}
+
+	defer rows.Close()
+
+	byOrder := make(map[int64][]structs.OrderLine)
+
+	for rows.Next() {
+		var line structs.OrderLine
+		var orderId int64
+
+		if err := rows.Scan(&line.Id, &orderId, &line.ProductId, &line.VariantId, &line.Name, &line.Sku, &line.UnitPrice, &line.Quantity, &line.LineTotal, &line.Tax.TaxClass, &line.Tax.Rate, &line.Tax.Tax); err != nil {
+			log.Error(err.Error())
+			return translateError(err, "order_line")
+		}
+
+		line.Tax.Net = line.LineTotal
+
+		if line.Tax.Gross, err = line.LineTotal.Add(line.Tax.Tax); err != nil {
+			log.Error(err.Error())
+			return err
+		}
+
+		byOrder[orderId] = append(byOrder[orderId], line)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error(err.Error())
+		return translateError(err, "order_line")
+	}
+
+	for i := range orders {
+		orders[i].Lines = make([]structs.OrderLine, 0, len(byOrder[orders[i].Id]))
+		orders[i].Lines = append(orders[i].Lines, byOrder[orders[i].Id]...)
+
+		orders[i].ItemCount = 0
+		for j := range orders[i].Lines {
+			orders[i].Lines[j].Tax.Region = orders[i].Region
+			orders[i].ItemCount += orders[i].Lines[j].Quantity
+		}
+	}
+
+	return nil
+}
+
+func orderTransitionHistory(ctx context.Context, q querier, orderId int64) ([]structs.OrderTransition, error) {
+	rows, err := q.QueryContext(ctx, "SELECT id, coalesce(from_status, ''), to_status, created_by, note, created_at FROM order_transition WHERE order_id = $1 ORDER BY id", orderId)
+
+	if err != nil {
+		log.Error(err.Error())
+		return nil, translateError(err, "order_transition")
+	}
+
+	defer rows.Close()
+
+	transitions := make([]structs.OrderTransition, 0)
+
+	for rows.Next() {
+		var t structs.OrderTransition
+
+		if err := rows.Scan(&t.Id, &t.From, &t.To, &t.User, &t.Note, &t.CreatedAt); err != nil {
+			log.Error(err.Error())
+			return nil, translateError(err, "order_transition")
+		}
+
+		transitions = append(transitions, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error(err.Error())
+		return nil, translateError(err, "order_transition")
+	}
+
+	return transitions, nil
+}
+
+// Loads a single order with its lines and its history
+func loadOrder(ctx context.Context, q querier, where string, args ...interface{}) (structs.Order, error) {
+	order, err := scanOrder(q.QueryRowContext(ctx, "SELECT "+orderColumns+" FROM customer_order WHERE "+where, args...).Scan)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.Order{}, translateError(err, "order")
+	}
+
+	orders := []structs.Order{order}
+
+	if err := loadOrderLines(ctx, q, orders); err != nil {
+		return structs.Order{}, err
+	}
+
+	orders[0].Transitions, err = orderTransitionHistory(ctx, q, order.Id)
+
+	if err != nil {
+		return structs.Order{}, err
+	}
+
+	return orders[0], nil
+}
+
+// Turns a cart into a pending order. Every line snapshots the name, sku, price and tax of its product and takes
+// its stock, all in one transaction, a line of a variant takes the sku, price and stock of the variant.
+// Lines are taxed in the region of the cart.
+// The reservations listed are the ones the checkout held for the cart, they're released so their stock goes
+// to the order. Listing a reservation of another cart fails the checkout, one that expired and was
+// swept is skipped. The cart is emptied.
+func (s DbSource) Checkout(ctx context.Context, token string, customerId string, reservationIds []int64) (structs.Order, error) {
+	tx, err := s.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.Order{}, err
+	}
+
+	defer tx.Rollback()
+
+	cart, err := lockCart(ctx, tx, token, customerId)
+
+	if err != nil {
+		return structs.Order{}, err
+	}
+
+	if len(reservationIds) > 0 {
+		var foreign int64
+		err := tx.QueryRowContext(ctx, "SELECT coalesce(min(id), 0) FROM stock_reservation WHERE id = ANY($1) AND cart_id IS DISTINCT FROM $2", pq.Array(reservationIds), cart.Id).Scan(&foreign)
+
+		if err != nil {
+			log.Error(err.Error())
+			return structs.Order{}, translateError(err, "stock_reservation")
+		}
+
+		if foreign != 0 {
+			return structs.Order{}, foreignReservation(foreign)
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM stock_reservation WHERE id = ANY($1) AND cart_id = $2", pq.Array(reservationIds), cart.Id); err != nil {
+			log.Error(err.Error())
+			return structs.Order{}, translateError(err, "stock_reservation")
+		}
+	}
+
+	// Products are locked in id order so two checkouts sharing products can't deadlock
+	rows, err := tx.QueryContext(ctx, "SELECT product_id, coalesce(variant_id, 0), quantity FROM cart_line WHERE cart_id = $1 ORDER BY product_id, variant_id NULLS FIRST", cart.Id)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.Order{}, translateError(err, "cart_line")
+	}
+
+	var lines []structs.CartLine
+
+	for rows.Next() {
+		var line structs.CartLine
+
+		if err := rows.Scan(&line.ProductId, &line.VariantId, &line.Quantity); err != nil {
+			rows.Close()
+			log.Error(err.Error())
+			return structs.Order{}, translateError(err, "cart_line")
+		}
+
+		lines = append(lines, line)
+	}
+
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		log.Error(err.Error())
+		return structs.Order{}, translateError(err, "cart_line")
+	}
+
+	if len(lines) == 0 {
+		return structs.Order{}, ValidationError("the cart is empty")
+	}
+
+	orderToken, err := newAccessToken()
+
+	if err != nil {
+		return structs.Order{}, err
+	}
+
+	table, err := loadTaxTable(ctx, tx)
+
+	if err != nil {
+		return structs.Order{}, err
+	}
+
+	now := time.Now()
+
+	var orderId int64
+	err = tx.QueryRowContext(ctx, "INSERT INTO customer_order (token, customer_id, status, region, subtotal, total, created_at, updated_at) VALUES ($1, NULLIF($2, ''), 'pending', $3, 0, 0, $4, $4) RETURNING id",
+		orderToken, customerId, cart.Region, now).Scan(&orderId)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.Order{}, translateError(err, "order")
+	}
+
+	var subtotal, taxTotal money.Amount
+
+	for _, line := range lines {
+		if err := lockProduct(ctx, tx, int(line.ProductId)); err != nil {
+			return structs.Order{}, err
+		}
+
+		var taxClassId, subcategoryId int64
+		err := tx.QueryRowContext(ctx, "SELECT name, sku, price, coalesce(tax_class_id, 0), subcategory_id FROM product WHERE id = $1", line.ProductId).Scan(&line.Name, &line.Sku, &line.UnitPrice, &taxClassId, &subcategoryId)
+
+		if err != nil {
+			log.Error(err.Error())
+			return structs.Order{}, translateError(err, "product")
+		}
+
+		var available int64
+
+		if line.VariantId != 0 {
+			err := tx.QueryRowContext(ctx, "SELECT sku, price FROM product_variant WHERE id = $1", line.VariantId).Scan(&line.Sku, &line.UnitPrice)
+
+			if err != nil {
+				log.Error(err.Error())
+				return structs.Order{}, translateError(err, "product_variant")
+			}
+
+			available, err = lockedVariantAvailableStock(ctx, tx, line.ProductId, line.VariantId, now)
+		} else {
+			available, err = lockedAvailableStock(ctx, tx, int(line.ProductId))
+		}
+
+		if err != nil {
+			return structs.Order{}, err
+		}
+
+		if line.Quantity > available {
+			return structs.Order{}, Conflict(fmt.Sprintf("insufficient stock of %s, %d available", line.Sku, available))
+		}
+
+		// Variant stock has no locations and no reorder point
+		if line.VariantId != 0 {
+			sale := structs.StockMovement{ProductId: line.ProductId, VariantId: line.VariantId, Reason: "sale", Quantity: -line.Quantity, User: customerId, Reference: orderReference(orderId)}
+			if _, err := insertStockMovement(ctx, tx, sale); err != nil {
+				return structs.Order{}, err
+			}
+		} else {
+			if err := allocateStock(ctx, tx, line.ProductId, line.Quantity, "sale", orderReference(orderId), customerId); err != nil {
+				return structs.Order{}, err
+			}
+
+			if err := recordStockAlert(ctx, tx, int(line.ProductId), available); err != nil {
+				return structs.Order{}, err
+			}
+		}
+
+		if err := priceCartLine(&line, table, taxClassId, subcategoryId, cart.Region); err != nil {
+			return structs.Order{}, err
+		}
+
+		if subtotal, err = subtotal.Add(line.LineTotal); err != nil {
+			return structs.Order{}, cartTotalOutOfRange()
+		}
+
+		if taxTotal, err = taxTotal.Add(line.Tax.Tax); err != nil {
+			return structs.Order{}, cartTotalOutOfRange()
+		}
+
+		_, err = tx.ExecContext(ctx, "INSERT INTO order_line (order_id, product_id, variant_id, name, sku, unit_price, quantity, line_total, tax_class, tax_rate, tax_amount) VALUES ($1, $2, NULLIF($3, 0), $4, $5, $6, $7, $8, $9, $10, $11)",
+			orderId, line.ProductId, line.VariantId, line.Name, line.Sku, line.UnitPrice, line.Quantity, line.LineTotal, line.Tax.TaxClass, line.Tax.Rate, line.Tax.Tax)
+
+		if err != nil {
+			log.Error(err.Error())
+			return structs.Order{}, translateError(err, "order_line")
+		}
+	}
+
+	// The columns of an order hold up to money.Max
+	total, err := subtotal.Add(taxTotal)
+	if err != nil || total > money.Max {
+		return structs.Order{}, cartTotalOutOfRange()
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE customer_order SET subtotal = $1, tax_total = $2, total = $3 WHERE id = $4", subtotal, taxTotal, total, orderId); err != nil {
+		log.Error(err.Error())
+		return structs.Order{}, translateError(err, "order")
+	}
+
+	if err := insertOrderTransition(ctx, tx, orderId, structs.OrderTransition{To: "pending", User: customerId}, now); err != nil {
+		return structs.Order{}, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM cart_line WHERE cart_id = $1", cart.Id); err != nil {
+		log.Error(err.Error())
+		return structs.Order{}, translateError(err, "cart_line")
+	}
+
+	if err := touchCart(ctx, tx, cart.Id); err != nil {
+		return structs.Order{}, err
+	}
+
+	order, err := loadOrder(ctx, tx, "id = $1", orderId)
+
+	if err != nil {
+		return structs.Order{}, err
+	}
+
+	return order, tx.Commit()
+}
+
+func (s DbSource) GetOrderById(ctx context.Context, id int) (structs.Order, error) {
+	return loadOrder(ctx, s.conn, "id = $1", id)
+}
+
+// An order is reached by its token, a customer order only by its customer as well
+func (s DbSource) GetOrderByToken(ctx context.Context, token string, customerId string) (structs.Order, error) {
+	return loadOrder(ctx, s.conn, "token = $1 AND (customer_id IS NULL OR customer_id = $2)", token, customerId)
+}
+
+// Returns orders newest first, only those of customerId and in status when they're set
+func (s DbSource) GetOrders(ctx context.Context, customerId string, status string, limit int, cursor string) (structs.OrderPage, error) {
+	before, err := parseIdCursor(cursor)
+
+	if err != nil {
+		return structs.OrderPage{}, err
+	}
+
+	if status != "" {
+		if err := validateOrderStatus(status); err != nil {
+			return structs.OrderPage{}, err
+		}
+	}
+
+	if limit <= 0 || limit > MaxPageSize {
+		limit = DefaultPageSize
+	}
+
+	rows, err := s.conn.QueryContext(ctx, "SELECT "+orderColumns+" FROM customer_order WHERE ($1 = '' OR customer_id = $1) AND ($2 = '' OR status = $2) AND ($3 = 0 OR id < $3) ORDER BY id DESC LIMIT $4",
+		customerId, status, before, limit+1)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.OrderPage{}, translateError(err, "order")
+	}
+
+	defer rows.Close()
+
+	orders := make([]structs.Order, 0, limit)
+
+	for rows.Next() {
+		o, err := scanOrder(rows.Scan)
+
+		if err != nil {
+			log.Error(err.Error())
+			return structs.OrderPage{}, translateError(err, "order")
+		}
+
+		orders = append(orders, o)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error(err.Error())
+		return structs.OrderPage{}, translateError(err, "order")
+	}
+
+	if err := loadOrderLines(ctx, s.conn, orders); err != nil {
+		return structs.OrderPage{}, err
+	}
+
+	return newOrderPage(orders, limit), nil
+}
+
+// Moves an order locked by tx to t.To and records the transition
+func transitionOrder(ctx context.Context, tx *sql.Tx, id int64, current string, t structs.OrderTransition) error {
+	if err := checkOrderTransition(current, &t); err != nil {
+		return err
+	}
+
+	// Cancelling would leave the money with us, a paid order is refunded instead
+	if t.To == "cancelled" {
+		var live int
+		err := tx.QueryRowContext(ctx, "SELECT count(*) FROM payment WHERE order_id = $1 AND status = ANY($2)", id, pq.Array(livePaymentStatuses)).Scan(&live)
+
+		if err != nil {
+			log.Error(err.Error())
+			return translateError(err, "payment")
+		}
+
+		if live > 0 {
+			return Conflict("the order has a payment going through, it has to be refunded instead of cancelled")
+		}
+	}
+
+	if orderReturnsStock(t.From, t.To) {
+		if err := restockOrder(ctx, tx, id, t.To, t.User); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE customer_order SET status = $1, updated_at = $2 WHERE id = $3", t.To, now, id); err != nil {
+		log.Error(err.Error())
+		return translateError(err, "order")
+	}
+
+	return insertOrderTransition(ctx, tx, id, t, now)
+}
+
+func lockOrder(ctx context.Context, tx *sql.Tx, id int64) (string, error) {
+	var status string
+	err := tx.QueryRowContext(ctx, "SELECT status FROM customer_order WHERE id = $1 FOR UPDATE", id).Scan(&status)
+
+	if err != nil {
+		log.Error(err.Error())
+		return "", translateError(err, "order")
+	}
+
+	return status, nil
+}
+
+// Moves an order to t.To and records the transition. Cancelling or refunding an order that hasn't
+// shipped puts its stock back, see orderReturnsStock. An order with a live payment can't be cancelled.
+func (s DbSource) TransitionOrder(ctx context.Context, id int, t structs.OrderTransition) (structs.Order, error) {
+	tx, err := s.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.Order{}, err
+	}
+
+	defer tx.Rollback()
+
+	current, err := lockOrder(ctx, tx, int64(id))
+
+	if err != nil {
+		return structs.Order{}, err
+	}
+
+	if err := transitionOrder(ctx, tx, int64(id), current, t); err != nil {
+		return structs.Order{}, err
+	}
+
+	order, err := loadOrder(ctx, tx, "id = $1", id)
+
+	if err != nil {
+		return structs.Order{}, err
+	}
+
+	return order, tx.Commit()
+}
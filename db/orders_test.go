@@ -0,0 +1,69 @@
+package db
+
+import (
+	"errors"
+	"testing"
+
+	"vayer-electric-backend/structs"
+)
+
+func TestCheckOrderTransition(t *testing.T) {
+	tests := []struct {
+		current string
+		from    string
+		to      string
+		err     error
+	}{
+		{"pending", "", "paid", nil},
+		{"pending", "", " Cancelled ", nil},
+		{"paid", "paid", "picking", nil},
+		{"shipped", "", "delivered", nil},
+		{"delivered", "", "refunded", nil},
+		{"pending", "", "shipped", ErrConflict},
+		{"shipped", "", "cancelled", ErrConflict},
+		{"cancelled", "", "pending", ErrConflict},
+		{"refunded", "", "paid", ErrConflict},
+		// Someone else moved the order first
+		{"cancelled", "pending", "cancelled", ErrConflict},
+		{"pending", "", "lost", ErrValidation},
+	}
+
+	for _, tt := range tests {
+		transition := structs.OrderTransition{From: tt.from, To: tt.to}
+		err := checkOrderTransition(tt.current, &transition)
+
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s from %q to %q: error = %v, want %v", tt.current, tt.from, tt.to, err, tt.err)
+			continue
+		}
+
+		if err == nil && transition.From != tt.current {
+			t.Errorf("%s to %q: From = %q, want %q", tt.current, tt.to, transition.From, tt.current)
+		}
+	}
+}
+
+func TestOrderReturnsStock(t *testing.T) {
+	tests := []struct {
+		from string
+		to   string
+		want bool
+	}{
+		{"pending", "cancelled", true},
+		{"paid", "cancelled", true},
+		{"picking", "cancelled", true},
+		{"paid", "refunded", true},
+		{"picking", "refunded", true},
+		// The goods left, they come back through a return movement
+		{"shipped", "refunded", false},
+		{"delivered", "refunded", false},
+		{"pending", "paid", false},
+		{"picking", "shipped", false},
+	}
+
+	for _, tt := range tests {
+		if got := orderReturnsStock(tt.from, tt.to); got != tt.want {
+			t.Errorf("orderReturnsStock(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
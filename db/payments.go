@@ -0,0 +1,365 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"vayer-electric-backend/structs"
+
+	"github.com/lib/pq"
+)
+
+// Statuses a payment can move to from each status. A partial refund leaves a payment captured,
+// it's refunded once the whole captured amount is.
+var paymentTransitions = map[string][]string{
+	"pending":    {"authorized", "declined", "failed"},
+	"authorized": {"captured"},
+	"captured":   {"refunded"},
+	"declined":   {},
+	"failed":     {},
+	"refunded":   {},
+}
+
+// Statuses of a payment that's going through or went through, an order has one at most
+var livePaymentStatuses = []string{"pending", "authorized", "captured"}
+
+func validatePaymentEvent(e *structs.PaymentEvent) error {
+	e.Id = strings.TrimSpace(e.Id)
+	e.Reference = strings.TrimSpace(e.Reference)
+	e.Status = strings.ToLower(strings.TrimSpace(e.Status))
+	e.Message = strings.TrimSpace(e.Message)
+
+	if _, ok := paymentTransitions[e.Status]; !ok {
+		return ValidationError("status must be one of pending, authorized, declined, failed, captured or refunded")
+	}
+
+	if e.Amount < 0 {
+		return ValidationError("amount can't be negative")
+	}
+
+	return nil
+}
+
+// Applies an outcome reported by a provider to p. An event with an id applies once, applying it again is a no-op.
+// Capturing defaults to the authorized amount and refunding to what's left of the captured one.
+// The order follows the payment, authorizing pays a pending order and a full refund refunds it.
+func applyPaymentEvent(p *structs.Payment, e structs.PaymentEvent) (orderStatus string, err error) {
+	if e.Reference != "" {
+		if p.Reference != "" && p.Reference != e.Reference {
+			return "", Conflict(fmt.Sprintf("payment %d is %s at the provider, not %s", p.Id, p.Reference, e.Reference))
+		}
+
+		p.Reference = e.Reference
+	}
+
+	if e.Status == p.Status {
+		return "", nil
+	}
+
+	allowed := false
+	for _, next := range paymentTransitions[p.Status] {
+		allowed = allowed || next == e.Status
+	}
+
+	if !allowed {
+		return "", Conflict(fmt.Sprintf("a payment can't go from %s to %s", p.Status, e.Status))
+	}
+
+	switch e.Status {
+	case "authorized":
+		orderStatus = "paid"
+	case "declined", "failed":
+		p.Message = e.Message
+	case "captured":
+		amount := e.Amount
+		if amount == 0 {
+			amount = p.Amount
+		}
+
+		if amount > p.Amount {
+			return "", ValidationError(fmt.Sprintf("can't capture more than the %s authorized", p.Amount))
+		}
+
+		p.CapturedAmount = amount
+	case "refunded":
+		left, err := p.CapturedAmount.Sub(p.RefundedAmount)
+		if err != nil {
+			return "", err
+		}
+
+		amount := e.Amount
+		if amount == 0 {
+			amount = left
+		}
+
+		if amount > left {
+			return "", ValidationError(fmt.Sprintf("can't refund more than the %s captured left", left))
+		}
+
+		if p.RefundedAmount, err = p.RefundedAmount.Add(amount); err != nil {
+			return "", err
+		}
+
+		if p.RefundedAmount < p.CapturedAmount {
+			return "", nil
+		}
+
+		orderStatus = "refunded"
+	}
+
+	p.Status = e.Status
+
+	return orderStatus, nil
+}
+
+const paymentColumns = "id, order_id, provider, coalesce(reference, ''), status, amount, captured_amount, refunded_amount, message, created_at, updated_at"
+
+func scanPayment(scan func(dest ...interface{}) error) (structs.Payment, error) {
+	var p structs.Payment
+	err := scan(&p.Id, &p.OrderId, &p.Provider, &p.Reference, &p.Status, &p.Amount, &p.CapturedAmount, &p.RefundedAmount, &p.Message, &p.CreatedAt, &p.UpdatedAt)
+	return p, err
+}
+
+// Applies e to the payment locked by tx, recording it when it has an id. Returns false for an event applied before.
+func updatePayment(ctx context.Context, tx *sql.Tx, p structs.Payment, e structs.PaymentEvent) (structs.Payment, bool, error) {
+	now := time.Now()
+
+	if e.Id != "" {
+		res, err := tx.ExecContext(ctx, "INSERT INTO payment_event (payment_id, provider, event_id, status, amount, message, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (provider, event_id) DO NOTHING",
+			p.Id, p.Provider, e.Id, e.Status, e.Amount, e.Message, now)
+
+		if err != nil {
+			log.Error(err.Error())
+			return structs.Payment{}, false, translateError(err, "payment_event")
+		}
+
+		if affected, err := res.RowsAffected(); err != nil || affected == 0 {
+			return p, false, err
+		}
+	}
+
+	orderStatus, err := applyPaymentEvent(&p, e)
+
+	if err != nil {
+		return structs.Payment{}, false, err
+	}
+
+	if orderStatus != "" {
+		current, err := lockOrder(ctx, tx, p.OrderId)
+
+		if err != nil {
+			return structs.Payment{}, false, err
+		}
+
+		// An order moved on by hand, like one cancelled while its payment was pending, is left for an admin to sort out
+		if orderTransitionAllowed(current, orderStatus) {
+			t := structs.OrderTransition{To: orderStatus, User: p.Provider, Note: fmt.Sprintf("payment %d %s", p.Id, p.Status)}
+
+			if err := transitionOrder(ctx, tx, p.OrderId, current, t); err != nil {
+				return structs.Payment{}, false, err
+			}
+		} else {
+			log.Warn(fmt.Sprintf("payment %d is %s but order %d is %s", p.Id, p.Status, p.OrderId, current))
+		}
+	}
+
+	row := tx.QueryRowContext(ctx, "UPDATE payment SET reference = NULLIF($1, ''), status = $2, captured_amount = $3, refunded_amount = $4, message = $5, updated_at = $6 WHERE id = $7 RETURNING "+paymentColumns,
+		p.Reference, p.Status, p.CapturedAmount, p.RefundedAmount, p.Message, now, p.Id)
+
+	updated, err := scanPayment(row.Scan)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.Payment{}, false, translateError(err, "payment")
+	}
+
+	return updated, true, nil
+}
+
+// Starts paying an order, the payment is pending until its provider answers. Only a pending order can be paid
+// and only when it has no other payment going through, a declined or failed payment can be tried again.
+func (s DbSource) InsertPayment(ctx context.Context, p structs.Payment) (structs.Payment, error) {
+	if p.Amount <= 0 {
+		return structs.Payment{}, ValidationError("amount must be positive")
+	}
+
+	tx, err := s.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.Payment{}, err
+	}
+
+	defer tx.Rollback()
+
+	status, err := lockOrder(ctx, tx, p.OrderId)
+
+	if err != nil {
+		return structs.Payment{}, err
+	}
+
+	if status != "pending" {
+		return structs.Payment{}, Conflict(fmt.Sprintf("the order is %s, only a pending order can be paid", status))
+	}
+
+	var live int
+	err = tx.QueryRowContext(ctx, "SELECT count(*) FROM payment WHERE order_id = $1 AND status = ANY($2)", p.OrderId, pq.Array(livePaymentStatuses)).Scan(&live)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.Payment{}, translateError(err, "payment")
+	}
+
+	if live > 0 {
+		return structs.Payment{}, Conflict("the order already has a payment going through")
+	}
+
+	now := time.Now()
+	row := tx.QueryRowContext(ctx, "INSERT INTO payment (order_id, provider, status, amount, created_at, updated_at) VALUES ($1, $2, 'pending', $3, $4, $4) RETURNING "+paymentColumns,
+		p.OrderId, p.Provider, p.Amount, now)
+
+	created, err := scanPayment(row.Scan)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.Payment{}, translateError(err, "payment")
+	}
+
+	return created, tx.Commit()
+}
+
+func (s DbSource) GetPaymentById(ctx context.Context, id int) (structs.Payment, error) {
+	p, err := scanPayment(s.conn.QueryRowContext(ctx, "SELECT "+paymentColumns+" FROM payment WHERE id = $1", id).Scan)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.Payment{}, translateError(err, "payment")
+	}
+
+	return p, nil
+}
+
+func (s DbSource) GetPayments(ctx context.Context, orderId int) ([]structs.Payment, error) {
+	var exists bool
+	err := s.conn.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM customer_order WHERE id = $1)", orderId).Scan(&exists)
+
+	if err != nil {
+		log.Error(err.Error())
+		return nil, translateError(err, "order")
+	}
+
+	if !exists {
+		return nil, NotFound("order")
+	}
+
+	rows, err := s.conn.QueryContext(ctx, "SELECT "+paymentColumns+" FROM payment WHERE order_id = $1 ORDER BY id", orderId)
+
+	if err != nil {
+		log.Error(err.Error())
+		return nil, translateError(err, "payment")
+	}
+
+	defer rows.Close()
+
+	payments := make([]structs.Payment, 0)
+
+	for rows.Next() {
+		p, err := scanPayment(rows.Scan)
+
+		if err != nil {
+			log.Error(err.Error())
+			return nil, translateError(err, "payment")
+		}
+
+		payments = append(payments, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error(err.Error())
+		return nil, translateError(err, "payment")
+	}
+
+	return payments, nil
+}
+
+// Applies the answer a provider gave to a call made for payment id
+func (s DbSource) UpdatePayment(ctx context.Context, id int64, e structs.PaymentEvent) (structs.Payment, error) {
+	if err := validatePaymentEvent(&e); err != nil {
+		return structs.Payment{}, err
+	}
+
+	tx, err := s.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.Payment{}, err
+	}
+
+	defer tx.Rollback()
+
+	p, err := scanPayment(tx.QueryRowContext(ctx, "SELECT "+paymentColumns+" FROM payment WHERE id = $1 FOR UPDATE", id).Scan)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.Payment{}, translateError(err, "payment")
+	}
+
+	p, _, err = updatePayment(ctx, tx, p, e)
+
+	if err != nil {
+		return structs.Payment{}, err
+	}
+
+	return p, tx.Commit()
+}
+
+// Applies an event a provider sent to its webhook. Providers deliver events more than once and in any order,
+// one seen before or one about a payment that moved past it is acknowledged without changing anything.
+// So is one capturing or refunding more than the payment holds, delivering it again wouldn't change that.
+func (s DbSource) ApplyPaymentEvent(ctx context.Context, provider string, e structs.PaymentEvent) (structs.Payment, error) {
+	if err := validatePaymentEvent(&e); err != nil {
+		return structs.Payment{}, err
+	}
+
+	if e.Id == "" || e.Reference == "" {
+		return structs.Payment{}, ValidationError("a payment event needs an id and a reference")
+	}
+
+	tx, err := s.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.Payment{}, err
+	}
+
+	defer tx.Rollback()
+
+	p, err := scanPayment(tx.QueryRowContext(ctx, "SELECT "+paymentColumns+" FROM payment WHERE provider = $1 AND reference = $2 FOR UPDATE", provider, e.Reference).Scan)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.Payment{}, translateError(err, "payment")
+	}
+
+	updated, applied, err := updatePayment(ctx, tx, p, e)
+
+	if errors.Is(err, ErrConflict) || errors.Is(err, ErrValidation) {
+		// The event is already recorded in tx, committing keeps it from being looked at again
+		log.Warn(fmt.Sprintf("ignored payment event %s: %s", e.Id, err.Error()))
+		return p, tx.Commit()
+	}
+
+	if err != nil {
+		return structs.Payment{}, err
+	}
+
+	if !applied {
+		return p, nil
+	}
+
+	return updated, tx.Commit()
+}
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"errors"
+	"testing"
+
+	"vayer-electric-backend/structs"
+)
+
+func TestApplyPaymentEvent(t *testing.T) {
+	tests := []struct {
+		name        string
+		payment     structs.Payment
+		event       structs.PaymentEvent
+		err         error
+		status      string
+		captured    int64
+		refunded    int64
+		orderStatus string
+	}{
+		{"authorizing pays the order", structs.Payment{Status: "pending", Amount: 1000}, structs.PaymentEvent{Status: "authorized"}, nil, "authorized", 0, 0, "paid"},
+		{"capture defaults to the amount", structs.Payment{Status: "authorized", Amount: 1000}, structs.PaymentEvent{Status: "captured"}, nil, "captured", 1000, 0, ""},
+		{"partial capture", structs.Payment{Status: "authorized", Amount: 1000}, structs.PaymentEvent{Status: "captured", Amount: 600}, nil, "captured", 600, 0, ""},
+		{"over capture", structs.Payment{Status: "authorized", Amount: 1000}, structs.PaymentEvent{Status: "captured", Amount: 1001}, ErrValidation, "authorized", 0, 0, ""},
+		{"partial refund stays captured", structs.Payment{Status: "captured", Amount: 1000, CapturedAmount: 600}, structs.PaymentEvent{Status: "refunded", Amount: 200}, nil, "captured", 600, 200, ""},
+		{"last refund refunds the order", structs.Payment{Status: "captured", Amount: 1000, CapturedAmount: 600, RefundedAmount: 200}, structs.PaymentEvent{Status: "refunded"}, nil, "refunded", 600, 600, "refunded"},
+		{"over refund", structs.Payment{Status: "captured", Amount: 1000, CapturedAmount: 600, RefundedAmount: 200}, structs.PaymentEvent{Status: "refunded", Amount: 401}, ErrValidation, "captured", 600, 200, ""},
+		{"same status is a no-op", structs.Payment{Status: "captured", Amount: 1000, CapturedAmount: 1000}, structs.PaymentEvent{Status: "captured", Amount: 1}, nil, "captured", 1000, 0, ""},
+		{"declined is final", structs.Payment{Status: "declined", Amount: 1000}, structs.PaymentEvent{Status: "authorized"}, ErrConflict, "declined", 0, 0, ""},
+		{"other reference", structs.Payment{Status: "pending", Amount: 1000, Reference: "pi_1"}, structs.PaymentEvent{Status: "authorized", Reference: "pi_2"}, ErrConflict, "pending", 0, 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.payment
+			orderStatus, err := applyPaymentEvent(&p, tt.event)
+
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+
+			if err == nil && orderStatus != tt.orderStatus {
+				t.Errorf("order status = %q, want %q", orderStatus, tt.orderStatus)
+			}
+
+			if p.Status != tt.status || p.CapturedAmount.Minor() != tt.captured || p.RefundedAmount.Minor() != tt.refunded {
+				t.Errorf("payment is %s with %s captured and %s refunded, want %s with %d and %d", p.Status, p.CapturedAmount, p.RefundedAmount, tt.status, tt.captured, tt.refunded)
+			}
+		})
+	}
+}
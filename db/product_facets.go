@@ -0,0 +1,142 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+
+	"vayer-electric-backend/money"
+	"vayer-electric-backend/structs"
+
+	"github.com/lib/pq"
+)
+
+// Upper bounds of the price buckets, the last bucket is open ended
+var PriceBucketBounds = []money.Amount{10 * money.Scale, 25 * money.Scale, 50 * money.Scale, 100 * money.Scale, 250 * money.Scale, 500 * money.Scale, 1000 * money.Scale}
+
+// GROUPING(f.brand, f.subcategory_id, f.price_bucket, f.in_stock) of each grouping set,
+// a bit is set for every column the row is not grouped by
+const (
+	facetBrand        = 0x7
+	facetSubcategory  = 0xB
+	facetPriceBucket  = 0xD
+	facetAvailability = 0xE
+	facetTotal        = 0xF
+)
+
+// Computes every facet and the total of the filtered set in a single statement through grouping sets
+func (s DbSource) productFacets(ctx context.Context, q ProductQuery) (*structs.ProductFacets, int64, error) {
+	var args sqlArgs
+	from := productFromSQL("product p JOIN subcategory s ON s.id = p.subcategory_id", q, &args)
+	conditions := productFilterSQL(q, &args)
+
+	statement := fmt.Sprintf(`SELECT GROUPING(f.brand, f.subcategory_id, f.price_bucket, f.in_stock), f.brand, f.subcategory_id, f.subcategory_name, f.price_bucket, f.in_stock, COUNT(*)
+		FROM (
+			SELECT p.brand, p.subcategory_id, s.name AS subcategory_name, width_bucket(p.price, %s::numeric[]) AS price_bucket, %s > 0 AS in_stock
+			FROM %s%s
+		) f
+		GROUP BY GROUPING SETS ((f.brand), (f.subcategory_id, f.subcategory_name), (f.price_bucket), (f.in_stock), ())`,
+		args.add(pq.Array(PriceBucketBounds)), productAvailableSQL(&args), from, whereSQL(conditions))
+
+	rows, err := s.conn.QueryContext(ctx, statement, args...)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer rows.Close()
+
+	facets := newProductFacets()
+	var total int64
+
+	for rows.Next() {
+		var (
+			grouping        int
+			brand           sql.NullString
+			subcategoryId   sql.NullInt64
+			subcategoryName sql.NullString
+			priceBucket     sql.NullInt64
+			inStock         sql.NullBool
+			count           int64
+		)
+
+		if err := rows.Scan(&grouping, &brand, &subcategoryId, &subcategoryName, &priceBucket, &inStock, &count); err != nil {
+			return nil, 0, err
+		}
+
+		switch grouping {
+		case facetBrand:
+			facets.Brands = append(facets.Brands, structs.FacetCount{Value: brand.String, Count: count})
+		case facetSubcategory:
+			facets.Subcategories = append(facets.Subcategories, structs.SubcategoryFacet{Id: subcategoryId.Int64, Name: subcategoryName.String, Count: count})
+		case facetPriceBucket:
+			facets.PriceBuckets[priceBucket.Int64].Count = count
+		case facetAvailability:
+			if inStock.Bool {
+				facets.Availability.InStock = count
+			} else {
+				facets.Availability.OutOfStock = count
+			}
+		case facetTotal:
+			total = count
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	sortFacets(facets)
+
+	return facets, total, nil
+}
+
+// Facets with every price bucket present so empty ones are reported with a zero count
+func newProductFacets() *structs.ProductFacets {
+	facets := &structs.ProductFacets{
+		Brands:        make([]structs.FacetCount, 0),
+		Subcategories: make([]structs.SubcategoryFacet, 0),
+		PriceBuckets:  make([]structs.PriceBucketFacet, len(PriceBucketBounds)+1),
+	}
+
+	for i := range facets.PriceBuckets {
+		if i > 0 {
+			min := PriceBucketBounds[i-1]
+			facets.PriceBuckets[i].Min = &min
+		} else {
+			facets.PriceBuckets[i].Min = new(money.Amount)
+		}
+
+		if i < len(PriceBucketBounds) {
+			max := PriceBucketBounds[i]
+			facets.PriceBuckets[i].Max = &max
+		}
+	}
+
+	return facets
+}
+
+// Same as width_bucket(price, PriceBucketBounds)
+func priceBucket(price money.Amount) int {
+	return sort.Search(len(PriceBucketBounds), func(i int) bool {
+		return PriceBucketBounds[i] > price
+	})
+}
+
+// Most common values first
+func sortFacets(facets *structs.ProductFacets) {
+	sort.Slice(facets.Brands, func(i, j int) bool {
+		if facets.Brands[i].Count != facets.Brands[j].Count {
+			return facets.Brands[i].Count > facets.Brands[j].Count
+		}
+		return facets.Brands[i].Value < facets.Brands[j].Value
+	})
+
+	sort.Slice(facets.Subcategories, func(i, j int) bool {
+		if facets.Subcategories[i].Count != facets.Subcategories[j].Count {
+			return facets.Subcategories[i].Count > facets.Subcategories[j].Count
+		}
+		return facets.Subcategories[i].Name < facets.Subcategories[j].Name
+	})
+}
@@ -0,0 +1,380 @@
+package db
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"vayer-electric-backend/money"
+	"vayer-electric-backend/structs"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// Columns of product in the order scanProduct expects them
+const productColumns = "p.id, p.name, p.description, p.created_at, p.subcategory_id, p.price, p.current_inventory, p.image_url, p.brand, p.sku, p.reorder_point, p.reorder_quantity, coalesce(p.tax_class_id, 0)"
+
+// Full text search uses the 'simple' configuration, sku codes and amperages like 20A must not be stemmed
+const productSearchRank = "ts_rank_cd(p.search_vector, query)::float8"
+
+var productSortColumns = map[string]string{
+	"created_at": "p.created_at",
+	"price":      "p.price",
+	"name":       "p.name",
+	"relevance":  productSearchRank,
+}
+
+// ProductQuery holds the filters, sort and page requested on a product listing.
+// Zero values mean "no filter".
+type ProductQuery struct {
+	// Full text search terms, websearch syntax ("quoted phrases", -excluded, or)
+	Search string
+
+	Brands        []string
+	SubcategoryId int
+	CategoryId    int
+	MinPrice      *money.Amount
+	MaxPrice      *money.Amount
+	InStockOnly   bool
+	Attributes    []AttributeFilter
+
+	// One of created_at, price, name or relevance (search only).
+	// An empty sort means newest first, or most relevant first when searching.
+	Sort       string
+	Descending bool
+
+	Limit  int
+	Cursor string
+
+	// Include brand, subcategory, price and availability counts for the filtered set
+	Facets bool
+}
+
+// Fills defaults and validates the query
+func (q *ProductQuery) normalize() error {
+	if q.Sort == "" && q.Search != "" {
+		q.Sort = "relevance"
+		q.Descending = true
+	}
+
+	if q.Sort == "" {
+		q.Sort = "created_at"
+		q.Descending = true
+	}
+
+	if _, ok := productSortColumns[q.Sort]; !ok {
+		return ValidationError("sort must be one of created_at, price, name or relevance")
+	}
+
+	if q.Sort == "relevance" && q.Search == "" {
+		return ValidationError("sort by relevance requires a search")
+	}
+
+	if q.Limit <= 0 {
+		q.Limit = DefaultPageSize
+	}
+
+	if q.Limit > MaxPageSize {
+		q.Limit = MaxPageSize
+	}
+
+	if q.MinPrice != nil && q.MaxPrice != nil && *q.MinPrice > *q.MaxPrice {
+		return ValidationError("min_price can't be greater than max_price")
+	}
+
+	return nil
+}
+
+// The cursor points to the last row of the previous page through its sort value and id.
+// It also records the sort it was issued for so it can't be replayed against a different ordering.
+type productCursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d"`
+	Value string `json:"v"`
+	Id    int64  `json:"id"`
+}
+
+func productSortValue(p structs.Product, sort string) string {
+	switch sort {
+	case "relevance":
+		if p.Match == nil {
+			return "0"
+		}
+		return strconv.FormatFloat(p.Match.Rank, 'g', -1, 64)
+	case "price":
+		return p.Price.String()
+	case "name":
+		return p.Name
+	default:
+		return p.CreatedAt
+	}
+}
+
+func encodeProductCursor(q ProductQuery, last structs.Product) string {
+	raw, _ := json.Marshal(productCursor{
+		Sort:  q.Sort,
+		Desc:  q.Descending,
+		Value: productSortValue(last, q.Sort),
+		Id:    last.Id,
+	})
+
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeProductCursor(q ProductQuery) (*productCursor, error) {
+	if q.Cursor == "" {
+		return nil, nil
+	}
+
+	invalid := ValidationError("invalid cursor")
+
+	raw, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, invalid
+	}
+
+	var c productCursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, invalid
+	}
+
+	if c.Sort != q.Sort || c.Desc != q.Descending {
+		return nil, ValidationError("cursor was issued for a different sort")
+	}
+
+	if c.Sort == "price" {
+		if _, err := money.Parse(c.Value); err != nil {
+			return nil, invalid
+		}
+	}
+
+	if c.Sort == "relevance" {
+		if _, err := strconv.ParseFloat(c.Value, 64); err != nil {
+			return nil, invalid
+		}
+	}
+
+	return &c, nil
+}
+
+// Accumulates positional arguments while a statement is being built
+type sqlArgs []interface{}
+
+func (a *sqlArgs) add(v interface{}) string {
+	*a = append(*a, v)
+	return fmt.Sprintf("$%d", len(*a))
+}
+
+// FROM clause of a listing over base, which has to expose product as p.
+// A search joins the parsed terms so they can be referenced as query.
+func productFromSQL(base string, q ProductQuery, args *sqlArgs) string {
+	if q.Search == "" {
+		return base
+	}
+	return fmt.Sprintf("%s, websearch_to_tsquery('simple', %s) query", base, args.add(q.Search))
+}
+
+// Stock of p left once its active reservations are held back, same as reservedStock
+func productAvailableSQL(args *sqlArgs) string {
+	return fmt.Sprintf("p.current_inventory - (SELECT coalesce(sum(r.quantity), 0) FROM stock_reservation r WHERE r.product_id = p.id AND r.variant_id IS NULL AND r.expires_at > %s)", args.add(time.Now()))
+}
+
+// Builds the WHERE conditions shared by the page and the count queries
+func productFilterSQL(q ProductQuery, args *sqlArgs) []string {
+	conditions := make([]string, 0)
+
+	if q.Search != "" {
+		conditions = append(conditions, "p.search_vector @@ query")
+	}
+
+	if len(q.Brands) > 0 {
+		placeholders := make([]string, 0, len(q.Brands))
+		for _, b := range q.Brands {
+			placeholders = append(placeholders, args.add(b))
+		}
+		conditions = append(conditions, fmt.Sprintf("p.brand IN (%s)", strings.Join(placeholders, ", ")))
+	}
+
+	if q.SubcategoryId != 0 {
+		conditions = append(conditions, fmt.Sprintf("p.subcategory_id = %s", args.add(q.SubcategoryId)))
+	}
+
+	if q.CategoryId != 0 {
+		conditions = append(conditions, fmt.Sprintf("p.subcategory_id IN (SELECT id FROM subcategory WHERE category_id = %s)", args.add(q.CategoryId)))
+	}
+
+	if q.MinPrice != nil {
+		conditions = append(conditions, fmt.Sprintf("p.price >= %s", args.add(*q.MinPrice)))
+	}
+
+	if q.MaxPrice != nil {
+		conditions = append(conditions, fmt.Sprintf("p.price <= %s", args.add(*q.MaxPrice)))
+	}
+
+	if q.InStockOnly {
+		conditions = append(conditions, productAvailableSQL(args)+" > 0")
+	}
+
+	for _, f := range q.Attributes {
+		conditions = append(conditions, attributeFilterSQL(f, args))
+	}
+
+	return conditions
+}
+
+func whereSQL(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+func scanProduct(scan func(dest ...interface{}) error) (structs.Product, error) {
+	var product structs.Product
+	err := scan(&product.Id, &product.Name, &product.Description, &product.CreatedAt, &product.SubcategoryId, &product.Price, &product.CurrentInventory, &product.ImageUrl, &product.Brand, &product.Sku, &product.ReorderPoint, &product.ReorderQuantity, &product.TaxClassId)
+	return product, err
+}
+
+// Scans productColumns followed by the rank and highlights of a search
+func scanProductMatch(scan func(dest ...interface{}) error) (structs.Product, error) {
+	var product structs.Product
+	var match structs.ProductMatch
+	err := scan(&product.Id, &product.Name, &product.Description, &product.CreatedAt, &product.SubcategoryId, &product.Price, &product.CurrentInventory, &product.ImageUrl, &product.Brand, &product.Sku, &product.ReorderPoint, &product.ReorderQuantity, &product.TaxClassId, &match.Rank, &match.Name, &match.Snippet)
+	product.Match = &match
+	return product, err
+}
+
+func (s DbSource) ListProducts(ctx context.Context, q ProductQuery) (structs.ProductPage, error) {
+	if err := q.normalize(); err != nil {
+		return structs.ProductPage{}, err
+	}
+
+	cursor, err := decodeProductCursor(q)
+	if err != nil {
+		return structs.ProductPage{}, err
+	}
+
+	if err := s.resolveAttributeFilters(ctx, &q); err != nil {
+		return structs.ProductPage{}, err
+	}
+
+	var total int64
+	var facets *structs.ProductFacets
+
+	// Facets come with the total so they replace the count query
+	if q.Facets {
+		facets, total, err = s.productFacets(ctx, q)
+	} else {
+		total, err = s.countProducts(ctx, q)
+	}
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.ProductPage{}, translateError(err, "product")
+	}
+
+	var args sqlArgs
+	from := productFromSQL("product p", q, &args)
+	conditions := productFilterSQL(q, &args)
+
+	columns := productColumns
+	if q.Search != "" {
+		// Highlights are only computed for the rows of the page
+		columns += ", " + productSearchRank +
+			", ts_headline('simple', p.name, query, 'HighlightAll=true')" +
+			", ts_headline('simple', coalesce(p.description, ''), query, 'MaxFragments=2, MaxWords=20, MinWords=5')"
+	}
+
+	column := productSortColumns[q.Sort]
+	direction, comparison := "ASC", ">"
+	if q.Descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	if cursor != nil {
+		conditions = append(conditions, fmt.Sprintf("(%s, p.id) %s (%s, %s)", column, comparison, args.add(cursor.Value), args.add(cursor.Id)))
+	}
+
+	// One extra row tells whether there is a next page
+	statement := fmt.Sprintf("SELECT %s FROM %s%s ORDER BY %s %s, p.id %s LIMIT %s",
+		columns, from, whereSQL(conditions), column, direction, direction, args.add(q.Limit+1))
+
+	rows, err := s.conn.QueryContext(ctx, statement, args...)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.ProductPage{}, translateError(err, "product")
+	}
+
+	defer rows.Close()
+
+	products := make([]structs.Product, 0, q.Limit)
+
+	for rows.Next() {
+		var product structs.Product
+
+		if q.Search != "" {
+			product, err = scanProductMatch(rows.Scan)
+		} else {
+			product, err = scanProduct(rows.Scan)
+		}
+
+		if err != nil {
+			log.Error(err.Error())
+			return structs.ProductPage{}, translateError(err, "product")
+		}
+
+		products = append(products, product)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error(err.Error())
+		return structs.ProductPage{}, translateError(err, "product")
+	}
+
+	page := newProductPage(q, products, total)
+	page.Facets = facets
+
+	if err := s.loadProductAttributes(ctx, page.Items); err != nil {
+		return structs.ProductPage{}, err
+	}
+
+	if err := s.loadProductImages(ctx, page.Items); err != nil {
+		return structs.ProductPage{}, err
+	}
+
+	return page, nil
+}
+
+func (s DbSource) countProducts(ctx context.Context, q ProductQuery) (int64, error) {
+	var args sqlArgs
+	from := productFromSQL("product p", q, &args)
+	conditions := productFilterSQL(q, &args)
+
+	var total int64
+	err := s.conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+from+whereSQL(conditions), args...).Scan(&total)
+
+	return total, err
+}
+
+// Trims the extra row fetched past the limit and sets the cursor for the next page
+func newProductPage(q ProductQuery, products []structs.Product, total int64) structs.ProductPage {
+	page := structs.ProductPage{
+		Items: products,
+		Total: total,
+	}
+
+	if len(products) > q.Limit {
+		page.Items = products[:q.Limit]
+		page.NextCursor = encodeProductCursor(q, page.Items[q.Limit-1])
+	}
+
+	return page
+}
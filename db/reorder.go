@@ -0,0 +1,172 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"vayer-electric-backend/structs"
+)
+
+func validateReorderPoint(point int64, quantity int64) error {
+	if point < 0 {
+		return ValidationError("reorder_point can't be negative")
+	}
+
+	if quantity < 0 {
+		return ValidationError("reorder_quantity can't be negative")
+	}
+
+	return nil
+}
+
+// Whether available stock went from at or above the reorder point to below it. A product already
+// below its reorder point doesn't raise another alert until it's been restocked past it.
+func crossedReorderPoint(before int64, after int64, point int64) bool {
+	return point > 0 && before >= point && after < point
+}
+
+// Available stock of a product, the caller holds the product lock
+func lockedAvailableStock(ctx context.Context, q querier, productId int) (int64, error) {
+	var onHand int64
+	err := q.QueryRowContext(ctx, "SELECT current_inventory FROM product WHERE id = $1", productId).Scan(&onHand)
+
+	if err != nil {
+		log.Error(err.Error())
+		return 0, translateError(err, "product")
+	}
+
+	reserved, err := reservedStock(ctx, q, productId, time.Now())
+
+	if err != nil {
+		return 0, err
+	}
+
+	return availableStock(onHand, reserved), nil
+}
+
+// Records a stock alert when the change made in tx took the product below its reorder point,
+// before is the available stock from ahead of the change
+func recordStockAlert(ctx context.Context, tx *sql.Tx, productId int, before int64) error {
+	after, err := lockedAvailableStock(ctx, tx, productId)
+
+	if err != nil {
+		return err
+	}
+
+	var point, quantity int64
+	err = tx.QueryRowContext(ctx, "SELECT reorder_point, reorder_quantity FROM product WHERE id = $1", productId).Scan(&point, &quantity)
+
+	if err != nil {
+		log.Error(err.Error())
+		return translateError(err, "product")
+	}
+
+	if !crossedReorderPoint(before, after, point) {
+		return nil
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO stock_alert (product_id, available, reorder_point, reorder_quantity, created_at) VALUES ($1, $2, $3, $4, $5)",
+		productId, after, point, quantity, time.Now())
+
+	if err != nil {
+		log.Error(err.Error())
+		return translateError(err, "stock_alert")
+	}
+
+	return nil
+}
+
+func (s DbSource) SetReorderPoint(ctx context.Context, productId int, point int64, quantity int64) error {
+	if err := validateReorderPoint(point, quantity); err != nil {
+		return err
+	}
+
+	result, err := s.conn.ExecContext(ctx, "UPDATE product SET reorder_point = $1, reorder_quantity = $2 WHERE id = $3", point, quantity, productId)
+	return translateExecResult(result, err, "product")
+}
+
+// Returns every product whose available stock is below its reorder point, the lowest available first
+func (s DbSource) GetLowStockProducts(ctx context.Context) ([]structs.LowStockProduct, error) {
+	rows, err := s.conn.QueryContext(ctx, `SELECT p.id, p.name, p.sku, p.current_inventory, coalesce(r.reserved, 0), p.reorder_point, p.reorder_quantity
+		FROM product p
+		LEFT JOIN (SELECT product_id, sum(quantity) AS reserved FROM stock_reservation WHERE variant_id IS NULL AND expires_at > $1 GROUP BY product_id) r ON r.product_id = p.id
+		WHERE p.reorder_point > 0 AND greatest(p.current_inventory - coalesce(r.reserved, 0), 0) < p.reorder_point
+		ORDER BY greatest(p.current_inventory - coalesce(r.reserved, 0), 0), p.id`, time.Now())
+
+	if err != nil {
+		log.Error(err.Error())
+		return nil, translateError(err, "product")
+	}
+
+	defer rows.Close()
+
+	products := make([]structs.LowStockProduct, 0)
+
+	for rows.Next() {
+		var p structs.LowStockProduct
+
+		if err := rows.Scan(&p.ProductId, &p.Name, &p.Sku, &p.CurrentInventory, &p.Reserved, &p.ReorderPoint, &p.ReorderQuantity); err != nil {
+			log.Error(err.Error())
+			return nil, translateError(err, "product")
+		}
+
+		p.Available = availableStock(p.CurrentInventory, p.Reserved)
+		products = append(products, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error(err.Error())
+		return nil, translateError(err, "product")
+	}
+
+	return products, nil
+}
+
+// Returns up to limit alerts not delivered yet that failed fewer than maxAttempts times, oldest first
+func (s DbSource) GetPendingStockAlerts(ctx context.Context, limit int, maxAttempts int) ([]structs.StockAlert, error) {
+	rows, err := s.conn.QueryContext(ctx, `SELECT a.id, a.product_id, p.name, p.sku, a.available, a.reorder_point, a.reorder_quantity, a.attempts, a.created_at
+		FROM stock_alert a
+		JOIN product p ON p.id = a.product_id
+		WHERE a.notified_at IS NULL AND a.attempts < $1
+		ORDER BY a.id
+		LIMIT $2`, maxAttempts, limit)
+
+	if err != nil {
+		log.Error(err.Error())
+		return nil, translateError(err, "stock_alert")
+	}
+
+	defer rows.Close()
+
+	alerts := make([]structs.StockAlert, 0)
+
+	for rows.Next() {
+		var a structs.StockAlert
+
+		if err := rows.Scan(&a.Id, &a.ProductId, &a.ProductName, &a.Sku, &a.Available, &a.ReorderPoint, &a.ReorderQuantity, &a.Attempts, &a.CreatedAt); err != nil {
+			log.Error(err.Error())
+			return nil, translateError(err, "stock_alert")
+		}
+
+		alerts = append(alerts, a)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error(err.Error())
+		return nil, translateError(err, "stock_alert")
+	}
+
+	return alerts, nil
+}
+
+func (s DbSource) MarkStockAlertNotified(ctx context.Context, id int64) error {
+	result, err := s.conn.ExecContext(ctx, "UPDATE stock_alert SET notified_at = $1 WHERE id = $2", time.Now(), id)
+	return translateExecResult(result, err, "stock_alert")
+}
+
+// Counts a failed delivery, the alert is retried until it reaches the maximum number of attempts
+func (s DbSource) MarkStockAlertFailed(ctx context.Context, id int64, message string) error {
+	result, err := s.conn.ExecContext(ctx, "UPDATE stock_alert SET attempts = attempts + 1, last_error = $1 WHERE id = $2", message, id)
+	return translateExecResult(result, err, "stock_alert")
+}
@@ -0,0 +1,234 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"vayer-electric-backend/env"
+	"vayer-electric-backend/structs"
+)
+
+// Checks a reservation before it's stored and works out how long it holds stock, a zero ttl takes the default
+func validateReservation(r *structs.Reservation, ttl time.Duration) (time.Duration, error) {
+	r.Reference = strings.TrimSpace(r.Reference)
+
+	if r.Quantity <= 0 {
+		return 0, ValidationError("quantity must be positive")
+	}
+
+	if ttl < 0 {
+		return 0, ValidationError("ttl can't be negative")
+	}
+
+	if ttl == 0 {
+		ttl = env.RESERVATION_TTL
+	}
+
+	if ttl > env.RESERVATION_MAX_TTL {
+		return 0, ValidationError(fmt.Sprintf("ttl can't be longer than %d seconds", int64(env.RESERVATION_MAX_TTL/time.Second)))
+	}
+
+	return ttl, nil
+}
+
+// Stock that can still be sold or reserved. Lowering current_inventory doesn't cancel reservations
+// so reserved can be more than on hand.
+func availableStock(onHand int64, reserved int64) int64 {
+	if reserved > onHand {
+		return 0
+	}
+	return onHand - reserved
+}
+
+func foreignReservation(id int64) error {
+	return ValidationError(fmt.Sprintf("reservation %d wasn't taken for this cart", id))
+}
+
+func insufficientAvailable(available int64) error {
+	return Conflict(fmt.Sprintf("insufficient stock, %d available", available))
+}
+
+const reservationColumns = "id, product_id, coalesce(variant_id, 0), coalesce(cart_id, 0), quantity, reference, expires_at, created_at"
+
+func scanReservation(scan func(dest ...interface{}) error) (structs.Reservation, error) {
+	var r structs.Reservation
+	err := scan(&r.Id, &r.ProductId, &r.VariantId, &r.CartId, &r.Quantity, &r.Reference, &r.ExpiresAt, &r.CreatedAt)
+	return r, err
+}
+
+// Quantity of a product held by reservations that haven't expired at now, reservations of its variants
+// hold variant stock and don't count
+func reservedStock(ctx context.Context, q querier, productId int, now time.Time) (int64, error) {
+	var reserved int64
+	err := q.QueryRowContext(ctx, "SELECT coalesce(sum(quantity), 0) FROM stock_reservation WHERE product_id = $1 AND variant_id IS NULL AND expires_at > $2", productId, now).Scan(&reserved)
+
+	if err != nil {
+		log.Error(err.Error())
+		return 0, translateError(err, "stock_reservation")
+	}
+
+	return reserved, nil
+}
+
+// Quantity of a variant held by reservations that haven't expired at now
+func reservedVariantStock(ctx context.Context, q querier, variantId int64, now time.Time) (int64, error) {
+	var reserved int64
+	err := q.QueryRowContext(ctx, "SELECT coalesce(sum(quantity), 0) FROM stock_reservation WHERE variant_id = $1 AND expires_at > $2", variantId, now).Scan(&reserved)
+
+	if err != nil {
+		log.Error(err.Error())
+		return 0, translateError(err, "stock_reservation")
+	}
+
+	return reserved, nil
+}
+
+// Available stock of a variant of a product, the caller holds the product lock
+func lockedVariantAvailableStock(ctx context.Context, q querier, productId int64, variantId int64, now time.Time) (int64, error) {
+	var onHand int64
+	err := q.QueryRowContext(ctx, "SELECT current_inventory FROM product_variant WHERE id = $1 AND product_id = $2", variantId, productId).Scan(&onHand)
+
+	if err != nil {
+		log.Error(err.Error())
+		return 0, translateError(err, "product_variant")
+	}
+
+	reserved, err := reservedVariantStock(ctx, q, variantId, now)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return availableStock(onHand, reserved), nil
+}
+
+func belowReservedStock(sku string, reserved int64) error {
+	return Conflict(fmt.Sprintf("stock of %s can't go below the %d held by reservations", sku, reserved))
+}
+
+// Holds stock of a product for ttl. The product row is locked while available stock is counted
+// so two requests can't both reserve the last unit. Reserving can raise a reorder alert.
+// A reservation taken during checkout belongs to the cart of cartToken, only its checkout can release it.
+// A reservation of a variant holds the variant's stock.
+func (s DbSource) InsertReservation(ctx context.Context, r structs.Reservation, cartToken string, customerId string, ttl time.Duration) (structs.Reservation, error) {
+	ttl, err := validateReservation(&r, ttl)
+
+	if err != nil {
+		return structs.Reservation{}, err
+	}
+
+	tx, err := s.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.Reservation{}, err
+	}
+
+	defer tx.Rollback()
+
+	// The cart is key share locked before the product, checkout locks its cart before its products
+	if cartToken != "" {
+		err := tx.QueryRowContext(ctx, "SELECT id "+cartLookup+" FOR KEY SHARE", cartToken, customerId).Scan(&r.CartId)
+
+		if err != nil {
+			log.Error(err.Error())
+			return structs.Reservation{}, translateError(err, "cart")
+		}
+	}
+
+	if err := lockProduct(ctx, tx, int(r.ProductId)); err != nil {
+		return structs.Reservation{}, err
+	}
+
+	now := time.Now()
+
+	var available int64
+	if r.VariantId != 0 {
+		available, err = lockedVariantAvailableStock(ctx, tx, r.ProductId, r.VariantId, now)
+	} else {
+		available, err = lockedAvailableStock(ctx, tx, int(r.ProductId))
+	}
+
+	if err != nil {
+		return structs.Reservation{}, err
+	}
+
+	if r.Quantity > available {
+		return structs.Reservation{}, insufficientAvailable(available)
+	}
+
+	row := tx.QueryRowContext(ctx, "INSERT INTO stock_reservation (product_id, variant_id, cart_id, quantity, reference, expires_at, created_at) VALUES ($1, NULLIF($2, 0), NULLIF($3, 0), $4, $5, $6, $7) RETURNING "+reservationColumns,
+		r.ProductId, r.VariantId, r.CartId, r.Quantity, r.Reference, now.Add(ttl), now)
+
+	created, err := scanReservation(row.Scan)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.Reservation{}, translateError(err, "stock_reservation")
+	}
+
+	// Reorder points are kept on products, variant stock doesn't raise alerts
+	if r.VariantId == 0 {
+		if err := recordStockAlert(ctx, tx, int(r.ProductId), available); err != nil {
+			return structs.Reservation{}, err
+		}
+	}
+
+	return created, tx.Commit()
+}
+
+// Returns the reservations of a product that haven't expired, oldest first
+func (s DbSource) GetReservations(ctx context.Context, productId int) ([]structs.Reservation, error) {
+	if err := productExists(ctx, s.conn, productId); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.conn.QueryContext(ctx, "SELECT "+reservationColumns+" FROM stock_reservation WHERE product_id = $1 AND expires_at > $2 ORDER BY id", productId, time.Now())
+
+	if err != nil {
+		log.Error(err.Error())
+		return nil, translateError(err, "stock_reservation")
+	}
+
+	defer rows.Close()
+
+	reservations := make([]structs.Reservation, 0)
+
+	for rows.Next() {
+		r, err := scanReservation(rows.Scan)
+
+		if err != nil {
+			log.Error(err.Error())
+			return nil, translateError(err, "stock_reservation")
+		}
+
+		reservations = append(reservations, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error(err.Error())
+		return nil, translateError(err, "stock_reservation")
+	}
+
+	return reservations, nil
+}
+
+// Releases a reservation before it expires
+func (s DbSource) DeleteReservation(ctx context.Context, id int) error {
+	result, err := s.conn.ExecContext(ctx, "DELETE FROM stock_reservation WHERE id = $1", id)
+	return translateExecResult(result, err, "stock_reservation")
+}
+
+// Removes reservations that expired before now and returns how many there were
+func (s DbSource) DeleteExpiredReservations(ctx context.Context, now time.Time) (int64, error) {
+	result, err := s.conn.ExecContext(ctx, "DELETE FROM stock_reservation WHERE expires_at <= $1", now)
+
+	if err != nil {
+		log.Error(err.Error())
+		return 0, translateError(err, "stock_reservation")
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,284 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"vayer-electric-backend/structs"
+)
+
+// Direction every reason moves stock in, 0 for either
+var stockReasons = map[string]int{
+	"receipt":    1,
+	"return":     1,
+	"sale":       -1,
+	"damage":     -1,
+	"adjustment": 0,
+	"transfer":   0,
+}
+
+// Reference recorded on the movements that replace direct writes to current_inventory
+const (
+	openingBalanceReference = "opening balance"
+	productUpdateReference  = "product update"
+	variantUpdateReference  = "variant update"
+)
+
+func validateStockMovement(m *structs.StockMovement) error {
+	m.Reason = strings.ToLower(strings.TrimSpace(m.Reason))
+	m.User = strings.TrimSpace(m.User)
+	m.Reference = strings.TrimSpace(m.Reference)
+
+	direction, ok := stockReasons[m.Reason]
+
+	// Transfer movements come in pairs and are only recorded by TransferStock
+	if !ok || m.Reason == "transfer" {
+		return ValidationError("reason must be one of receipt, sale, adjustment, return or damage")
+	}
+
+	if m.Quantity == 0 {
+		return ValidationError("quantity can't be zero")
+	}
+
+	if m.VariantId != 0 && m.LocationId != 0 {
+		return ValidationError("variant stock isn't kept per location, leave location_id out")
+	}
+
+	if direction > 0 && m.Quantity < 0 {
+		return ValidationError(fmt.Sprintf("%s quantities add stock and must be positive", m.Reason))
+	}
+
+	if direction < 0 && m.Quantity > 0 {
+		return ValidationError(fmt.Sprintf("%s quantities take stock away and must be negative", m.Reason))
+	}
+
+	return nil
+}
+
+// Checks the stock left at a location after a movement, nothing can take more than what's on hand there
+func stockBalance(current int64, quantity int64) (int64, error) {
+	if current+quantity < 0 {
+		return 0, Conflict(fmt.Sprintf("insufficient stock, %d on hand", current))
+	}
+
+	return current + quantity, nil
+}
+
+// Lists paged by id newest first take the id of the last item they returned as cursor
+func parseIdCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.ParseInt(cursor, 10, 64)
+
+	if err != nil || id <= 0 {
+		return 0, ValidationError("invalid cursor")
+	}
+
+	return id, nil
+}
+
+const stockMovementColumns = "id, product_id, coalesce(variant_id, 0), coalesce(location_id, 0), reason, quantity, balance, created_by, reference, created_at"
+
+func scanStockMovement(scan func(dest ...interface{}) error) (structs.StockMovement, error) {
+	var m structs.StockMovement
+	err := scan(&m.Id, &m.ProductId, &m.VariantId, &m.LocationId, &m.Reason, &m.Quantity, &m.Balance, &m.User, &m.Reference, &m.CreatedAt)
+	return m, err
+}
+
+// Applies a movement to the stock of a product at a location and appends it to the ledger, movements without
+// a location go to the default one. The product row stays locked until tx ends so concurrent movements
+// of a product are applied one after the other.
+func insertStockMovement(ctx context.Context, tx *sql.Tx, m structs.StockMovement) (structs.StockMovement, error) {
+	if err := lockProduct(ctx, tx, int(m.ProductId)); err != nil {
+		return structs.StockMovement{}, err
+	}
+
+	if m.VariantId != 0 {
+		return insertVariantStockMovement(ctx, tx, m)
+	}
+
+	if m.LocationId == 0 {
+		err := tx.QueryRowContext(ctx, "SELECT id FROM location WHERE is_default").Scan(&m.LocationId)
+
+		if err != nil {
+			log.Error(err.Error())
+			return structs.StockMovement{}, translateError(err, "location")
+		}
+	}
+
+	var current int64
+	err := tx.QueryRowContext(ctx, "SELECT coalesce((SELECT quantity FROM product_stock WHERE product_id = $1 AND location_id = $2), 0)", m.ProductId, m.LocationId).Scan(&current)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.StockMovement{}, translateError(err, "product_stock")
+	}
+
+	balance, err := stockBalance(current, m.Quantity)
+
+	if err != nil {
+		return structs.StockMovement{}, err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO product_stock (product_id, location_id, quantity) VALUES ($1, $2, $3) ON CONFLICT (product_id, location_id) DO UPDATE SET quantity = EXCLUDED.quantity",
+		m.ProductId, m.LocationId, balance)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.StockMovement{}, translateError(err, "product_stock")
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE product SET current_inventory = current_inventory + $1 WHERE id = $2", m.Quantity, m.ProductId); err != nil {
+		log.Error(err.Error())
+		return structs.StockMovement{}, translateError(err, "product")
+	}
+
+	row := tx.QueryRowContext(ctx, "INSERT INTO stock_movement (product_id, location_id, reason, quantity, balance, created_by, reference, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING "+stockMovementColumns,
+		m.ProductId, m.LocationId, m.Reason, m.Quantity, balance, m.User, m.Reference, time.Now())
+
+	created, err := scanStockMovement(row.Scan)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.StockMovement{}, translateError(err, "stock_movement")
+	}
+
+	return created, nil
+}
+
+// Applies a movement to the stock of a variant, the caller locked its product
+func insertVariantStockMovement(ctx context.Context, tx *sql.Tx, m structs.StockMovement) (structs.StockMovement, error) {
+	var current int64
+	err := tx.QueryRowContext(ctx, "SELECT current_inventory FROM product_variant WHERE id = $1 AND product_id = $2", m.VariantId, m.ProductId).Scan(&current)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.StockMovement{}, translateError(err, "product_variant")
+	}
+
+	balance, err := stockBalance(current, m.Quantity)
+
+	if err != nil {
+		return structs.StockMovement{}, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE product_variant SET current_inventory = $1 WHERE id = $2", balance, m.VariantId); err != nil {
+		log.Error(err.Error())
+		return structs.StockMovement{}, translateError(err, "product_variant")
+	}
+
+	row := tx.QueryRowContext(ctx, "INSERT INTO stock_movement (product_id, variant_id, reason, quantity, balance, created_by, reference, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING "+stockMovementColumns,
+		m.ProductId, m.VariantId, m.Reason, m.Quantity, balance, m.User, m.Reference, time.Now())
+
+	created, err := scanStockMovement(row.Scan)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.StockMovement{}, translateError(err, "stock_movement")
+	}
+
+	return created, nil
+}
+
+// Records a stock movement, one that takes the product below its reorder point raises an alert
+func (s DbSource) InsertStockMovement(ctx context.Context, m structs.StockMovement) (structs.StockMovement, error) {
+	if err := validateStockMovement(&m); err != nil {
+		return structs.StockMovement{}, err
+	}
+
+	tx, err := s.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.StockMovement{}, err
+	}
+
+	defer tx.Rollback()
+
+	if err := lockProduct(ctx, tx, int(m.ProductId)); err != nil {
+		return structs.StockMovement{}, err
+	}
+
+	before, err := lockedAvailableStock(ctx, tx, int(m.ProductId))
+
+	if err != nil {
+		return structs.StockMovement{}, err
+	}
+
+	created, err := insertStockMovement(ctx, tx, m)
+
+	if err != nil {
+		return structs.StockMovement{}, err
+	}
+
+	if err := recordStockAlert(ctx, tx, int(m.ProductId), before); err != nil {
+		return structs.StockMovement{}, err
+	}
+
+	return created, tx.Commit()
+}
+
+// Returns the stock history of a product newest first, cursor is the next_cursor of the previous page
+func (s DbSource) GetStockMovements(ctx context.Context, productId int, limit int, cursor string) (structs.StockMovementPage, error) {
+	before, err := parseIdCursor(cursor)
+
+	if err != nil {
+		return structs.StockMovementPage{}, err
+	}
+
+	if limit <= 0 || limit > MaxPageSize {
+		limit = DefaultPageSize
+	}
+
+	if err := productExists(ctx, s.conn, productId); err != nil {
+		return structs.StockMovementPage{}, err
+	}
+
+	rows, err := s.conn.QueryContext(ctx, "SELECT "+stockMovementColumns+" FROM stock_movement WHERE product_id = $1 AND ($2 = 0 OR id < $2) ORDER BY id DESC LIMIT $3",
+		productId, before, limit+1)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.StockMovementPage{}, translateError(err, "stock_movement")
+	}
+
+	defer rows.Close()
+
+	movements := make([]structs.StockMovement, 0, limit)
+
+	for rows.Next() {
+		m, err := scanStockMovement(rows.Scan)
+
+		if err != nil {
+			log.Error(err.Error())
+			return structs.StockMovementPage{}, translateError(err, "stock_movement")
+		}
+
+		movements = append(movements, m)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error(err.Error())
+		return structs.StockMovementPage{}, translateError(err, "stock_movement")
+	}
+
+	return newStockMovementPage(movements, limit), nil
+}
+
+// Cuts the extra row fetched to know whether there's a next page
+func newStockMovementPage(movements []structs.StockMovement, limit int) structs.StockMovementPage {
+	page := structs.StockMovementPage{Items: movements}
+
+	if len(movements) > limit {
+		page.Items = movements[:limit]
+		page.NextCursor = strconv.FormatInt(page.Items[limit-1].Id, 10)
+	}
+
+	return page
+}
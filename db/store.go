@@ -0,0 +1,138 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"vayer-electric-backend/env"
+	"vayer-electric-backend/money"
+	"vayer-electric-backend/structs"
+	"vayer-electric-backend/tax"
+)
+
+// Store is the catalog persistence layer used by the handlers.
+// DbSource is the Postgres implementation and MemoryStore keeps everything in process.
+type Store interface {
+	ValidateConnection(ctx context.Context) bool
+	Migrate(path string) error
+	Close() error
+	Stats() sql.DBStats
+
+	InsertProduct(ctx context.Context, name string, description string, subcategory_id int, price money.Amount, currentInventory int, imageUrl string, brand string, sku string) error
+	UpdateProduct(ctx context.Context, id int, name string, price money.Amount, currentInventory int) error
+	DeleteProduct(ctx context.Context, id int) error
+	GetProducts(ctx context.Context) ([]structs.Product, error)
+	GetProductById(ctx context.Context, id int) (structs.Product, error)
+	GetProductByName(ctx context.Context, name string) (structs.Product, error)
+	GetProductsBySubcategoryId(ctx context.Context, subcategory_id int) ([]structs.Product, error)
+	GetProductsByCategoryId(ctx context.Context, categoryId int) ([]structs.Product, error)
+	GetProductsByCategoryName(ctx context.Context, categoryName string) ([]structs.Product, error)
+	ListProducts(ctx context.Context, q ProductQuery) (structs.ProductPage, error)
+
+	SetProductAttributes(ctx context.Context, productId int, values map[string]interface{}) error
+	InsertAttributeDefinition(ctx context.Context, def structs.AttributeDefinition) (structs.AttributeDefinition, error)
+	DeleteAttributeDefinition(ctx context.Context, subcategoryId int, id int) error
+	GetAttributeDefinitions(ctx context.Context, subcategoryId int) ([]structs.AttributeDefinition, error)
+
+	GetVariantMatrix(ctx context.Context, productId int) (structs.VariantMatrix, error)
+	SetVariantAxes(ctx context.Context, productId int, axes []string) error
+	InsertProductVariant(ctx context.Context, v structs.ProductVariant) (structs.ProductVariant, error)
+	UpdateProductVariant(ctx context.Context, v structs.ProductVariant) (structs.ProductVariant, error)
+	SetProductVariantImage(ctx context.Context, productId int, variantId int, imageUrl string) (structs.ProductVariant, error)
+	DeleteProductVariant(ctx context.Context, productId int, variantId int) error
+
+	GetProductImages(ctx context.Context, productId int) ([]structs.ProductImage, error)
+	InsertProductImage(ctx context.Context, img structs.ProductImage) (structs.ProductImage, error)
+	UpdateProductImage(ctx context.Context, img structs.ProductImage) (structs.ProductImage, error)
+	ReorderProductImages(ctx context.Context, productId int, ids []int64) ([]structs.ProductImage, error)
+	DeleteProductImage(ctx context.Context, productId int, imageId int) error
+
+	InsertStockMovement(ctx context.Context, m structs.StockMovement) (structs.StockMovement, error)
+	GetStockMovements(ctx context.Context, productId int, limit int, cursor string) (structs.StockMovementPage, error)
+
+	GetWarehouses(ctx context.Context) ([]structs.Warehouse, error)
+	InsertWarehouse(ctx context.Context, name string) (structs.Warehouse, error)
+	DeleteWarehouse(ctx context.Context, id int) error
+	InsertLocation(ctx context.Context, l structs.Location) (structs.Location, error)
+	DeleteLocation(ctx context.Context, warehouseId int, id int) error
+	GetProductStock(ctx context.Context, productId int) (structs.ProductStock, error)
+	TransferStock(ctx context.Context, t structs.StockTransfer) ([]structs.StockMovement, error)
+
+	InsertReservation(ctx context.Context, r structs.Reservation, cartToken string, customerId string, ttl time.Duration) (structs.Reservation, error)
+	GetReservations(ctx context.Context, productId int) ([]structs.Reservation, error)
+	DeleteReservation(ctx context.Context, id int) error
+	DeleteExpiredReservations(ctx context.Context, now time.Time) (int64, error)
+
+	SetReorderPoint(ctx context.Context, productId int, point int64, quantity int64) error
+	GetLowStockProducts(ctx context.Context) ([]structs.LowStockProduct, error)
+	GetPendingStockAlerts(ctx context.Context, limit int, maxAttempts int) ([]structs.StockAlert, error)
+	MarkStockAlertNotified(ctx context.Context, id int64) error
+	MarkStockAlertFailed(ctx context.Context, id int64, message string) error
+
+	InsertCart(ctx context.Context, customerId string, region string) (structs.Cart, error)
+	GetCart(ctx context.Context, token string, customerId string) (structs.Cart, error)
+	SetCartRegion(ctx context.Context, token string, customerId string, region string) (structs.Cart, error)
+	AddCartLine(ctx context.Context, token string, customerId string, productId int64, variantId int64, quantity int64) (structs.Cart, error)
+	UpdateCartLine(ctx context.Context, token string, customerId string, lineId int, quantity int64) (structs.Cart, error)
+	DeleteCartLine(ctx context.Context, token string, customerId string, lineId int) (structs.Cart, error)
+	MergeCart(ctx context.Context, token string, customerId string) (structs.Cart, error)
+
+	Checkout(ctx context.Context, token string, customerId string, reservationIds []int64) (structs.Order, error)
+	GetOrders(ctx context.Context, customerId string, status string, limit int, cursor string) (structs.OrderPage, error)
+	GetOrderById(ctx context.Context, id int) (structs.Order, error)
+	GetOrderByToken(ctx context.Context, token string, customerId string) (structs.Order, error)
+	TransitionOrder(ctx context.Context, id int, t structs.OrderTransition) (structs.Order, error)
+
+	InsertPayment(ctx context.Context, p structs.Payment) (structs.Payment, error)
+	GetPaymentById(ctx context.Context, id int) (structs.Payment, error)
+	GetPayments(ctx context.Context, orderId int) ([]structs.Payment, error)
+	UpdatePayment(ctx context.Context, id int64, e structs.PaymentEvent) (structs.Payment, error)
+	ApplyPaymentEvent(ctx context.Context, provider string, e structs.PaymentEvent) (structs.Payment, error)
+
+	GetTaxClasses(ctx context.Context) ([]structs.TaxClass, error)
+	GetTaxTable(ctx context.Context) (tax.Table, error)
+	InsertTaxClass(ctx context.Context, name string) (structs.TaxClass, error)
+	DeleteTaxClass(ctx context.Context, id int) error
+	SetTaxRate(ctx context.Context, r structs.TaxRate) (structs.TaxRate, error)
+	DeleteTaxRate(ctx context.Context, taxClassId int, region string) error
+	SetCategoryTaxClass(ctx context.Context, id int, taxClassId int64) error
+	SetSubcategoryTaxClass(ctx context.Context, id int, taxClassId int64) error
+	SetProductTaxClass(ctx context.Context, id int, taxClassId int64) error
+
+	InsertSubcategory(ctx context.Context, name string, description string, category_id int, image_url string) error
+	UpdateSubcategory(ctx context.Context, id int, name string, description string, category_id int, image_url string) error
+	DeleteSubcategory(ctx context.Context, id int) error
+	GetSubcategories(ctx context.Context) ([]structs.Subcategory, error)
+	GetSubcategoryById(ctx context.Context, id int) (structs.Subcategory, error)
+	GetSubcategoryByName(ctx context.Context, name string) (structs.Subcategory, error)
+	GetSubcategoriesByCategoryId(ctx context.Context, category_id int) ([]structs.Subcategory, error)
+	SetSubcategoryImage(ctx context.Context, id int, imageUrl string) (string, error)
+
+	InsertCategory(ctx context.Context, name string, description string, image_url string) error
+	UpdateCategory(ctx context.Context, id int, name string, description string, image_url string) error
+	DeleteCategory(ctx context.Context, id int) error
+	GetCategories(ctx context.Context) ([]structs.Category, error)
+	GetCategoryById(ctx context.Context, id int) (structs.Category, error)
+	GetCategoryByName(ctx context.Context, name string) (structs.Category, error)
+	SetCategoryImage(ctx context.Context, id int, imageUrl string) (string, error)
+
+	ImageReferenced(ctx context.Context, imageUrl string) (bool, error)
+	ImageReferences(ctx context.Context) (map[string]bool, error)
+}
+
+var (
+	_ Store = DbSource{}
+	_ Store = (*MemoryStore)(nil)
+)
+
+// Returns the Store selected by env.STORE_DRIVER
+func GetStore() Store {
+	switch env.STORE_DRIVER {
+	case "memory":
+		log.Info("using in-memory store, data will not survive a restart")
+		return NewMemoryStore()
+	default:
+		return GetDbSource()
+	}
+}
@@ -0,0 +1,236 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"vayer-electric-backend/money"
+	"vayer-electric-backend/structs"
+	"vayer-electric-backend/tax"
+)
+
+func validateRegion(region string) (string, error) {
+	normalized, ok := tax.NormalizeRegion(region)
+
+	if !ok {
+		return "", ValidationError("region must be up to 16 letters, digits and dashes, or " + tax.AnyRegion)
+	}
+
+	return normalized, nil
+}
+
+func validateTaxRate(r *structs.TaxRate) error {
+	region, err := validateRegion(r.Region)
+
+	if err != nil {
+		return err
+	}
+
+	if r.Rate < 0 || r.Rate > money.MaxRate {
+		return ValidationError("rate must be between 0 and 100")
+	}
+
+	r.Region = region
+	return nil
+}
+
+// Tax classes with their rates, the default class first
+func loadTaxClasses(ctx context.Context, q querier) ([]structs.TaxClass, error) {
+	rows, err := q.QueryContext(ctx, "SELECT id, name, is_default, created_at FROM tax_class ORDER BY is_default DESC, id")
+
+	if err != nil {
+		log.Error(err.Error())
+		return nil, translateError(err, "tax_class")
+	}
+
+	defer rows.Close()
+
+	classes := make([]structs.TaxClass, 0)
+	byId := make(map[int64]int)
+
+	for rows.Next() {
+		c := structs.TaxClass{Rates: make([]structs.TaxRate, 0)}
+
+		if err := rows.Scan(&c.Id, &c.Name, &c.Default, &c.CreatedAt); err != nil {
+			log.Error(err.Error())
+			return nil, translateError(err, "tax_class")
+		}
+
+		byId[c.Id] = len(classes)
+		classes = append(classes, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error(err.Error())
+		return nil, translateError(err, "tax_class")
+	}
+
+	rateRows, err := q.QueryContext(ctx, "SELECT "+taxRateColumns+" FROM tax_rate ORDER BY region")
+
+	if err != nil {
+		log.Error(err.Error())
+		return nil, translateError(err, "tax_rate")
+	}
+
+	defer rateRows.Close()
+
+	for rateRows.Next() {
+		r, err := scanTaxRate(rateRows.Scan)
+
+		if err != nil {
+			log.Error(err.Error())
+			return nil, translateError(err, "tax_rate")
+		}
+
+		if i, ok := byId[r.TaxClassId]; ok {
+			classes[i].Rates = append(classes[i].Rates, r)
+		}
+	}
+
+	if err = rateRows.Err(); err != nil {
+		log.Error(err.Error())
+		return nil, translateError(err, "tax_rate")
+	}
+
+	return classes, nil
+}
+
+const taxRateColumns = "id, tax_class_id, region, rate, created_at"
+
+func scanTaxRate(scan func(dest ...interface{}) error) (structs.TaxRate, error) {
+	var r structs.TaxRate
+	err := scan(&r.Id, &r.TaxClassId, &r.Region, &r.Rate, &r.CreatedAt)
+	return r, err
+}
+
+// Loads the tax tables, checkout loads them in its transaction so an order is taxed with the rates it reads stock with
+func loadTaxTable(ctx context.Context, q querier) (tax.Table, error) {
+	classes, err := loadTaxClasses(ctx, q)
+
+	if err != nil {
+		return tax.Table{}, err
+	}
+
+	rows, err := q.QueryContext(ctx, "SELECT s.id, coalesce(s.tax_class_id, 0), coalesce(c.tax_class_id, 0) FROM subcategory s JOIN category c ON c.id = s.category_id")
+
+	if err != nil {
+		log.Error(err.Error())
+		return tax.Table{}, translateError(err, "subcategory")
+	}
+
+	defer rows.Close()
+
+	assignments := make([]tax.Assignment, 0)
+
+	for rows.Next() {
+		var a tax.Assignment
+
+		if err := rows.Scan(&a.SubcategoryId, &a.SubcategoryClassId, &a.CategoryClassId); err != nil {
+			log.Error(err.Error())
+			return tax.Table{}, translateError(err, "subcategory")
+		}
+
+		assignments = append(assignments, a)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error(err.Error())
+		return tax.Table{}, translateError(err, "subcategory")
+	}
+
+	return tax.NewTable(classes, assignments), nil
+}
+
+func (s DbSource) GetTaxClasses(ctx context.Context) ([]structs.TaxClass, error) {
+	return loadTaxClasses(ctx, s.conn)
+}
+
+func (s DbSource) GetTaxTable(ctx context.Context) (tax.Table, error) {
+	return loadTaxTable(ctx, s.conn)
+}
+
+func (s DbSource) InsertTaxClass(ctx context.Context, name string) (structs.TaxClass, error) {
+	name = strings.TrimSpace(name)
+
+	if name == "" {
+		return structs.TaxClass{}, ValidationError("name is required")
+	}
+
+	c := structs.TaxClass{Rates: make([]structs.TaxRate, 0)}
+	err := s.conn.QueryRowContext(ctx, "INSERT INTO tax_class (name, created_at) VALUES ($1, $2) RETURNING id, name, is_default, created_at", name, time.Now()).Scan(&c.Id, &c.Name, &c.Default, &c.CreatedAt)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.TaxClass{}, translateError(err, "tax_class")
+	}
+
+	return c, nil
+}
+
+// Products, subcategories and categories of a deleted class go back to the class they'd inherit,
+// the default class can't be deleted
+func (s DbSource) DeleteTaxClass(ctx context.Context, id int) error {
+	var isDefault bool
+	err := s.conn.QueryRowContext(ctx, "SELECT is_default FROM tax_class WHERE id = $1", id).Scan(&isDefault)
+
+	if err != nil {
+		log.Error(err.Error())
+		return translateError(err, "tax_class")
+	}
+
+	if isDefault {
+		return Conflict("the default tax class can't be deleted")
+	}
+
+	result, err := s.conn.ExecContext(ctx, "DELETE FROM tax_class WHERE id = $1", id)
+	return translateExecResult(result, err, "tax_class")
+}
+
+// Sets the rate of a class in a region, replacing the rate it had there
+func (s DbSource) SetTaxRate(ctx context.Context, r structs.TaxRate) (structs.TaxRate, error) {
+	if err := validateTaxRate(&r); err != nil {
+		return structs.TaxRate{}, err
+	}
+
+	row := s.conn.QueryRowContext(ctx, "INSERT INTO tax_rate (tax_class_id, region, rate, created_at) VALUES ($1, $2, $3, $4) ON CONFLICT (tax_class_id, region) DO UPDATE SET rate = EXCLUDED.rate RETURNING "+taxRateColumns,
+		r.TaxClassId, r.Region, r.Rate, time.Now())
+
+	rate, err := scanTaxRate(row.Scan)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.TaxRate{}, translateError(err, "tax_rate")
+	}
+
+	return rate, nil
+}
+
+func (s DbSource) DeleteTaxRate(ctx context.Context, taxClassId int, region string) error {
+	region, err := validateRegion(region)
+
+	if err != nil {
+		return err
+	}
+
+	result, err := s.conn.ExecContext(ctx, "DELETE FROM tax_rate WHERE tax_class_id = $1 AND region = $2", taxClassId, region)
+	return translateExecResult(result, err, "tax_rate")
+}
+
+// Sets the tax class of a row of table, 0 clears it
+func (s DbSource) setTaxClass(ctx context.Context, table string, id int, taxClassId int64) error {
+	result, err := s.conn.ExecContext(ctx, "UPDATE "+table+" SET tax_class_id = NULLIF($1, 0) WHERE id = $2", taxClassId, id)
+	return translateExecResult(result, err, table)
+}
+
+func (s DbSource) SetCategoryTaxClass(ctx context.Context, id int, taxClassId int64) error {
+	return s.setTaxClass(ctx, "category", id, taxClassId)
+}
+
+func (s DbSource) SetSubcategoryTaxClass(ctx context.Context, id int, taxClassId int64) error {
+	return s.setTaxClass(ctx, "subcategory", id, taxClassId)
+}
+
+func (s DbSource) SetProductTaxClass(ctx context.Context, id int, taxClassId int64) error {
+	return s.setTaxClass(ctx, "product", id, taxClassId)
+}
@@ -0,0 +1,416 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"vayer-electric-backend/structs"
+)
+
+// Cleans up the variant axes of a product, axis names follow the attribute code format
+func validateVariantAxes(axes []string) ([]string, error) {
+	cleaned := make([]string, 0, len(axes))
+	seen := make(map[string]bool)
+
+	for _, axis := range axes {
+		axis = strings.TrimSpace(axis)
+
+		if !attributeCodePattern.MatchString(axis) {
+			return nil, ValidationError("axes must be lowercase letters, digits or underscores and start with a letter")
+		}
+
+		if seen[axis] {
+			return nil, ValidationError(fmt.Sprintf("axis %s is listed twice", axis))
+		}
+
+		seen[axis] = true
+		cleaned = append(cleaned, axis)
+	}
+
+	return cleaned, nil
+}
+
+// Checks a variant before it's stored, its options have to give a value for every axis of the product and nothing else
+func validateVariant(v *structs.ProductVariant, axes []string) error {
+	v.Sku = strings.TrimSpace(v.Sku)
+
+	if v.Sku == "" {
+		return ValidationError("sku is required")
+	}
+
+	if v.Price < 0 {
+		return ValidationError("price can't be negative")
+	}
+
+	if v.CurrentInventory < 0 {
+		return ValidationError("current_inventory can't be negative")
+	}
+
+	if len(axes) == 0 {
+		return ValidationError("the product has no variant axes, set them first")
+	}
+
+	options := make(map[string]string, len(axes))
+
+	for _, axis := range axes {
+		value := strings.TrimSpace(v.Options[axis])
+
+		if value == "" {
+			return ValidationError(fmt.Sprintf("option %s is required", axis))
+		}
+
+		options[axis] = value
+	}
+
+	if len(v.Options) != len(axes) {
+		for name := range v.Options {
+			if _, ok := options[name]; !ok {
+				return ValidationError(fmt.Sprintf("%s is not a variant axis of the product", name))
+			}
+		}
+	}
+
+	v.Options = options
+
+	return nil
+}
+
+func sameAxes(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	set := make(map[string]bool, len(a))
+	for _, axis := range a {
+		set[axis] = true
+	}
+
+	for _, axis := range b {
+		if !set[axis] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Builds the matrix of a product from its axes and its variants, axis values come in the order variants use them
+func newVariantMatrix(axes []string, variants []structs.ProductVariant) structs.VariantMatrix {
+	matrix := structs.VariantMatrix{
+		Axes:  make([]structs.VariantAxis, 0, len(axes)),
+		Items: variants,
+	}
+
+	for _, axis := range axes {
+		values := make([]string, 0)
+		seen := make(map[string]bool)
+
+		for _, v := range variants {
+			if value := v.Options[axis]; !seen[value] {
+				seen[value] = true
+				values = append(values, value)
+			}
+		}
+
+		matrix.Axes = append(matrix.Axes, structs.VariantAxis{Name: axis, Values: values})
+	}
+
+	return matrix
+}
+
+const productVariantColumns = "id, product_id, sku, price, current_inventory, coalesce(image_url, ''), options, created_at"
+
+func scanProductVariant(scan func(dest ...interface{}) error) (structs.ProductVariant, error) {
+	var v structs.ProductVariant
+	var options []byte
+
+	if err := scan(&v.Id, &v.ProductId, &v.Sku, &v.Price, &v.CurrentInventory, &v.ImageUrl, &options, &v.CreatedAt); err != nil {
+		return v, err
+	}
+
+	err := json.Unmarshal(options, &v.Options)
+
+	return v, err
+}
+
+// Locks the product row so the variants or the gallery of a product are changed one request at a time
+func lockProduct(ctx context.Context, tx *sql.Tx, productId int) error {
+	var id int
+	err := tx.QueryRowContext(ctx, "SELECT id FROM product WHERE id = $1 FOR UPDATE", productId).Scan(&id)
+	return translateError(err, "product")
+}
+
+func (s DbSource) variantAxes(ctx context.Context, q querier, productId int) ([]string, error) {
+	rows, err := q.QueryContext(ctx, "SELECT name FROM product_variant_axis WHERE product_id = $1 ORDER BY position", productId)
+
+	if err != nil {
+		log.Error(err.Error())
+		return nil, translateError(err, "product_variant_axis")
+	}
+
+	defer rows.Close()
+
+	axes := make([]string, 0)
+
+	for rows.Next() {
+		var axis string
+
+		if err := rows.Scan(&axis); err != nil {
+			log.Error(err.Error())
+			return nil, translateError(err, "product_variant_axis")
+		}
+
+		axes = append(axes, axis)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error(err.Error())
+		return nil, translateError(err, "product_variant_axis")
+	}
+
+	return axes, nil
+}
+
+func (s DbSource) variantMatrix(ctx context.Context, q querier, productId int) (structs.VariantMatrix, error) {
+	axes, err := s.variantAxes(ctx, q, productId)
+
+	if err != nil {
+		return structs.VariantMatrix{}, err
+	}
+
+	rows, err := q.QueryContext(ctx, "SELECT "+productVariantColumns+" FROM product_variant WHERE product_id = $1 ORDER BY id", productId)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.VariantMatrix{}, translateError(err, "product_variant")
+	}
+
+	defer rows.Close()
+
+	variants := make([]structs.ProductVariant, 0)
+
+	for rows.Next() {
+		v, err := scanProductVariant(rows.Scan)
+
+		if err != nil {
+			log.Error(err.Error())
+			return structs.VariantMatrix{}, translateError(err, "product_variant")
+		}
+
+		variants = append(variants, v)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error(err.Error())
+		return structs.VariantMatrix{}, translateError(err, "product_variant")
+	}
+
+	return newVariantMatrix(axes, variants), nil
+}
+
+func (s DbSource) GetVariantMatrix(ctx context.Context, productId int) (structs.VariantMatrix, error) {
+	if err := productExists(ctx, s.conn, productId); err != nil {
+		return structs.VariantMatrix{}, err
+	}
+
+	return s.variantMatrix(ctx, s.conn, productId)
+}
+
+// Replaces the variant axes of a product. Axes can be reordered at any time but only
+// changed while the product has no variants, their options would no longer match.
+func (s DbSource) SetVariantAxes(ctx context.Context, productId int, axes []string) error {
+	axes, err := validateVariantAxes(axes)
+
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		log.Error(err.Error())
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if err := lockProduct(ctx, tx, productId); err != nil {
+		return err
+	}
+
+	current, err := s.variantAxes(ctx, tx, productId)
+
+	if err != nil {
+		return err
+	}
+
+	if !sameAxes(current, axes) {
+		var variants int
+		err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM product_variant WHERE product_id = $1", productId).Scan(&variants)
+
+		if err != nil {
+			log.Error(err.Error())
+			return translateError(err, "product_variant")
+		}
+
+		if variants > 0 {
+			return Conflict("the axes of a product with variants can't change, delete its variants first")
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM product_variant_axis WHERE product_id = $1", productId); err != nil {
+		log.Error(err.Error())
+		return translateError(err, "product_variant_axis")
+	}
+
+	for i, axis := range axes {
+		_, err := tx.ExecContext(ctx, "INSERT INTO product_variant_axis (product_id, name, position) VALUES ($1, $2, $3)", productId, axis, i)
+
+		if err != nil {
+			log.Error(err.Error())
+			return translateError(err, "product_variant_axis")
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s DbSource) InsertProductVariant(ctx context.Context, v structs.ProductVariant) (structs.ProductVariant, error) {
+	tx, err := s.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.ProductVariant{}, err
+	}
+
+	defer tx.Rollback()
+
+	if err := lockProduct(ctx, tx, int(v.ProductId)); err != nil {
+		return structs.ProductVariant{}, err
+	}
+
+	axes, err := s.variantAxes(ctx, tx, int(v.ProductId))
+
+	if err != nil {
+		return structs.ProductVariant{}, err
+	}
+
+	if err := validateVariant(&v, axes); err != nil {
+		return structs.ProductVariant{}, err
+	}
+
+	options, err := json.Marshal(v.Options)
+
+	if err != nil {
+		return structs.ProductVariant{}, err
+	}
+
+	row := tx.QueryRowContext(ctx, "INSERT INTO product_variant (product_id, sku, price, current_inventory, image_url, options, created_at) VALUES ($1, $2, $3, 0, NULLIF($4, ''), $5, $6) RETURNING "+productVariantColumns,
+		v.ProductId, v.Sku, v.Price, v.ImageUrl, options, time.Now())
+
+	created, err := scanProductVariant(row.Scan)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.ProductVariant{}, translateError(err, "product_variant")
+	}
+
+	if v.CurrentInventory > 0 {
+		opening := structs.StockMovement{ProductId: v.ProductId, VariantId: created.Id, Reason: "adjustment", Quantity: v.CurrentInventory, Reference: openingBalanceReference}
+		if _, err := insertStockMovement(ctx, tx, opening); err != nil {
+			return structs.ProductVariant{}, err
+		}
+
+		created.CurrentInventory = v.CurrentInventory
+	}
+
+	return created, tx.Commit()
+}
+
+// Updates the sku, price, stock and options of a variant, its image is changed through SetProductVariantImage.
+// A change of current_inventory is recorded in the stock ledger as an adjustment.
+func (s DbSource) UpdateProductVariant(ctx context.Context, v structs.ProductVariant) (structs.ProductVariant, error) {
+	tx, err := s.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.ProductVariant{}, err
+	}
+
+	defer tx.Rollback()
+
+	if err := lockProduct(ctx, tx, int(v.ProductId)); err != nil {
+		return structs.ProductVariant{}, err
+	}
+
+	axes, err := s.variantAxes(ctx, tx, int(v.ProductId))
+
+	if err != nil {
+		return structs.ProductVariant{}, err
+	}
+
+	if err := validateVariant(&v, axes); err != nil {
+		return structs.ProductVariant{}, err
+	}
+
+	options, err := json.Marshal(v.Options)
+
+	if err != nil {
+		return structs.ProductVariant{}, err
+	}
+
+	row := tx.QueryRowContext(ctx, "UPDATE product_variant SET sku = $1, price = $2, options = $3 WHERE id = $4 AND product_id = $5 RETURNING "+productVariantColumns,
+		v.Sku, v.Price, options, v.Id, v.ProductId)
+
+	updated, err := scanProductVariant(row.Scan)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.ProductVariant{}, translateError(err, "product_variant")
+	}
+
+	if v.CurrentInventory != updated.CurrentInventory {
+		// Reservations of the variant are taken under the product lock held here
+		reserved, err := reservedVariantStock(ctx, tx, v.Id, time.Now())
+
+		if err != nil {
+			return structs.ProductVariant{}, err
+		}
+
+		if v.CurrentInventory < reserved {
+			return structs.ProductVariant{}, belowReservedStock(updated.Sku, reserved)
+		}
+
+		adjustment := structs.StockMovement{ProductId: v.ProductId, VariantId: v.Id, Reason: "adjustment", Quantity: v.CurrentInventory - updated.CurrentInventory, Reference: variantUpdateReference}
+		if _, err := insertStockMovement(ctx, tx, adjustment); err != nil {
+			return structs.ProductVariant{}, err
+		}
+
+		updated.CurrentInventory = v.CurrentInventory
+	}
+
+	return updated, tx.Commit()
+}
+
+func (s DbSource) SetProductVariantImage(ctx context.Context, productId int, variantId int, imageUrl string) (structs.ProductVariant, error) {
+	row := s.conn.QueryRowContext(ctx, "UPDATE product_variant SET image_url = NULLIF($1, '') WHERE id = $2 AND product_id = $3 RETURNING "+productVariantColumns,
+		imageUrl, variantId, productId)
+
+	updated, err := scanProductVariant(row.Scan)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.ProductVariant{}, translateError(err, "product_variant")
+	}
+
+	return updated, nil
+}
+
+func (s DbSource) DeleteProductVariant(ctx context.Context, productId int, variantId int) error {
+	result, err := s.conn.ExecContext(ctx, "DELETE FROM product_variant WHERE id = $1 AND product_id = $2", variantId, productId)
+	return translateExecResult(result, err, "product_variant")
+}
@@ -0,0 +1,266 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"vayer-electric-backend/structs"
+)
+
+func validateStockTransfer(t *structs.StockTransfer) error {
+	t.User = strings.TrimSpace(t.User)
+	t.Reference = strings.TrimSpace(t.Reference)
+
+	if t.Quantity <= 0 {
+		return ValidationError("quantity must be positive")
+	}
+
+	if t.FromLocationId == 0 || t.ToLocationId == 0 {
+		return ValidationError("from_location_id and to_location_id are required")
+	}
+
+	if t.FromLocationId == t.ToLocationId {
+		return ValidationError("stock can't be transferred to the location it's in")
+	}
+
+	return nil
+}
+
+// The two movements of a transfer, stock leaves one location and enters the other
+func transferMovements(t structs.StockTransfer) (structs.StockMovement, structs.StockMovement) {
+	out := structs.StockMovement{ProductId: t.ProductId, LocationId: t.FromLocationId, Reason: "transfer", Quantity: -t.Quantity, User: t.User, Reference: t.Reference}
+	in := structs.StockMovement{ProductId: t.ProductId, LocationId: t.ToLocationId, Reason: "transfer", Quantity: t.Quantity, User: t.User, Reference: t.Reference}
+	return out, in
+}
+
+const locationColumns = "id, warehouse_id, name, is_default, created_at"
+
+func scanLocation(scan func(dest ...interface{}) error) (structs.Location, error) {
+	var l structs.Location
+	err := scan(&l.Id, &l.WarehouseId, &l.Name, &l.Default, &l.CreatedAt)
+	return l, err
+}
+
+func (s DbSource) GetWarehouses(ctx context.Context) ([]structs.Warehouse, error) {
+	rows, err := s.conn.QueryContext(ctx, "SELECT id, name, created_at FROM warehouse ORDER BY id")
+
+	if err != nil {
+		log.Error(err.Error())
+		return nil, translateError(err, "warehouse")
+	}
+
+	defer rows.Close()
+
+	warehouses := make([]structs.Warehouse, 0)
+	byId := make(map[int64]int)
+
+	for rows.Next() {
+		w := structs.Warehouse{Locations: make([]structs.Location, 0)}
+
+		if err := rows.Scan(&w.Id, &w.Name, &w.CreatedAt); err != nil {
+			log.Error(err.Error())
+			return nil, translateError(err, "warehouse")
+		}
+
+		byId[w.Id] = len(warehouses)
+		warehouses = append(warehouses, w)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error(err.Error())
+		return nil, translateError(err, "warehouse")
+	}
+
+	locationRows, err := s.conn.QueryContext(ctx, "SELECT "+locationColumns+" FROM location ORDER BY id")
+
+	if err != nil {
+		log.Error(err.Error())
+		return nil, translateError(err, "location")
+	}
+
+	defer locationRows.Close()
+
+	for locationRows.Next() {
+		l, err := scanLocation(locationRows.Scan)
+
+		if err != nil {
+			log.Error(err.Error())
+			return nil, translateError(err, "location")
+		}
+
+		if i, ok := byId[l.WarehouseId]; ok {
+			warehouses[i].Locations = append(warehouses[i].Locations, l)
+		}
+	}
+
+	if err = locationRows.Err(); err != nil {
+		log.Error(err.Error())
+		return nil, translateError(err, "location")
+	}
+
+	return warehouses, nil
+}
+
+func (s DbSource) InsertWarehouse(ctx context.Context, name string) (structs.Warehouse, error) {
+	name = strings.TrimSpace(name)
+
+	if name == "" {
+		return structs.Warehouse{}, ValidationError("name is required")
+	}
+
+	w := structs.Warehouse{Locations: make([]structs.Location, 0)}
+	err := s.conn.QueryRowContext(ctx, "INSERT INTO warehouse (name, created_at) VALUES ($1, $2) RETURNING id, name, created_at", name, time.Now()).Scan(&w.Id, &w.Name, &w.CreatedAt)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.Warehouse{}, translateError(err, "warehouse")
+	}
+
+	return w, nil
+}
+
+// Only warehouses without locations can be deleted
+func (s DbSource) DeleteWarehouse(ctx context.Context, id int) error {
+	result, err := s.conn.ExecContext(ctx, "DELETE FROM warehouse WHERE id = $1", id)
+	return translateExecResult(result, err, "warehouse")
+}
+
+func (s DbSource) InsertLocation(ctx context.Context, l structs.Location) (structs.Location, error) {
+	l.Name = strings.TrimSpace(l.Name)
+
+	if l.Name == "" {
+		return structs.Location{}, ValidationError("name is required")
+	}
+
+	row := s.conn.QueryRowContext(ctx, "INSERT INTO location (warehouse_id, name, created_at) VALUES ($1, $2, $3) RETURNING "+locationColumns, l.WarehouseId, l.Name, time.Now())
+
+	created, err := scanLocation(row.Scan)
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.Location{}, translateError(err, "location")
+	}
+
+	return created, nil
+}
+
+// Locations that ever held stock are part of the ledger and can't be deleted, neither can the default one
+func (s DbSource) DeleteLocation(ctx context.Context, warehouseId int, id int) error {
+	var isDefault bool
+	err := s.conn.QueryRowContext(ctx, "SELECT is_default FROM location WHERE id = $1 AND warehouse_id = $2", id, warehouseId).Scan(&isDefault)
+
+	if err != nil {
+		log.Error(err.Error())
+		return translateError(err, "location")
+	}
+
+	if isDefault {
+		return Conflict("the default location can't be deleted")
+	}
+
+	result, err := s.conn.ExecContext(ctx, "DELETE FROM location WHERE id = $1 AND warehouse_id = $2", id, warehouseId)
+	return translateExecResult(result, err, "location")
+}
+
+func (s DbSource) productStock(ctx context.Context, productId int) ([]structs.LocationStock, error) {
+	rows, err := s.conn.QueryContext(ctx, `SELECT l.id, l.name, w.id, w.name, ps.quantity
+		FROM product_stock ps
+		JOIN location l ON l.id = ps.location_id
+		JOIN warehouse w ON w.id = l.warehouse_id
+		WHERE ps.product_id = $1 AND ps.quantity > 0
+		ORDER BY w.id, l.id`, productId)
+
+	if err != nil {
+		log.Error(err.Error())
+		return nil, translateError(err, "product_stock")
+	}
+
+	defer rows.Close()
+
+	stock := make([]structs.LocationStock, 0)
+
+	for rows.Next() {
+		var ls structs.LocationStock
+
+		if err := rows.Scan(&ls.LocationId, &ls.LocationName, &ls.WarehouseId, &ls.WarehouseName, &ls.Quantity); err != nil {
+			log.Error(err.Error())
+			return nil, translateError(err, "product_stock")
+		}
+
+		stock = append(stock, ls)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error(err.Error())
+		return nil, translateError(err, "product_stock")
+	}
+
+	return stock, nil
+}
+
+// Returns the stock of a product per location along with its total and what's available once reservations
+// are held back, locations without stock are left out
+func (s DbSource) GetProductStock(ctx context.Context, productId int) (structs.ProductStock, error) {
+	stock := structs.ProductStock{ProductId: int64(productId)}
+	err := s.conn.QueryRowContext(ctx, "SELECT current_inventory FROM product WHERE id = $1", productId).Scan(&stock.Total)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return structs.ProductStock{}, NotFound("product")
+	}
+
+	if err != nil {
+		log.Error(err.Error())
+		return structs.ProductStock{}, translateError(err, "product")
+	}
+
+	stock.Reserved, err = reservedStock(ctx, s.conn, productId, time.Now())
+
+	if err != nil {
+		return structs.ProductStock{}, err
+	}
+
+	stock.Available = availableStock(stock.Total, stock.Reserved)
+
+	stock.Locations, err = s.productStock(ctx, productId)
+
+	if err != nil {
+		return structs.ProductStock{}, err
+	}
+
+	return stock, nil
+}
+
+// Moves stock of a product from one location to another, both movements are recorded or neither is
+func (s DbSource) TransferStock(ctx context.Context, t structs.StockTransfer) ([]structs.StockMovement, error) {
+	if err := validateStockTransfer(&t); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	out, in := transferMovements(t)
+
+	out, err = insertStockMovement(ctx, tx, out)
+
+	if err != nil {
+		return nil, err
+	}
+
+	in, err = insertStockMovement(ctx, tx, in)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return []structs.StockMovement{out, in}, tx.Commit()
+}
@@ -14,3 +14,91 @@ var DB_PORT = getOptionalEnvAsInt("DB_PORT", 5432)
 var DB_USER = getOptionalEnv("DB_USER", "vayer-electric")
 var DB_PASSWORD = getOptionalEnv("DB_PASSWORD", "vayer-electric")
 var DB_NAME = getOptionalEnv("DB_NAME", "vayer-electric")
+var DB_MAX_OPEN_CONNS = getOptionalEnvAsInt("DB_MAX_OPEN_CONNS", 6)
+var DB_MAX_IDLE_CONNS = getOptionalEnvAsInt("DB_MAX_IDLE_CONNS", 2)
+var DB_CONN_MAX_LIFETIME = getOptionalEnvAsMinutes("DB_CONN_MAX_LIFETIME", 30)
+var DB_CONN_MAX_IDLE_TIME = getOptionalEnvAsMinutes("DB_CONN_MAX_IDLE_TIME", 5)
+
+// "postgres" or "memory"
+var STORE_DRIVER = getOptionalEnv("STORE_DRIVER", "postgres")
+
+// "local" or "s3"
+var BLOB_DRIVER = getOptionalEnv("BLOB_DRIVER", "local")
+var BLOB_LOCAL_PATH = getOptionalEnv("BLOB_LOCAL_PATH", "./uploads/")
+var BLOB_S3_ENDPOINT = getOptionalEnv("BLOB_S3_ENDPOINT", "https://s3.amazonaws.com")
+var BLOB_S3_REGION = getOptionalEnv("BLOB_S3_REGION", "us-east-1")
+var BLOB_S3_BUCKET = getOptionalEnv("BLOB_S3_BUCKET", "vayer-electric")
+var BLOB_S3_ACCESS_KEY = getOptionalEnv("BLOB_S3_ACCESS_KEY", "")
+var BLOB_S3_SECRET_KEY = getOptionalEnv("BLOB_S3_SECRET_KEY", "")
+
+// MinIO and most S3 compatible services expect endpoint/bucket/key urls
+var BLOB_S3_PATH_STYLE = getOptionalEnvAsBool("BLOB_S3_PATH_STYLE", true)
+
+// Images are redirected to presigned urls valid for this many seconds, 0 proxies them through the api
+var BLOB_S3_PRESIGN_EXPIRY = getOptionalEnvAsSeconds("BLOB_S3_PRESIGN_EXPIRY", 0)
+
+// Path of an image sent in place of missing product images, they get a json 404 when it's empty
+var IMAGE_PLACEHOLDER = getOptionalEnv("IMAGE_PLACEHOLDER", "")
+
+// Minutes between sweeps of images nothing references anymore, 0 disables the sweeper
+var MEDIA_GC_INTERVAL = getOptionalEnvAsMinutes("MEDIA_GC_INTERVAL", 360)
+
+// Images younger than this many minutes are never swept, their row may still be on its way
+var MEDIA_GC_GRACE = getOptionalEnvAsMinutes("MEDIA_GC_GRACE", 1440)
+
+// Only log what the sweeper would remove
+var MEDIA_GC_DRY_RUN = getOptionalEnvAsBool("MEDIA_GC_DRY_RUN", false)
+
+// Minutes stock stays reserved when a reservation doesn't ask for a ttl
+var RESERVATION_TTL = getOptionalEnvAsMinutes("RESERVATION_TTL", 15)
+
+// Longest ttl a reservation can ask for, in minutes
+var RESERVATION_MAX_TTL = getOptionalEnvAsMinutes("RESERVATION_MAX_TTL", 1440)
+
+// Seconds between releases of expired reservations, 0 disables the reaper.
+// Expired reservations stop counting against available stock either way.
+var RESERVATION_REAP_INTERVAL = getOptionalEnvAsSeconds("RESERVATION_REAP_INTERVAL", 60)
+
+// Where stock alerts are delivered, a comma separated list of log, smtp and webhook
+var NOTIFIERS = getOptionalEnv("NOTIFIERS", "log")
+
+// host:port of the mail server alert emails go through, the default suits a local mail catcher
+var SMTP_ADDR = getOptionalEnv("SMTP_ADDR", "localhost:1025")
+var SMTP_FROM = getOptionalEnv("SMTP_FROM", "")
+
+// Comma separated recipients of alert emails
+var SMTP_TO = getOptionalEnv("SMTP_TO", "")
+var SMTP_USERNAME = getOptionalEnv("SMTP_USERNAME", "")
+var SMTP_PASSWORD = getOptionalEnv("SMTP_PASSWORD", "")
+
+var WEBHOOK_URL = getOptionalEnv("WEBHOOK_URL", "")
+
+// Signs webhook bodies with HMAC-SHA256 when set
+var WEBHOOK_SECRET = getOptionalEnv("WEBHOOK_SECRET", "")
+
+// Seconds between deliveries of pending stock alerts, 0 disables delivery
+var STOCK_ALERT_INTERVAL = getOptionalEnvAsSeconds("STOCK_ALERT_INTERVAL", 30)
+
+// Failed deliveries after which an alert is given up on
+var STOCK_ALERT_MAX_ATTEMPTS = getOptionalEnvAsInt("STOCK_ALERT_MAX_ATTEMPTS", 5)
+
+// Header the authenticating proxy in front of the api puts the logged in customer id in. The api trusts it
+// as is, so only set it behind a proxy that drops the header from incoming requests before setting it,
+// anyone could pose as any customer otherwise. Empty, the default, turns customer carts off.
+var CUSTOMER_ID_HEADER = getOptionalEnv("CUSTOMER_ID_HEADER", "")
+
+// Gateway checkout payments go through, "fake" or empty to turn payments off
+var PAYMENT_PROVIDER = getOptionalEnv("PAYMENT_PROVIDER", "")
+
+// Signs and verifies the webhooks of the fake payment provider
+var PAYMENT_FAKE_SECRET = getOptionalEnv("PAYMENT_FAKE_SECRET", "fake-secret")
+
+// Where the fake payment provider posts its delayed webhooks, the api's own webhook by default
+var PAYMENT_FAKE_WEBHOOK_URL = getOptionalEnv("PAYMENT_FAKE_WEBHOOK_URL", "")
+
+// Seconds the fake payment provider waits before posting a delayed webhook
+var PAYMENT_FAKE_WEBHOOK_DELAY = getOptionalEnvAsSeconds("PAYMENT_FAKE_WEBHOOK_DELAY", 5)
+
+// Region prices are taxed in when a request doesn't ask for one with ?region=, and new carts start in.
+// "*" takes the rates tax classes have for any region.
+var TAX_REGION = getOptionalEnv("TAX_REGION", "*")
@@ -42,3 +42,21 @@ func getOptionalEnvAsMinutes(name string, defaultVal int) time.Duration {
 
 	return time.Duration(defaultVal) * time.Minute
 }
+
+func getOptionalEnvAsSeconds(name string, defaultVal int) time.Duration {
+	valueStr := getOptionalEnv(name, "")
+	if value, err := strconv.Atoi(valueStr); err == nil {
+		return time.Duration(value) * time.Second
+	}
+
+	return time.Duration(defaultVal) * time.Second
+}
+
+func getOptionalEnvAsBool(name string, defaultVal bool) bool {
+	valueStr := getOptionalEnv(name, "")
+	if value, err := strconv.ParseBool(valueStr); err == nil {
+		return value
+	}
+
+	return defaultVal
+}
@@ -12,10 +12,11 @@ import (
 )
 
 type GracefulServer struct {
-	server  *http.Server
-	cancel  context.CancelFunc
-	wait    func() error
-	running bool
+	server     *http.Server
+	cancel     context.CancelFunc
+	wait       func() error
+	running    bool
+	onShutdown []func() error
 }
 
 // New graceful server wrapper
@@ -25,6 +26,13 @@ func New(srv *http.Server) *GracefulServer {
 	}
 }
 
+// Registers a function to be called once the http server has stopped serving,
+// used to release resources like the db pool after in-flight requests are done.
+// Functions are called in registration order, all of them even when shutting down the server or one of them fails.
+func (s *GracefulServer) RegisterOnShutdown(f func() error) {
+	s.onShutdown = append(s.onShutdown, f)
+}
+
 func (s *GracefulServer) StartListening(ctx context.Context) error {
 	if s.server == nil {
 		return errors.Errorf("http.Server required")
@@ -46,7 +54,8 @@ func (s *GracefulServer) StartListening(ctx context.Context) error {
 		<-gCtx.Done()
 
 		// Shutdown signal with grace period of constants.ShutdownTimeout seconds
-		timeout, _ := context.WithTimeout(context.Background(), constants.ShutdownTimeout)
+		timeout, cancelTimeout := context.WithTimeout(context.Background(), constants.ShutdownTimeout)
+		defer cancelTimeout()
 		go func() {
 			<-timeout.Done()
 			if timeout.Err() == context.DeadlineExceeded {
@@ -54,7 +63,24 @@ func (s *GracefulServer) StartListening(ctx context.Context) error {
 			}
 		}()
 
-		return s.server.Shutdown(context.Background())
+		// The hooks run even when shutdown fails, the resources they release would leak otherwise
+		err := s.server.Shutdown(context.Background())
+
+		if err != nil {
+			log.Printf("http server shutdown failed: %s", err)
+		}
+
+		for _, f := range s.onShutdown {
+			if hookErr := f(); hookErr != nil {
+				log.Printf("shutdown hook failed: %s", hookErr)
+
+				if err == nil {
+					err = hookErr
+				}
+			}
+		}
+
+		return err
 	})
 
 	s.running = true
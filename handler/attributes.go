@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"vayer-electric-backend/db"
+	"vayer-electric-backend/structs"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func GetAttributeDefinitions(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		parsedId, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "id must be an integer")
+			return
+		}
+
+		defs, err := store.GetAttributeDefinitions(ctx, parsedId)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(defs)
+	}
+}
+
+func CreateAttributeDefinition(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		parsedId, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "id must be an integer")
+			return
+		}
+
+		raw, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		var body struct {
+			Code          string   `json:"code"`
+			Name          string   `json:"name"`
+			Type          string   `json:"type"`
+			Unit          string   `json:"unit"`
+			AllowedValues []string `json:"allowed_values"`
+			Required      bool     `json:"required"`
+		}
+		if err := json.Unmarshal(raw, &body); err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		def, err := store.InsertAttributeDefinition(ctx, structs.AttributeDefinition{
+			SubcategoryId: int64(parsedId),
+			Code:          body.Code,
+			Name:          body.Name,
+			Type:          body.Type,
+			Unit:          body.Unit,
+			AllowedValues: body.AllowedValues,
+			Required:      body.Required,
+		})
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(def)
+	}
+}
+
+func DeleteAttributeDefinition(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		parsedId, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "id must be an integer")
+			return
+		}
+
+		parsedAttributeId, err := strconv.Atoi(chi.URLParam(r, "attributeId"))
+
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "attributeId must be an integer")
+			return
+		}
+
+		err = store.DeleteAttributeDefinition(ctx, parsedId, parsedAttributeId)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// Replaces the attribute values of a product, the body is an object keyed by attribute code:
+//
+//	{"voltage": 230, "section": {"value": 2.5, "unit": "mm2"}, "ip_rating": "IP65", "certifications": ["CE"], "outdoor": true}
+func SetProductAttributes(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		parsedId, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "id must be an integer")
+			return
+		}
+
+		raw, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		var values map[string]interface{}
+		if err := json.Unmarshal(raw, &values); err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		err = store.SetProductAttributes(ctx, parsedId, values)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"vayer-electric-backend/db"
+	"vayer-electric-backend/env"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// The logged in customer set by the authenticating proxy, empty for anonymous requests and when
+// env.CUSTOMER_ID_HEADER isn't configured
+func customerId(r *http.Request) string {
+	if env.CUSTOMER_ID_HEADER == "" {
+		return ""
+	}
+
+	return strings.TrimSpace(r.Header.Get(env.CUSTOMER_ID_HEADER))
+}
+
+// Creates an anonymous cart taxed in ?region=, or returns the cart of the logged in customer creating it the first time
+func CreateCart(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		region, err := parseTaxRegion(r)
+
+		if err != nil {
+			writeBadRequest(w, err.Error())
+			return
+		}
+
+		cart, err := store.InsertCart(ctx, customerId(r), region)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(cart)
+	}
+}
+
+// Returns a cart priced from the current product prices, with the stock available for each line
+func GetCart(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		cart, err := store.GetCart(ctx, chi.URLParam(r, "token"), customerId(r))
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(cart)
+	}
+}
+
+// Sets the region a cart is taxed in, like the country it ships to:
+//
+//	{"region": "DE"}
+func SetCartRegion(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		raw, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		var body struct {
+			Region string `json:"region"`
+		}
+		if err := json.Unmarshal(raw, &body); err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		cart, err := store.SetCartRegion(ctx, chi.URLParam(r, "token"), customerId(r), body.Region)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(cart)
+	}
+}
+
+// Adds a product to a cart, quantity adds to the line the cart already has for it. variant_id is optional
+// and picks the variant of the product sold, at the variant's price and from its stock:
+//
+//	{"product_id": 12, "variant_id": 40, "quantity": 2}
+func AddCartLine(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		raw, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		var body struct {
+			ProductId int64 `json:"product_id"`
+			VariantId int64 `json:"variant_id"`
+			Quantity  int64 `json:"quantity"`
+		}
+		if err := json.Unmarshal(raw, &body); err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		cart, err := store.AddCartLine(ctx, chi.URLParam(r, "token"), customerId(r), body.ProductId, body.VariantId, body.Quantity)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(cart)
+	}
+}
+
+// Sets the quantity of a cart line:
+//
+//	{"quantity": 3}
+func UpdateCartLine(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		parsedLineId, err := strconv.Atoi(chi.URLParam(r, "lineId"))
+
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "lineId must be an integer")
+			return
+		}
+
+		raw, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		var body struct {
+			Quantity int64 `json:"quantity"`
+		}
+		if err := json.Unmarshal(raw, &body); err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		cart, err := store.UpdateCartLine(ctx, chi.URLParam(r, "token"), customerId(r), parsedLineId, body.Quantity)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(cart)
+	}
+}
+
+func DeleteCartLine(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		parsedLineId, err := strconv.Atoi(chi.URLParam(r, "lineId"))
+
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "lineId must be an integer")
+			return
+		}
+
+		cart, err := store.DeleteCartLine(ctx, chi.URLParam(r, "token"), customerId(r), parsedLineId)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(cart)
+	}
+}
+
+// Merges an anonymous cart into the cart of the logged in customer, called by the storefront right after login.
+// Responds with the customer's cart, the anonymous one is gone.
+func MergeCart(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		customer := customerId(r)
+
+		if customer == "" {
+			writeError(w, http.StatusUnauthorized, "unauthorized", "log in to merge a cart")
+			return
+		}
+
+		cart, err := store.MergeCart(ctx, chi.URLParam(r, "token"), customer)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(cart)
+	}
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"vayer-electric-backend/db"
+)
+
+func GetDbStats(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		stats := store.Stats()
+
+		json.NewEncoder(w).Encode(struct {
+			Reachable          bool  `json:"reachable"`
+			MaxOpenConnections int   `json:"max_open_connections"`
+			OpenConnections    int   `json:"open_connections"`
+			InUse              int   `json:"in_use"`
+			Idle               int   `json:"idle"`
+			WaitCount          int64 `json:"wait_count"`
+			WaitDurationMs     int64 `json:"wait_duration_ms"`
+			MaxIdleClosed      int64 `json:"max_idle_closed"`
+			MaxIdleTimeClosed  int64 `json:"max_idle_time_closed"`
+			MaxLifetimeClosed  int64 `json:"max_lifetime_closed"`
+		}{
+			Reachable:          store.ValidateConnection(ctx),
+			MaxOpenConnections: stats.MaxOpenConnections,
+			OpenConnections:    stats.OpenConnections,
+			InUse:              stats.InUse,
+			Idle:               stats.Idle,
+			WaitCount:          stats.WaitCount,
+			WaitDurationMs:     stats.WaitDuration.Milliseconds(),
+			MaxIdleClosed:      stats.MaxIdleClosed,
+			MaxIdleTimeClosed:  stats.MaxIdleTimeClosed,
+			MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+		})
+	}
+}
@@ -1,33 +1,36 @@
 package handler
 
 import (
-	"crypto/rand"
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"os"
 	"strconv"
 	"strings"
 	"vayer-electric-backend/db"
+	"vayer-electric-backend/imaging"
 	"vayer-electric-backend/logging"
+	"vayer-electric-backend/money"
+	"vayer-electric-backend/storage"
+	"vayer-electric-backend/structs"
 
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 )
 
-var (
-	volumePath = "./uploads/"
-	log        = logging.GetLogger()
-)
+var log = logging.GetLogger()
 
-func GetCategories() http.HandlerFunc {
+func GetCategories(store db.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		dbs := db.GetDbSource()
-		categories, err := dbs.GetCategories()
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		categories, err := store.GetCategories(ctx)
 
 		if err != nil {
-			log.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeStoreError(w, ctx, err)
 			return
 		}
 
@@ -35,23 +38,25 @@ func GetCategories() http.HandlerFunc {
 	}
 }
 
-func GetCategoryById() http.HandlerFunc {
+func GetCategoryById(store db.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
 		id := chi.URLParam(r, "id")
 
 		parsedId, err := strconv.Atoi(id)
 
 		if err != nil {
 			log.Error(err.Error())
+			writeBadRequest(w, "id must be an integer")
 			return
 		}
 
-		dbs := db.GetDbSource()
-		category, err := dbs.GetCategoryById(parsedId)
+		category, err := store.GetCategoryById(ctx, parsedId)
 
 		if err != nil {
-			log.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeStoreError(w, ctx, err)
 			return
 		}
 
@@ -59,16 +64,17 @@ func GetCategoryById() http.HandlerFunc {
 	}
 }
 
-func GetCategoryByName() http.HandlerFunc {
+func GetCategoryByName(store db.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
 		name := chi.URLParam(r, "name")
 
-		dbs := db.GetDbSource()
-		category, err := dbs.GetCategoryByName(name)
+		category, err := store.GetCategoryByName(ctx, name)
 
 		if err != nil {
-			log.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeStoreError(w, ctx, err)
 			return
 		}
 
@@ -76,12 +82,15 @@ func GetCategoryByName() http.HandlerFunc {
 	}
 }
 
-func CreateCategory() http.HandlerFunc {
+func CreateCategory(store db.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
 		raw, err := io.ReadAll(r.Body)
 		if err != nil {
 			log.Error(err.Error())
-			w.WriteHeader(http.StatusBadRequest)
+			writeBadRequest(w, "invalid request body")
 			return
 		}
 
@@ -92,7 +101,7 @@ func CreateCategory() http.HandlerFunc {
 		}
 		if err := json.Unmarshal(raw, &body); err != nil {
 			log.Error(err.Error())
-			w.WriteHeader(http.StatusBadRequest)
+			writeBadRequest(w, "invalid request body")
 			return
 		}
 
@@ -105,18 +114,10 @@ func CreateCategory() http.HandlerFunc {
 		description := body.Description
 		image_url := body.ImageUrl
 
-		if err != nil {
-			log.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		dbs := db.GetDbSource()
-		err = dbs.InsertCategory(name, description, image_url)
+		err = store.InsertCategory(ctx, name, description, image_url)
 
 		if err != nil {
-			log.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeStoreError(w, ctx, err)
 			return
 		}
 
@@ -124,12 +125,15 @@ func CreateCategory() http.HandlerFunc {
 	}
 }
 
-func UpdateCategory() http.HandlerFunc {
+func UpdateCategory(store db.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
 		raw, err := io.ReadAll(r.Body)
 		if err != nil {
 			log.Error(err.Error())
-			w.WriteHeader(http.StatusBadRequest)
+			writeBadRequest(w, "invalid request body")
 			return
 		}
 
@@ -141,7 +145,7 @@ func UpdateCategory() http.HandlerFunc {
 		}
 		if err := json.Unmarshal(raw, &body); err != nil {
 			log.Error(err.Error())
-			w.WriteHeader(http.StatusBadRequest)
+			writeBadRequest(w, "invalid request body")
 			return
 		}
 
@@ -156,24 +160,18 @@ func UpdateCategory() http.HandlerFunc {
 		description := body.Description
 		image_url := body.ImageUrl
 
-		if err != nil {
-			log.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-
-		dbs := db.GetDbSource()
 		parsedId, err := strconv.Atoi(id)
 
 		if err != nil {
 			log.Error(err.Error())
+			writeBadRequest(w, "id must be an integer")
 			return
 		}
 
-		err = dbs.UpdateCategory(parsedId, name, description, image_url)
+		err = store.UpdateCategory(ctx, parsedId, name, description, image_url)
 
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeStoreError(w, ctx, err)
 			return
 		}
 
@@ -181,23 +179,25 @@ func UpdateCategory() http.HandlerFunc {
 	}
 }
 
-func DeleteCategory() http.HandlerFunc {
+func DeleteCategory(store db.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
 		id := chi.URLParam(r, "id")
 
-		dbs := db.GetDbSource()
 		parsedId, err := strconv.Atoi(id)
 
 		if err != nil {
 			log.Error(err.Error())
+			writeBadRequest(w, "id must be an integer")
 			return
 		}
 
-		err = dbs.DeleteCategory(parsedId)
+		err = store.DeleteCategory(ctx, parsedId)
 
 		if err != nil {
-			log.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeStoreError(w, ctx, err)
 			return
 		}
 
@@ -205,14 +205,15 @@ func DeleteCategory() http.HandlerFunc {
 	}
 }
 
-func GetSubcategories() http.HandlerFunc {
+func GetSubcategories(store db.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		dbs := db.GetDbSource()
-		subcategories, err := dbs.GetSubcategories()
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		subcategories, err := store.GetSubcategories(ctx)
 
 		if err != nil {
-			log.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeStoreError(w, ctx, err)
 			return
 		}
 
@@ -220,23 +221,25 @@ func GetSubcategories() http.HandlerFunc {
 	}
 }
 
-func GetSubcategoryById() http.HandlerFunc {
+func GetSubcategoryById(store db.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
 		id := chi.URLParam(r, "id")
 
-		dbs := db.GetDbSource()
 		parsedId, err := strconv.Atoi(id)
 
 		if err != nil {
 			log.Error(err.Error())
+			writeBadRequest(w, "id must be an integer")
 			return
 		}
 
-		subcategory, err := dbs.GetSubcategoryById(parsedId)
+		subcategory, err := store.GetSubcategoryById(ctx, parsedId)
 
 		if err != nil {
-			log.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeStoreError(w, ctx, err)
 			return
 		}
 
@@ -244,12 +247,15 @@ func GetSubcategoryById() http.HandlerFunc {
 	}
 }
 
-func CreateSubcategory() http.HandlerFunc {
+func CreateSubcategory(store db.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
 		raw, err := io.ReadAll(r.Body)
 		if err != nil {
 			log.Error(err.Error())
-			w.WriteHeader(http.StatusBadRequest)
+			writeBadRequest(w, "invalid request body")
 			return
 		}
 
@@ -261,7 +267,7 @@ func CreateSubcategory() http.HandlerFunc {
 		}
 		if err := json.Unmarshal(raw, &body); err != nil {
 			log.Error(err.Error())
-			w.WriteHeader(http.StatusBadRequest)
+			writeBadRequest(w, "invalid request body")
 			return
 		}
 
@@ -276,25 +282,18 @@ func CreateSubcategory() http.HandlerFunc {
 		categoryId := body.CategoryId
 		image_url := body.ImageUrl
 
-		if err != nil {
-			log.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-
-		dbs := db.GetDbSource()
 		parsedCategoryId, err := strconv.Atoi(categoryId)
 
 		if err != nil {
 			log.Error(err.Error())
+			writeBadRequest(w, "category_id must be an integer")
 			return
 		}
 
-		err = dbs.InsertSubcategory(name, description, parsedCategoryId, image_url)
+		err = store.InsertSubcategory(ctx, name, description, parsedCategoryId, image_url)
 
 		if err != nil {
-			log.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeStoreError(w, ctx, err)
 			return
 		}
 
@@ -302,12 +301,15 @@ func CreateSubcategory() http.HandlerFunc {
 	}
 }
 
-func UpdateSubcategory() http.HandlerFunc {
+func UpdateSubcategory(store db.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
 		raw, err := io.ReadAll(r.Body)
 		if err != nil {
 			log.Error(err.Error())
-			w.WriteHeader(http.StatusBadRequest)
+			writeBadRequest(w, "invalid request body")
 			return
 		}
 
@@ -320,7 +322,7 @@ func UpdateSubcategory() http.HandlerFunc {
 		}
 		if err := json.Unmarshal(raw, &body); err != nil {
 			log.Error(err.Error())
-			w.WriteHeader(http.StatusBadRequest)
+			writeBadRequest(w, "invalid request body")
 			return
 		}
 
@@ -337,17 +339,11 @@ func UpdateSubcategory() http.HandlerFunc {
 		categoryId := body.CategoryId
 		image_url := body.ImageUrl
 
-		if err != nil {
-			log.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-
-		dbs := db.GetDbSource()
 		parsedId, err := strconv.Atoi(id)
 
 		if err != nil {
 			log.Error(err.Error())
+			writeBadRequest(w, "id must be an integer")
 			return
 		}
 
@@ -355,14 +351,14 @@ func UpdateSubcategory() http.HandlerFunc {
 
 		if err != nil {
 			log.Error(err.Error())
+			writeBadRequest(w, "category_id must be an integer")
 			return
 		}
 
-		err = dbs.UpdateSubcategory(parsedId, name, description, parsedCategoryId, image_url)
+		err = store.UpdateSubcategory(ctx, parsedId, name, description, parsedCategoryId, image_url)
 
 		if err != nil {
-			log.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeStoreError(w, ctx, err)
 			return
 		}
 
@@ -370,23 +366,25 @@ func UpdateSubcategory() http.HandlerFunc {
 	}
 }
 
-func DeleteSubcategory() http.HandlerFunc {
+func DeleteSubcategory(store db.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
 		id := chi.URLParam(r, "id")
 
-		dbs := db.GetDbSource()
 		parsedId, err := strconv.Atoi(id)
 
 		if err != nil {
 			log.Error(err.Error())
+			writeBadRequest(w, "id must be an integer")
 			return
 		}
 
-		err = dbs.DeleteSubcategory(parsedId)
+		err = store.DeleteSubcategory(ctx, parsedId)
 
 		if err != nil {
-			log.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeStoreError(w, ctx, err)
 			return
 		}
 
@@ -394,23 +392,25 @@ func DeleteSubcategory() http.HandlerFunc {
 	}
 }
 
-func GetSubcategoriesByCategoryId() http.HandlerFunc {
+func GetSubcategoriesByCategoryId(store db.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
 		id := chi.URLParam(r, "id")
 
-		dbs := db.GetDbSource()
 		parsedId, err := strconv.Atoi(id)
 
 		if err != nil {
 			log.Error(err.Error())
+			writeBadRequest(w, "id must be an integer")
 			return
 		}
 
-		subcategories, err := dbs.GetSubcategoriesByCategoryId(parsedId)
+		subcategories, err := store.GetSubcategoriesByCategoryId(ctx, parsedId)
 
 		if err != nil {
-			log.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeStoreError(w, ctx, err)
 			return
 		}
 
@@ -418,145 +418,475 @@ func GetSubcategoriesByCategoryId() http.HandlerFunc {
 	}
 }
 
-func GetProducts() http.HandlerFunc {
+func GetProducts(store db.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		dbs := db.GetDbSource()
-		products, err := dbs.GetProducts()
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		query, err := parseProductQuery(r, "")
 
 		if err != nil {
-			log.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeBadRequest(w, err.Error())
+			return
+		}
+
+		preference, err := parseUnitPreference(r)
+
+		if err != nil {
+			writeBadRequest(w, err.Error())
+			return
+		}
+
+		region, err := parseTaxRegion(r)
+
+		if err != nil {
+			writeBadRequest(w, err.Error())
+			return
+		}
+
+		page, err := store.ListProducts(ctx, query)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
 			return
 		}
 
-		json.NewEncoder(w).Encode(products)
+		if err := taxProducts(ctx, store, page.Items, region); err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		convertProductUnits(page.Items, preference)
+
+		json.NewEncoder(w).Encode(page)
 	}
 }
 
-func GetProductById() http.HandlerFunc {
+func SearchProducts(store db.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		search := strings.TrimSpace(r.URL.Query().Get("q"))
+
+		if search == "" {
+			writeBadRequest(w, "q is required")
+			return
+		}
+
+		query, err := parseProductQuery(r, search)
+
+		if err != nil {
+			writeBadRequest(w, err.Error())
+			return
+		}
+
+		preference, err := parseUnitPreference(r)
+
+		if err != nil {
+			writeBadRequest(w, err.Error())
+			return
+		}
+
+		region, err := parseTaxRegion(r)
+
+		if err != nil {
+			writeBadRequest(w, err.Error())
+			return
+		}
+
+		page, err := store.ListProducts(ctx, query)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		if err := taxProducts(ctx, store, page.Items, region); err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		convertProductUnits(page.Items, preference)
+
+		json.NewEncoder(w).Encode(page)
+	}
+}
+
+func GetProductById(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
 		id := chi.URLParam(r, "id")
 
-		dbs := db.GetDbSource()
 		parsedId, err := strconv.Atoi(id)
 
 		if err != nil {
 			log.Error(err.Error())
+			writeBadRequest(w, "id must be an integer")
 			return
 		}
 
-		product, err := dbs.GetProductById(parsedId)
+		preference, err := parseUnitPreference(r)
 
 		if err != nil {
-			log.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeBadRequest(w, err.Error())
+			return
+		}
+
+		region, err := parseTaxRegion(r)
+
+		if err != nil {
+			writeBadRequest(w, err.Error())
 			return
 		}
 
-		json.NewEncoder(w).Encode(product)
+		product, err := store.GetProductById(ctx, parsedId)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		products := []structs.Product{product}
+
+		if err := taxProducts(ctx, store, products, region); err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		convertProductUnits(products, preference)
+
+		json.NewEncoder(w).Encode(products[0])
 	}
 }
 
-func GetProductByName() http.HandlerFunc {
+func GetProductByName(store db.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
 		name := chi.URLParam(r, "name")
 
-		dbs := db.GetDbSource()
-		product, err := dbs.GetProductByName(name)
+		preference, err := parseUnitPreference(r)
 
 		if err != nil {
-			log.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeBadRequest(w, err.Error())
+			return
+		}
+
+		region, err := parseTaxRegion(r)
+
+		if err != nil {
+			writeBadRequest(w, err.Error())
 			return
 		}
 
-		json.NewEncoder(w).Encode(product)
+		product, err := store.GetProductByName(ctx, name)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		products := []structs.Product{product}
+
+		if err := taxProducts(ctx, store, products, region); err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		convertProductUnits(products, preference)
+
+		json.NewEncoder(w).Encode(products[0])
 	}
 }
 
-func GetProductsBySubcategoryId() http.HandlerFunc {
+func GetProductsBySubcategoryId(store db.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
 		id := chi.URLParam(r, "id")
 
-		dbs := db.GetDbSource()
 		parsedId, err := strconv.Atoi(id)
 
 		if err != nil {
 			log.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeBadRequest(w, "id must be an integer")
 			return
 		}
 
-		products, err := dbs.GetProductsBySubcategoryId(parsedId)
+		query, err := parseProductQuery(r, "")
 
 		if err != nil {
-			log.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeBadRequest(w, err.Error())
+			return
+		}
+
+		preference, err := parseUnitPreference(r)
+
+		if err != nil {
+			writeBadRequest(w, err.Error())
+			return
+		}
+
+		region, err := parseTaxRegion(r)
+
+		if err != nil {
+			writeBadRequest(w, err.Error())
+			return
+		}
+
+		query.SubcategoryId = parsedId
+
+		page, err := store.ListProducts(ctx, query)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
 			return
 		}
 
-		json.NewEncoder(w).Encode(products)
+		if err := taxProducts(ctx, store, page.Items, region); err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		convertProductUnits(page.Items, preference)
+
+		json.NewEncoder(w).Encode(page)
 	}
 }
 
-func GetProductsByCategoryId() http.HandlerFunc {
+func GetProductsByCategoryId(store db.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
 		id := chi.URLParam(r, "id")
 
-		dbs := db.GetDbSource()
 		parsedId, err := strconv.Atoi(id)
 
 		if err != nil {
 			log.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeBadRequest(w, "id must be an integer")
 			return
 		}
 
-		products, err := dbs.GetProductsByCategoryId(parsedId)
+		query, err := parseProductQuery(r, "")
 
 		if err != nil {
-			log.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeBadRequest(w, err.Error())
+			return
+		}
+
+		preference, err := parseUnitPreference(r)
+
+		if err != nil {
+			writeBadRequest(w, err.Error())
+			return
+		}
+
+		region, err := parseTaxRegion(r)
+
+		if err != nil {
+			writeBadRequest(w, err.Error())
+			return
+		}
+
+		query.CategoryId = parsedId
+
+		page, err := store.ListProducts(ctx, query)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
 			return
 		}
 
-		json.NewEncoder(w).Encode(products)
+		if err := taxProducts(ctx, store, page.Items, region); err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		convertProductUnits(page.Items, preference)
+
+		json.NewEncoder(w).Encode(page)
 	}
 }
 
-func GetProductsByCategoryName() http.HandlerFunc {
+func GetProductsByCategoryName(store db.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
 		name := chi.URLParam(r, "name")
 
-		dbs := db.GetDbSource()
-		products, err := dbs.GetProductsByCategoryName(name)
+		category, err := store.GetCategoryByName(ctx, name)
 
 		if err != nil {
-			log.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		query, err := parseProductQuery(r, "")
+
+		if err != nil {
+			writeBadRequest(w, err.Error())
+			return
+		}
+
+		preference, err := parseUnitPreference(r)
+
+		if err != nil {
+			writeBadRequest(w, err.Error())
+			return
+		}
+
+		region, err := parseTaxRegion(r)
+
+		if err != nil {
+			writeBadRequest(w, err.Error())
 			return
 		}
 
-		json.NewEncoder(w).Encode(products)
+		query.CategoryId = int(category.Id)
+
+		page, err := store.ListProducts(ctx, query)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		if err := taxProducts(ctx, store, page.Items, region); err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		convertProductUnits(page.Items, preference)
+
+		json.NewEncoder(w).Encode(page)
 	}
 }
 
-func ServeProductImage() http.HandlerFunc {
+// Stored images never change, their names are derived from their content
+const imageCacheControl = "public, max-age=31536000, immutable"
+
+// Serves a stored image, ?rendition=thumbnail, medium or large picks one of its scaled down copies.
+// Blob stores that support it redirect the client to the image instead of proxying it.
+// Missing images get the placeholder file when one is configured, a json error otherwise.
+func ServeProductImage(blobs storage.BlobStore, placeholderPath string) http.HandlerFunc {
+	var placeholder []byte
+	var placeholderFormat imaging.Format
+
+	if placeholderPath != "" {
+		data, err := os.ReadFile(placeholderPath)
+
+		if err == nil {
+			placeholderFormat, err = imaging.DetectFormat(data)
+		}
+
+		if err != nil {
+			log.Error("image placeholder can't be used", zap.String("path", placeholderPath), zap.Error(err))
+		} else {
+			placeholder = data
+		}
+	}
+
+	// The image may show up later, the placeholder must not be cached in its place
+	writeNotFound := func(w http.ResponseWriter) {
+		if placeholder == nil {
+			writeError(w, http.StatusNotFound, "not_found", "image not found")
+			return
+		}
+
+		w.Header().Set("Content-Type", placeholderFormat.ContentType)
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write(placeholder)
+	}
+
 	return func(w http.ResponseWriter, r *http.Request) {
-		name := chi.URLParam(r, "name")
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		key := chi.URLParam(r, "name")
+
+		format, ok := imaging.ParseName(key)
+
+		if !ok {
+			writeBadRequest(w, "invalid image name")
+			return
+		}
+
+		if rendition := r.URL.Query().Get("rendition"); rendition != "" {
+			if _, ok := imaging.RenditionByName(rendition); !ok {
+				writeBadRequest(w, "rendition must be one of thumbnail, medium or large")
+				return
+			}
+
+			// Images uploaded before renditions existed only have their original
+			if _, err := blobs.Stat(ctx, imaging.RenditionName(key, rendition)); err == nil {
+				key = imaging.RenditionName(key, rendition)
+			}
+		}
+
+		// A presigned url is handed out without asking the backend, a missing image would only fail at the client
+		if redirector, ok := blobs.(storage.Redirector); ok {
+			if url, ok := redirector.RedirectURL(key); ok {
+				_, err := blobs.Stat(ctx, key)
+
+				if errors.Is(err, storage.ErrNotFound) {
+					writeNotFound(w)
+					return
+				}
+
+				if err != nil {
+					log.Error(err.Error())
+					writeInternalError(w)
+					return
+				}
+
+				http.Redirect(w, r, url, http.StatusFound)
+				return
+			}
+		}
+
+		blob, err := blobs.Open(ctx, key)
+
+		if errors.Is(err, storage.ErrNotFound) {
+			writeNotFound(w)
+			return
+		}
+
+		if err != nil {
+			log.Error(err.Error())
+			writeInternalError(w)
+			return
+		}
 
-		imagePath := volumePath + name
+		defer blob.Close()
 
-		http.ServeFile(w, r, imagePath)
+		// ServeContent answers If-None-Match, If-Range and Range requests from these
+		w.Header().Set("Content-Type", format.ContentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Cache-Control", imageCacheControl)
+		w.Header().Set("ETag", `"`+strings.TrimSuffix(key, format.Extension)+`"`)
+
+		http.ServeContent(w, r, key, blob.ModTime, blob)
 	}
 }
 
-func CreateProduct() http.HandlerFunc {
+func CreateProduct(store db.Store, blobs storage.BlobStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
 		err := r.ParseMultipartForm(10 << 20) // Limit to 10 MB file size
 		if err != nil {
 			log.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeBadRequest(w, "invalid multipart form, uploads are limited to 10 MB")
 			return
 		}
 
@@ -573,75 +903,51 @@ func CreateProduct() http.HandlerFunc {
 		imageFile, _, err := r.FormFile("image")
 		if err != nil {
 			log.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeBadRequest(w, "image is required")
 			return
 		}
 		defer imageFile.Close()
 
-		if err != nil {
-			log.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-
-		dbs := db.GetDbSource()
+		subcategoryObj, err := store.GetSubcategoryByName(ctx, subcategory)
 
-		subcategoryObj, err := dbs.GetSubcategoryByName(subcategory)
-
-		if err != nil {
-			log.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		// An unknown subcategory is the client referencing a missing row, not a missing resource
+		if errors.Is(err, db.ErrNotFound) {
+			writeError(w, http.StatusUnprocessableEntity, "foreign_key_violation", "subcategory does not reference an existing row")
 			return
 		}
 
-		parsedPrice, err := strconv.ParseFloat(price, 64)
-
 		if err != nil {
-			log.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeStoreError(w, ctx, err)
 			return
 		}
 
-		parsedCurrentInventory, err := strconv.Atoi(currentInventory)
+		parsedPrice, err := parseAmount(price, "price")
 
 		if err != nil {
 			log.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeBadRequest(w, err.Error())
 			return
 		}
 
-		// Generate a unique name for the image using the timestamp
-		imageName, err := generateRandomFilename(".jpg", 10)
+		parsedCurrentInventory, err := strconv.Atoi(currentInventory)
 
 		if err != nil {
 			log.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeBadRequest(w, "current_inventory must be an integer")
 			return
 		}
 
-		imagePath := volumePath + imageName
-		newImageFile, err := os.Create(imagePath)
-		if err != nil {
-			log.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		defer newImageFile.Close()
+		imageName, err := saveImage(ctx, blobs, imageFile)
 
-		_, err = io.Copy(newImageFile, imageFile) // Copy image data
 		if err != nil {
-			log.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeImageError(w, err)
 			return
 		}
 
-		dbs = db.GetDbSource()
-
-		err = dbs.InsertProduct(name, description, int(subcategoryObj.Id), parsedPrice, parsedCurrentInventory, imageName, brand, sku)
+		err = store.InsertProduct(ctx, name, description, int(subcategoryObj.Id), parsedPrice, parsedCurrentInventory, imageName, brand, sku)
 
 		if err != nil {
-			log.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeStoreError(w, ctx, err)
 			return
 		}
 
@@ -649,41 +955,51 @@ func CreateProduct() http.HandlerFunc {
 	}
 }
 
-func generateRandomFilename(extension string, length int) (string, error) {
-	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+// Processes an uploaded image and stores it along with its renditions under its content name, returning that name
+func saveImage(ctx context.Context, blobs storage.BlobStore, upload io.Reader) (string, error) {
+	processed, err := imaging.Process(upload)
 
-	randomBytes := make([]byte, length)
-	_, err := rand.Read(randomBytes)
 	if err != nil {
 		return "", err
 	}
 
-	for i := 0; i < length; i++ {
-		randomBytes[i] = charset[randomBytes[i]%byte(len(charset))]
+	imageName := imaging.ContentName(processed)
+
+	if err := blobs.Put(ctx, imageName, processed.Data, processed.Format.ContentType); err != nil {
+		return "", err
+	}
+
+	for rendition, data := range processed.Renditions {
+		if err := blobs.Put(ctx, imaging.RenditionName(imageName, rendition), data, processed.Format.ContentType); err != nil {
+			return "", err
+		}
 	}
 
-	return string(randomBytes) + extension, nil
+	return imageName, nil
 }
 
-func UpdateProduct() http.HandlerFunc {
+func UpdateProduct(store db.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
 		raw, err := io.ReadAll(r.Body)
 		if err != nil {
 			log.Error(err.Error())
-			w.WriteHeader(http.StatusBadRequest)
+			writeBadRequest(w, "invalid request body")
 			return
 		}
 
 		id := chi.URLParam(r, "id")
 
 		var body struct {
-			Name             string  `json:"name"`
-			Price            float64 `json:"price"`
-			CurrentInventory int     `json:"current_inventory"`
+			Name             string       `json:"name"`
+			Price            money.Amount `json:"price"`
+			CurrentInventory int          `json:"current_inventory"`
 		}
 		if err := json.Unmarshal(raw, &body); err != nil {
 			log.Error(err.Error())
-			w.WriteHeader(http.StatusBadRequest)
+			writeBadRequest(w, "invalid request body")
 			return
 		}
 
@@ -694,26 +1010,20 @@ func UpdateProduct() http.HandlerFunc {
 		price := body.Price
 		currentInventory := body.CurrentInventory
 
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-
-		dbs := db.GetDbSource()
 		parsedId, err := strconv.Atoi(id)
 
 		if err != nil {
 			log.Error(err.Error())
+			writeBadRequest(w, "id must be an integer")
 			return
 		}
 
-		log.Info("Updating product with id: ", zap.Int("id", parsedId), zap.String("name", name), zap.Float64("price", price), zap.Int("current_inventory", currentInventory))
+		log.Info("Updating product with id: ", zap.Int("id", parsedId), zap.String("name", name), zap.Stringer("price", price), zap.Int("current_inventory", currentInventory))
 
-		err = dbs.UpdateProduct(parsedId, name, price, currentInventory)
+		err = store.UpdateProduct(ctx, parsedId, name, price, currentInventory)
 
 		if err != nil {
-			log.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeStoreError(w, ctx, err)
 			return
 		}
 
@@ -721,23 +1031,25 @@ func UpdateProduct() http.HandlerFunc {
 	}
 }
 
-func DeleteProduct() http.HandlerFunc {
+func DeleteProduct(store db.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
 		id := chi.URLParam(r, "id")
 
-		dbs := db.GetDbSource()
 		parsedId, err := strconv.Atoi(id)
 
 		if err != nil {
 			log.Error(err.Error())
+			writeBadRequest(w, "id must be an integer")
 			return
 		}
 
-		err = dbs.DeleteProduct(parsedId)
+		err = store.DeleteProduct(ctx, parsedId)
 
 		if err != nil {
-			log.Error(err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeStoreError(w, ctx, err)
 			return
 		}
 
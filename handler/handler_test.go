@@ -0,0 +1,216 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"vayer-electric-backend/db"
+	"vayer-electric-backend/money"
+	"vayer-electric-backend/structs"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Routes of main.go the tests go through, chi fills in the path parameters
+func newTestRouter(store db.Store) http.Handler {
+	r := chi.NewRouter()
+	r.Get("/products/{id:[0-9]+}", GetProductById(store))
+	r.Put("/products/{id}", UpdateProduct(store))
+	r.Get("/products/{id}/stock", GetProductStock(store))
+	r.Get("/categories/{id}", GetCategoryById(store))
+	r.Post("/categories", CreateCategory(store))
+	r.Put("/categories/{id}", UpdateCategory(store))
+	r.Delete("/categories/{id}", DeleteCategory(store))
+	r.Put("/categories/{id}/tax-class", SetCategoryTaxClass(store))
+	r.Post("/tax-classes", CreateTaxClass(store))
+	r.Post("/carts", CreateCart(store))
+	r.Post("/carts/{token}/lines", AddCartLine(store))
+	r.Post("/carts/{token}/checkout", Checkout(store))
+	r.Post("/orders/{token}/cancel", CancelCustomerOrder(store))
+	return r
+}
+
+// Memory store with a category, a subcategory and product 1 priced 12.50 with 10 in stock
+func newTestStore(t *testing.T) db.Store {
+	t.Helper()
+
+	ctx := context.Background()
+	store := db.NewMemoryStore()
+
+	if err := store.InsertCategory(ctx, "Cables", "Cables and wires", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.InsertSubcategory(ctx, "Wire", "Building wire", 1, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.InsertProduct(ctx, "THHN 12", "Copper wire", 1, money.FromMinor(1250), 10, "thhn.jpg", "Southwire", "THHN-12"); err != nil {
+		t.Fatal(err)
+	}
+
+	return store
+}
+
+func serve(t *testing.T, h http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+
+	if err := json.NewDecoder(w.Body).Decode(v); err != nil {
+		t.Fatalf("decoding %q: %s", w.Body.String(), err)
+	}
+}
+
+func TestCategoryRoundTrip(t *testing.T) {
+	h := newTestRouter(db.NewMemoryStore())
+
+	w := serve(t, h, httptest.NewRequest(http.MethodPost, "/categories", strings.NewReader(`{"name": " Cables ", "description": "Cables and wires"}`)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", w.Code, w.Body.String())
+	}
+
+	w = serve(t, h, httptest.NewRequest(http.MethodPut, "/categories/1", strings.NewReader(`{"id": "1", "name": "Wires", "description": "Building wire"}`)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("update: %d %s", w.Code, w.Body.String())
+	}
+
+	w = serve(t, h, httptest.NewRequest(http.MethodGet, "/categories/1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("get: %d %s", w.Code, w.Body.String())
+	}
+
+	var category structs.Category
+	decode(t, w, &category)
+
+	if category.Name != "Wires" || category.Description != "Building wire" {
+		t.Errorf("got %q %q, want the updated category", category.Name, category.Description)
+	}
+
+	w = serve(t, h, httptest.NewRequest(http.MethodDelete, "/categories/1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("delete: %d %s", w.Code, w.Body.String())
+	}
+
+	w = serve(t, h, httptest.NewRequest(http.MethodGet, "/categories/1", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("get after delete: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestGetProductById(t *testing.T) {
+	h := newTestRouter(newTestStore(t))
+
+	w := serve(t, h, httptest.NewRequest(http.MethodGet, "/products/1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("get: %d %s", w.Code, w.Body.String())
+	}
+
+	var product structs.Product
+	decode(t, w, &product)
+
+	if product.Sku != "THHN-12" || product.Price != money.FromMinor(1250) || product.CurrentInventory != 10 {
+		t.Errorf("got %s at %s with %d in stock", product.Sku, product.Price, product.CurrentInventory)
+	}
+}
+
+func TestCheckoutTakesStockAndCancelGivesItBack(t *testing.T) {
+	h := newTestRouter(newTestStore(t))
+
+	w := serve(t, h, httptest.NewRequest(http.MethodPost, "/carts", nil))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("creating a cart: %d %s", w.Code, w.Body.String())
+	}
+
+	var cart structs.Cart
+	decode(t, w, &cart)
+
+	// More than the 10 in stock
+	w = serve(t, h, httptest.NewRequest(http.MethodPost, "/carts/"+cart.Token+"/lines", strings.NewReader(`{"product_id": 1, "quantity": 11}`)))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("adding 11: status = %d, want %d", w.Code, http.StatusConflict)
+	}
+
+	w = serve(t, h, httptest.NewRequest(http.MethodPost, "/carts/"+cart.Token+"/lines", strings.NewReader(`{"product_id": 1, "quantity": 4}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("adding 4: %d %s", w.Code, w.Body.String())
+	}
+
+	decode(t, w, &cart)
+	if cart.Subtotal != money.FromMinor(5000) {
+		t.Errorf("subtotal = %s, want 50.00", cart.Subtotal)
+	}
+
+	w = serve(t, h, httptest.NewRequest(http.MethodPost, "/carts/"+cart.Token+"/checkout", nil))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("checkout: %d %s", w.Code, w.Body.String())
+	}
+
+	var order structs.Order
+	decode(t, w, &order)
+
+	if order.Status != "pending" || order.Subtotal != cart.Subtotal {
+		t.Errorf("order is %s with a subtotal of %s, want pending with %s", order.Status, order.Subtotal, cart.Subtotal)
+	}
+
+	assertStock(t, h, 6)
+
+	w = serve(t, h, httptest.NewRequest(http.MethodPost, "/orders/"+order.Token+"/cancel", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("cancel: %d %s", w.Code, w.Body.String())
+	}
+
+	assertStock(t, h, 10)
+
+	// Cancelled is final
+	w = serve(t, h, httptest.NewRequest(http.MethodPost, "/orders/"+order.Token+"/cancel", nil))
+	if w.Code != http.StatusConflict {
+		t.Errorf("cancelling twice: status = %d, want %d", w.Code, http.StatusConflict)
+	}
+
+	assertStock(t, h, 10)
+}
+
+func TestUpdateProductStock(t *testing.T) {
+	h := newTestRouter(newTestStore(t))
+
+	w := serve(t, h, httptest.NewRequest(http.MethodPut, "/products/1", strings.NewReader(`{"name": "THHN 12", "price": "12.50", "current_inventory": 3}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("lowering stock: %d %s", w.Code, w.Body.String())
+	}
+
+	assertStock(t, h, 3)
+
+	w = serve(t, h, httptest.NewRequest(http.MethodPut, "/products/1", strings.NewReader(`{"name": "THHN 12", "price": "12.50", "current_inventory": -1}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("negative stock: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	assertStock(t, h, 3)
+}
+
+func assertStock(t *testing.T, h http.Handler, want int64) {
+	t.Helper()
+
+	w := serve(t, h, httptest.NewRequest(http.MethodGet, "/products/1/stock", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("stock: %d %s", w.Code, w.Body.String())
+	}
+
+	var stock structs.ProductStock
+	decode(t, w, &stock)
+
+	if stock.Total != want || stock.Available != want {
+		t.Errorf("stock is %d with %d available, want %d", stock.Total, stock.Available, want)
+	}
+}
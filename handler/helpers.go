@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"vayer-electric-backend/constants"
+	"vayer-electric-backend/db"
+	"vayer-electric-backend/imaging"
+)
+
+// Body of every error response
+type errorResponse struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+// Returns the request context bounded by constants.RequestTimeout.
+// It's canceled as well when the client goes away, which cancels the running query.
+func requestContext(r *http.Request) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(r.Context(), constants.RequestTimeout)
+}
+
+func writeError(w http.ResponseWriter, status int, code string, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{
+		Error:   code,
+		Message: message,
+	})
+}
+
+func writeBadRequest(w http.ResponseWriter, message string) {
+	writeError(w, http.StatusBadRequest, "bad_request", message)
+}
+
+func writeInternalError(w http.ResponseWriter) {
+	writeError(w, http.StatusInternalServerError, "internal_error", "internal server error")
+}
+
+// Writes the response for an upload that couldn't be processed or stored,
+// files that aren't an image we take are the client's fault
+func writeImageError(w http.ResponseWriter, err error) {
+	log.Error(err.Error())
+
+	switch {
+	case errors.Is(err, imaging.ErrUnsupportedFormat):
+		writeError(w, http.StatusUnsupportedMediaType, "unsupported_media_type", "images must be JPEG, PNG or GIF")
+	case errors.Is(err, imaging.ErrInvalidImage):
+		writeError(w, http.StatusUnprocessableEntity, "invalid_image", "image could not be decoded")
+	default:
+		writeInternalError(w)
+	}
+}
+
+// Writes the response for an error returned by the store.
+// Domain errors are mapped to their status code with their client safe message,
+// queries cut by the request deadline are reported as 504 and anything else is a 500
+// without details, the full error only goes to the logs.
+func writeStoreError(w http.ResponseWriter, ctx context.Context, err error) {
+	log.Error(err.Error())
+
+	if ctx.Err() == context.DeadlineExceeded {
+		writeError(w, http.StatusGatewayTimeout, "timeout", "request timed out")
+		return
+	}
+
+	var dbErr *db.Error
+	if !errors.As(err, &dbErr) {
+		writeInternalError(w)
+		return
+	}
+
+	switch dbErr.Kind {
+	case db.ErrNotFound:
+		writeError(w, http.StatusNotFound, "not_found", dbErr.Message)
+	case db.ErrConflict:
+		writeError(w, http.StatusConflict, "conflict", dbErr.Message)
+	case db.ErrForeignKey:
+		writeError(w, http.StatusUnprocessableEntity, "foreign_key_violation", dbErr.Message)
+	case db.ErrValidation:
+		writeError(w, http.StatusBadRequest, "validation_failed", dbErr.Message)
+	default:
+		writeInternalError(w)
+	}
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"vayer-electric-backend/db"
+)
+
+func TestWriteStoreError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"not found", db.NotFound("product"), http.StatusNotFound, "not_found"},
+		{"conflict", db.Conflict("taken"), http.StatusConflict, "conflict"},
+		{"foreign key", &db.Error{Kind: db.ErrForeignKey, Message: "no such category"}, http.StatusUnprocessableEntity, "foreign_key_violation"},
+		{"validation", db.ValidationError("name is required"), http.StatusBadRequest, "validation_failed"},
+		{"wrapped", fmt.Errorf("checkout: %w", db.NotFound("cart")), http.StatusNotFound, "not_found"},
+		{"unknown", errors.New("connection reset"), http.StatusInternalServerError, "internal_error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			writeStoreError(w, context.Background(), tt.err)
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+
+			var body errorResponse
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+
+			if body.Error != tt.code {
+				t.Errorf("error = %q, want %q", body.Error, tt.code)
+			}
+		})
+	}
+}
+
+func TestWriteStoreErrorDeadline(t *testing.T) {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	w := httptest.NewRecorder()
+	writeStoreError(w, ctx, ctx.Err())
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusGatewayTimeout)
+	}
+}
+
+func TestStoreErrorStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{"bad id", http.MethodGet, "/categories/abc", "", http.StatusBadRequest, "bad_request"},
+		{"bad body", http.MethodPost, "/tax-classes", "{", http.StatusBadRequest, "bad_request"},
+		{"missing product", http.MethodGet, "/products/99", "", http.StatusNotFound, "not_found"},
+		{"missing category", http.MethodGet, "/categories/99", "", http.StatusNotFound, "not_found"},
+		{"duplicate tax class", http.MethodPost, "/tax-classes", `{"name": "Standard"}`, http.StatusConflict, "conflict"},
+		{"missing tax class", http.MethodPut, "/categories/1/tax-class", `{"tax_class_id": 99}`, http.StatusUnprocessableEntity, "foreign_key_violation"},
+		{"empty name", http.MethodPost, "/tax-classes", `{"name": " "}`, http.StatusBadRequest, "validation_failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestRouter(newTestStore(t))
+			w := serve(t, h, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+
+			var body errorResponse
+			decode(t, w, &body)
+
+			if body.Error != tt.code {
+				t.Errorf("error = %q, want %q", body.Error, tt.code)
+			}
+		})
+	}
+}
+
+// A request past its deadline before the store runs is a 504, not the 500 of other store errors
+func TestStoreErrorRequestDeadline(t *testing.T) {
+	h := newTestRouter(newTestStore(t))
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	w := serve(t, h, httptest.NewRequest(http.MethodGet, "/products/1", nil).WithContext(ctx))
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusGatewayTimeout)
+	}
+}
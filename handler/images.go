@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"vayer-electric-backend/db"
+	"vayer-electric-backend/storage"
+	"vayer-electric-backend/structs"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func GetProductImages(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		parsedId, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "id must be an integer")
+			return
+		}
+
+		images, err := store.GetProductImages(ctx, parsedId)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(images)
+	}
+}
+
+// Adds an image to the end of the gallery of a product from a multipart form with
+// the image file, an optional alt_text and primary=true to make it the primary image
+func CreateProductImage(store db.Store, blobs storage.BlobStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		parsedId, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "id must be an integer")
+			return
+		}
+
+		err = r.ParseMultipartForm(10 << 20) // Limit to 10 MB file size
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid multipart form, uploads are limited to 10 MB")
+			return
+		}
+
+		primary := false
+		if value := r.FormValue("primary"); value != "" {
+			if primary, err = strconv.ParseBool(value); err != nil {
+				writeBadRequest(w, "primary must be a boolean")
+				return
+			}
+		}
+
+		imageFile, _, err := r.FormFile("image")
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "image is required")
+			return
+		}
+		defer imageFile.Close()
+
+		imageName, err := saveImage(ctx, blobs, imageFile)
+
+		if err != nil {
+			writeImageError(w, err)
+			return
+		}
+
+		img, err := store.InsertProductImage(ctx, structs.ProductImage{
+			ProductId: int64(parsedId),
+			ImageUrl:  imageName,
+			AltText:   r.FormValue("alt_text"),
+			Primary:   primary,
+		})
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(img)
+	}
+}
+
+// Sets the alt text of an image and makes it the primary image of its product when primary is true:
+//
+//	{"alt_text": "Front view", "primary": true}
+func UpdateProductImage(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		parsedId, parsedImageId, ok := parseImageIds(w, r)
+		if !ok {
+			return
+		}
+
+		raw, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		var body struct {
+			AltText string `json:"alt_text"`
+			Primary bool   `json:"primary"`
+		}
+		if err := json.Unmarshal(raw, &body); err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		img, err := store.UpdateProductImage(ctx, structs.ProductImage{
+			Id:        int64(parsedImageId),
+			ProductId: int64(parsedId),
+			AltText:   body.AltText,
+			Primary:   body.Primary,
+		})
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(img)
+	}
+}
+
+// Reorders the gallery of a product, the body lists every image id in the new order:
+//
+//	{"ids": [3, 1, 2]}
+func ReorderProductImages(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		parsedId, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "id must be an integer")
+			return
+		}
+
+		raw, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		var body struct {
+			Ids []int64 `json:"ids"`
+		}
+		if err := json.Unmarshal(raw, &body); err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		images, err := store.ReorderProductImages(ctx, parsedId, body.Ids)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(images)
+	}
+}
+
+func DeleteProductImage(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		parsedId, parsedImageId, ok := parseImageIds(w, r)
+		if !ok {
+			return
+		}
+
+		err := store.DeleteProductImage(ctx, parsedId, parsedImageId)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// Parses the product and image ids of the url, writing a bad request when they aren't integers
+func parseImageIds(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	parsedId, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		log.Error(err.Error())
+		writeBadRequest(w, "id must be an integer")
+		return 0, 0, false
+	}
+
+	parsedImageId, err := strconv.Atoi(chi.URLParam(r, "imageId"))
+
+	if err != nil {
+		log.Error(err.Error())
+		writeBadRequest(w, "imageId must be an integer")
+		return 0, 0, false
+	}
+
+	return parsedId, parsedImageId, true
+}
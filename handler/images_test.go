@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"vayer-electric-backend/storage"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const testImageName = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef.png"
+
+// Local store that redirects to a made up url, like S3Store with presigning enabled
+type redirectingStore struct {
+	*storage.LocalStore
+}
+
+func (redirectingStore) RedirectURL(key string) (string, bool) {
+	return "https://blobs.example.com/" + key + "?signed", true
+}
+
+func newImageRouter(t *testing.T, redirect bool) (http.Handler, *storage.LocalStore) {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	local, err := storage.NewLocalStore(filepath.Join(dir, "blobs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	placeholder := filepath.Join(dir, "placeholder.png")
+	if err := os.WriteFile(placeholder, []byte("\x89PNG\r\n\x1a\nplaceholder"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var blobs storage.BlobStore = local
+	if redirect {
+		blobs = redirectingStore{local}
+	}
+
+	r := chi.NewRouter()
+	r.Get("/images/{name}", ServeProductImage(blobs, placeholder))
+	return r, local
+}
+
+func TestServeProductImage(t *testing.T) {
+	h, blobs := newImageRouter(t, false)
+
+	if err := blobs.Put(context.Background(), testImageName, []byte("\x89PNG\r\n\x1a\nimage"), "image/png"); err != nil {
+		t.Fatal(err)
+	}
+
+	w := serve(t, h, httptest.NewRequest(http.MethodGet, "/images/"+testImageName, nil))
+
+	if w.Code != http.StatusOK || !strings.HasSuffix(w.Body.String(), "image") {
+		t.Fatalf("get: %d %q", w.Code, w.Body.String())
+	}
+
+	if got := w.Header().Get("Cache-Control"); got != imageCacheControl {
+		t.Errorf("Cache-Control = %q, want %q", got, imageCacheControl)
+	}
+
+	w = serve(t, h, httptest.NewRequest(http.MethodGet, "/images/not-an-image.png", nil))
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid name: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestServeProductImagePlaceholder(t *testing.T) {
+	for _, redirect := range []bool{false, true} {
+		h, _ := newImageRouter(t, redirect)
+
+		w := serve(t, h, httptest.NewRequest(http.MethodGet, "/images/"+testImageName, nil))
+
+		if w.Code != http.StatusNotFound || !strings.HasSuffix(w.Body.String(), "placeholder") {
+			t.Errorf("redirect %v: got %d %q, want the placeholder", redirect, w.Code, w.Body.String())
+		}
+
+		if got := w.Header().Get("Cache-Control"); got != "no-cache" {
+			t.Errorf("redirect %v: Cache-Control = %q, want no-cache", redirect, got)
+		}
+	}
+}
+
+// Stores that hand out presigned urls only redirect to images that exist
+func TestServeProductImageRedirect(t *testing.T) {
+	h, blobs := newImageRouter(t, true)
+
+	if err := blobs.Put(context.Background(), testImageName, []byte("\x89PNG\r\n\x1a\nimage"), "image/png"); err != nil {
+		t.Fatal(err)
+	}
+
+	w := serve(t, h, httptest.NewRequest(http.MethodGet, "/images/"+testImageName, nil))
+
+	if w.Code != http.StatusFound {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusFound)
+	}
+
+	if got, want := w.Header().Get("Location"), "https://blobs.example.com/"+testImageName+"?signed"; got != want {
+		t.Errorf("Location = %q, want %q", got, want)
+	}
+}
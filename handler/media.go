@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"vayer-electric-backend/db"
+	"vayer-electric-backend/imaging"
+	"vayer-electric-backend/storage"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// Replaces the image of a category with the image file of a multipart form
+func SetCategoryImage(store db.Store, blobs storage.BlobStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		parsedId, imageName, ok := uploadImage(ctx, w, r, blobs)
+		if !ok {
+			return
+		}
+
+		previous, err := store.SetCategoryImage(ctx, parsedId, imageName)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		releaseImage(ctx, store, blobs, previous, imageName)
+
+		category, err := store.GetCategoryById(ctx, parsedId)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(category)
+	}
+}
+
+// Replaces the image of a subcategory with the image file of a multipart form
+func SetSubcategoryImage(store db.Store, blobs storage.BlobStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		parsedId, imageName, ok := uploadImage(ctx, w, r, blobs)
+		if !ok {
+			return
+		}
+
+		previous, err := store.SetSubcategoryImage(ctx, parsedId, imageName)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		releaseImage(ctx, store, blobs, previous, imageName)
+
+		subcategory, err := store.GetSubcategoryById(ctx, parsedId)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(subcategory)
+	}
+}
+
+// Parses the id of the url and saves the image file of a multipart form, writing the error response when either fails
+func uploadImage(ctx context.Context, w http.ResponseWriter, r *http.Request, blobs storage.BlobStore) (int, string, bool) {
+	parsedId, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		log.Error(err.Error())
+		writeBadRequest(w, "id must be an integer")
+		return 0, "", false
+	}
+
+	err = r.ParseMultipartForm(10 << 20) // Limit to 10 MB file size
+	if err != nil {
+		log.Error(err.Error())
+		writeBadRequest(w, "invalid multipart form, uploads are limited to 10 MB")
+		return 0, "", false
+	}
+
+	imageFile, _, err := r.FormFile("image")
+	if err != nil {
+		log.Error(err.Error())
+		writeBadRequest(w, "image is required")
+		return 0, "", false
+	}
+	defer imageFile.Close()
+
+	imageName, err := saveImage(ctx, blobs, imageFile)
+
+	if err != nil {
+		writeImageError(w, err)
+		return 0, "", false
+	}
+
+	return parsedId, imageName, true
+}
+
+// Removes a replaced image and its renditions once nothing points at it anymore.
+// Images hosted elsewhere before uploads existed aren't ours to remove, failures are only logged
+// since the new image is already in place.
+func releaseImage(ctx context.Context, store db.Store, blobs storage.BlobStore, previous string, current string) {
+	if previous == "" || previous == current {
+		return
+	}
+
+	if _, ok := imaging.ParseName(previous); !ok {
+		return
+	}
+
+	referenced, err := store.ImageReferenced(ctx, previous)
+
+	if err != nil || referenced {
+		return
+	}
+
+	keys := []string{previous}
+	for _, rendition := range imaging.Renditions {
+		keys = append(keys, imaging.RenditionName(previous, rendition.Name))
+	}
+
+	for _, key := range keys {
+		if err := blobs.Delete(ctx, key); err != nil {
+			log.Error("replaced image could not be removed", zap.String("key", key), zap.Error(err))
+		}
+	}
+}
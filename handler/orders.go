@@ -0,0 +1,220 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"vayer-electric-backend/db"
+	"vayer-electric-backend/structs"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Turns a cart into a pending order, taking the stock of its lines. The body is optional and lists
+// the reservations the storefront held for the cart during checkout, they're released into the order:
+//
+//	{"reservations": [31, 32]}
+func Checkout(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		raw, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		var body struct {
+			Reservations []int64 `json:"reservations"`
+		}
+		if len(raw) > 0 {
+			if err := json.Unmarshal(raw, &body); err != nil {
+				log.Error(err.Error())
+				writeBadRequest(w, "invalid request body")
+				return
+			}
+		}
+
+		order, err := store.Checkout(ctx, chi.URLParam(r, "token"), customerId(r), body.Reservations)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(order)
+	}
+}
+
+// Returns the orders of the logged in customer newest first, paged with ?limit= and ?cursor= and filtered by ?status=
+func GetCustomerOrders(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		customer := customerId(r)
+
+		if customer == "" {
+			writeError(w, http.StatusUnauthorized, "unauthorized", "log in to see your orders")
+			return
+		}
+
+		limit, err := parseOptionalInt(r.URL.Query().Get("limit"), "limit")
+
+		if err != nil {
+			writeBadRequest(w, err.Error())
+			return
+		}
+
+		page, err := store.GetOrders(ctx, customer, r.URL.Query().Get("status"), limit, r.URL.Query().Get("cursor"))
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(page)
+	}
+}
+
+// Returns an order with its status history, the order of a customer only to that customer
+func GetCustomerOrder(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		order, err := store.GetOrderByToken(ctx, chi.URLParam(r, "token"), customerId(r))
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(order)
+	}
+}
+
+// Cancels an order that hasn't been paid yet, its stock goes back on the shelf
+func CancelCustomerOrder(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		customer := customerId(r)
+
+		order, err := store.GetOrderByToken(ctx, chi.URLParam(r, "token"), customer)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		order, err = store.TransitionOrder(ctx, int(order.Id), structs.OrderTransition{From: "pending", To: "cancelled", User: customer, Note: "cancelled by the customer"})
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(order)
+	}
+}
+
+// Returns every order newest first, paged with ?limit= and ?cursor= and filtered by ?status= and ?customer_id=
+func GetOrders(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		limit, err := parseOptionalInt(r.URL.Query().Get("limit"), "limit")
+
+		if err != nil {
+			writeBadRequest(w, err.Error())
+			return
+		}
+
+		query := r.URL.Query()
+		page, err := store.GetOrders(ctx, query.Get("customer_id"), query.Get("status"), limit, query.Get("cursor"))
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(page)
+	}
+}
+
+func GetOrderById(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		parsedId, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "id must be an integer")
+			return
+		}
+
+		order, err := store.GetOrderById(ctx, parsedId)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(order)
+	}
+}
+
+// Moves an order to another status, from is optional and makes the change fail when the order is no longer in it:
+//
+//	{"status": "shipped", "from": "picking", "user": "warehouse-2", "note": "tracking 1Z999"}
+func TransitionOrder(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		parsedId, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "id must be an integer")
+			return
+		}
+
+		raw, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		var body struct {
+			Status string `json:"status"`
+			From   string `json:"from"`
+			User   string `json:"user"`
+			Note   string `json:"note"`
+		}
+		if err := json.Unmarshal(raw, &body); err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		order, err := store.TransitionOrder(ctx, parsedId, structs.OrderTransition{From: body.From, To: body.Status, User: body.User, Note: body.Note})
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(order)
+	}
+}
@@ -0,0 +1,229 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"vayer-electric-backend/db"
+	"vayer-electric-backend/money"
+	"vayer-electric-backend/payment"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func writePaymentError(w http.ResponseWriter, ctx context.Context, err error) {
+	switch {
+	case errors.Is(err, payment.ErrInvalidSignature):
+		log.Error(err.Error())
+		writeError(w, http.StatusUnauthorized, "invalid_signature", err.Error())
+	case errors.Is(err, payment.ErrProvider):
+		log.Error(err.Error())
+		writeError(w, http.StatusBadGateway, "payment_provider_error", err.Error())
+	default:
+		writeStoreError(w, ctx, err)
+	}
+}
+
+// Reads the optional {"amount": "10.50"} body of captures and refunds, 0 when missing
+func readPaymentAmount(r *http.Request) (money.Amount, error) {
+	raw, err := io.ReadAll(r.Body)
+
+	if err != nil || len(raw) == 0 {
+		return 0, err
+	}
+
+	var body struct {
+		Amount money.Amount `json:"amount"`
+	}
+	err = json.Unmarshal(raw, &body)
+
+	return body.Amount, err
+}
+
+// Pays a pending order, source is the payment method token the storefront got from the provider:
+//
+//	{"source": "fake_success"}
+//
+// Responds with the payment, authorized, declined or pending until the provider's webhook settles it.
+func CreatePayment(store db.Store, processor *payment.Processor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		raw, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		var body struct {
+			Source string `json:"source"`
+		}
+		if err := json.Unmarshal(raw, &body); err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		order, err := store.GetOrderByToken(ctx, chi.URLParam(r, "token"), customerId(r))
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		p, err := processor.Pay(ctx, order, body.Source)
+
+		if err != nil {
+			writePaymentError(w, ctx, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(p)
+	}
+}
+
+func GetCustomerOrderPayments(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		order, err := store.GetOrderByToken(ctx, chi.URLParam(r, "token"), customerId(r))
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		payments, err := store.GetPayments(ctx, int(order.Id))
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(payments)
+	}
+}
+
+func GetOrderPayments(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		parsedId, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "id must be an integer")
+			return
+		}
+
+		payments, err := store.GetPayments(ctx, parsedId)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(payments)
+	}
+}
+
+// Captures an authorized payment, the body is optional and captures less than what was authorized:
+//
+//	{"amount": "42.50"}
+func CapturePayment(processor *payment.Processor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		parsedId, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "id must be an integer")
+			return
+		}
+
+		amount, err := readPaymentAmount(r)
+
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		p, err := processor.Capture(ctx, parsedId, amount)
+
+		if err != nil {
+			writePaymentError(w, ctx, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(p)
+	}
+}
+
+// Refunds a captured payment, the body is optional and refunds part of it. Refunding all of it refunds the order:
+//
+//	{"amount": "10.00"}
+func RefundPayment(processor *payment.Processor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		parsedId, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "id must be an integer")
+			return
+		}
+
+		amount, err := readPaymentAmount(r)
+
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		p, err := processor.Refund(ctx, parsedId, amount)
+
+		if err != nil {
+			writePaymentError(w, ctx, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(p)
+	}
+}
+
+// Receives the provider's webhook callbacks. Anything but a 2xx makes the provider deliver the callback again,
+// an event applied before is acknowledged with a 200 like a new one.
+func PaymentWebhook(processor *payment.Processor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		raw, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		if _, err := processor.HandleWebhook(ctx, r.Header, raw); err != nil {
+			writePaymentError(w, ctx, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
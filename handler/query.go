@@ -0,0 +1,262 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"vayer-electric-backend/db"
+	"vayer-electric-backend/money"
+	"vayer-electric-backend/units"
+)
+
+// Parses the filters, sort and pagination of a product listing from the query string, search is the full text
+// search of the listing if it's one:
+//
+//	brand=a,b&subcategory=1&category=2&min_price=10&max_price=20&in_stock=true
+//	sort=price&order=asc&limit=20&cursor=...&facets=true
+//	attr.poles=2&attr.certifications=CE,UL&attr.amperage.min=10&attr.amperage.max=32
+//	attr.section.min=2.5mm2&attr.section.max=10AWG&attr.power.min=1&attr.power.unit=kW
+func parseProductQuery(r *http.Request, search string) (db.ProductQuery, error) {
+	values := r.URL.Query()
+	q := db.ProductQuery{
+		Search: search,
+		Sort:   values.Get("sort"),
+		Cursor: values.Get("cursor"),
+	}
+
+	for _, brand := range values["brand"] {
+		for _, b := range strings.Split(brand, ",") {
+			if b = strings.TrimSpace(b); b != "" {
+				q.Brands = append(q.Brands, b)
+			}
+		}
+	}
+
+	var err error
+
+	if q.SubcategoryId, err = parseOptionalInt(values.Get("subcategory"), "subcategory"); err != nil {
+		return q, err
+	}
+
+	if q.CategoryId, err = parseOptionalInt(values.Get("category"), "category"); err != nil {
+		return q, err
+	}
+
+	if q.Limit, err = parseOptionalInt(values.Get("limit"), "limit"); err != nil {
+		return q, err
+	}
+
+	if q.MinPrice, err = parseOptionalAmount(values.Get("min_price"), "min_price"); err != nil {
+		return q, err
+	}
+
+	if q.MaxPrice, err = parseOptionalAmount(values.Get("max_price"), "max_price"); err != nil {
+		return q, err
+	}
+
+	if inStock := values.Get("in_stock"); inStock != "" {
+		if q.InStockOnly, err = strconv.ParseBool(inStock); err != nil {
+			return q, errors.New("in_stock must be a boolean")
+		}
+	}
+
+	if q.Attributes, err = parseAttributeFilters(values); err != nil {
+		return q, err
+	}
+
+	if facets := values.Get("facets"); facets != "" {
+		if q.Facets, err = strconv.ParseBool(facets); err != nil {
+			return q, errors.New("facets must be a boolean")
+		}
+	}
+
+	// Newest or most relevant first by default, price and name ascending unless asked otherwise
+	order := values.Get("order")
+	if order != "" && q.Sort == "" {
+		q.Sort = "created_at"
+
+		if q.Search != "" {
+			q.Sort = "relevance"
+		}
+	}
+
+	switch order {
+	case "":
+		q.Descending = q.Sort == "created_at" || q.Sort == "relevance"
+	case "asc":
+		q.Descending = false
+	case "desc":
+		q.Descending = true
+	default:
+		return q, errors.New("order must be asc or desc")
+	}
+
+	return q, nil
+}
+
+// Collects the attr.<code>, attr.<code>.min, attr.<code>.max and attr.<code>.unit parameters into one filter per code.
+// Bounds may carry their own unit, attr.power.min=1kW&attr.power.max=1500W and attr.section.min=12AWG both work,
+// bare bounds are in attr.<code>.unit or else in the unit of each attribute definition.
+func parseAttributeFilters(values url.Values) ([]db.AttributeFilter, error) {
+	byCode := make(map[string]*db.AttributeFilter)
+	bounds := make(map[string][2]string)
+	codes := make([]string, 0)
+
+	filter := func(code string) *db.AttributeFilter {
+		if byCode[code] == nil {
+			byCode[code] = &db.AttributeFilter{Code: code}
+			codes = append(codes, code)
+		}
+		return byCode[code]
+	}
+
+	for key, value := range values {
+		if !strings.HasPrefix(key, "attr.") || len(value) == 0 {
+			continue
+		}
+
+		code := strings.TrimPrefix(key, "attr.")
+
+		switch {
+		case strings.HasSuffix(code, ".min"):
+			code = strings.TrimSuffix(code, ".min")
+			filter(code)
+			b := bounds[code]
+			b[0] = value[0]
+			bounds[code] = b
+
+		case strings.HasSuffix(code, ".max"):
+			code = strings.TrimSuffix(code, ".max")
+			filter(code)
+			b := bounds[code]
+			b[1] = value[0]
+			bounds[code] = b
+
+		case strings.HasSuffix(code, ".unit"):
+			filter(strings.TrimSuffix(code, ".unit")).Unit = strings.TrimSpace(value[0])
+
+		default:
+			f := filter(code)
+			for _, v := range value {
+				for _, item := range strings.Split(v, ",") {
+					if item = strings.TrimSpace(item); item != "" {
+						f.Values = append(f.Values, item)
+					}
+				}
+			}
+		}
+	}
+
+	// Map iteration order is random, keep the statement stable
+	sort.Strings(codes)
+
+	filters := make([]db.AttributeFilter, 0, len(codes))
+	for _, code := range codes {
+		f := byCode[code]
+
+		if err := parseAttributeBounds(f, bounds[code]); err != nil {
+			return nil, err
+		}
+
+		filters = append(filters, *f)
+	}
+
+	return filters, nil
+}
+
+// Parses the min and max bounds of a filter, converting them to a single unit
+func parseAttributeBounds(f *db.AttributeFilter, bounds [2]string) error {
+	var unit *units.Unit
+
+	if f.Unit != "" {
+		u, err := units.Lookup(f.Unit)
+		if err != nil {
+			return fmt.Errorf("attr.%s.unit: %s", f.Code, err.Error())
+		}
+		unit = &u
+	}
+
+	parsed := make([]*float64, 2)
+
+	for i, suffix := range []string{"min", "max"} {
+		if bounds[i] == "" {
+			continue
+		}
+
+		name := fmt.Sprintf("attr.%s.%s", f.Code, suffix)
+
+		v, boundUnit, err := units.Parse(bounds[i])
+		if err != nil {
+			return fmt.Errorf("%s must be a number with an optional unit", name)
+		}
+
+		if boundUnit != nil {
+			if unit == nil {
+				unit = boundUnit
+			} else if v, err = units.Convert(v, *boundUnit, *unit); err != nil {
+				return fmt.Errorf("%s: %s", name, err.Error())
+			} else if math.IsNaN(v) {
+				return fmt.Errorf("%s: %s has no size in %s", name, bounds[i], unit.Symbol)
+			}
+		}
+
+		parsed[i] = &v
+	}
+
+	// A bare bound next to one with a unit is read in that unit
+	if unit != nil {
+		f.Unit = unit.Symbol
+	}
+
+	f.Min, f.Max = parsed[0], parsed[1]
+
+	return nil
+}
+
+func parseOptionalInt(value string, name string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	// 0 would read as the parameter not being there
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed <= 0 {
+		return 0, fmt.Errorf("%s must be a positive integer", name)
+	}
+
+	return parsed, nil
+}
+
+// Parses an amount of money like "12.50", with the reason it isn't one as error
+func parseAmount(value string, name string) (money.Amount, error) {
+	parsed, err := money.Parse(value)
+
+	switch {
+	case errors.Is(err, money.ErrPrecision):
+		return 0, fmt.Errorf("%s can't have more than 2 decimals", name)
+	case errors.Is(err, money.ErrOverflow):
+		return 0, fmt.Errorf("%s is out of range", name)
+	case err != nil:
+		return 0, fmt.Errorf("%s must be a number", name)
+	}
+
+	return parsed, nil
+}
+
+func parseOptionalAmount(value string, name string) (*money.Amount, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := parseAmount(value, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &parsed, nil
+}
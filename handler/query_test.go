@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseProductQuerySort(t *testing.T) {
+	tests := []struct {
+		query      string
+		search     string
+		sort       string
+		descending bool
+	}{
+		// Left to the store, newest or most relevant first
+		{"", "", "", false},
+		{"", "cable", "", false},
+		{"order=asc", "", "created_at", false},
+		// A search keeps its ranking, only reversed
+		{"order=asc", "cable", "relevance", false},
+		{"sort=price", "", "price", false},
+		{"sort=price&order=desc", "cable", "price", true},
+	}
+
+	for _, tt := range tests {
+		q, err := parseProductQuery(httptest.NewRequest("GET", "/products?"+tt.query, nil), tt.search)
+
+		if err != nil {
+			t.Errorf("%q: %s", tt.query, err)
+			continue
+		}
+
+		if q.Sort != tt.sort || q.Descending != tt.descending {
+			t.Errorf("%q searching %q: sort %q descending %v, want %q %v", tt.query, tt.search, q.Sort, q.Descending, tt.sort, tt.descending)
+		}
+	}
+}
+
+func TestParseOptionalInt(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int
+		ok    bool
+	}{
+		{"", 0, true},
+		{"20", 20, true},
+		{"0", 0, false},
+		{"-1", 0, false},
+		{"ten", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, err := parseOptionalInt(tt.value, "limit")
+
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parseOptionalInt(%q) = %d, %v", tt.value, got, err)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"vayer-electric-backend/db"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Sets when a product counts as low on stock and how much to reorder, a reorder_point of 0 turns alerts off:
+//
+//	{"reorder_point": 10, "reorder_quantity": 50}
+func SetReorderPoint(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		parsedId, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "id must be an integer")
+			return
+		}
+
+		raw, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		var body struct {
+			ReorderPoint    int64 `json:"reorder_point"`
+			ReorderQuantity int64 `json:"reorder_quantity"`
+		}
+		if err := json.Unmarshal(raw, &body); err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		err = store.SetReorderPoint(ctx, parsedId, body.ReorderPoint, body.ReorderQuantity)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// Returns every product whose available stock is below its reorder point
+func GetLowStockProducts(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		products, err := store.GetLowStockProducts(ctx)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(products)
+	}
+}
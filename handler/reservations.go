@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+	"vayer-electric-backend/db"
+	"vayer-electric-backend/structs"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Returns the reservations of a product that haven't expired
+func GetReservations(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		parsedId, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "id must be an integer")
+			return
+		}
+
+		reservations, err := store.GetReservations(ctx, parsedId)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(reservations)
+	}
+}
+
+// Holds stock of a product, or of one of its variants with variant_id, ttl is in seconds and defaults
+// to env.RESERVATION_TTL. A reservation held during checkout names the token of its cart, the checkout
+// of that cart is the only one that can release it:
+//
+//	{"quantity": 2, "variant_id": 40, "ttl": 900, "reference": "checkout 8f2c", "cart": "3f9a..."}
+func CreateReservation(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		parsedId, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "id must be an integer")
+			return
+		}
+
+		raw, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		var body struct {
+			Quantity  int64  `json:"quantity"`
+			VariantId int64  `json:"variant_id"`
+			TTL       int64  `json:"ttl"`
+			Reference string `json:"reference"`
+			Cart      string `json:"cart"`
+		}
+		if err := json.Unmarshal(raw, &body); err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		reservation, err := store.InsertReservation(ctx, structs.Reservation{
+			ProductId: int64(parsedId),
+			VariantId: body.VariantId,
+			Quantity:  body.Quantity,
+			Reference: body.Reference,
+		}, body.Cart, customerId(r), time.Duration(body.TTL)*time.Second)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(reservation)
+	}
+}
+
+// Releases a reservation, its stock is available again right away
+func DeleteReservation(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		parsedId, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "id must be an integer")
+			return
+		}
+
+		err = store.DeleteReservation(ctx, parsedId)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
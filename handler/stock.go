@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"vayer-electric-backend/db"
+	"vayer-electric-backend/structs"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Returns the stock history of a product newest first, paged with ?limit= and ?cursor=
+func GetStockMovements(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		parsedId, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "id must be an integer")
+			return
+		}
+
+		limit, err := parseOptionalInt(r.URL.Query().Get("limit"), "limit")
+
+		if err != nil {
+			writeBadRequest(w, err.Error())
+			return
+		}
+
+		page, err := store.GetStockMovements(ctx, parsedId, limit, r.URL.Query().Get("cursor"))
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(page)
+	}
+}
+
+// Records a stock movement of a product, quantity is signed and location_id defaults to the default location.
+// A movement of a variant names it with variant_id instead of a location:
+//
+//	{"reason": "sale", "quantity": -2, "location_id": 3, "user": "counter-1", "reference": "invoice 1042"}
+func CreateStockMovement(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		parsedId, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "id must be an integer")
+			return
+		}
+
+		raw, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		var body struct {
+			Reason     string `json:"reason"`
+			Quantity   int64  `json:"quantity"`
+			VariantId  int64  `json:"variant_id"`
+			LocationId int64  `json:"location_id"`
+			User       string `json:"user"`
+			Reference  string `json:"reference"`
+		}
+		if err := json.Unmarshal(raw, &body); err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		movement, err := store.InsertStockMovement(ctx, structs.StockMovement{
+			ProductId:  int64(parsedId),
+			VariantId:  body.VariantId,
+			LocationId: body.LocationId,
+			Reason:     body.Reason,
+			Quantity:   body.Quantity,
+			User:       body.User,
+			Reference:  body.Reference,
+		})
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(movement)
+	}
+}
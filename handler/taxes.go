@@ -0,0 +1,253 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"vayer-electric-backend/db"
+	"vayer-electric-backend/env"
+	"vayer-electric-backend/money"
+	"vayer-electric-backend/structs"
+	"vayer-electric-backend/tax"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Reads the region prices are taxed in, ?region=DE, env.TAX_REGION when the request doesn't ask for one
+func parseTaxRegion(r *http.Request) (string, error) {
+	region := r.URL.Query().Get("region")
+
+	if region == "" {
+		region = env.TAX_REGION
+	}
+
+	normalized, ok := tax.NormalizeRegion(region)
+
+	if !ok {
+		return "", errors.New("region must be up to 16 letters, digits and dashes, or " + tax.AnyRegion)
+	}
+
+	return normalized, nil
+}
+
+// Taxes products and their variants in place in region
+func taxProducts(ctx context.Context, store db.Store, products []structs.Product, region string) error {
+	table, err := store.GetTaxTable(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	return table.TaxProducts(products, region)
+}
+
+// Returns every tax class with its rates, the default class first
+func GetTaxClasses(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		classes, err := store.GetTaxClasses(ctx)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(classes)
+	}
+}
+
+// Creates a tax class without rates:
+//
+//	{"name": "Reduced"}
+func CreateTaxClass(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		raw, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		var body struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(raw, &body); err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		class, err := store.InsertTaxClass(ctx, body.Name)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(class)
+	}
+}
+
+// Deletes a tax class and its rates, what was in it goes back to the class it inherits
+func DeleteTaxClass(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		parsedId, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "id must be an integer")
+			return
+		}
+
+		if err := store.DeleteTaxClass(ctx, parsedId); err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// Sets the rate of a tax class in the region of the path, in percent. The region * covers regions
+// the class has no rate of their own for:
+//
+//	{"rate": "21.00"}
+func SetTaxRate(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		parsedId, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "id must be an integer")
+			return
+		}
+
+		raw, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		var body struct {
+			Rate *json.RawMessage `json:"rate"`
+		}
+		if err := json.Unmarshal(raw, &body); err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		if body.Rate == nil {
+			writeBadRequest(w, "rate is required")
+			return
+		}
+
+		var rate money.Rate
+		if err := rate.UnmarshalJSON(*body.Rate); err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "rate must be a percentage between 0 and 100 with at most 2 decimals")
+			return
+		}
+
+		created, err := store.SetTaxRate(ctx, structs.TaxRate{TaxClassId: int64(parsedId), Region: chi.URLParam(r, "region"), Rate: rate})
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(created)
+	}
+}
+
+func DeleteTaxRate(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		parsedId, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "id must be an integer")
+			return
+		}
+
+		if err := store.DeleteTaxRate(ctx, parsedId, chi.URLParam(r, "region")); err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// Sets the tax class of the category, subcategory or product of the path, null clears it so the class
+// is inherited again:
+//
+//	{"tax_class_id": 2}
+func setTaxClass(set func(ctx context.Context, id int, taxClassId int64) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		parsedId, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "id must be an integer")
+			return
+		}
+
+		raw, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		var body struct {
+			TaxClassId int64 `json:"tax_class_id"`
+		}
+		if err := json.Unmarshal(raw, &body); err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		if err := set(ctx, parsedId, body.TaxClassId); err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func SetCategoryTaxClass(store db.Store) http.HandlerFunc {
+	return setTaxClass(store.SetCategoryTaxClass)
+}
+
+func SetSubcategoryTaxClass(store db.Store) http.HandlerFunc {
+	return setTaxClass(store.SetSubcategoryTaxClass)
+}
+
+func SetProductTaxClass(store db.Store) http.HandlerFunc {
+	return setTaxClass(store.SetProductTaxClass)
+}
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"vayer-electric-backend/structs"
+	"vayer-electric-backend/units"
+)
+
+// Reads the units attribute values are returned in: ?units=si for canonical SI units
+// and/or a list of units like ?units=AWG,kW
+func parseUnitPreference(r *http.Request) (units.Preference, error) {
+	preference, err := units.ParsePreference(r.URL.Query().Get("units"))
+
+	if err != nil {
+		return units.Preference{}, fmt.Errorf("units: %s", err.Error())
+	}
+
+	return preference, nil
+}
+
+// Converts the numeric attributes of products in place to the preferred units
+func convertProductUnits(products []structs.Product, preference units.Preference) {
+	if preference.IsEmpty() {
+		return
+	}
+
+	for i := range products {
+		for j, a := range products[i].Attributes {
+			if a.SIValue == nil {
+				continue
+			}
+
+			current, err := units.Lookup(a.Unit)
+			if err != nil {
+				continue
+			}
+
+			preferred := preference.For(current)
+			products[i].Attributes[j].Value = units.Present(preferred.FromCanonical(*a.SIValue), preferred)
+			products[i].Attributes[j].Unit = preferred.Symbol
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"vayer-electric-backend/db"
+	"vayer-electric-backend/money"
+	"vayer-electric-backend/storage"
+	"vayer-electric-backend/structs"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func GetProductVariants(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		parsedId, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "id must be an integer")
+			return
+		}
+
+		matrix, err := store.GetVariantMatrix(ctx, parsedId)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(matrix)
+	}
+}
+
+// Replaces the axes the variants of a product vary on, the body lists them in display order:
+//
+//	{"axes": ["colour", "length"]}
+func SetVariantAxes(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		parsedId, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "id must be an integer")
+			return
+		}
+
+		raw, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		var body struct {
+			Axes []string `json:"axes"`
+		}
+		if err := json.Unmarshal(raw, &body); err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		err = store.SetVariantAxes(ctx, parsedId, body.Axes)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// Creates a variant from a multipart form with sku, price, current_inventory, an option.<axis> field
+// for every axis of the product and an optional image
+func CreateProductVariant(store db.Store, blobs storage.BlobStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		parsedId, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "id must be an integer")
+			return
+		}
+
+		err = r.ParseMultipartForm(10 << 20) // Limit to 10 MB file size
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid multipart form, uploads are limited to 10 MB")
+			return
+		}
+
+		parsedPrice, err := parseAmount(r.FormValue("price"), "price")
+
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, err.Error())
+			return
+		}
+
+		parsedCurrentInventory, err := strconv.Atoi(r.FormValue("current_inventory"))
+
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "current_inventory must be an integer")
+			return
+		}
+
+		options := make(map[string]string)
+		for key, values := range r.MultipartForm.Value {
+			if strings.HasPrefix(key, "option.") && len(values) > 0 {
+				options[strings.TrimPrefix(key, "option.")] = values[0]
+			}
+		}
+
+		imageName := ""
+
+		imageFile, _, err := r.FormFile("image")
+		if err == nil {
+			defer imageFile.Close()
+
+			imageName, err = saveImage(ctx, blobs, imageFile)
+
+			if err != nil {
+				writeImageError(w, err)
+				return
+			}
+		}
+
+		variant, err := store.InsertProductVariant(ctx, structs.ProductVariant{
+			ProductId:        int64(parsedId),
+			Sku:              r.FormValue("sku"),
+			Price:            parsedPrice,
+			CurrentInventory: int64(parsedCurrentInventory),
+			ImageUrl:         imageName,
+			Options:          options,
+		})
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(variant)
+	}
+}
+
+// Updates a variant from a json body with its sku, price, current_inventory and options:
+//
+//	{"sku": "CAB-RED-50", "price": 42.5, "current_inventory": 12, "options": {"colour": "red", "length": "50 m"}}
+func UpdateProductVariant(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		parsedId, parsedVariantId, ok := parseVariantIds(w, r)
+		if !ok {
+			return
+		}
+
+		raw, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		var body struct {
+			Sku              string            `json:"sku"`
+			Price            money.Amount      `json:"price"`
+			CurrentInventory int64             `json:"current_inventory"`
+			Options          map[string]string `json:"options"`
+		}
+		if err := json.Unmarshal(raw, &body); err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid request body")
+			return
+		}
+
+		variant, err := store.UpdateProductVariant(ctx, structs.ProductVariant{
+			Id:               int64(parsedVariantId),
+			ProductId:        int64(parsedId),
+			Sku:              body.Sku,
+			Price:            body.Price,
+			CurrentInventory: body.CurrentInventory,
+			Options:          body.Options,
+		})
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(variant)
+	}
+}
+
+// Replaces the image of a variant with the image file of a multipart form
+func SetProductVariantImage(store db.Store, blobs storage.BlobStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		parsedId, parsedVariantId, ok := parseVariantIds(w, r)
+		if !ok {
+			return
+		}
+
+		err := r.ParseMultipartForm(10 << 20) // Limit to 10 MB file size
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "invalid multipart form, uploads are limited to 10 MB")
+			return
+		}
+
+		imageFile, _, err := r.FormFile("image")
+		if err != nil {
+			log.Error(err.Error())
+			writeBadRequest(w, "image is required")
+			return
+		}
+		defer imageFile.Close()
+
+		imageName, err := saveImage(ctx, blobs, imageFile)
+
+		if err != nil {
+			writeImageError(w, err)
+			return
+		}
+
+		variant, err := store.SetProductVariantImage(ctx, parsedId, parsedVariantId, imageName)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(variant)
+	}
+}
+
+func DeleteProductVariant(store db.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r)
+		defer cancel()
+
+		parsedId, parsedVariantId, ok := parseVariantIds(w, r)
+		if !ok {
+			return
+		}
+
+		err := store.DeleteProductVariant(ctx, parsedId, parsedVariantId)
+
+		if err != nil {
+			writeStoreError(w, ctx, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// Parses the product and variant ids of the url, writing a bad request when they aren't integers
+func parseVariantIds(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	parsedId, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		log.Error(err.Error())
+		writeBadRequest(w, "id must be an integer")
+		return 0, 0, false
+	}
+
+	parsedVariantId, err := strconv.Atoi(chi.URLParam(r, "variantId"))
+
+	if err != nil {
+		log.Error(err.Error())
+		writeBadRequest(w, "variantId must be an integer")
+		return 0, 0, false
+	}
+
+	return parsedId, parsedVariantId, true
+}
@@ -0,0 +1,49 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrInvalidImage      = errors.New("invalid image")
+)
+
+// Format is an image format uploads are accepted in
+type Format struct {
+	Name        string
+	Extension   string
+	ContentType string
+	magic       []byte
+}
+
+var (
+	JPEG = Format{Name: "jpeg", Extension: ".jpg", ContentType: "image/jpeg", magic: []byte{0xFF, 0xD8, 0xFF}}
+	PNG  = Format{Name: "png", Extension: ".png", ContentType: "image/png", magic: []byte("\x89PNG\r\n\x1a\n")}
+	GIF  = Format{Name: "gif", Extension: ".gif", ContentType: "image/gif", magic: []byte("GIF8")}
+)
+
+var formats = []Format{JPEG, PNG, GIF}
+
+// Detects the format of an image from its first bytes, whatever name or content type it was uploaded with
+func DetectFormat(header []byte) (Format, error) {
+	for _, f := range formats {
+		if bytes.HasPrefix(header, f.magic) {
+			return f, nil
+		}
+	}
+
+	return Format{}, ErrUnsupportedFormat
+}
+
+// Returns the format stored files with the extension ext are in
+func FormatByExtension(ext string) (Format, bool) {
+	for _, f := range formats {
+		if f.Extension == ext {
+			return f, true
+		}
+	}
+
+	return Format{}, false
+}
@@ -0,0 +1,64 @@
+package imaging
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+)
+
+var (
+	// Images are stored under the sha256 of their processed original, renditions add a suffix to it
+	contentNamePattern *regexp.Regexp
+	// Names given out at random before uploads were named after their content, they're still served
+	legacyNamePattern *regexp.Regexp
+)
+
+func init() {
+	renditions := make([]string, 0, len(Renditions))
+	for _, r := range Renditions {
+		renditions = append(renditions, r.Name)
+	}
+
+	extensions := make([]string, 0, len(formats))
+	for _, f := range formats {
+		extensions = append(extensions, regexp.QuoteMeta(f.Extension))
+	}
+
+	suffix := `(?:_(?:` + strings.Join(renditions, "|") + `))?(?:` + strings.Join(extensions, "|") + `)$`
+
+	contentNamePattern = regexp.MustCompile(`^[0-9a-f]{64}` + suffix)
+	legacyNamePattern = regexp.MustCompile(`^[A-Za-z0-9]{10}` + suffix)
+}
+
+// Returns the name a processed image is stored under, uploading the same image twice gives the same name
+func ContentName(img Image) string {
+	sum := sha256.Sum256(img.Data)
+	return hex.EncodeToString(sum[:]) + img.Format.Extension
+}
+
+// Checks name is one we store images or their renditions under and returns the format its extension stands for
+func ParseName(name string) (Format, bool) {
+	if !contentNamePattern.MatchString(name) && !legacyNamePattern.MatchString(name) {
+		return Format{}, false
+	}
+
+	return FormatByExtension(name[strings.LastIndexByte(name, '.'):])
+}
+
+// Returns the name of the original image a stored name belongs to, renditions lose their suffix
+func OriginalName(name string) (string, bool) {
+	format, ok := ParseName(name)
+
+	if !ok {
+		return "", false
+	}
+
+	for _, r := range Renditions {
+		if suffix := "_" + r.Name + format.Extension; strings.HasSuffix(name, suffix) {
+			return strings.TrimSuffix(name, suffix) + format.Extension, true
+		}
+	}
+
+	return name, true
+}
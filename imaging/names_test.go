@@ -0,0 +1,72 @@
+package imaging
+
+import (
+	"strings"
+	"testing"
+)
+
+var testHash = strings.Repeat("0123456789abcdef", 4)
+
+func TestContentName(t *testing.T) {
+	a := ContentName(Image{Data: []byte("a"), Format: PNG})
+	b := ContentName(Image{Data: []byte("b"), Format: PNG})
+
+	if a != ContentName(Image{Data: []byte("a"), Format: PNG}) {
+		t.Error("the same image got two names")
+	}
+
+	if a == b {
+		t.Error("two images got the same name")
+	}
+
+	if _, ok := ParseName(a); !ok || !strings.HasSuffix(a, ".png") {
+		t.Errorf("ContentName = %q, want a name ParseName accepts", a)
+	}
+}
+
+func TestParseName(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		ok     bool
+	}{
+		{testHash + ".jpg", JPEG, true},
+		{testHash + "_thumbnail.png", PNG, true},
+		{testHash + "_large.gif", GIF, true},
+		{"Ab3dE5gH9k.jpg", JPEG, true},
+		{"Ab3dE5gH9k_medium.jpg", JPEG, true},
+		{testHash + ".webp", Format{}, false},
+		{testHash + "_huge.jpg", Format{}, false},
+		{strings.ToUpper(testHash) + ".jpg", Format{}, false},
+		{"../" + testHash + ".jpg", Format{}, false},
+		{"short.jpg", Format{}, false},
+		{"", Format{}, false},
+	}
+
+	for _, tt := range tests {
+		format, ok := ParseName(tt.name)
+
+		if ok != tt.ok || format.Name != tt.format.Name {
+			t.Errorf("ParseName(%q) = %s, %v, want %s, %v", tt.name, format.Name, ok, tt.format.Name, tt.ok)
+		}
+	}
+}
+
+func TestOriginalName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{testHash + ".jpg", testHash + ".jpg", true},
+		{testHash + "_medium.jpg", testHash + ".jpg", true},
+		{"Ab3dE5gH9k_thumbnail.png", "Ab3dE5gH9k.png", true},
+		{"abc_medium.jpg", "", false},
+	}
+
+	for _, tt := range tests {
+		if got, ok := OriginalName(tt.name); got != tt.want || ok != tt.ok {
+			t.Errorf("OriginalName(%q) = %q, %v, want %q, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
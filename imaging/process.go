@@ -0,0 +1,154 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+)
+
+// Uploads over 40 megapixels are rejected before they're decoded, a small file can expand to gigabytes of pixels
+const MaxPixels = 40_000_000
+
+// Rendition is a scaled down copy of an uploaded image that fits in a MaxSize square
+type Rendition struct {
+	Name    string
+	MaxSize int
+}
+
+var Renditions = []Rendition{
+	{Name: "thumbnail", MaxSize: 160},
+	{Name: "medium", MaxSize: 640},
+	{Name: "large", MaxSize: 1280},
+}
+
+func RenditionByName(name string) (Rendition, bool) {
+	for _, r := range Renditions {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return Rendition{}, false
+}
+
+// Image is an upload decoded, re-encoded without its metadata and scaled into every rendition
+type Image struct {
+	Format     Format
+	Width      int
+	Height     int
+	Data       []byte
+	Renditions map[string][]byte
+}
+
+// Decodes an upload, whatever its name claims it is, applies its EXIF orientation and re-encodes it
+// along with its renditions. Fails with ErrUnsupportedFormat or ErrInvalidImage when it's not an image we take.
+func Process(r io.Reader) (Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Image{}, err
+	}
+
+	format, err := DetectFormat(data)
+	if err != nil {
+		return Image{}, err
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, fmt.Errorf("%w: %s", ErrInvalidImage, err.Error())
+	}
+
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > MaxPixels {
+		return Image{}, fmt.Errorf("%w: %dx%d pixels is over the limit", ErrInvalidImage, config.Width, config.Height)
+	}
+
+	var pixels image.Image
+	result := Image{Format: format, Renditions: make(map[string][]byte)}
+
+	switch format.Name {
+	case JPEG.Name:
+		decoded, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return Image{}, fmt.Errorf("%w: %s", ErrInvalidImage, err.Error())
+		}
+
+		pixels = applyOrientation(decoded, jpegOrientation(data))
+
+		if result.Data, err = encode(pixels, format, 90); err != nil {
+			return Image{}, err
+		}
+
+	case PNG.Name:
+		decoded, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return Image{}, fmt.Errorf("%w: %s", ErrInvalidImage, err.Error())
+		}
+
+		pixels = decoded
+
+		if result.Data, err = encode(pixels, format, 0); err != nil {
+			return Image{}, err
+		}
+
+	case GIF.Name:
+		// Animations are kept whole, renditions are made of the first frame
+		decoded, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil || len(decoded.Image) == 0 {
+			return Image{}, fmt.Errorf("%w: invalid gif", ErrInvalidImage)
+		}
+
+		var buf bytes.Buffer
+		if err := gif.EncodeAll(&buf, decoded); err != nil {
+			return Image{}, err
+		}
+		result.Data = buf.Bytes()
+
+		canvas := image.NewRGBA(image.Rect(0, 0, decoded.Config.Width, decoded.Config.Height))
+		draw.Draw(canvas, decoded.Image[0].Bounds(), decoded.Image[0], decoded.Image[0].Bounds().Min, draw.Over)
+		pixels = canvas
+	}
+
+	result.Width, result.Height = pixels.Bounds().Dx(), pixels.Bounds().Dy()
+
+	for _, rendition := range Renditions {
+		w, h := fitSize(result.Width, result.Height, rendition.MaxSize)
+
+		if result.Renditions[rendition.Name], err = encode(resize(pixels, w, h), format, 85); err != nil {
+			return Image{}, err
+		}
+	}
+
+	return result, nil
+}
+
+func encode(img image.Image, format Format, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+
+	switch format.Name {
+	case JPEG.Name:
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+	case PNG.Name:
+		err = png.Encode(&buf, img)
+	case GIF.Name:
+		err = gif.Encode(&buf, img, nil)
+	default:
+		err = ErrUnsupportedFormat
+	}
+
+	return buf.Bytes(), err
+}
+
+// Returns the name a rendition of the stored image name is kept under, abc.jpg becomes abc_thumbnail.jpg
+func RenditionName(name string, rendition string) string {
+	for i := len(name) - 1; i >= 0 && name[i] != '/'; i-- {
+		if name[i] == '.' {
+			return name[:i] + "_" + rendition + name[i:]
+		}
+	}
+	return name + "_" + rendition
+}
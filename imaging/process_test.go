@@ -0,0 +1,172 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func testImage(w int, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// Returns a JPEG of img carrying an EXIF orientation
+func encodeJPEGWithOrientation(t *testing.T, img image.Image, orientation uint16) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Big endian TIFF header, one IFD entry: the orientation as an inline SHORT
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01\x01\x12\x00\x03\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00\x00\x00")
+	binary.BigEndian.PutUint16(tiff[18:], orientation)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(segment)+2))
+	app1 = append(app1, segment...)
+
+	data := buf.Bytes()
+	return append(append(append([]byte(nil), data[:2]...), app1...), data[2:]...)
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		header []byte
+		want   Format
+		err    error
+	}{
+		{[]byte{0xFF, 0xD8, 0xFF, 0xE0}, JPEG, nil},
+		{[]byte("\x89PNG\r\n\x1a\n...."), PNG, nil},
+		{[]byte("GIF89a"), GIF, nil},
+		{[]byte("<svg xmlns="), Format{}, ErrUnsupportedFormat},
+		{nil, Format{}, ErrUnsupportedFormat},
+	}
+
+	for _, tt := range tests {
+		got, err := DetectFormat(tt.header)
+
+		if !errors.Is(err, tt.err) || got.Name != tt.want.Name {
+			t.Errorf("DetectFormat(%q) = %s, %v, want %s, %v", tt.header, got.Name, err, tt.want.Name, tt.err)
+		}
+	}
+}
+
+func TestFitSize(t *testing.T) {
+	tests := []struct {
+		w, h, max    int
+		wantW, wantH int
+	}{
+		{100, 50, 160, 100, 50},
+		{2000, 1000, 160, 160, 80},
+		{1000, 2000, 640, 320, 640},
+		{5000, 1, 160, 160, 1},
+		{640, 640, 640, 640, 640},
+	}
+
+	for _, tt := range tests {
+		if w, h := fitSize(tt.w, tt.h, tt.max); w != tt.wantW || h != tt.wantH {
+			t.Errorf("fitSize(%d, %d, %d) = %dx%d, want %dx%d", tt.w, tt.h, tt.max, w, h, tt.wantW, tt.wantH)
+		}
+	}
+}
+
+func TestProcessRenditions(t *testing.T) {
+	img, err := Process(bytes.NewReader(encodePNG(t, testImage(2000, 1000))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if img.Format.Name != PNG.Name || img.Width != 2000 || img.Height != 1000 {
+		t.Fatalf("got a %dx%d %s", img.Width, img.Height, img.Format.Name)
+	}
+
+	want := map[string]image.Point{"thumbnail": {160, 80}, "medium": {640, 320}, "large": {1280, 640}}
+
+	for name, size := range want {
+		config, err := png.DecodeConfig(bytes.NewReader(img.Renditions[name]))
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		if config.Width != size.X || config.Height != size.Y {
+			t.Errorf("%s is %dx%d, want %dx%d", name, config.Width, config.Height, size.X, size.Y)
+		}
+	}
+}
+
+// Orientation 6 is a photo taken with the camera turned, it's displayed rotated a quarter turn
+func TestProcessAppliesOrientation(t *testing.T) {
+	img, err := Process(bytes.NewReader(encodeJPEGWithOrientation(t, testImage(40, 20), 6)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if img.Width != 20 || img.Height != 40 {
+		t.Errorf("got %dx%d, want 20x40", img.Width, img.Height)
+	}
+
+	// The EXIF segment isn't carried over
+	if jpegOrientation(img.Data) != 1 {
+		t.Error("processed image kept its orientation")
+	}
+}
+
+func TestProcessRejects(t *testing.T) {
+	valid := encodePNG(t, testImage(10, 10))
+
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"text", []byte("just some text"), ErrUnsupportedFormat},
+		{"truncated", valid[:20], ErrInvalidImage},
+	}
+
+	for _, tt := range tests {
+		if _, err := Process(bytes.NewReader(tt.data)); !errors.Is(err, tt.err) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestRenditionName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"abc.jpg", "abc_thumbnail.jpg"},
+		{"dir.d/abc", "dir.d/abc_thumbnail"},
+		{"abc", "abc_thumbnail"},
+	}
+
+	for _, tt := range tests {
+		if got := RenditionName(tt.name, "thumbnail"); got != tt.want {
+			t.Errorf("RenditionName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package imaging
+
+import (
+	"image"
+	"image/draw"
+)
+
+// Returns the size of a w x h image scaled down to fit in a max x max box, images that already fit keep their size
+func fitSize(w int, h int, max int) (int, int) {
+	if w <= max && h <= max {
+		return w, h
+	}
+
+	if w >= h {
+		scaled := h * max / w
+		if scaled < 1 {
+			scaled = 1
+		}
+		return max, scaled
+	}
+
+	scaled := w * max / h
+	if scaled < 1 {
+		scaled = 1
+	}
+	return scaled, max
+}
+
+type contribution struct {
+	index  int
+	weight float64
+}
+
+// Computes for every destination pixel the source pixels it covers and by how much, an area average
+// that's exact for downscaling and needs no external package
+func contributions(src int, dst int) [][]contribution {
+	scale := float64(src) / float64(dst)
+	result := make([][]contribution, dst)
+
+	for d := 0; d < dst; d++ {
+		start, end := float64(d)*scale, float64(d+1)*scale
+
+		for s := int(start); s < src && float64(s) < end; s++ {
+			left, right := float64(s), float64(s+1)
+			if left < start {
+				left = start
+			}
+			if right > end {
+				right = end
+			}
+			if right > left {
+				result[d] = append(result[d], contribution{s, (right - left) / scale})
+			}
+		}
+	}
+
+	return result
+}
+
+// Scales src to w x h, averaging premultiplied colors so transparent edges don't darken
+func resize(src image.Image, w int, h int) *image.RGBA {
+	b := src.Bounds()
+
+	rgba, ok := src.(*image.RGBA)
+	if !ok || b.Min != (image.Point{}) {
+		rgba = image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+	}
+
+	sw, sh := b.Dx(), b.Dy()
+	if sw == w && sh == h {
+		return rgba
+	}
+
+	// Horizontal pass into a float buffer, then vertical pass into the result
+	columns := contributions(sw, w)
+	rows := contributions(sh, h)
+
+	tmp := make([]float64, w*sh*4)
+	for y := 0; y < sh; y++ {
+		line := rgba.Pix[y*rgba.Stride:]
+		for x, cs := range columns {
+			var r, g, bl, a float64
+			for _, c := range cs {
+				p := line[c.index*4:]
+				r += float64(p[0]) * c.weight
+				g += float64(p[1]) * c.weight
+				bl += float64(p[2]) * c.weight
+				a += float64(p[3]) * c.weight
+			}
+			t := tmp[(y*w+x)*4:]
+			t[0], t[1], t[2], t[3] = r, g, bl, a
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y, cs := range rows {
+		for x := 0; x < w; x++ {
+			var r, g, bl, a float64
+			for _, c := range cs {
+				t := tmp[(c.index*w+x)*4:]
+				r += t[0] * c.weight
+				g += t[1] * c.weight
+				bl += t[2] * c.weight
+				a += t[3] * c.weight
+			}
+			p := dst.Pix[y*dst.Stride+x*4:]
+			p[0], p[1], p[2], p[3] = clamp(r), clamp(g), clamp(bl), clamp(a)
+
+			// Rounding can leave a premultiplied channel above alpha
+			for i := 0; i < 3; i++ {
+				if p[i] > p[3] {
+					p[i] = p[3]
+				}
+			}
+		}
+	}
+
+	return dst
+}
+
+func clamp(v float64) uint8 {
+	v += 0.5
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v)
+}
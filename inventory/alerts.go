@@ -0,0 +1,84 @@
+package inventory
+
+import (
+	"context"
+	"time"
+
+	"vayer-electric-backend/db"
+	"vayer-electric-backend/notify"
+
+	"go.uber.org/zap"
+)
+
+// Alerts delivered per round, the rest wait for the next tick
+const alertBatchSize = 50
+
+// Time a notifier gets to deliver one alert
+const notifyTimeout = 30 * time.Second
+
+// AlertDispatcher delivers the stock alerts the store records. Alerts are stored with the stock change
+// that raised them so none is lost when a notifier is down, failed ones are retried up to maxAttempts times.
+type AlertDispatcher struct {
+	store       db.Store
+	notifier    notify.Notifier
+	maxAttempts int
+}
+
+func NewAlertDispatcher(store db.Store, notifier notify.Notifier, maxAttempts int) *AlertDispatcher {
+	return &AlertDispatcher{
+		store:       store,
+		notifier:    notifier,
+		maxAttempts: maxAttempts,
+	}
+}
+
+// Delivers pending alerts and returns how many went through
+func (d *AlertDispatcher) Dispatch(ctx context.Context) (int, error) {
+	alerts, err := d.store.GetPendingStockAlerts(ctx, alertBatchSize, d.maxAttempts)
+
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+
+	for _, alert := range alerts {
+		notifyCtx, cancel := context.WithTimeout(ctx, notifyTimeout)
+		err := d.notifier.Notify(notifyCtx, alert)
+		cancel()
+
+		if err != nil {
+			log.Error("stock alert delivery failed", zap.Int64("alert_id", alert.Id), zap.Int("attempt", alert.Attempts+1), zap.Error(err))
+
+			if err := d.store.MarkStockAlertFailed(ctx, alert.Id, err.Error()); err != nil {
+				return delivered, err
+			}
+
+			continue
+		}
+
+		if err := d.store.MarkStockAlertNotified(ctx, alert.Id); err != nil {
+			return delivered, err
+		}
+
+		delivered++
+	}
+
+	return delivered, nil
+}
+
+func (d *AlertDispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := d.Dispatch(ctx); err != nil {
+				log.Error("stock alert dispatch failed", zap.Error(err))
+			}
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package inventory
+
+import (
+	"context"
+	"time"
+
+	"vayer-electric-backend/db"
+	"vayer-electric-backend/logging"
+
+	"go.uber.org/zap"
+)
+
+var log = logging.GetLogger()
+
+// Reaper releases reservations once they expire. Expired reservations already stop counting
+// against available stock, reaping only keeps the table small.
+type Reaper struct {
+	store db.Store
+}
+
+func NewReaper(store db.Store) *Reaper {
+	return &Reaper{store: store}
+}
+
+// Releases every reservation expired by now and returns how many there were
+func (r *Reaper) Reap(ctx context.Context) (int64, error) {
+	return r.store.DeleteExpiredReservations(ctx, time.Now())
+}
+
+func (r *Reaper) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			released, err := r.Reap(ctx)
+
+			if err != nil {
+				log.Error("reservation reap failed", zap.Error(err))
+				continue
+			}
+
+			if released > 0 {
+				log.Info("expired reservations released", zap.Int64("released", released))
+			}
+		}
+	}
+}
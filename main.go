@@ -11,7 +11,12 @@ import (
 	"vayer-electric-backend/env"
 	"vayer-electric-backend/gracefulserver"
 	"vayer-electric-backend/handler"
+	"vayer-electric-backend/inventory"
 	"vayer-electric-backend/logging"
+	"vayer-electric-backend/media"
+	"vayer-electric-backend/notify"
+	"vayer-electric-backend/payment"
+	"vayer-electric-backend/storage"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/cors"
@@ -49,19 +54,49 @@ func main() {
 
 	mainCtx := getMainContext()
 
-	src := db.GetDbSource()
-	err := src.Migrate("./migrations")
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(mainCtx, os.Args[1:]))
+	}
+
+	// One pool for the whole process, closed by the graceful server once requests are drained
+	store := db.GetStore()
+	err := store.Migrate("./migrations")
 
 	if err != nil {
 		panic(err)
 	}
 
+	// Uploaded images, on local disk or in an S3 bucket depending on env.BLOB_DRIVER
+	blobs := storage.GetBlobStore()
+
+	// Removes images left behind by failed inserts, deleted rows and replaced images
+	if env.MEDIA_GC_INTERVAL > 0 {
+		go media.NewSweeper(store, blobs, media.SweepConfigFromEnv()).Run(mainCtx, env.MEDIA_GC_INTERVAL)
+	}
+
+	// Releases expired stock reservations
+	if env.RESERVATION_REAP_INTERVAL > 0 {
+		go inventory.NewReaper(store).Run(mainCtx, env.RESERVATION_REAP_INTERVAL)
+	}
+
+	// Delivers the alerts raised when products drop below their reorder point
+	if env.STOCK_ALERT_INTERVAL > 0 {
+		go inventory.NewAlertDispatcher(store, notify.GetNotifier(), env.STOCK_ALERT_MAX_ATTEMPTS).Run(mainCtx, env.STOCK_ALERT_INTERVAL)
+	}
+
+	// Nil when env.PAYMENT_PROVIDER is empty, the payment routes are left out then
+	var payments *payment.Processor
+	if provider := payment.GetPaymentProvider(); provider != nil {
+		payments = payment.NewProcessor(store, provider)
+	}
+
 	r := chi.NewRouter()
 
 	server := gracefulserver.New(&http.Server{
 		Addr:    fmt.Sprintf(":%d", httpPort),
 		Handler: r,
 	})
+	server.RegisterOnShutdown(store.Close)
 
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins: []string{"https://*", "http://*"},
@@ -70,31 +105,114 @@ func main() {
 
 	r.Route("/api", func(r chi.Router) {
 		r.Route("/products", func(r chi.Router) {
-			r.Get("/", handler.GetProducts())
-			r.Get("/{id}", handler.GetProductById())
-			r.Post("/", handler.CreateProduct())
-			r.Put("/{id}", handler.UpdateProduct())
-			r.Delete("/{id}", handler.DeleteProduct())
-			r.Get("/category/{id}", handler.GetProductsByCategoryId())
-			r.Get("/category/{name}", handler.GetProductsByCategoryName())
-			r.Get("/{name}", handler.GetProductByName())
+			r.Get("/", handler.GetProducts(store))
+			r.Get("/search", handler.SearchProducts(store))
+			r.Get("/{id:[0-9]+}", handler.GetProductById(store))
+			r.Post("/", handler.CreateProduct(store, blobs))
+			r.Put("/{id}", handler.UpdateProduct(store))
+			r.Delete("/{id}", handler.DeleteProduct(store))
+			r.Put("/{id}/attributes", handler.SetProductAttributes(store))
+			r.Get("/{id}/variants", handler.GetProductVariants(store))
+			r.Post("/{id}/variants", handler.CreateProductVariant(store, blobs))
+			r.Put("/{id}/variant-axes", handler.SetVariantAxes(store))
+			r.Put("/{id}/variants/{variantId}", handler.UpdateProductVariant(store))
+			r.Put("/{id}/variants/{variantId}/image", handler.SetProductVariantImage(store, blobs))
+			r.Delete("/{id}/variants/{variantId}", handler.DeleteProductVariant(store))
+			r.Get("/{id}/images", handler.GetProductImages(store))
+			r.Post("/{id}/images", handler.CreateProductImage(store, blobs))
+			r.Put("/{id}/images/order", handler.ReorderProductImages(store))
+			r.Put("/{id}/images/{imageId}", handler.UpdateProductImage(store))
+			r.Delete("/{id}/images/{imageId}", handler.DeleteProductImage(store))
+			r.Get("/{id}/stock-movements", handler.GetStockMovements(store))
+			r.Post("/{id}/stock-movements", handler.CreateStockMovement(store))
+			r.Get("/{id}/stock", handler.GetProductStock(store))
+			r.Post("/{id}/stock-transfers", handler.TransferStock(store))
+			r.Get("/{id}/reservations", handler.GetReservations(store))
+			r.Post("/{id}/reservations", handler.CreateReservation(store))
+			r.Put("/{id}/reorder-point", handler.SetReorderPoint(store))
+			r.Put("/{id}/tax-class", handler.SetProductTaxClass(store))
+			r.Get("/category/{id:[0-9]+}", handler.GetProductsByCategoryId(store))
+			r.Get("/category/{name}", handler.GetProductsByCategoryName(store))
+			r.Get("/{name}", handler.GetProductByName(store))
 		})
 		r.Route("/categories", func(r chi.Router) {
-			r.Get("/", handler.GetCategories())
-			r.Get("/{id}", handler.GetCategoryById())
-			r.Post("/", handler.CreateCategory())
-			r.Put("/{id}", handler.UpdateCategory())
-			r.Delete("/{id}", handler.DeleteCategory())
+			r.Get("/", handler.GetCategories(store))
+			r.Get("/{id}", handler.GetCategoryById(store))
+			r.Post("/", handler.CreateCategory(store))
+			r.Put("/{id}", handler.UpdateCategory(store))
+			r.Put("/{id}/image", handler.SetCategoryImage(store, blobs))
+			r.Put("/{id}/tax-class", handler.SetCategoryTaxClass(store))
+			r.Delete("/{id}", handler.DeleteCategory(store))
 		})
 		r.Route("/subcategories", func(r chi.Router) {
-			r.Get("/", handler.GetSubcategories())
-			r.Get("/{id}", handler.GetSubcategoryById())
-			r.Post("/", handler.CreateSubcategory())
-			r.Put("/{id}", handler.UpdateSubcategory())
-			r.Delete("/{id}", handler.DeleteSubcategory())
+			r.Get("/", handler.GetSubcategories(store))
+			r.Get("/{id}", handler.GetSubcategoryById(store))
+			r.Post("/", handler.CreateSubcategory(store))
+			r.Put("/{id}", handler.UpdateSubcategory(store))
+			r.Put("/{id}/image", handler.SetSubcategoryImage(store, blobs))
+			r.Put("/{id}/tax-class", handler.SetSubcategoryTaxClass(store))
+			r.Delete("/{id}", handler.DeleteSubcategory(store))
+			r.Get("/{id}/attributes", handler.GetAttributeDefinitions(store))
+			r.Post("/{id}/attributes", handler.CreateAttributeDefinition(store))
+			r.Delete("/{id}/attributes/{attributeId}", handler.DeleteAttributeDefinition(store))
+		})
+		r.Route("/carts", func(r chi.Router) {
+			r.Post("/", handler.CreateCart(store))
+			r.Get("/{token}", handler.GetCart(store))
+			r.Put("/{token}/region", handler.SetCartRegion(store))
+			r.Post("/{token}/lines", handler.AddCartLine(store))
+			r.Put("/{token}/lines/{lineId}", handler.UpdateCartLine(store))
+			r.Delete("/{token}/lines/{lineId}", handler.DeleteCartLine(store))
+			r.Post("/{token}/merge", handler.MergeCart(store))
+			r.Post("/{token}/checkout", handler.Checkout(store))
+		})
+		r.Route("/orders", func(r chi.Router) {
+			r.Get("/", handler.GetCustomerOrders(store))
+			r.Get("/{token}", handler.GetCustomerOrder(store))
+			r.Post("/{token}/cancel", handler.CancelCustomerOrder(store))
+			r.Get("/{token}/payments", handler.GetCustomerOrderPayments(store))
+			if payments != nil {
+				r.Post("/{token}/payments", handler.CreatePayment(store, payments))
+			}
+		})
+		r.Route("/tax-classes", func(r chi.Router) {
+			r.Get("/", handler.GetTaxClasses(store))
+			r.Post("/", handler.CreateTaxClass(store))
+			r.Delete("/{id}", handler.DeleteTaxClass(store))
+			r.Put("/{id}/rates/{region}", handler.SetTaxRate(store))
+			r.Delete("/{id}/rates/{region}", handler.DeleteTaxRate(store))
 		})
+		r.Route("/reservations", func(r chi.Router) {
+			r.Delete("/{id}", handler.DeleteReservation(store))
+		})
+		r.Route("/warehouses", func(r chi.Router) {
+			r.Get("/", handler.GetWarehouses(store))
+			r.Post("/", handler.CreateWarehouse(store))
+			r.Delete("/{id}", handler.DeleteWarehouse(store))
+			r.Post("/{id}/locations", handler.CreateLocation(store))
+			r.Delete("/{id}/locations/{locationId}", handler.DeleteLocation(store))
+		})
+		if payments != nil {
+			r.Route("/payments", func(r chi.Router) {
+				r.Post("/webhook", handler.PaymentWebhook(payments))
+			})
+		}
 		r.Route("/images", func(r chi.Router) {
-			r.Get("/{name}", handler.ServeProductImage())
+			r.Get("/{name}", handler.ServeProductImage(blobs, env.IMAGE_PLACEHOLDER))
+		})
+		r.Route("/admin", func(r chi.Router) {
+			r.Get("/low-stock", handler.GetLowStockProducts(store))
+			r.Get("/orders", handler.GetOrders(store))
+			r.Get("/orders/{id}", handler.GetOrderById(store))
+			r.Post("/orders/{id}/transitions", handler.TransitionOrder(store))
+			r.Get("/orders/{id}/payments", handler.GetOrderPayments(store))
+			if payments != nil {
+				r.Post("/payments/{id}/capture", handler.CapturePayment(payments))
+				r.Post("/payments/{id}/refund", handler.RefundPayment(payments))
+			}
+		})
+		r.Route("/diagnostics", func(r chi.Router) {
+			r.Get("/db", handler.GetDbStats(store))
 		})
 
 	})
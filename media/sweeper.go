@@ -0,0 +1,146 @@
+package media
+
+import (
+	"context"
+	"time"
+
+	"vayer-electric-backend/db"
+	"vayer-electric-backend/env"
+	"vayer-electric-backend/imaging"
+	"vayer-electric-backend/logging"
+	"vayer-electric-backend/storage"
+
+	"go.uber.org/zap"
+)
+
+var log = logging.GetLogger()
+
+// Sweeper removes stored images no product, product image, variant, category or subcategory points at.
+// Images younger than the grace period are left alone, their row may not be committed yet.
+type Sweeper struct {
+	store  db.Store
+	blobs  storage.BlobStore
+	grace  time.Duration
+	dryRun bool
+}
+
+type SweepConfig struct {
+	Grace time.Duration
+	// Reports what would be removed without removing anything
+	DryRun bool
+}
+
+func SweepConfigFromEnv() SweepConfig {
+	return SweepConfig{
+		Grace:  env.MEDIA_GC_GRACE,
+		DryRun: env.MEDIA_GC_DRY_RUN,
+	}
+}
+
+// Outcome of a sweep, orphaned lists the keys that were removed or would be on a dry run
+type SweepReport struct {
+	DryRun     bool      `json:"dry_run"`
+	StartedAt  time.Time `json:"started_at"`
+	Scanned    int       `json:"scanned"`
+	Referenced int       `json:"referenced"`
+	InGrace    int       `json:"in_grace"`
+	// Blobs not named like our images, never removed
+	Unknown    int      `json:"unknown"`
+	Orphaned   []string `json:"orphaned"`
+	FreedBytes int64    `json:"freed_bytes"`
+	Failed     []string `json:"failed"`
+}
+
+func NewSweeper(store db.Store, blobs storage.BlobStore, config SweepConfig) *Sweeper {
+	return &Sweeper{
+		store:  store,
+		blobs:  blobs,
+		grace:  config.Grace,
+		dryRun: config.DryRun,
+	}
+}
+
+// Runs a single sweep. Blobs are listed before references are loaded so an image
+// referenced while the sweep runs is either seen as referenced or still in its grace period.
+func (s *Sweeper) Sweep(ctx context.Context) (SweepReport, error) {
+	report := SweepReport{
+		DryRun:    s.dryRun,
+		StartedAt: time.Now(),
+		Orphaned:  make([]string, 0),
+		Failed:    make([]string, 0),
+	}
+
+	blobs, err := s.blobs.List(ctx)
+
+	if err != nil {
+		return report, err
+	}
+
+	references, err := s.store.ImageReferences(ctx)
+
+	if err != nil {
+		return report, err
+	}
+
+	cutoff := report.StartedAt.Add(-s.grace)
+
+	for _, blob := range blobs {
+		report.Scanned++
+
+		original, ok := imaging.OriginalName(blob.Key)
+
+		switch {
+		case !ok:
+			report.Unknown++
+			continue
+		case references[original]:
+			report.Referenced++
+			continue
+		case blob.ModTime.After(cutoff):
+			report.InGrace++
+			continue
+		}
+
+		if !s.dryRun {
+			if err := s.blobs.Delete(ctx, blob.Key); err != nil {
+				log.Error("orphaned image could not be removed", zap.String("key", blob.Key), zap.Error(err))
+				report.Failed = append(report.Failed, blob.Key)
+				continue
+			}
+		}
+
+		report.Orphaned = append(report.Orphaned, blob.Key)
+		report.FreedBytes += blob.Size
+	}
+
+	return report, nil
+}
+
+// Sweeps every interval until ctx is canceled
+func (s *Sweeper) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := s.Sweep(ctx)
+
+			if err != nil {
+				log.Error("media sweep failed", zap.Error(err))
+				continue
+			}
+
+			log.Info("media sweep done",
+				zap.Bool("dry_run", report.DryRun),
+				zap.Int("scanned", report.Scanned),
+				zap.Int("orphaned", len(report.Orphaned)),
+				zap.Int("failed", len(report.Failed)),
+				zap.Int64("freed_bytes", report.FreedBytes),
+				zap.Strings("keys", report.Orphaned),
+			)
+		}
+	}
+}
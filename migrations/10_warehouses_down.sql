@@ -0,0 +1,8 @@
+DELETE FROM stock_movement WHERE reason = 'transfer';
+ALTER TABLE stock_movement DROP CONSTRAINT stock_movement_reason_check;
+ALTER TABLE stock_movement ADD CONSTRAINT stock_movement_reason_check CHECK (reason IN ('receipt', 'sale', 'adjustment', 'return', 'damage'));
+ALTER TABLE stock_movement DROP CONSTRAINT IF EXISTS stock_movement_location_check;
+ALTER TABLE stock_movement DROP COLUMN IF EXISTS location_id;
+DROP TABLE IF EXISTS product_stock;
+DROP TABLE IF EXISTS location;
+DROP TABLE IF EXISTS warehouse;
@@ -0,0 +1,41 @@
+CREATE TABLE warehouse (
+  id SERIAL PRIMARY KEY,
+  name varchar(255) NOT NULL UNIQUE,
+  created_at timestamp NOT NULL
+);
+
+-- A place stock sits in, like an aisle of the main warehouse or a shop counter.
+-- Stock changes that don't name a location, like current_inventory edits, go to the default one.
+CREATE TABLE location (
+  id SERIAL PRIMARY KEY,
+  warehouse_id int NOT NULL REFERENCES warehouse(id),
+  name varchar(255) NOT NULL,
+  is_default boolean NOT NULL DEFAULT false,
+  created_at timestamp NOT NULL,
+  UNIQUE (warehouse_id, name)
+);
+
+CREATE UNIQUE INDEX location_default_idx ON location (is_default) WHERE is_default;
+
+-- Stock of a product per location, product.current_inventory is kept equal to their sum
+CREATE TABLE product_stock (
+  product_id int NOT NULL REFERENCES product(id) ON DELETE CASCADE,
+  location_id int NOT NULL REFERENCES location(id),
+  quantity int NOT NULL CHECK (quantity >= 0),
+  PRIMARY KEY (product_id, location_id)
+);
+
+INSERT INTO warehouse (name, created_at) VALUES ('Main warehouse', now());
+INSERT INTO location (warehouse_id, name, is_default, created_at) SELECT id, 'Main', true, now() FROM warehouse;
+
+INSERT INTO product_stock (product_id, location_id, quantity)
+SELECT p.id, l.id, p.current_inventory FROM product p, location l WHERE p.current_inventory > 0;
+
+-- Product movements now happen at a location and balance is the stock left there, transfers move stock between locations.
+-- A variant keeps a single pool of stock in product_variant.current_inventory, its movements have no location.
+ALTER TABLE stock_movement ADD COLUMN location_id int REFERENCES location(id);
+UPDATE stock_movement SET location_id = (SELECT id FROM location WHERE is_default) WHERE variant_id IS NULL;
+ALTER TABLE stock_movement ADD CONSTRAINT stock_movement_location_check CHECK ((variant_id IS NULL) = (location_id IS NOT NULL));
+
+ALTER TABLE stock_movement DROP CONSTRAINT stock_movement_reason_check;
+ALTER TABLE stock_movement ADD CONSTRAINT stock_movement_reason_check CHECK (reason IN ('receipt', 'sale', 'adjustment', 'return', 'damage', 'transfer'));
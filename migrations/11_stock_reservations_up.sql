@@ -0,0 +1,16 @@
+-- Stock held for a checkout or an open quote. A reservation counts against available stock until it
+-- expires or is released, current_inventory only changes when the stock actually leaves.
+-- Reservations of a variant name it, variant stock is reserved apart from product stock.
+CREATE TABLE stock_reservation (
+  id SERIAL PRIMARY KEY,
+  product_id int NOT NULL REFERENCES product(id) ON DELETE CASCADE,
+  variant_id int REFERENCES product_variant(id) ON DELETE CASCADE,
+  quantity int NOT NULL CHECK (quantity > 0),
+  reference varchar(255) NOT NULL DEFAULT '',
+  expires_at timestamp NOT NULL,
+  created_at timestamp NOT NULL
+);
+
+CREATE INDEX stock_reservation_product_idx ON stock_reservation (product_id, expires_at);
+CREATE INDEX stock_reservation_variant_idx ON stock_reservation (variant_id, expires_at);
+CREATE INDEX stock_reservation_expires_idx ON stock_reservation (expires_at);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS stock_alert;
+ALTER TABLE product DROP COLUMN IF EXISTS reorder_quantity;
+ALTER TABLE product DROP COLUMN IF EXISTS reorder_point;
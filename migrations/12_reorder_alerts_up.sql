@@ -0,0 +1,20 @@
+-- A product is low on stock once its available stock, on hand minus active reservations, drops below its
+-- reorder point. 0 never triggers. reorder_quantity is how much to order when it does.
+ALTER TABLE product ADD COLUMN reorder_point int NOT NULL DEFAULT 0 CHECK (reorder_point >= 0);
+ALTER TABLE product ADD COLUMN reorder_quantity int NOT NULL DEFAULT 0 CHECK (reorder_quantity >= 0);
+
+-- Raised when a stock change takes a product below its reorder point, delivered to the notifiers
+-- in the background. notified_at stays null until a delivery succeeds.
+CREATE TABLE stock_alert (
+  id SERIAL PRIMARY KEY,
+  product_id int NOT NULL REFERENCES product(id) ON DELETE CASCADE,
+  available int NOT NULL,
+  reorder_point int NOT NULL,
+  reorder_quantity int NOT NULL,
+  attempts int NOT NULL DEFAULT 0,
+  last_error text NOT NULL DEFAULT '',
+  notified_at timestamp,
+  created_at timestamp NOT NULL
+);
+
+CREATE INDEX stock_alert_pending_idx ON stock_alert (id) WHERE notified_at IS NULL;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS stock_reservation_cart_idx;
+ALTER TABLE stock_reservation DROP COLUMN IF EXISTS cart_id;
+DROP TABLE IF EXISTS cart_line;
+DROP TABLE IF EXISTS cart;
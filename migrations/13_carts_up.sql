@@ -0,0 +1,28 @@
+-- Carts are addressed by an unguessable token. Anonymous carts have no customer_id, a customer has at most one cart.
+CREATE TABLE cart (
+  id SERIAL PRIMARY KEY,
+  token varchar(64) NOT NULL UNIQUE,
+  customer_id varchar(255) UNIQUE,
+  created_at timestamp NOT NULL,
+  updated_at timestamp NOT NULL
+);
+
+-- Lines don't keep a price, carts are always priced from product.price.
+-- Lines of a variant name it, the variant's sku, price and stock stand in for the product's.
+CREATE TABLE cart_line (
+  id SERIAL PRIMARY KEY,
+  cart_id int NOT NULL REFERENCES cart(id) ON DELETE CASCADE,
+  product_id int NOT NULL REFERENCES product(id) ON DELETE CASCADE,
+  variant_id int REFERENCES product_variant(id) ON DELETE CASCADE,
+  quantity int NOT NULL CHECK (quantity > 0),
+  created_at timestamp NOT NULL
+);
+
+CREATE UNIQUE INDEX cart_line_item_idx ON cart_line (cart_id, product_id, coalesce(variant_id, 0));
+CREATE INDEX cart_line_product_idx ON cart_line (product_id);
+
+-- The cart a checkout reservation was taken for, only that cart's checkout can release it into an order.
+-- Reservations for open quotes have no cart.
+ALTER TABLE stock_reservation ADD COLUMN cart_id int REFERENCES cart(id) ON DELETE CASCADE;
+
+CREATE INDEX stock_reservation_cart_idx ON stock_reservation (cart_id);
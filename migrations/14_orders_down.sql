@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS order_transition;
+DROP TABLE IF EXISTS order_line;
+DROP TABLE IF EXISTS customer_order;
@@ -0,0 +1,41 @@
+-- "order" is a reserved word
+CREATE TABLE customer_order (
+  id SERIAL PRIMARY KEY,
+  token varchar(64) NOT NULL UNIQUE,
+  customer_id varchar(255),
+  status varchar(16) NOT NULL CHECK (status IN ('pending', 'paid', 'picking', 'shipped', 'delivered', 'cancelled', 'refunded')),
+  subtotal numeric(10,2) NOT NULL,
+  created_at timestamp NOT NULL,
+  updated_at timestamp NOT NULL
+);
+
+CREATE INDEX customer_order_customer_idx ON customer_order (customer_id, id);
+CREATE INDEX customer_order_status_idx ON customer_order (status, id);
+
+-- Name, sku and price are copied from the product at checkout, the product can change or go away afterwards
+CREATE TABLE order_line (
+  id SERIAL PRIMARY KEY,
+  order_id int NOT NULL REFERENCES customer_order(id) ON DELETE CASCADE,
+  product_id int REFERENCES product(id) ON DELETE SET NULL,
+  variant_id int REFERENCES product_variant(id) ON DELETE SET NULL,
+  name varchar(255) NOT NULL,
+  sku varchar(255) NOT NULL,
+  unit_price numeric(10,2) NOT NULL,
+  quantity int NOT NULL CHECK (quantity > 0),
+  line_total numeric(10,2) NOT NULL
+);
+
+CREATE INDEX order_line_order_idx ON order_line (order_id, id);
+
+-- Every status an order went through, from_status is null on the transition that created it
+CREATE TABLE order_transition (
+  id SERIAL PRIMARY KEY,
+  order_id int NOT NULL REFERENCES customer_order(id) ON DELETE CASCADE,
+  from_status varchar(16),
+  to_status varchar(16) NOT NULL,
+  created_by varchar(255) NOT NULL DEFAULT '',
+  note varchar(255) NOT NULL DEFAULT '',
+  created_at timestamp NOT NULL
+);
+
+CREATE INDEX order_transition_order_idx ON order_transition (order_id, id);
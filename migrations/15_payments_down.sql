@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS payment_event;
+DROP TABLE IF EXISTS payment;
@@ -0,0 +1,33 @@
+-- reference is the id the provider gave the payment, unknown until it answers the authorization
+CREATE TABLE payment (
+  id SERIAL PRIMARY KEY,
+  order_id int NOT NULL REFERENCES customer_order(id) ON DELETE CASCADE,
+  provider varchar(32) NOT NULL,
+  reference varchar(255),
+  status varchar(16) NOT NULL CHECK (status IN ('pending', 'authorized', 'declined', 'failed', 'captured', 'refunded')),
+  amount numeric(10,2) NOT NULL CHECK (amount > 0),
+  captured_amount numeric(10,2) NOT NULL DEFAULT 0,
+  refunded_amount numeric(10,2) NOT NULL DEFAULT 0,
+  message varchar(255) NOT NULL DEFAULT '',
+  created_at timestamp NOT NULL,
+  updated_at timestamp NOT NULL,
+  UNIQUE (provider, reference)
+);
+
+CREATE INDEX payment_order_idx ON payment (order_id, id);
+
+-- Every outcome a provider reported, by its id for the operation. Providers retry webhooks and report
+-- outcomes both in their response and in a webhook, an event already here is not applied again.
+CREATE TABLE payment_event (
+  id SERIAL PRIMARY KEY,
+  payment_id int NOT NULL REFERENCES payment(id) ON DELETE CASCADE,
+  provider varchar(32) NOT NULL,
+  event_id varchar(255) NOT NULL,
+  status varchar(16) NOT NULL,
+  amount numeric(10,2) NOT NULL,
+  message varchar(255) NOT NULL DEFAULT '',
+  created_at timestamp NOT NULL,
+  UNIQUE (provider, event_id)
+);
+
+CREATE INDEX payment_event_payment_idx ON payment_event (payment_id, id);
@@ -0,0 +1,12 @@
+ALTER TABLE order_line DROP COLUMN IF EXISTS tax_amount;
+ALTER TABLE order_line DROP COLUMN IF EXISTS tax_rate;
+ALTER TABLE order_line DROP COLUMN IF EXISTS tax_class;
+ALTER TABLE customer_order DROP COLUMN IF EXISTS total;
+ALTER TABLE customer_order DROP COLUMN IF EXISTS tax_total;
+ALTER TABLE customer_order DROP COLUMN IF EXISTS region;
+ALTER TABLE cart DROP COLUMN IF EXISTS region;
+ALTER TABLE product DROP COLUMN IF EXISTS tax_class_id;
+ALTER TABLE subcategory DROP COLUMN IF EXISTS tax_class_id;
+ALTER TABLE category DROP COLUMN IF EXISTS tax_class_id;
+DROP TABLE IF EXISTS tax_rate;
+DROP TABLE IF EXISTS tax_class;
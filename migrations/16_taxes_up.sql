@@ -0,0 +1,39 @@
+-- Goods taxed alike, products without a class take the class of their subcategory, then of their category,
+-- then the default class
+CREATE TABLE tax_class (
+  id SERIAL PRIMARY KEY,
+  name varchar(255) NOT NULL UNIQUE,
+  is_default boolean NOT NULL DEFAULT false,
+  created_at timestamp NOT NULL
+);
+
+CREATE UNIQUE INDEX tax_class_default_idx ON tax_class (is_default) WHERE is_default;
+
+-- Rate of a class in a region, in percent. The '*' region covers regions a class has no rate for.
+CREATE TABLE tax_rate (
+  id SERIAL PRIMARY KEY,
+  tax_class_id int NOT NULL REFERENCES tax_class(id) ON DELETE CASCADE,
+  region varchar(16) NOT NULL,
+  rate numeric(5,2) NOT NULL CHECK (rate >= 0 AND rate <= 100),
+  created_at timestamp NOT NULL,
+  UNIQUE (tax_class_id, region)
+);
+
+INSERT INTO tax_class (name, is_default, created_at) VALUES ('Standard', true, now());
+
+ALTER TABLE category ADD COLUMN tax_class_id int REFERENCES tax_class(id) ON DELETE SET NULL;
+ALTER TABLE subcategory ADD COLUMN tax_class_id int REFERENCES tax_class(id) ON DELETE SET NULL;
+ALTER TABLE product ADD COLUMN tax_class_id int REFERENCES tax_class(id) ON DELETE SET NULL;
+
+ALTER TABLE cart ADD COLUMN region varchar(16) NOT NULL DEFAULT '*';
+
+-- Orders keep the tax they were sold with, rates can change afterwards
+ALTER TABLE customer_order ADD COLUMN region varchar(16) NOT NULL DEFAULT '*';
+ALTER TABLE customer_order ADD COLUMN tax_total numeric(10,2) NOT NULL DEFAULT 0;
+ALTER TABLE customer_order ADD COLUMN total numeric(10,2);
+UPDATE customer_order SET total = subtotal;
+ALTER TABLE customer_order ALTER COLUMN total SET NOT NULL;
+
+ALTER TABLE order_line ADD COLUMN tax_class varchar(255) NOT NULL DEFAULT '';
+ALTER TABLE order_line ADD COLUMN tax_rate numeric(5,2) NOT NULL DEFAULT 0;
+ALTER TABLE order_line ADD COLUMN tax_amount numeric(10,2) NOT NULL DEFAULT 0;
@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS product_search_vector_trigger ON product;
+DROP FUNCTION IF EXISTS product_search_vector_update();
+DROP INDEX IF EXISTS product_search_vector_idx;
+ALTER TABLE product
+DROP COLUMN search_vector;
@@ -0,0 +1,26 @@
+ALTER TABLE product
+ADD COLUMN search_vector tsvector;
+
+-- name and sku weigh the most, then brand, then description
+CREATE FUNCTION product_search_vector_update() RETURNS trigger AS $$
+BEGIN
+  NEW.search_vector :=
+    setweight(to_tsvector('simple', coalesce(NEW.name, '')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(NEW.sku, '')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(NEW.brand, '')), 'B') ||
+    setweight(to_tsvector('simple', coalesce(NEW.description, '')), 'C');
+  RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER product_search_vector_trigger
+BEFORE INSERT OR UPDATE OF name, sku, brand, description ON product
+FOR EACH ROW EXECUTE PROCEDURE product_search_vector_update();
+
+UPDATE product SET search_vector =
+  setweight(to_tsvector('simple', coalesce(name, '')), 'A') ||
+  setweight(to_tsvector('simple', coalesce(sku, '')), 'A') ||
+  setweight(to_tsvector('simple', coalesce(brand, '')), 'B') ||
+  setweight(to_tsvector('simple', coalesce(description, '')), 'C');
+
+CREATE INDEX product_search_vector_idx ON product USING GIN (search_vector);
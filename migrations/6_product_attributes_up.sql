@@ -0,0 +1,26 @@
+CREATE TABLE attribute_definition (
+  id SERIAL PRIMARY KEY,
+  subcategory_id int NOT NULL REFERENCES subcategory(id) ON DELETE CASCADE,
+  code varchar(64) NOT NULL,
+  name varchar(255) NOT NULL,
+  type varchar(16) NOT NULL CHECK (type IN ('number', 'enum', 'multi_enum', 'boolean')),
+  unit varchar(16),
+  allowed_values text[],
+  required boolean NOT NULL DEFAULT false,
+  created_at timestamp NOT NULL,
+  UNIQUE (subcategory_id, code)
+);
+
+-- One row per value, multi_enum attributes take a row for each selected value
+CREATE TABLE product_attribute (
+  id SERIAL PRIMARY KEY,
+  product_id int NOT NULL REFERENCES product(id) ON DELETE CASCADE,
+  attribute_id int NOT NULL REFERENCES attribute_definition(id) ON DELETE CASCADE,
+  number_value numeric,
+  text_value varchar(255),
+  bool_value boolean
+);
+
+CREATE INDEX product_attribute_product_idx ON product_attribute (product_id);
+CREATE INDEX product_attribute_number_idx ON product_attribute (attribute_id, number_value);
+CREATE INDEX product_attribute_text_idx ON product_attribute (attribute_id, text_value);
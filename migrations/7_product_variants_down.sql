@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS product_variant;
+DROP TABLE IF EXISTS product_variant_axis;
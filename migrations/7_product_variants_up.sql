@@ -0,0 +1,21 @@
+-- Axes a product varies on, like colour or length, in display order
+CREATE TABLE product_variant_axis (
+  id SERIAL PRIMARY KEY,
+  product_id int NOT NULL REFERENCES product(id) ON DELETE CASCADE,
+  name varchar(64) NOT NULL,
+  position int NOT NULL,
+  UNIQUE (product_id, name)
+);
+
+-- options maps every axis of the product to the value of this variant, {"colour": "red", "length": "50 m"}
+CREATE TABLE product_variant (
+  id SERIAL PRIMARY KEY,
+  product_id int NOT NULL REFERENCES product(id) ON DELETE CASCADE,
+  sku varchar(255) NOT NULL UNIQUE,
+  price numeric(10,2) NOT NULL,
+  current_inventory int NOT NULL,
+  image_url varchar(255),
+  options jsonb NOT NULL,
+  created_at timestamp NOT NULL,
+  UNIQUE (product_id, options)
+);
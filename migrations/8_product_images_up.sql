@@ -0,0 +1,16 @@
+-- Gallery of a product in display order, product.image_url keeps the name of the primary image for older clients
+CREATE TABLE product_image (
+  id SERIAL PRIMARY KEY,
+  product_id int NOT NULL REFERENCES product(id) ON DELETE CASCADE,
+  image_url varchar(255) NOT NULL,
+  alt_text varchar(255) NOT NULL DEFAULT '',
+  position int NOT NULL,
+  is_primary boolean NOT NULL DEFAULT false,
+  created_at timestamp NOT NULL
+);
+
+CREATE INDEX product_image_product_idx ON product_image (product_id, position);
+CREATE UNIQUE INDEX product_image_primary_idx ON product_image (product_id) WHERE is_primary;
+
+INSERT INTO product_image (product_id, image_url, position, is_primary, created_at)
+SELECT id, image_url, 0, true, created_at FROM product WHERE image_url <> '';
@@ -0,0 +1,23 @@
+-- Append-only ledger of stock changes, product.current_inventory is kept equal to the balance of the last movement.
+-- quantity is signed: receipts and returns add stock, sales and damage take it away, adjustments go either way.
+-- Movements of a variant name it in variant_id and their balance is the variant's stock.
+-- product_id and variant_id have no foreign keys, the ledger keeps the movements of deleted products and variants.
+CREATE TABLE stock_movement (
+  id SERIAL PRIMARY KEY,
+  product_id int NOT NULL,
+  variant_id int,
+  reason varchar(16) NOT NULL CHECK (reason IN ('receipt', 'sale', 'adjustment', 'return', 'damage')),
+  quantity int NOT NULL CHECK (quantity <> 0),
+  balance int NOT NULL CHECK (balance >= 0),
+  created_by varchar(255) NOT NULL DEFAULT '',
+  reference varchar(255) NOT NULL DEFAULT '',
+  created_at timestamp NOT NULL
+);
+
+CREATE INDEX stock_movement_product_idx ON stock_movement (product_id, id);
+
+INSERT INTO stock_movement (product_id, reason, quantity, balance, reference, created_at)
+SELECT id, 'adjustment', current_inventory, current_inventory, 'opening balance', now() FROM product WHERE current_inventory > 0;
+
+INSERT INTO stock_movement (product_id, variant_id, reason, quantity, balance, reference, created_at)
+SELECT product_id, id, 'adjustment', current_inventory, current_inventory, 'opening balance', now() FROM product_variant WHERE current_inventory > 0;
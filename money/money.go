@@ -0,0 +1,259 @@
+package money
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Amount is an amount of money in minor units, cents of the two decimals prices are kept with.
+// Sums and multiples are exact or ErrOverflow, anything that divides takes a RoundingMode.
+//
+// In json an amount is a string like "12.50" so clients don't parse it into a float,
+// numbers like 12.5 are accepted as input too.
+type Amount int64
+
+// Minor units in a unit
+const Scale = 100
+
+// Largest amount a numeric(10,2) column holds
+const Max Amount = 9999999999
+
+type RoundingMode int
+
+const (
+	// Halves away from zero, 0.125 is 0.13. What receipts and most tax rules expect.
+	HalfUp RoundingMode = iota
+	// Halves to the even neighbour, 0.125 is 0.12. Keeps the sum of many roundings unbiased.
+	HalfEven
+	// Toward zero
+	Down
+	// Away from zero
+	Up
+)
+
+var (
+	ErrInvalid   = errors.New("invalid amount")
+	ErrPrecision = errors.New("amount has more than 2 decimals")
+	ErrOverflow  = errors.New("amount out of range")
+)
+
+func FromMinor(units int64) Amount {
+	return Amount(units)
+}
+
+func (a Amount) Minor() int64 {
+	return int64(a)
+}
+
+// Parses a decimal like "12.5", "-3" or "1.25e2" exactly, more than 2 decimals is ErrPrecision
+func Parse(s string) (Amount, error) {
+	r, err := parseRat(s)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if !r.IsInt() {
+		return 0, ErrPrecision
+	}
+
+	return fromInt(r.Num())
+}
+
+// Parses a decimal like Parse does, rounding any decimals past the second with mode
+func ParseRound(s string, mode RoundingMode) (Amount, error) {
+	r, err := parseRat(s)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return fromInt(round(r, mode))
+}
+
+// Longest text and exponent an amount is parsed from, way past any amount in range
+const (
+	maxLength   = 64
+	maxExponent = 30
+)
+
+// Returns s in minor units
+func parseRat(s string) (*big.Rat, error) {
+	s = strings.TrimSpace(s)
+
+	// big.Rat takes fractions like 1/3 and hex floats, amounts are plain decimals
+	if s == "" || len(s) > maxLength || strings.ContainsAny(s, "/xXpP_") {
+		return nil, ErrInvalid
+	}
+
+	// An exponent like 1e999999999 would have big.Rat build a huge number
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		if exp, err := strconv.Atoi(s[i+1:]); err != nil || exp > maxExponent || exp < -maxExponent {
+			return nil, ErrInvalid
+		}
+	}
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, ErrInvalid
+	}
+
+	return r.Mul(r, big.NewRat(Scale, 1)), nil
+}
+
+func fromInt(n *big.Int) (Amount, error) {
+	if !n.IsInt64() || n.Int64() > int64(Max) || n.Int64() < -int64(Max) {
+		return 0, ErrOverflow
+	}
+
+	return Amount(n.Int64()), nil
+}
+
+// Rounds r to an integer with mode
+func round(r *big.Rat, mode RoundingMode) *big.Int {
+	q, rem := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+
+	if rem.Sign() == 0 {
+		return q
+	}
+
+	away := false
+
+	switch mode {
+	case Up:
+		away = true
+	case HalfUp, HalfEven:
+		// Compares the remainder with half of the denominator
+		c := new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2)).Cmp(r.Denom())
+		away = c > 0 || (c == 0 && (mode == HalfUp || q.Bit(0) == 1))
+	}
+
+	if away {
+		q.Add(q, big.NewInt(int64(r.Sign())))
+	}
+
+	return q
+}
+
+func (a Amount) String() string {
+	sign := ""
+	units := uint64(a)
+
+	// Negating in uint64 keeps the smallest int64, which has no positive counterpart
+	if a < 0 {
+		sign = "-"
+		units = -units
+	}
+
+	return fmt.Sprintf("%s%d.%02d", sign, units/Scale, units%Scale)
+}
+
+// Returns a + b, ErrOverflow when it doesn't fit in an int64
+func (a Amount) Add(b Amount) (Amount, error) {
+	sum := a + b
+
+	if (b > 0 && sum < a) || (b < 0 && sum > a) {
+		return 0, ErrOverflow
+	}
+
+	return sum, nil
+}
+
+// Returns a - b, ErrOverflow when it doesn't fit in an int64
+func (a Amount) Sub(b Amount) (Amount, error) {
+	difference := a - b
+
+	if (b > 0 && difference > a) || (b < 0 && difference < a) {
+		return 0, ErrOverflow
+	}
+
+	return difference, nil
+}
+
+// Returns a times quantity, like the total of a line, ErrOverflow when it doesn't fit in an int64
+func (a Amount) Mul(quantity int64) (Amount, error) {
+	product := new(big.Int).Mul(big.NewInt(int64(a)), big.NewInt(quantity))
+
+	if !product.IsInt64() {
+		return 0, ErrOverflow
+	}
+
+	return Amount(product.Int64()), nil
+}
+
+// Returns a * num / den rounded with mode, like a share of a: a.MulRatio(1, 3, money.HalfEven) is a third of a.
+// ErrOverflow when it doesn't fit in an int64, ErrInvalid when den is 0.
+func (a Amount) MulRatio(num int64, den int64, mode RoundingMode) (Amount, error) {
+	if den == 0 {
+		return 0, ErrInvalid
+	}
+
+	r := new(big.Rat).SetFrac(new(big.Int).Mul(big.NewInt(int64(a)), big.NewInt(num)), big.NewInt(den))
+	n := round(r, mode)
+
+	if !n.IsInt64() {
+		return 0, ErrOverflow
+	}
+
+	return Amount(n.Int64()), nil
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(a.String())), nil
+}
+
+// Takes a string like "12.50" or, for clients written before amounts were strings, a number like 12.5.
+// The number is read from its text so it's as exact as a string.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	text := string(data)
+
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+	}
+
+	parsed, err := Parse(text)
+
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, data)
+	}
+
+	*a = parsed
+	return nil
+}
+
+// Reads numeric columns, which the driver hands over as text
+func (a *Amount) Scan(src interface{}) error {
+	var err error
+
+	switch v := src.(type) {
+	case []byte:
+		*a, err = Parse(string(v))
+	case string:
+		*a, err = Parse(v)
+	case int64:
+		*a, err = fromInt(big.NewInt(v * Scale))
+	default:
+		return fmt.Errorf("can't scan %T into an amount", src)
+	}
+
+	return err
+}
+
+// Writes amounts as decimal text, which Postgres reads into a numeric exactly
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
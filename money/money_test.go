@@ -0,0 +1,183 @@
+package money
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Amount
+		err  error
+	}{
+		{"12.5", 1250, nil},
+		{"12.50", 1250, nil},
+		{" -3 ", -300, nil},
+		{"0.01", 1, nil},
+		{"1.25e2", 12500, nil},
+		{"99999999.99", Max, nil},
+		{"-99999999.99", -Max, nil},
+		{"0.125", 0, ErrPrecision},
+		{"1e-3", 0, ErrPrecision},
+		{"100000000", 0, ErrOverflow},
+		{"1e20", 0, ErrOverflow},
+		{"", 0, ErrInvalid},
+		{"abc", 0, ErrInvalid},
+		{"1/3", 0, ErrInvalid},
+		{"0x10", 0, ErrInvalid},
+		{"1_000", 0, ErrInvalid},
+		{"1e999999999", 0, ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Parse(%q) error = %v, want %v", tt.in, err, tt.err)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("Parse(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		in   Amount
+		want string
+	}{
+		{0, "0.00"},
+		{1, "0.01"},
+		{10, "0.10"},
+		{1250, "12.50"},
+		{-1, "-0.01"},
+		{-1250, "-12.50"},
+		{Max, "99999999.99"},
+		{math.MinInt64, "-92233720368547758.08"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.in.String(); got != tt.want {
+			t.Errorf("Amount(%d).String() = %q, want %q", int64(tt.in), got, tt.want)
+		}
+	}
+}
+
+func TestArithmeticOverflow(t *testing.T) {
+	if _, err := Amount(math.MaxInt64).Add(1); !errors.Is(err, ErrOverflow) {
+		t.Errorf("MaxInt64 + 1 error = %v, want ErrOverflow", err)
+	}
+
+	if _, err := Amount(math.MinInt64).Add(-1); !errors.Is(err, ErrOverflow) {
+		t.Errorf("MinInt64 + -1 error = %v, want ErrOverflow", err)
+	}
+
+	if _, err := Amount(math.MinInt64).Sub(1); !errors.Is(err, ErrOverflow) {
+		t.Errorf("MinInt64 - 1 error = %v, want ErrOverflow", err)
+	}
+
+	if _, err := Amount(0).Sub(math.MinInt64); !errors.Is(err, ErrOverflow) {
+		t.Errorf("0 - MinInt64 error = %v, want ErrOverflow", err)
+	}
+
+	if _, err := Max.Mul(math.MaxInt64/int64(Max) + 1); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Max * huge quantity error = %v, want ErrOverflow", err)
+	}
+
+	if got, err := Amount(math.MaxInt64 - 1).Add(1); err != nil || got != math.MaxInt64 {
+		t.Errorf("MaxInt64-1 + 1 = %d, %v, want MaxInt64", got, err)
+	}
+
+	if got, err := Amount(-5).Sub(-7); err != nil || got != 2 {
+		t.Errorf("-5 - -7 = %d, %v, want 2", got, err)
+	}
+
+	if got, err := Amount(1250).Mul(3); err != nil || got != 3750 {
+		t.Errorf("12.50 * 3 = %d, %v, want 3750", got, err)
+	}
+
+	if got, err := Max.Mul(-1); err != nil || got != -Max {
+		t.Errorf("Max * -1 = %d, %v, want -Max", got, err)
+	}
+}
+
+func TestParseRound(t *testing.T) {
+	tests := []struct {
+		in   string
+		mode RoundingMode
+		want Amount
+		err  error
+	}{
+		{"0.125", HalfUp, 13, nil},
+		{"0.125", HalfEven, 12, nil},
+		{"0.135", HalfEven, 14, nil},
+		{"0.125", Down, 12, nil},
+		{"0.125", Up, 13, nil},
+		{"-0.125", HalfUp, -13, nil},
+		{"-0.125", HalfEven, -12, nil},
+		{"-0.125", Down, -12, nil},
+		{"-0.125", Up, -13, nil},
+		{"0.1249", HalfUp, 12, nil},
+		{"0.1251", HalfEven, 13, nil},
+		{"0.121", Up, 13, nil},
+		{"0.129", Down, 12, nil},
+		{"12.50", Up, 1250, nil},
+		{"99999999.995", HalfUp, 0, ErrOverflow},
+		{"99999999.995", Down, Max, nil},
+		{"abc", HalfUp, 0, ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseRound(tt.in, tt.mode)
+
+		if !errors.Is(err, tt.err) {
+			t.Errorf("ParseRound(%q, %d) error = %v, want %v", tt.in, tt.mode, err, tt.err)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("ParseRound(%q, %d) = %d, want %d", tt.in, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestMulRatio(t *testing.T) {
+	tests := []struct {
+		amount Amount
+		num    int64
+		den    int64
+		mode   RoundingMode
+		want   Amount
+		err    error
+	}{
+		{1000, 1, 3, HalfUp, 333, nil},
+		{1000, 2, 3, HalfUp, 667, nil},
+		{1000, 2, 3, Down, 666, nil},
+		{1000, 1, 3, Up, 334, nil},
+		{25, 1, 2, HalfUp, 13, nil},
+		{25, 1, 2, HalfEven, 12, nil},
+		{-25, 1, 2, HalfUp, -13, nil},
+		{25, -1, 2, HalfEven, -12, nil},
+		{1000, 1, 0, HalfUp, 0, ErrInvalid},
+		{math.MaxInt64, 2, 1, HalfUp, 0, ErrOverflow},
+		// Intermediate products past int64 are fine when the result fits
+		{math.MaxInt64, 3, 3, HalfUp, math.MaxInt64, nil},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.amount.MulRatio(tt.num, tt.den, tt.mode)
+
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%d * %d/%d error = %v, want %v", tt.amount, tt.num, tt.den, err, tt.err)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("%d * %d/%d with mode %d = %d, want %d", tt.amount, tt.num, tt.den, tt.mode, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+// Rate is a percentage in hundredths of a percent, 2100 is 21%. Like amounts it's a string like "21.00" in json
+// and takes 2 decimals at most, the finest tax rates go.
+type Rate int64
+
+// Largest rate, 100%
+const MaxRate Rate = 100 * Scale
+
+var ErrRateRange = errors.New("rate must be between 0 and 100")
+
+// Parses a percentage like "21" or "5.5"
+func ParseRate(s string) (Rate, error) {
+	a, err := Parse(s)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if a < 0 || Rate(a) > MaxRate {
+		return 0, ErrRateRange
+	}
+
+	return Rate(a), nil
+}
+
+func (r Rate) String() string {
+	return Amount(r).String()
+}
+
+// Returns r percent of a rounded with mode. Rates are 100% at most so it never overflows.
+func (r Rate) Of(a Amount, mode RoundingMode) Amount {
+	share := new(big.Rat).SetFrac(new(big.Int).Mul(big.NewInt(int64(a)), big.NewInt(int64(r))), big.NewInt(int64(MaxRate)))
+	return Amount(round(share, mode).Int64())
+}
+
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(r.String())), nil
+}
+
+// Takes a string like "21.00" or a number like 21, the same way amounts do
+func (r *Rate) UnmarshalJSON(data []byte) error {
+	var a Amount
+
+	if err := a.UnmarshalJSON(data); err != nil {
+		return err
+	}
+
+	if a < 0 || Rate(a) > MaxRate {
+		return fmt.Errorf("%w: %s", ErrRateRange, data)
+	}
+
+	*r = Rate(a)
+	return nil
+}
+
+func (r *Rate) Scan(src interface{}) error {
+	var a Amount
+
+	if err := a.Scan(src); err != nil {
+		return err
+	}
+
+	*r = Rate(a)
+	return nil
+}
+
+func (r Rate) Value() (driver.Value, error) {
+	return r.String(), nil
+}
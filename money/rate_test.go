@@ -0,0 +1,100 @@
+package money
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRateOf(t *testing.T) {
+	tests := []struct {
+		rate   Rate
+		amount Amount
+		want   Amount
+	}{
+		// Exact shares
+		{2100, 10000, 2100},
+		{MaxRate, 1999, 1999},
+		{0, 1999, 0},
+		// Halves round away from zero
+		{5000, 1, 1},
+		{5000, 25, 13},
+		{5000, 3, 2},
+		{5000, -1, -1},
+		{5000, -3, -2},
+		{1250, 4, 1},
+		// Just under and over a half
+		{4999, 1, 0},
+		{5001, 1, 1},
+		{1900, 1099, 209},
+		{2100, 1099, 231},
+		{550, 1099, 60},
+	}
+
+	for _, tt := range tests {
+		if got := tt.rate.Of(tt.amount, HalfUp); got != tt.want {
+			t.Errorf("%s%% of %s = %s, want %s", tt.rate, tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestRateOfModes(t *testing.T) {
+	tests := []struct {
+		rate   Rate
+		amount Amount
+		mode   RoundingMode
+		want   Amount
+	}{
+		// 50% of 0.25 is 0.125
+		{5000, 25, HalfUp, 13},
+		{5000, 25, HalfEven, 12},
+		{5000, 25, Down, 12},
+		{5000, 25, Up, 13},
+		// 50% of 0.35 is 0.175, the even neighbour is above
+		{5000, 35, HalfEven, 18},
+		{5000, -25, HalfEven, -12},
+		{5000, -25, Down, -12},
+		{5000, -25, Up, -13},
+		// 21% of 10.99 is 2.3079
+		{2100, 1099, HalfEven, 231},
+		{2100, 1099, Down, 230},
+		{2100, 1099, Up, 231},
+		// Exact shares aren't moved by any mode
+		{2100, 10000, Up, 2100},
+		{2100, 10000, Down, 2100},
+	}
+
+	for _, tt := range tests {
+		if got := tt.rate.Of(tt.amount, tt.mode); got != tt.want {
+			t.Errorf("%s%% of %s with mode %d = %s, want %s", tt.rate, tt.amount, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Rate
+		err  error
+	}{
+		{"21", 2100, nil},
+		{"5.5", 550, nil},
+		{"100", MaxRate, nil},
+		{"0", 0, nil},
+		{"100.01", 0, ErrRateRange},
+		{"-1", 0, ErrRateRange},
+		{"5.555", 0, ErrPrecision},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseRate(tt.in)
+
+		if !errors.Is(err, tt.err) {
+			t.Errorf("ParseRate(%q) error = %v, want %v", tt.in, err, tt.err)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("ParseRate(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package notify
+
+import (
+	"context"
+
+	"vayer-electric-backend/structs"
+
+	"go.uber.org/zap"
+)
+
+// LogNotifier writes alerts to the server log, it never fails
+type LogNotifier struct{}
+
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{}
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, alert structs.StockAlert) error {
+	log.Warn(alertSubject(alert),
+		zap.Int64("alert_id", alert.Id),
+		zap.Int64("product_id", alert.ProductId),
+		zap.Int64("available", alert.Available),
+		zap.Int64("reorder_point", alert.ReorderPoint),
+		zap.Int64("reorder_quantity", alert.ReorderQuantity),
+	)
+
+	return nil
+}
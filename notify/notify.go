@@ -0,0 +1,104 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"vayer-electric-backend/env"
+	"vayer-electric-backend/logging"
+	"vayer-electric-backend/structs"
+)
+
+var log = logging.GetLogger()
+
+// Notifier delivers stock alerts to whoever restocks
+type Notifier interface {
+	Notify(ctx context.Context, alert structs.StockAlert) error
+}
+
+var (
+	_ Notifier = (*LogNotifier)(nil)
+	_ Notifier = (*SMTPNotifier)(nil)
+	_ Notifier = (*WebhookNotifier)(nil)
+	_ Notifier = multiNotifier(nil)
+)
+
+// Returns the notifiers listed in env.NOTIFIERS
+func GetNotifier() Notifier {
+	n, err := NewNotifier(strings.Split(env.NOTIFIERS, ","))
+
+	if err != nil {
+		panic(err)
+	}
+
+	return n
+}
+
+// Builds a notifier delivering to every one of names, each of log, smtp or webhook configured from env
+func NewNotifier(names []string) (Notifier, error) {
+	notifiers := make(multiNotifier, 0, len(names))
+
+	for _, name := range names {
+		switch strings.TrimSpace(name) {
+		case "":
+			continue
+		case "log":
+			notifiers = append(notifiers, NewLogNotifier())
+		case "smtp":
+			n, err := NewSMTPNotifier(SMTPConfigFromEnv())
+
+			if err != nil {
+				return nil, err
+			}
+
+			notifiers = append(notifiers, n)
+		case "webhook":
+			n, err := NewWebhookNotifier(WebhookConfigFromEnv())
+
+			if err != nil {
+				return nil, err
+			}
+
+			notifiers = append(notifiers, n)
+		default:
+			return nil, fmt.Errorf("unknown notifier %q, expected log, smtp or webhook", name)
+		}
+	}
+
+	if len(notifiers) == 1 {
+		return notifiers[0], nil
+	}
+
+	return notifiers, nil
+}
+
+// Delivers to every notifier in turn. The alert only counts as delivered once all of them took it,
+// a retry goes to all of them again.
+type multiNotifier []Notifier
+
+func (m multiNotifier) Notify(ctx context.Context, alert structs.StockAlert) error {
+	failures := make([]string, 0)
+
+	for _, n := range m {
+		if err := n.Notify(ctx, alert); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+
+	if len(failures) > 0 {
+		return errors.New(strings.Join(failures, "; "))
+	}
+
+	return nil
+}
+
+func alertSubject(alert structs.StockAlert) string {
+	return fmt.Sprintf("Low stock: %s (%s)", alert.ProductName, alert.Sku)
+}
+
+func alertText(alert structs.StockAlert) string {
+	return fmt.Sprintf("%s (sku %s) is down to %d available, below its reorder point of %d.\nReorder quantity: %d\n",
+		alert.ProductName, alert.Sku, alert.Available, alert.ReorderPoint, alert.ReorderQuantity)
+}
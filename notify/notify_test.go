@@ -0,0 +1,88 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"vayer-electric-backend/structs"
+)
+
+var testAlert = structs.StockAlert{
+	Id:              3,
+	ProductId:       1,
+	ProductName:     "THHN 12",
+	Sku:             "THHN-12",
+	Available:       4,
+	ReorderPoint:    5,
+	ReorderQuantity: 50,
+}
+
+type recordingNotifier struct {
+	err    error
+	alerts []structs.StockAlert
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, alert structs.StockAlert) error {
+	n.alerts = append(n.alerts, alert)
+	return n.err
+}
+
+func TestNewNotifier(t *testing.T) {
+	n, err := NewNotifier([]string{" log "})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := n.(*LogNotifier); !ok {
+		t.Errorf("NewNotifier(log) = %T, want *LogNotifier", n)
+	}
+
+	n, err = NewNotifier([]string{"log", "", "log"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m, ok := n.(multiNotifier); !ok || len(m) != 2 {
+		t.Errorf("NewNotifier(log, log) = %#v, want both", n)
+	}
+
+	if _, err := NewNotifier([]string{"log", "pager"}); err == nil {
+		t.Error("NewNotifier(pager) didn't fail")
+	}
+}
+
+// Every notifier gets the alert even when one before it failed
+func TestMultiNotifier(t *testing.T) {
+	failing := &recordingNotifier{err: errors.New("smtp down")}
+	working := &recordingNotifier{}
+
+	err := multiNotifier{failing, working, NewLogNotifier()}.Notify(context.Background(), testAlert)
+
+	if err == nil || !strings.Contains(err.Error(), "smtp down") {
+		t.Errorf("error = %v, want the smtp failure", err)
+	}
+
+	if len(failing.alerts) != 1 || len(working.alerts) != 1 || working.alerts[0].Id != testAlert.Id {
+		t.Errorf("delivered %d and %d alerts, want one each", len(failing.alerts), len(working.alerts))
+	}
+
+	if err := (multiNotifier{working}).Notify(context.Background(), testAlert); err != nil {
+		t.Errorf("error = %v, want nil", err)
+	}
+}
+
+func TestAlertText(t *testing.T) {
+	if got, want := alertSubject(testAlert), "Low stock: THHN 12 (THHN-12)"; got != want {
+		t.Errorf("alertSubject = %q, want %q", got, want)
+	}
+
+	text := alertText(testAlert)
+
+	for _, want := range []string{"down to 4 available", "reorder point of 5", "Reorder quantity: 50"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("alertText = %q, want it to say %q", text, want)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"vayer-electric-backend/env"
+	"vayer-electric-backend/structs"
+)
+
+type SMTPConfig struct {
+	// host:port of the mail server, like localhost:1025 for a local mail catcher
+	Addr     string
+	From     string
+	To       []string
+	Username string
+	Password string
+}
+
+func SMTPConfigFromEnv() SMTPConfig {
+	to := make([]string, 0)
+	for _, address := range strings.Split(env.SMTP_TO, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			to = append(to, address)
+		}
+	}
+
+	return SMTPConfig{
+		Addr:     env.SMTP_ADDR,
+		From:     env.SMTP_FROM,
+		To:       to,
+		Username: env.SMTP_USERNAME,
+		Password: env.SMTP_PASSWORD,
+	}
+}
+
+// SMTPNotifier emails alerts. STARTTLS is used when the server offers it and
+// credentials are only sent when a username is set.
+type SMTPNotifier struct {
+	config SMTPConfig
+	host   string
+}
+
+func NewSMTPNotifier(config SMTPConfig) (*SMTPNotifier, error) {
+	host, _, err := net.SplitHostPort(config.Addr)
+
+	if err != nil {
+		return nil, fmt.Errorf("smtp address %q must be host:port", config.Addr)
+	}
+
+	if config.From == "" || len(config.To) == 0 {
+		return nil, errors.New("the smtp notifier needs a sender and at least one recipient")
+	}
+
+	return &SMTPNotifier{config: config, host: host}, nil
+}
+
+func (n *SMTPNotifier) Notify(ctx context.Context, alert structs.StockAlert) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", n.config.Addr)
+
+	if err != nil {
+		return err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, n.host)
+
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: n.host}); err != nil {
+			return err
+		}
+	}
+
+	if n.config.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", n.config.Username, n.config.Password, n.host)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(n.config.From); err != nil {
+		return err
+	}
+
+	for _, to := range n.config.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(n.message(alert)); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+func (n *SMTPNotifier) message(alert structs.StockAlert) []byte {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "From: %s\r\n", n.config.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(n.config.To, ", "))
+	// Product names are free text, encoding keeps line breaks and accents out of the raw header
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", alertSubject(alert)))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(alertText(alert), "\n", "\r\n"))
+
+	return b.Bytes()
+}
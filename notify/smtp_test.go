@@ -0,0 +1,173 @@
+package notify
+
+import (
+	"context"
+	"mime"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+type smtpDelivery struct {
+	from string
+	to   []string
+	data string
+}
+
+// Accepts one connection and answers like a mail server without STARTTLS or AUTH, the mail it
+// got is sent on the channel once the client quits
+func newTestSMTPServer(t *testing.T) (string, <-chan smtpDelivery) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	deliveries := make(chan smtpDelivery, 1)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		c := textproto.NewConn(conn)
+		var d smtpDelivery
+
+		c.PrintfLine("220 localhost ready")
+
+		for {
+			line, err := c.ReadLine()
+			if err != nil {
+				return
+			}
+
+			verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+			switch {
+			case verb == "EHLO":
+				c.PrintfLine("250-localhost")
+				c.PrintfLine("250 8BITMIME")
+			case strings.HasPrefix(strings.ToUpper(line), "MAIL FROM:"):
+				d.from = smtpPath(line)
+				c.PrintfLine("250 ok")
+			case strings.HasPrefix(strings.ToUpper(line), "RCPT TO:"):
+				d.to = append(d.to, smtpPath(line))
+				c.PrintfLine("250 ok")
+			case verb == "DATA":
+				c.PrintfLine("354 go ahead")
+
+				lines, err := c.ReadDotLines()
+				if err != nil {
+					return
+				}
+
+				d.data = strings.Join(lines, "\n")
+				c.PrintfLine("250 queued")
+			case verb == "QUIT":
+				c.PrintfLine("221 bye")
+				deliveries <- d
+				return
+			default:
+				c.PrintfLine("502 not implemented")
+			}
+		}
+	}()
+
+	return ln.Addr().String(), deliveries
+}
+
+// Returns the address between the angle brackets of a MAIL or RCPT command, parameters like BODY= follow them
+func smtpPath(line string) string {
+	start := strings.IndexByte(line, '<')
+	end := strings.IndexByte(line, '>')
+
+	if start < 0 || end < start {
+		return ""
+	}
+
+	return line[start+1 : end]
+}
+
+func TestNewSMTPNotifier(t *testing.T) {
+	configs := []SMTPConfig{
+		{Addr: "localhost", From: "stock@example.com", To: []string{"buyer@example.com"}},
+		{Addr: "localhost:25", To: []string{"buyer@example.com"}},
+		{Addr: "localhost:25", From: "stock@example.com"},
+	}
+
+	for _, config := range configs {
+		if _, err := NewSMTPNotifier(config); err == nil {
+			t.Errorf("NewSMTPNotifier(%+v) didn't fail", config)
+		}
+	}
+}
+
+func TestSMTPNotify(t *testing.T) {
+	addr, deliveries := newTestSMTPServer(t)
+
+	n, err := NewSMTPNotifier(SMTPConfig{Addr: addr, From: "stock@example.com", To: []string{"buyer@example.com", "shop@example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A line break in the name must not start a header of its own
+	alert := testAlert
+	alert.ProductName = "Câble THHN\r\nBcc: everyone@example.com"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := n.Notify(ctx, alert); err != nil {
+		t.Fatal(err)
+	}
+
+	d := <-deliveries
+
+	if d.from != "stock@example.com" || strings.Join(d.to, ",") != "buyer@example.com,shop@example.com" {
+		t.Errorf("mail from %s to %v", d.from, d.to)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(d.data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bcc := msg.Header.Get("Bcc"); bcc != "" {
+		t.Errorf("the product name added a Bcc header: %q", bcc)
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if subject != alertSubject(alert) {
+		t.Errorf("Subject = %q, want %q", subject, alertSubject(alert))
+	}
+}
+
+func TestSMTPNotifyUnreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr := ln.Addr().String()
+	ln.Close()
+
+	n, err := NewSMTPNotifier(SMTPConfig{Addr: addr, From: "stock@example.com", To: []string{"buyer@example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := n.Notify(context.Background(), testAlert); err == nil {
+		t.Error("delivered to a closed port")
+	}
+}
@@ -0,0 +1,95 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"vayer-electric-backend/env"
+	"vayer-electric-backend/structs"
+)
+
+type WebhookConfig struct {
+	URL string
+	// Signs the body into the X-Signature-256 header when set
+	Secret string
+}
+
+func WebhookConfigFromEnv() WebhookConfig {
+	return WebhookConfig{
+		URL:    env.WEBHOOK_URL,
+		Secret: env.WEBHOOK_SECRET,
+	}
+}
+
+// WebhookNotifier posts alerts as json:
+//
+//	{"event": "stock.below_reorder_point", "alert": {...}}
+//
+// Any response outside 2xx is a failed delivery.
+type WebhookNotifier struct {
+	url    string
+	secret []byte
+	client *http.Client
+}
+
+func NewWebhookNotifier(config WebhookConfig) (*WebhookNotifier, error) {
+	u, err := url.Parse(config.URL)
+
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("webhook url %q must be an http or https url", config.URL)
+	}
+
+	return &WebhookNotifier{
+		url:    config.URL,
+		secret: []byte(config.Secret),
+		client: &http.Client{},
+	}, nil
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, alert structs.StockAlert) error {
+	body, err := json.Marshal(struct {
+		Event string             `json:"event"`
+		Alert structs.StockAlert `json:"alert"`
+	}{"stock.below_reorder_point", alert})
+
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if len(n.secret) > 0 {
+		mac := hmac.New(sha256.New, n.secret)
+		mac.Write(body)
+		req.Header.Set("X-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := n.client.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"vayer-electric-backend/structs"
+)
+
+func TestNewWebhookNotifier(t *testing.T) {
+	urls := []string{"", "example.com/hooks", "ftp://example.com/hooks", "https://"}
+
+	for _, u := range urls {
+		if _, err := NewWebhookNotifier(WebhookConfig{URL: u}); err == nil {
+			t.Errorf("NewWebhookNotifier(%q) didn't fail", u)
+		}
+	}
+}
+
+func TestWebhookNotify(t *testing.T) {
+	var body []byte
+	var signature string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get("X-Signature-256")
+	}))
+	defer server.Close()
+
+	n, err := NewWebhookNotifier(WebhookConfig{URL: server.URL, Secret: "whsec_test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := n.Notify(context.Background(), testAlert); err != nil {
+		t.Fatal(err)
+	}
+
+	var payload struct {
+		Event string             `json:"event"`
+		Alert structs.StockAlert `json:"alert"`
+	}
+
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatal(err)
+	}
+
+	if payload.Event != "stock.below_reorder_point" || payload.Alert.Sku != testAlert.Sku {
+		t.Errorf("posted %s", body)
+	}
+
+	mac := hmac.New(sha256.New, []byte("whsec_test"))
+	mac.Write(body)
+
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); signature != want {
+		t.Errorf("X-Signature-256 = %q, want %q", signature, want)
+	}
+}
+
+func TestWebhookNotifyUnsigned(t *testing.T) {
+	signed := false
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, signed = r.Header["X-Signature-256"]
+	}))
+	defer server.Close()
+
+	n, err := NewWebhookNotifier(WebhookConfig{URL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := n.Notify(context.Background(), testAlert); err != nil {
+		t.Fatal(err)
+	}
+
+	if signed {
+		t.Error("signed without a secret")
+	}
+}
+
+func TestWebhookNotifyFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	n, err := NewWebhookNotifier(WebhookConfig{URL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := n.Notify(context.Background(), testAlert); err == nil {
+		t.Error("a 503 counted as delivered")
+	}
+}
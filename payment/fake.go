@@ -0,0 +1,291 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"vayer-electric-backend/env"
+	"vayer-electric-backend/money"
+	"vayer-electric-backend/structs"
+
+	"go.uber.org/zap"
+)
+
+// Sources the storefront passes to the fake provider to pick the outcome of an authorization
+const (
+	// Authorized right away, an empty source does the same
+	FakeSourceSuccess = "fake_success"
+	// Declined right away
+	FakeSourceDecline = "fake_decline"
+	// Pending, authorized by a webhook after the webhook delay
+	FakeSourceDelayed = "fake_delayed"
+	// Pending, declined by a webhook after the webhook delay
+	FakeSourceDelayedDecline = "fake_delayed_decline"
+)
+
+// Header the fake provider signs its webhooks in, as t=<unix time>,v1=<hex HMAC-SHA256 of "<unix time>.<body>">
+const FakeSignatureHeader = "Fake-Signature"
+
+// Webhooks signed longer ago than this are refused so a captured one can't be replayed later
+const fakeSignatureTolerance = 5 * time.Minute
+
+type FakeConfig struct {
+	Secret       string
+	WebhookURL   string
+	WebhookDelay time.Duration
+}
+
+func FakeConfigFromEnv() FakeConfig {
+	webhookURL := env.PAYMENT_FAKE_WEBHOOK_URL
+	if webhookURL == "" {
+		webhookURL = fmt.Sprintf("http://localhost:%d/api/payments/webhook", env.PORT)
+	}
+
+	return FakeConfig{
+		Secret:       env.PAYMENT_FAKE_SECRET,
+		WebhookURL:   webhookURL,
+		WebhookDelay: env.PAYMENT_FAKE_WEBHOOK_DELAY,
+	}
+}
+
+// FakeProvider stands in for a gateway when running locally. Nothing leaves the process but its delayed webhooks,
+// outcomes only depend on the source and ids only on our payment id so a scenario plays out the same every time.
+// Payments are kept in memory, ones authorized before a restart can't be captured or refunded after it.
+type FakeProvider struct {
+	secret       []byte
+	webhookURL   string
+	webhookDelay time.Duration
+	client       *http.Client
+
+	mu       sync.Mutex
+	payments map[string]*fakePayment
+}
+
+type fakePayment struct {
+	paymentId  int64
+	status     string
+	authorized money.Amount
+	captured   money.Amount
+	refunded   money.Amount
+	refunds    int
+}
+
+func NewFakeProvider(config FakeConfig) (*FakeProvider, error) {
+	if config.Secret == "" {
+		return nil, fmt.Errorf("the fake payment provider needs a secret to sign its webhooks")
+	}
+
+	return &FakeProvider{
+		secret:       []byte(config.Secret),
+		webhookURL:   config.WebhookURL,
+		webhookDelay: config.WebhookDelay,
+		client:       &http.Client{Timeout: 10 * time.Second},
+		payments:     make(map[string]*fakePayment),
+	}, nil
+}
+
+func (f *FakeProvider) Name() string {
+	return "fake"
+}
+
+func (f *FakeProvider) Authorize(ctx context.Context, req AuthorizeRequest) (structs.PaymentEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return structs.PaymentEvent{}, err
+	}
+
+	reference := fmt.Sprintf("fake_pay_%d", req.PaymentId)
+	event := structs.PaymentEvent{
+		Id:        reference + "_authorization",
+		Reference: reference,
+		Status:    "authorized",
+		Amount:    req.Amount,
+	}
+
+	switch req.Source {
+	case "", FakeSourceSuccess, FakeSourceDelayed:
+	case FakeSourceDecline, FakeSourceDelayedDecline:
+		event.Status = "declined"
+		event.Message = "card declined"
+	default:
+		event.Status = "declined"
+		event.Message = fmt.Sprintf("unknown source %q", req.Source)
+	}
+
+	f.mu.Lock()
+	_, seen := f.payments[reference]
+	f.payments[reference] = &fakePayment{paymentId: req.PaymentId, status: event.Status, authorized: req.Amount}
+	f.mu.Unlock()
+
+	if req.Source != FakeSourceDelayed && req.Source != FakeSourceDelayedDecline {
+		return event, nil
+	}
+
+	// A retried authorization doesn't send the webhook twice, a real provider would have kept its own
+	if !seen {
+		time.AfterFunc(f.webhookDelay, func() { f.deliver(event) })
+	}
+
+	return structs.PaymentEvent{Reference: reference, Status: "pending"}, nil
+}
+
+func (f *FakeProvider) Capture(ctx context.Context, reference string, amount money.Amount) (structs.PaymentEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return structs.PaymentEvent{}, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p, ok := f.payments[reference]
+	if !ok {
+		return structs.PaymentEvent{}, fmt.Errorf("%w: no payment %s", ErrProvider, reference)
+	}
+
+	if p.status != "authorized" {
+		return structs.PaymentEvent{}, fmt.Errorf("%w: payment %s is %s", ErrProvider, reference, p.status)
+	}
+
+	if amount == 0 {
+		amount = p.authorized
+	}
+
+	if amount > p.authorized {
+		return structs.PaymentEvent{}, fmt.Errorf("%w: can't capture more than the %s authorized", ErrProvider, p.authorized)
+	}
+
+	p.status = "captured"
+	p.captured = amount
+
+	return structs.PaymentEvent{Id: reference + "_capture", Reference: reference, Status: "captured", Amount: amount}, nil
+}
+
+func (f *FakeProvider) Refund(ctx context.Context, reference string, amount money.Amount) (structs.PaymentEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return structs.PaymentEvent{}, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p, ok := f.payments[reference]
+	if !ok {
+		return structs.PaymentEvent{}, fmt.Errorf("%w: no payment %s", ErrProvider, reference)
+	}
+
+	if p.status != "captured" {
+		return structs.PaymentEvent{}, fmt.Errorf("%w: payment %s is %s", ErrProvider, reference, p.status)
+	}
+
+	left, err := p.captured.Sub(p.refunded)
+	if err != nil {
+		return structs.PaymentEvent{}, fmt.Errorf("%w: %s", ErrProvider, err)
+	}
+
+	if amount == 0 {
+		amount = left
+	}
+
+	if amount > left {
+		return structs.PaymentEvent{}, fmt.Errorf("%w: can't refund more than the %s captured left", ErrProvider, left)
+	}
+
+	if p.refunded, err = p.refunded.Add(amount); err != nil {
+		return structs.PaymentEvent{}, fmt.Errorf("%w: %s", ErrProvider, err)
+	}
+	p.refunds++
+
+	if p.refunded >= p.captured {
+		p.status = "refunded"
+	}
+
+	return structs.PaymentEvent{Id: fmt.Sprintf("%s_refund_%d", reference, p.refunds), Reference: reference, Status: "refunded", Amount: amount}, nil
+}
+
+func (f *FakeProvider) VerifyWebhook(header http.Header, body []byte) (structs.PaymentEvent, error) {
+	var timestamp, signature string
+
+	for _, part := range strings.Split(header.Get(FakeSignatureHeader), ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signature = value
+		}
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+
+	if err != nil || signature == "" {
+		return structs.PaymentEvent{}, ErrInvalidSignature
+	}
+
+	if age := time.Since(time.Unix(unix, 0)); age > fakeSignatureTolerance || age < -fakeSignatureTolerance {
+		return structs.PaymentEvent{}, ErrInvalidSignature
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(f.sign(timestamp, body))) {
+		return structs.PaymentEvent{}, ErrInvalidSignature
+	}
+
+	var event structs.PaymentEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return structs.PaymentEvent{}, ErrInvalidSignature
+	}
+
+	return event, nil
+}
+
+func (f *FakeProvider) sign(timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, f.secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Posts event to the webhook url once, failures are only logged
+func (f *FakeProvider) deliver(event structs.PaymentEvent) {
+	body, err := json.Marshal(event)
+
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodPost, f.webhookURL, bytes.NewReader(body))
+
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(FakeSignatureHeader, fmt.Sprintf("t=%s,v1=%s", timestamp, f.sign(timestamp, body)))
+
+	resp, err := f.client.Do(req)
+
+	if err != nil {
+		log.Error("fake payment webhook failed", zap.String("event_id", event.Id), zap.Error(err))
+		return
+	}
+
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		log.Error("fake payment webhook failed", zap.String("event_id", event.Id), zap.String("status", resp.Status))
+	}
+}
@@ -0,0 +1,205 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"vayer-electric-backend/money"
+	"vayer-electric-backend/structs"
+)
+
+func newTestFakeProvider(t *testing.T, config FakeConfig) *FakeProvider {
+	t.Helper()
+
+	if config.Secret == "" {
+		config.Secret = "whsec_test"
+	}
+
+	f, err := NewFakeProvider(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return f
+}
+
+// Header signing body at signedAt with secret the way the fake provider does
+func fakeSignature(secret string, signedAt time.Time, body []byte) http.Header {
+	f := &FakeProvider{secret: []byte(secret)}
+	timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+
+	header := http.Header{}
+	header.Set(FakeSignatureHeader, fmt.Sprintf("t=%s,v1=%s", timestamp, f.sign(timestamp, body)))
+	return header
+}
+
+func TestNewFakeProviderNeedsSecret(t *testing.T) {
+	if _, err := NewFakeProvider(FakeConfig{}); err == nil {
+		t.Error("created a fake provider without a secret")
+	}
+}
+
+func TestFakeAuthorize(t *testing.T) {
+	tests := []struct {
+		source string
+		status string
+	}{
+		{"", "authorized"},
+		{FakeSourceSuccess, "authorized"},
+		{FakeSourceDecline, "declined"},
+		{"tok_visa", "declined"},
+	}
+
+	for _, tt := range tests {
+		f := newTestFakeProvider(t, FakeConfig{})
+
+		event, err := f.Authorize(context.Background(), AuthorizeRequest{PaymentId: 7, Amount: money.FromMinor(1000), Source: tt.source})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if event.Status != tt.status || event.Reference != "fake_pay_7" || event.Id == "" {
+			t.Errorf("source %q: got %+v, want %s", tt.source, event, tt.status)
+		}
+	}
+}
+
+func TestFakeCaptureAndRefund(t *testing.T) {
+	ctx := context.Background()
+	f := newTestFakeProvider(t, FakeConfig{})
+
+	if _, err := f.Capture(ctx, "fake_pay_7", 0); !errors.Is(err, ErrProvider) {
+		t.Errorf("capturing an unknown payment: error = %v, want ErrProvider", err)
+	}
+
+	if _, err := f.Authorize(ctx, AuthorizeRequest{PaymentId: 7, Amount: money.FromMinor(1000)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.Refund(ctx, "fake_pay_7", 0); !errors.Is(err, ErrProvider) {
+		t.Errorf("refunding before capture: error = %v, want ErrProvider", err)
+	}
+
+	if _, err := f.Capture(ctx, "fake_pay_7", money.FromMinor(1001)); !errors.Is(err, ErrProvider) {
+		t.Errorf("capturing more than authorized: error = %v, want ErrProvider", err)
+	}
+
+	captured, err := f.Capture(ctx, "fake_pay_7", money.FromMinor(600))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if captured.Status != "captured" || captured.Amount != money.FromMinor(600) {
+		t.Errorf("capture = %+v, want 6.00 captured", captured)
+	}
+
+	first, err := f.Refund(ctx, "fake_pay_7", money.FromMinor(200))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.Refund(ctx, "fake_pay_7", money.FromMinor(401)); !errors.Is(err, ErrProvider) {
+		t.Errorf("refunding more than captured: error = %v, want ErrProvider", err)
+	}
+
+	// Refunds what's left
+	second, err := f.Refund(ctx, "fake_pay_7", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if second.Amount != money.FromMinor(400) || first.Id == second.Id {
+		t.Errorf("refunds %+v and %+v, want 4.00 last under another id", first, second)
+	}
+
+	if _, err := f.Refund(ctx, "fake_pay_7", 0); !errors.Is(err, ErrProvider) {
+		t.Errorf("refunding a refunded payment: error = %v, want ErrProvider", err)
+	}
+}
+
+func TestFakeVerifyWebhook(t *testing.T) {
+	f := newTestFakeProvider(t, FakeConfig{Secret: "whsec_test"})
+	body := []byte(`{"id": "fake_pay_7_authorization", "reference": "fake_pay_7", "status": "authorized"}`)
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		header http.Header
+		body   []byte
+		ok     bool
+	}{
+		{"signed", fakeSignature("whsec_test", now, body), body, true},
+		{"signed a minute ago", fakeSignature("whsec_test", now.Add(-time.Minute), body), body, true},
+		{"replayed", fakeSignature("whsec_test", now.Add(-fakeSignatureTolerance-time.Minute), body), body, false},
+		{"from the future", fakeSignature("whsec_test", now.Add(fakeSignatureTolerance+time.Minute), body), body, false},
+		{"other secret", fakeSignature("whsec_other", now, body), body, false},
+		{"tampered body", fakeSignature("whsec_test", now, body), []byte(`{"id": "fake_pay_7_authorization", "reference": "fake_pay_8", "status": "authorized"}`), false},
+		{"unsigned", http.Header{}, body, false},
+		{"not json", fakeSignature("whsec_test", now, []byte("authorized")), []byte("authorized"), false},
+	}
+
+	for _, tt := range tests {
+		event, err := f.VerifyWebhook(tt.header, tt.body)
+
+		if !tt.ok {
+			if !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("%s: error = %v, want ErrInvalidSignature", tt.name, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+
+		if event.Id != "fake_pay_7_authorization" || event.Status != "authorized" {
+			t.Errorf("%s: got %+v", tt.name, event)
+		}
+	}
+}
+
+// Delayed sources answer pending and settle through a webhook the provider itself accepts
+func TestFakeDelayedWebhook(t *testing.T) {
+	events := make(chan structs.PaymentEvent, 1)
+
+	var f *FakeProvider
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		event, err := f.VerifyWebhook(r.Header, body)
+		if err != nil {
+			t.Error(err)
+		}
+
+		events <- event
+	}))
+	defer server.Close()
+
+	f = newTestFakeProvider(t, FakeConfig{WebhookURL: server.URL})
+
+	event, err := f.Authorize(context.Background(), AuthorizeRequest{PaymentId: 7, Amount: money.FromMinor(1000), Source: FakeSourceDelayedDecline})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if event.Status != "pending" || event.Id != "" {
+		t.Errorf("authorize = %+v, want pending without id", event)
+	}
+
+	select {
+	case event := <-events:
+		if event.Status != "declined" || event.Reference != "fake_pay_7" {
+			t.Errorf("webhook = %+v, want fake_pay_7 declined", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no webhook")
+	}
+}
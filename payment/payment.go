@@ -0,0 +1,70 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"vayer-electric-backend/env"
+	"vayer-electric-backend/logging"
+	"vayer-electric-backend/money"
+	"vayer-electric-backend/structs"
+)
+
+var log = logging.GetLogger()
+
+var (
+	// Returned by VerifyWebhook for a callback that wasn't signed by the provider
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	// Wraps failures to reach the provider or errors it answered with
+	ErrProvider = errors.New("payment provider error")
+)
+
+// PaymentProvider talks to a payment gateway. Outcomes come back as events, from the call itself or later
+// through the provider's webhook. An event with an id is final, a pending one without id is settled by a webhook.
+type PaymentProvider interface {
+	// Recorded on payments, webhooks are matched to payments by provider and reference
+	Name() string
+	// Reserves the amount on the customer's payment method, the event is authorized, declined or pending
+	Authorize(ctx context.Context, req AuthorizeRequest) (structs.PaymentEvent, error)
+	// Takes amount of an authorized payment
+	Capture(ctx context.Context, reference string, amount money.Amount) (structs.PaymentEvent, error)
+	// Gives back amount of a captured payment, a payment can be refunded in parts
+	Refund(ctx context.Context, reference string, amount money.Amount) (structs.PaymentEvent, error)
+	// Checks a webhook callback was signed by the provider and returns the event it carries
+	VerifyWebhook(header http.Header, body []byte) (structs.PaymentEvent, error)
+}
+
+type AuthorizeRequest struct {
+	// Our id for the payment, providers take it as idempotency key so a retried call doesn't charge twice
+	PaymentId  int64
+	OrderToken string
+	Amount     money.Amount
+	// Payment method token the storefront got from the provider's client library
+	Source string
+}
+
+var _ PaymentProvider = (*FakeProvider)(nil)
+
+// Returns the provider selected by env.PAYMENT_PROVIDER, nil when payments are turned off
+func GetPaymentProvider() PaymentProvider {
+	p, err := NewPaymentProvider(env.PAYMENT_PROVIDER)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return p
+}
+
+func NewPaymentProvider(name string) (PaymentProvider, error) {
+	switch name {
+	case "":
+		return nil, nil
+	case "fake":
+		return NewFakeProvider(FakeConfigFromEnv())
+	default:
+		return nil, fmt.Errorf("unknown payment provider %q, expected fake", name)
+	}
+}
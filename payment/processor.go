@@ -0,0 +1,133 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"vayer-electric-backend/db"
+	"vayer-electric-backend/money"
+	"vayer-electric-backend/structs"
+
+	"go.uber.org/zap"
+)
+
+// Time a provider gets to answer a call
+const providerTimeout = 30 * time.Second
+
+// Processor pays orders through a provider and records what it answers. A payment is recorded as pending
+// before the provider is called so an answer that gets lost can still be matched by the webhook that follows.
+type Processor struct {
+	store    db.Store
+	provider PaymentProvider
+}
+
+func NewProcessor(store db.Store, provider PaymentProvider) *Processor {
+	return &Processor{
+		store:    store,
+		provider: provider,
+	}
+}
+
+// Authorizes the total of a pending order, tax included. A declined payment is no error, it's returned declined
+// and the order stays pending for the customer to try again.
+func (p *Processor) Pay(ctx context.Context, order structs.Order, source string) (structs.Payment, error) {
+	payment, err := p.store.InsertPayment(ctx, structs.Payment{OrderId: order.Id, Provider: p.provider.Name(), Amount: order.Total})
+
+	if err != nil {
+		return structs.Payment{}, err
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, providerTimeout)
+	event, err := p.provider.Authorize(callCtx, AuthorizeRequest{
+		PaymentId:  payment.Id,
+		OrderToken: order.Token,
+		Amount:     payment.Amount,
+		Source:     source,
+	})
+	cancel()
+
+	if err != nil {
+		log.Error("payment authorization failed", zap.Int64("payment_id", payment.Id), zap.Error(err))
+		event = structs.PaymentEvent{Status: "failed", Message: "the payment provider couldn't be reached"}
+	}
+
+	return p.store.UpdatePayment(ctx, payment.Id, event)
+}
+
+// Takes amount of an authorized payment, all of it when amount is 0
+func (p *Processor) Capture(ctx context.Context, id int, amount money.Amount) (structs.Payment, error) {
+	payment, err := p.payable(ctx, id, "authorized")
+
+	if err != nil {
+		return structs.Payment{}, err
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, providerTimeout)
+	event, err := p.provider.Capture(callCtx, payment.Reference, amount)
+	cancel()
+
+	if err != nil {
+		return structs.Payment{}, providerError(err)
+	}
+
+	return p.store.UpdatePayment(ctx, payment.Id, event)
+}
+
+// Gives back amount of a captured payment, all that's left of it when amount is 0
+func (p *Processor) Refund(ctx context.Context, id int, amount money.Amount) (structs.Payment, error) {
+	payment, err := p.payable(ctx, id, "captured")
+
+	if err != nil {
+		return structs.Payment{}, err
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, providerTimeout)
+	event, err := p.provider.Refund(callCtx, payment.Reference, amount)
+	cancel()
+
+	if err != nil {
+		return structs.Payment{}, providerError(err)
+	}
+
+	return p.store.UpdatePayment(ctx, payment.Id, event)
+}
+
+func providerError(err error) error {
+	if errors.Is(err, ErrProvider) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", ErrProvider, err)
+}
+
+// Returns payment id when it went through this processor's provider and is in status
+func (p *Processor) payable(ctx context.Context, id int, status string) (structs.Payment, error) {
+	payment, err := p.store.GetPaymentById(ctx, id)
+
+	if err != nil {
+		return structs.Payment{}, err
+	}
+
+	if payment.Provider != p.provider.Name() {
+		return structs.Payment{}, db.Conflict(fmt.Sprintf("payment %d went through %s", payment.Id, payment.Provider))
+	}
+
+	if payment.Status != status {
+		return structs.Payment{}, db.Conflict(fmt.Sprintf("the payment is %s, not %s", payment.Status, status))
+	}
+
+	return payment, nil
+}
+
+// Verifies and applies a webhook callback of the provider, returns ErrInvalidSignature for a forged one
+func (p *Processor) HandleWebhook(ctx context.Context, header http.Header, body []byte) (structs.Payment, error) {
+	event, err := p.provider.VerifyWebhook(header, body)
+
+	if err != nil {
+		return structs.Payment{}, err
+	}
+
+	return p.store.ApplyPaymentEvent(ctx, p.provider.Name(), event)
+}
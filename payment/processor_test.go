@@ -0,0 +1,173 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"vayer-electric-backend/db"
+	"vayer-electric-backend/money"
+	"vayer-electric-backend/structs"
+)
+
+// Memory store with a pending order for 4 of a product priced 12.50
+func newTestOrder(t *testing.T) (db.Store, structs.Order) {
+	t.Helper()
+
+	ctx := context.Background()
+	store := db.NewMemoryStore()
+
+	if err := store.InsertCategory(ctx, "Cables", "Cables and wires", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.InsertSubcategory(ctx, "Wire", "Building wire", 1, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.InsertProduct(ctx, "THHN 12", "Copper wire", 1, money.FromMinor(1250), 10, "thhn.jpg", "Southwire", "THHN-12"); err != nil {
+		t.Fatal(err)
+	}
+
+	cart, err := store.InsertCart(ctx, "", "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.AddCartLine(ctx, cart.Token, "", 1, 0, 4); err != nil {
+		t.Fatal(err)
+	}
+
+	order, err := store.Checkout(ctx, cart.Token, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return store, order
+}
+
+func assertOrderStatus(t *testing.T, store db.Store, order structs.Order, want string) {
+	t.Helper()
+
+	order, err := store.GetOrderById(context.Background(), int(order.Id))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if order.Status != want {
+		t.Errorf("order is %s, want %s", order.Status, want)
+	}
+}
+
+func TestProcessorPayCaptureRefund(t *testing.T) {
+	ctx := context.Background()
+	store, order := newTestOrder(t)
+	p := NewProcessor(store, newTestFakeProvider(t, FakeConfig{}))
+
+	payment, err := p.Pay(ctx, order, FakeSourceSuccess)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if payment.Status != "authorized" || payment.Amount != order.Total {
+		t.Errorf("payment is %s for %s, want authorized for %s", payment.Status, payment.Amount, order.Total)
+	}
+
+	assertOrderStatus(t, store, order, "paid")
+
+	if _, err := p.Refund(ctx, int(payment.Id), 0); !errors.Is(err, db.ErrConflict) {
+		t.Errorf("refunding before capture: error = %v, want a conflict", err)
+	}
+
+	if payment, err = p.Capture(ctx, int(payment.Id), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if payment.CapturedAmount != order.Total {
+		t.Errorf("captured %s, want %s", payment.CapturedAmount, order.Total)
+	}
+
+	if payment, err = p.Refund(ctx, int(payment.Id), money.FromMinor(1000)); err != nil {
+		t.Fatal(err)
+	}
+
+	// A partial refund leaves the order paid
+	assertOrderStatus(t, store, order, "paid")
+
+	if payment, err = p.Refund(ctx, int(payment.Id), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if payment.Status != "refunded" || payment.RefundedAmount != order.Total {
+		t.Errorf("payment is %s with %s refunded, want all of %s refunded", payment.Status, payment.RefundedAmount, order.Total)
+	}
+
+	assertOrderStatus(t, store, order, "refunded")
+}
+
+// A declined payment leaves the order pending for the customer to try again
+func TestProcessorDecline(t *testing.T) {
+	ctx := context.Background()
+	store, order := newTestOrder(t)
+	p := NewProcessor(store, newTestFakeProvider(t, FakeConfig{}))
+
+	payment, err := p.Pay(ctx, order, FakeSourceDecline)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if payment.Status != "declined" || payment.Message == "" {
+		t.Errorf("payment is %s %q, want declined with a message", payment.Status, payment.Message)
+	}
+
+	assertOrderStatus(t, store, order, "pending")
+
+	if payment, err = p.Pay(ctx, order, FakeSourceSuccess); err != nil {
+		t.Fatal(err)
+	}
+
+	if payment.Status != "authorized" {
+		t.Errorf("second payment is %s, want authorized", payment.Status)
+	}
+
+	assertOrderStatus(t, store, order, "paid")
+}
+
+// A pending payment is settled by its webhook, delivering the webhook again changes nothing
+func TestProcessorWebhook(t *testing.T) {
+	ctx := context.Background()
+	store, order := newTestOrder(t)
+	f := newTestFakeProvider(t, FakeConfig{Secret: "whsec_test", WebhookDelay: time.Hour})
+	p := NewProcessor(store, f)
+
+	payment, err := p.Pay(ctx, order, FakeSourceDelayed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if payment.Status != "pending" {
+		t.Fatalf("payment is %s, want pending", payment.Status)
+	}
+
+	body := []byte(`{"id": "fake_pay_1_authorization", "reference": "fake_pay_1", "status": "authorized", "amount": "` + order.Total.String() + `"}`)
+
+	if _, err := p.HandleWebhook(ctx, fakeSignature("whsec_other", time.Now(), body), body); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("forged webhook: error = %v, want ErrInvalidSignature", err)
+	}
+
+	assertOrderStatus(t, store, order, "pending")
+
+	for i := 0; i < 2; i++ {
+		payment, err = p.HandleWebhook(ctx, fakeSignature("whsec_test", time.Now(), body), body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if payment.Status != "authorized" {
+			t.Errorf("delivery %d: payment is %s, want authorized", i+1, payment.Status)
+		}
+	}
+
+	assertOrderStatus(t, store, order, "paid")
+}
@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStore keeps blobs as files of a single directory, every replica needs to share it
+type LocalStore struct {
+	root string
+}
+
+func NewLocalStore(root string) (*LocalStore, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+
+	return &LocalStore{root: root}, nil
+}
+
+// Keys are flat so anything that could leave the directory is treated as missing
+func (s *LocalStore) path(key string) (string, error) {
+	if key == "" || key == "." || key == ".." || strings.ContainsAny(key, `/\`) {
+		return "", ErrNotFound
+	}
+
+	return filepath.Join(s.root, key), nil
+}
+
+// Writes to a temporary file first so readers never see a partial blob
+func (s *LocalStore) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	path, err := s.path(key)
+
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.root, ".upload-*")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *LocalStore) Stat(ctx context.Context, key string) (BlobInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return BlobInfo{}, err
+	}
+
+	path, err := s.path(key)
+
+	if err != nil {
+		return BlobInfo{}, err
+	}
+
+	fi, err := os.Stat(path)
+
+	if err != nil {
+		return BlobInfo{}, translateFileError(err)
+	}
+
+	if fi.IsDir() {
+		return BlobInfo{}, ErrNotFound
+	}
+
+	return localInfo(key, fi), nil
+}
+
+func (s *LocalStore) Open(ctx context.Context, key string) (Blob, error) {
+	if err := ctx.Err(); err != nil {
+		return Blob{}, err
+	}
+
+	path, err := s.path(key)
+
+	if err != nil {
+		return Blob{}, err
+	}
+
+	f, err := os.Open(path)
+
+	if err != nil {
+		return Blob{}, translateFileError(err)
+	}
+
+	fi, err := f.Stat()
+
+	if err != nil {
+		f.Close()
+		return Blob{}, err
+	}
+
+	if fi.IsDir() {
+		f.Close()
+		return Blob{}, ErrNotFound
+	}
+
+	return Blob{ReadSeekCloser: f, BlobInfo: localInfo(key, fi)}, nil
+}
+
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	path, err := s.path(key)
+
+	if err != nil {
+		return nil
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// Files being written by Put are hidden until they're renamed into place
+func (s *LocalStore) List(ctx context.Context) ([]BlobInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(s.root)
+
+	if err != nil {
+		return nil, err
+	}
+
+	blobs := make([]BlobInfo, 0, len(entries))
+
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		fi, err := entry.Info()
+
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		blobs = append(blobs, localInfo(entry.Name(), fi))
+	}
+
+	return blobs, nil
+}
+
+func localInfo(key string, fi fs.FileInfo) BlobInfo {
+	return BlobInfo{
+		Key:         key,
+		Size:        fi.Size(),
+		ContentType: mime.TypeByExtension(filepath.Ext(key)),
+		ModTime:     fi.ModTime(),
+	}
+}
+
+func translateFileError(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+
+	return err
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalStoreRoundTrip(t *testing.T) {
+	ctx := context.Background()
+
+	s, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Put(ctx, "abc.jpg", []byte("jpeg"), "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+
+	blob, err := s.Open(ctx, "abc.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := io.ReadAll(blob)
+	blob.Close()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "jpeg" || blob.Size != 4 || blob.ContentType != "image/jpeg" {
+		t.Errorf("got %q of %d bytes as %q", data, blob.Size, blob.ContentType)
+	}
+
+	blobs, err := s.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(blobs) != 1 || blobs[0].Key != "abc.jpg" {
+		t.Errorf("List = %+v, want abc.jpg only", blobs)
+	}
+
+	if err := s.Delete(ctx, "abc.jpg"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Stat(ctx, "abc.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat after Delete = %v, want ErrNotFound", err)
+	}
+
+	if err := s.Delete(ctx, "abc.jpg"); err != nil {
+		t.Errorf("deleting a missing blob = %v, want nil", err)
+	}
+}
+
+// Keys that could reach outside the root must not read, write or remove anything there
+func TestLocalStoreRejectsTraversal(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	root := filepath.Join(dir, "blobs")
+
+	s, err := NewLocalStore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outside := filepath.Join(dir, "secret.txt")
+	if err := os.WriteFile(outside, []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := []string{"", ".", "..", "../secret.txt", `..\secret.txt`, "sub/abc.jpg", "/etc/passwd"}
+
+	for _, key := range keys {
+		if _, err := s.Stat(ctx, key); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Stat(%q) = %v, want ErrNotFound", key, err)
+		}
+
+		if _, err := s.Open(ctx, key); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Open(%q) = %v, want ErrNotFound", key, err)
+		}
+
+		if err := s.Put(ctx, key, []byte("overwritten"), ""); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Put(%q) = %v, want ErrNotFound", key, err)
+		}
+
+		if err := s.Delete(ctx, key); err != nil {
+			t.Errorf("Delete(%q) = %v, want nil", key, err)
+		}
+	}
+
+	data, err := os.ReadFile(outside)
+	if err != nil || string(data) != "secret" {
+		t.Errorf("file outside the root is now %q, %v", data, err)
+	}
+}
+
+// Temporary files of uploads in progress aren't blobs
+func TestLocalStoreListSkipsHiddenFiles(t *testing.T) {
+	root := t.TempDir()
+
+	s, err := NewLocalStore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(root, ".upload-123"), []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	blobs, err := s.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(blobs) != 0 {
+		t.Errorf("List = %+v, want nothing", blobs)
+	}
+}
@@ -0,0 +1,291 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"vayer-electric-backend/env"
+)
+
+type S3Config struct {
+	// Scheme and host of the service, like https://s3.eu-west-1.amazonaws.com or http://localhost:9000 for MinIO
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// Addresses objects as endpoint/bucket/key instead of bucket.endpoint/key
+	PathStyle bool
+	// How long presigned urls last, zero proxies blobs instead of redirecting to them
+	PresignExpiry time.Duration
+}
+
+func S3ConfigFromEnv() S3Config {
+	return S3Config{
+		Endpoint:      env.BLOB_S3_ENDPOINT,
+		Region:        env.BLOB_S3_REGION,
+		Bucket:        env.BLOB_S3_BUCKET,
+		AccessKey:     env.BLOB_S3_ACCESS_KEY,
+		SecretKey:     env.BLOB_S3_SECRET_KEY,
+		PathStyle:     env.BLOB_S3_PATH_STYLE,
+		PresignExpiry: env.BLOB_S3_PRESIGN_EXPIRY,
+	}
+}
+
+// S3Store keeps blobs in a bucket of S3 or any service speaking its api like MinIO
+type S3Store struct {
+	endpoint      *url.URL
+	bucket        string
+	pathStyle     bool
+	presignExpiry time.Duration
+	signer        signer
+	client        *http.Client
+}
+
+func NewS3Store(config S3Config) (*S3Store, error) {
+	endpoint, err := url.Parse(config.Endpoint)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("s3 endpoint %q must be a url like https://s3.amazonaws.com", config.Endpoint)
+	}
+
+	if config.Bucket == "" || config.AccessKey == "" || config.SecretKey == "" {
+		return nil, errors.New("the s3 blob driver needs a bucket, an access key and a secret key")
+	}
+
+	return &S3Store{
+		endpoint:      endpoint,
+		bucket:        config.Bucket,
+		pathStyle:     config.PathStyle,
+		presignExpiry: config.PresignExpiry,
+		signer: signer{
+			accessKey: config.AccessKey,
+			secretKey: config.SecretKey,
+			region:    config.Region,
+			service:   "s3",
+		},
+		client: &http.Client{},
+	}, nil
+}
+
+func (s *S3Store) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	u.RawQuery = ""
+
+	if s.pathStyle {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.bucket + "/" + key
+	} else {
+		u.Host = s.bucket + "." + u.Host
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	}
+
+	u.RawPath = uriEncode(u.Path, false)
+
+	return &u
+}
+
+// Sends a signed request for an object, a 404 is reported as ErrNotFound and any other failure status as an error
+func (s *S3Store) do(ctx context.Context, method string, key string, body []byte, header http.Header) (*http.Response, error) {
+	return s.send(ctx, method, s.objectURL(key), body, header)
+}
+
+func (s *S3Store) send(ctx context.Context, method string, u *url.URL, body []byte, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+
+	if err != nil {
+		return nil, err
+	}
+
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	s.signer.sign(req, hashHex(body), time.Now())
+
+	resp, err := s.client.Do(req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrNotFound
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+		return nil, fmt.Errorf("s3 %s %s: %s %s", method, u.Path, resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	return resp, nil
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+
+	resp, err := s.do(ctx, http.MethodPut, key, data, header)
+
+	if err != nil {
+		log.Error(err.Error())
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+func (s *S3Store) Stat(ctx context.Context, key string) (BlobInfo, error) {
+	resp, err := s.do(ctx, http.MethodHead, key, nil, nil)
+
+	if err != nil {
+		return BlobInfo{}, err
+	}
+
+	resp.Body.Close()
+
+	return s3Info(key, resp), nil
+}
+
+// Objects are read in full so they can be served with ranges, uploads are limited to a few megabytes
+func (s *S3Store) Open(ctx context.Context, key string) (Blob, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, nil)
+
+	if err != nil {
+		return Blob{}, err
+	}
+
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+
+	if err != nil {
+		return Blob{}, err
+	}
+
+	info := s3Info(key, resp)
+	info.Size = int64(len(data))
+
+	return Blob{ReadSeekCloser: nopSeekCloser{bytes.NewReader(data)}, BlobInfo: info}, nil
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, nil)
+
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+
+	if err != nil {
+		log.Error(err.Error())
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+func (s *S3Store) RedirectURL(key string) (string, bool) {
+	if s.presignExpiry <= 0 {
+		return "", false
+	}
+
+	return s.signer.presign(http.MethodGet, s.objectURL(key), s.presignExpiry, time.Now()).String(), true
+}
+
+// Pages through ListObjectsV2, a bucket is expected to hold the api's images only
+func (s *S3Store) List(ctx context.Context) ([]BlobInfo, error) {
+	blobs := make([]BlobInfo, 0)
+	continuationToken := ""
+
+	for {
+		u := s.objectURL("")
+		if s.pathStyle {
+			u.Path = strings.TrimSuffix(u.Path, "/")
+			u.RawPath = uriEncode(u.Path, false)
+		}
+
+		query := url.Values{"list-type": {"2"}}
+		if continuationToken != "" {
+			query.Set("continuation-token", continuationToken)
+		}
+		u.RawQuery = query.Encode()
+
+		resp, err := s.send(ctx, http.MethodGet, u, nil, nil)
+
+		if err != nil {
+			log.Error(err.Error())
+			return nil, err
+		}
+
+		var page struct {
+			IsTruncated           bool
+			NextContinuationToken string
+			Contents              []struct {
+				Key          string
+				Size         int64
+				LastModified time.Time
+			}
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range page.Contents {
+			blobs = append(blobs, BlobInfo{
+				Key:         object.Key,
+				Size:        object.Size,
+				ContentType: mime.TypeByExtension(path.Ext(object.Key)),
+				ModTime:     object.LastModified,
+			})
+		}
+
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return blobs, nil
+		}
+
+		continuationToken = page.NextContinuationToken
+	}
+}
+
+func s3Info(key string, resp *http.Response) BlobInfo {
+	size, _ := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+
+	return BlobInfo{
+		Key:         key,
+		Size:        size,
+		ContentType: resp.Header.Get("Content-Type"),
+		ModTime:     modTime,
+	}
+}
+
+type nopSeekCloser struct {
+	*bytes.Reader
+}
+
+func (nopSeekCloser) Close() error {
+	return nil
+}
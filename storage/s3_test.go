@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestS3Store(t *testing.T, handler http.HandlerFunc, presignExpiry time.Duration) *S3Store {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	s, err := NewS3Store(S3Config{
+		Endpoint:      server.URL,
+		Region:        "us-east-1",
+		Bucket:        "images",
+		AccessKey:     "access",
+		SecretKey:     "secret",
+		PathStyle:     true,
+		PresignExpiry: presignExpiry,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func TestS3StoreSignsPathStyleRequests(t *testing.T) {
+	var path, authorization string
+
+	s := newTestS3Store(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		authorization = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "image/png")
+		io.WriteString(w, "png")
+	}, 0)
+
+	blob, err := s.Open(context.Background(), "abc.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob.Close()
+
+	if path != "/images/abc.png" {
+		t.Errorf("path = %q, want /images/abc.png", path)
+	}
+
+	if !strings.HasPrefix(authorization, "AWS4-HMAC-SHA256 Credential=access/") {
+		t.Errorf("Authorization = %q, want a SigV4 signature", authorization)
+	}
+
+	if blob.Size != 3 || blob.ContentType != "image/png" {
+		t.Errorf("got %d bytes as %q", blob.Size, blob.ContentType)
+	}
+}
+
+func TestS3StoreNotFound(t *testing.T) {
+	s := newTestS3Store(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}, 0)
+
+	ctx := context.Background()
+
+	if _, err := s.Stat(ctx, "abc.png"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat = %v, want ErrNotFound", err)
+	}
+
+	if _, err := s.Open(ctx, "abc.png"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Open = %v, want ErrNotFound", err)
+	}
+
+	if err := s.Delete(ctx, "abc.png"); err != nil {
+		t.Errorf("Delete = %v, want nil", err)
+	}
+}
+
+func TestS3StoreRedirectURL(t *testing.T) {
+	s := newTestS3Store(t, func(w http.ResponseWriter, r *http.Request) {}, 0)
+
+	if _, ok := s.RedirectURL("abc.png"); ok {
+		t.Error("redirecting without a presign expiry, want proxying")
+	}
+
+	s = newTestS3Store(t, func(w http.ResponseWriter, r *http.Request) {}, time.Hour)
+
+	u, ok := s.RedirectURL("abc.png")
+	if !ok {
+		t.Fatal("not redirecting with a presign expiry")
+	}
+
+	if !strings.Contains(u, "/images/abc.png?") || !strings.Contains(u, "X-Amz-Expires=3600") || !strings.Contains(u, "X-Amz-Signature=") {
+		t.Errorf("RedirectURL = %s, want a presigned url of the object", u)
+	}
+}
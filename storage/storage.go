@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"vayer-electric-backend/env"
+	"vayer-electric-backend/logging"
+)
+
+var log = logging.GetLogger()
+
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore keeps uploaded files under flat keys like "abc.jpg".
+// LocalStore writes them to a directory and S3Store to a bucket of an S3 compatible service.
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Returns ErrNotFound when there's no blob under key
+	Stat(ctx context.Context, key string) (BlobInfo, error)
+	// Returns ErrNotFound when there's no blob under key, the caller closes the blob
+	Open(ctx context.Context, key string) (Blob, error)
+	// Deleting a missing blob is not an error
+	Delete(ctx context.Context, key string) error
+	// Returns every blob, in no particular order
+	List(ctx context.Context) ([]BlobInfo, error)
+}
+
+// Redirector is implemented by drivers that can send clients to fetch a blob straight from the backend
+type Redirector interface {
+	// Returns the url to redirect to, false when the blob has to be proxied instead
+	RedirectURL(key string) (string, bool)
+}
+
+type BlobInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+type Blob struct {
+	io.ReadSeekCloser
+	BlobInfo
+}
+
+var (
+	_ BlobStore  = (*LocalStore)(nil)
+	_ BlobStore  = (*S3Store)(nil)
+	_ Redirector = (*S3Store)(nil)
+)
+
+// Returns the BlobStore selected by env.BLOB_DRIVER
+func GetBlobStore() BlobStore {
+	switch env.BLOB_DRIVER {
+	case "s3":
+		s, err := NewS3Store(S3ConfigFromEnv())
+
+		if err != nil {
+			panic(err)
+		}
+
+		return s
+	default:
+		s, err := NewLocalStore(env.BLOB_LOCAL_PATH)
+
+		if err != nil {
+			panic(err)
+		}
+
+		return s
+	}
+}
@@ -0,0 +1,27 @@
+package structs
+
+// Raised when a stock change takes the available stock of a product below its reorder point
+type StockAlert struct {
+	Id              int64  `json:"id"`
+	ProductId       int64  `json:"product_id"`
+	ProductName     string `json:"product_name"`
+	Sku             string `json:"sku"`
+	Available       int64  `json:"available"`
+	ReorderPoint    int64  `json:"reorder_point"`
+	ReorderQuantity int64  `json:"reorder_quantity"`
+	// Failed deliveries so far
+	Attempts  int    `json:"attempts"`
+	CreatedAt string `json:"created_at"`
+}
+
+// A product whose available stock is below its reorder point
+type LowStockProduct struct {
+	ProductId        int64  `json:"product_id"`
+	Name             string `json:"name"`
+	Sku              string `json:"sku"`
+	CurrentInventory int64  `json:"current_inventory"`
+	Reserved         int64  `json:"reserved"`
+	Available        int64  `json:"available"`
+	ReorderPoint     int64  `json:"reorder_point"`
+	ReorderQuantity  int64  `json:"reorder_quantity"`
+}
@@ -0,0 +1,37 @@
+package structs
+
+// Attribute types
+const (
+	AttributeNumber    = "number"
+	AttributeEnum      = "enum"
+	AttributeMultiEnum = "multi_enum"
+	AttributeBoolean   = "boolean"
+)
+
+// AttributeDefinition describes a specification products of a subcategory can carry,
+// like the voltage of a breaker or the IP rating of a luminaire
+type AttributeDefinition struct {
+	Id            int64    `json:"id"`
+	SubcategoryId int64    `json:"subcategory_id"`
+	Code          string   `json:"code"`
+	Name          string   `json:"name"`
+	Type          string   `json:"type"`
+	Unit          string   `json:"unit,omitempty"`
+	AllowedValues []string `json:"allowed_values,omitempty"`
+	Required      bool     `json:"required"`
+	CreatedAt     string   `json:"created_at"`
+}
+
+// ProductAttribute is the value of an attribute for a product.
+// Value is a float64 for numbers, a string for enums, a []string for multi enums and a bool for booleans.
+// Numbers are given in Unit, wire gauges in AWG are strings like "4/0".
+type ProductAttribute struct {
+	AttributeId int64       `json:"attribute_id"`
+	Code        string      `json:"code"`
+	Name        string      `json:"name"`
+	Type        string      `json:"type"`
+	Value       interface{} `json:"value"`
+	Unit        string      `json:"unit,omitempty"`
+	// Number in the canonical SI unit it's stored in, other units are converted from it
+	SIValue *float64 `json:"-"`
+}
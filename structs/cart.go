@@ -0,0 +1,39 @@
+package structs
+
+import "vayer-electric-backend/money"
+
+// A cart priced from the current product prices, Token is all an anonymous cart needs to be reached
+type Cart struct {
+	Id         int64  `json:"-"`
+	Token      string `json:"token"`
+	CustomerId string `json:"customer_id,omitempty"`
+	// Region the cart is taxed in
+	Region    string       `json:"region"`
+	Lines     []CartLine   `json:"lines"`
+	ItemCount int64        `json:"item_count"`
+	Subtotal  money.Amount `json:"subtotal"`
+	TaxTotal  money.Amount `json:"tax_total"`
+	Total     money.Amount `json:"total"`
+	// Whether available stock covers every line
+	Available bool   `json:"available"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// A line of a product, or of one of its variants when VariantId is set. The sku, price, image and stock
+// of a variant stand in for its product's.
+type CartLine struct {
+	Id        int64        `json:"id"`
+	ProductId int64        `json:"product_id"`
+	VariantId int64        `json:"variant_id,omitempty"`
+	Name      string       `json:"name"`
+	Sku       string       `json:"sku"`
+	ImageUrl  string       `json:"image_url"`
+	Quantity  int64        `json:"quantity"`
+	UnitPrice money.Amount `json:"unit_price"`
+	LineTotal money.Amount `json:"line_total"`
+	Tax       Tax          `json:"tax"`
+	// Available stock of the product or variant, InStock is whether it covers the quantity
+	Available int64 `json:"available"`
+	InStock   bool  `json:"in_stock"`
+}
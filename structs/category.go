@@ -6,4 +6,5 @@ type Category struct {
 	Description string `json:"description"`
 	CreatedAt   string `json:"created_at"`
 	ImageUrl    string `json:"image_url"`
+	TaxClassId  int64  `json:"tax_class_id"`
 }
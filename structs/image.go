@@ -0,0 +1,12 @@
+package structs
+
+// ProductImage is an image of the gallery of a product, the primary one is also the image_url of the product
+type ProductImage struct {
+	Id        int64  `json:"id"`
+	ProductId int64  `json:"product_id"`
+	ImageUrl  string `json:"image_url"`
+	AltText   string `json:"alt_text"`
+	Position  int    `json:"position"`
+	Primary   bool   `json:"primary"`
+	CreatedAt string `json:"created_at"`
+}
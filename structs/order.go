@@ -0,0 +1,53 @@
+package structs
+
+import "vayer-electric-backend/money"
+
+type Order struct {
+	Id         int64        `json:"id"`
+	Token      string       `json:"token"`
+	CustomerId string       `json:"customer_id,omitempty"`
+	Status     string       `json:"status"`
+	Region     string       `json:"region"`
+	Lines      []OrderLine  `json:"lines"`
+	ItemCount  int64        `json:"item_count"`
+	Subtotal   money.Amount `json:"subtotal"`
+	TaxTotal   money.Amount `json:"tax_total"`
+	// What the customer pays, Subtotal plus TaxTotal
+	Total     money.Amount `json:"total"`
+	CreatedAt string       `json:"created_at"`
+	UpdatedAt string       `json:"updated_at"`
+
+	// Only set when a single order is fetched
+	Transitions []OrderTransition `json:"transitions,omitempty"`
+}
+
+// A line of an order as it was sold, taxed as it was then. ProductId and VariantId are 0 once
+// the product or variant is deleted.
+type OrderLine struct {
+	Id        int64        `json:"id"`
+	ProductId int64        `json:"product_id"`
+	VariantId int64        `json:"variant_id,omitempty"`
+	Name      string       `json:"name"`
+	Sku       string       `json:"sku"`
+	UnitPrice money.Amount `json:"unit_price"`
+	Quantity  int64        `json:"quantity"`
+	LineTotal money.Amount `json:"line_total"`
+	// Tax on LineTotal at the rate of the product when it was sold
+	Tax Tax `json:"tax"`
+}
+
+// A change of status of an order, From is empty on the transition that created it
+type OrderTransition struct {
+	Id        int64  `json:"id"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	User      string `json:"user"`
+	Note      string `json:"note"`
+	CreatedAt string `json:"created_at"`
+}
+
+// Orders newest first
+type OrderPage struct {
+	Items      []Order `json:"items"`
+	NextCursor string  `json:"next_cursor"`
+}
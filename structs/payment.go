@@ -0,0 +1,29 @@
+package structs
+
+import "vayer-electric-backend/money"
+
+// A payment of an order through a payment provider, Reference is the id the provider knows it by
+type Payment struct {
+	Id             int64        `json:"id"`
+	OrderId        int64        `json:"order_id"`
+	Provider       string       `json:"provider"`
+	Reference      string       `json:"reference"`
+	Status         string       `json:"status"`
+	Amount         money.Amount `json:"amount"`
+	CapturedAmount money.Amount `json:"captured_amount"`
+	RefundedAmount money.Amount `json:"refunded_amount"`
+	// Why the payment was declined or failed
+	Message   string `json:"message"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// An outcome reported by a payment provider, in its response to a call or in a webhook.
+// Id is the provider's id for the operation, empty when the outcome isn't final yet.
+type PaymentEvent struct {
+	Id        string       `json:"id"`
+	Reference string       `json:"reference"`
+	Status    string       `json:"status"`
+	Amount    money.Amount `json:"amount"`
+	Message   string       `json:"message"`
+}
@@ -1,14 +1,78 @@
 package structs
 
+import "vayer-electric-backend/money"
+
 type Product struct {
-	Id               int64   `json:"id"`
-	Name             string  `json:"name"`
-	Description      string  `json:"description"`
-	SubcategoryId    int64   `json:"subcategory_id"`
-	Price            float64 `json:"price"`
-	CurrentInventory int64   `json:"current_inventory"`
-	ImageUrl         string  `json:"image_url"`
-	Brand            string  `json:"brand"`
-	Sku              string  `json:"sku"`
-	CreatedAt        string  `json:"created_at"`
+	Id               int64        `json:"id"`
+	Name             string       `json:"name"`
+	Description      string       `json:"description"`
+	SubcategoryId    int64        `json:"subcategory_id"`
+	Price            money.Amount `json:"price"`
+	CurrentInventory int64        `json:"current_inventory"`
+	ImageUrl         string       `json:"image_url"`
+	Brand            string       `json:"brand"`
+	Sku              string       `json:"sku"`
+	ReorderPoint     int64        `json:"reorder_point"`
+	ReorderQuantity  int64        `json:"reorder_quantity"`
+	// Class set on the product itself, 0 when it takes the class of its subcategory or category
+	TaxClassId int64  `json:"tax_class_id"`
+	CreatedAt  string `json:"created_at"`
+
+	Attributes []ProductAttribute `json:"attributes"`
+	Images     []ProductImage     `json:"images"`
+
+	// Only set when a single product is fetched
+	Variants *VariantMatrix  `json:"variants,omitempty"`
+	Stock    []LocationStock `json:"stock,omitempty"`
+
+	// Only set on search results
+	Match *ProductMatch `json:"match,omitempty"`
+
+	// Price taxed in the region asked for, Price is the net price
+	Tax *Tax `json:"tax,omitempty"`
+}
+
+// How a product matched a search, Name and Snippet have the matched terms wrapped in <b></b>
+type ProductMatch struct {
+	Rank    float64 `json:"rank"`
+	Name    string  `json:"name"`
+	Snippet string  `json:"snippet"`
+}
+
+type ProductPage struct {
+	Items      []Product      `json:"items"`
+	NextCursor string         `json:"next_cursor"`
+	Total      int64          `json:"total"`
+	Facets     *ProductFacets `json:"facets,omitempty"`
+}
+
+// Counts of the filtered products, used to build the storefront filter sidebar
+type ProductFacets struct {
+	Brands        []FacetCount       `json:"brands"`
+	Subcategories []SubcategoryFacet `json:"subcategories"`
+	PriceBuckets  []PriceBucketFacet `json:"price_buckets"`
+	Availability  AvailabilityFacet  `json:"availability"`
+}
+
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+type SubcategoryFacet struct {
+	Id    int64  `json:"id"`
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+// Products with Min <= price < Max, Max is null on the last bucket
+type PriceBucketFacet struct {
+	Min   *money.Amount `json:"min"`
+	Max   *money.Amount `json:"max"`
+	Count int64         `json:"count"`
+}
+
+type AvailabilityFacet struct {
+	InStock    int64 `json:"in_stock"`
+	OutOfStock int64 `json:"out_of_stock"`
 }
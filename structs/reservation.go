@@ -0,0 +1,15 @@
+package structs
+
+// Stock held for a checkout or an open quote, it stops counting against available stock
+// once it expires or is released. CartId is the cart a checkout reservation was taken for, 0 for quotes.
+// A reservation of a variant holds stock of the variant instead of its product.
+type Reservation struct {
+	Id        int64  `json:"id"`
+	ProductId int64  `json:"product_id"`
+	VariantId int64  `json:"variant_id,omitempty"`
+	CartId    int64  `json:"cart_id,omitempty"`
+	Quantity  int64  `json:"quantity"`
+	Reference string `json:"reference"`
+	ExpiresAt string `json:"expires_at"`
+	CreatedAt string `json:"created_at"`
+}
@@ -0,0 +1,23 @@
+package structs
+
+// A change to the stock of a product at a location, or of one of its variants. Quantity is signed and Balance
+// is the stock left at the location, or of the variant, right after the movement.
+type StockMovement struct {
+	Id        int64 `json:"id"`
+	ProductId int64 `json:"product_id"`
+	// Set on movements of a variant, variant stock isn't kept per location so LocationId is 0 on them
+	VariantId  int64  `json:"variant_id,omitempty"`
+	LocationId int64  `json:"location_id"`
+	Reason     string `json:"reason"`
+	Quantity   int64  `json:"quantity"`
+	Balance    int64  `json:"balance"`
+	User       string `json:"user"`
+	Reference  string `json:"reference"`
+	CreatedAt  string `json:"created_at"`
+}
+
+// Stock history of a product, newest movement first
+type StockMovementPage struct {
+	Items      []StockMovement `json:"items"`
+	NextCursor string          `json:"next_cursor"`
+}
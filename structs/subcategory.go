@@ -7,4 +7,6 @@ type Subcategory struct {
 	CategoryId  int64  `json:"category_id"`
 	CreatedAt   string `json:"created_at"`
 	ImageUrl    string `json:"image_url"`
+	// 0 when products take the class of the category
+	TaxClassId int64 `json:"tax_class_id"`
 }
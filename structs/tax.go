@@ -0,0 +1,32 @@
+package structs
+
+import "vayer-electric-backend/money"
+
+// A class of goods taxed alike, like standard or reduced rate goods. Products without a class of their own
+// take the class of their subcategory, then of their category, then the default class.
+type TaxClass struct {
+	Id        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Default   bool      `json:"default"`
+	Rates     []TaxRate `json:"rates"`
+	CreatedAt string    `json:"created_at"`
+}
+
+// Rate of a tax class in a region, the "*" region covers regions the class has no rate for
+type TaxRate struct {
+	Id         int64      `json:"id"`
+	TaxClassId int64      `json:"tax_class_id"`
+	Region     string     `json:"region"`
+	Rate       money.Rate `json:"rate"`
+	CreatedAt  string     `json:"created_at"`
+}
+
+// Tax on a net amount sold in a region, Gross is Net plus Tax
+type Tax struct {
+	TaxClass string       `json:"tax_class"`
+	Region   string       `json:"region"`
+	Rate     money.Rate   `json:"rate"`
+	Net      money.Amount `json:"net"`
+	Tax      money.Amount `json:"tax"`
+	Gross    money.Amount `json:"gross"`
+}
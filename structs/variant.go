@@ -0,0 +1,30 @@
+package structs
+
+import "vayer-electric-backend/money"
+
+// ProductVariant is a version of a product sold on its own, like the red 50 m roll of a cable.
+// Options holds the value of every variant axis of the parent product.
+type ProductVariant struct {
+	Id               int64             `json:"id"`
+	ProductId        int64             `json:"product_id"`
+	Sku              string            `json:"sku"`
+	Price            money.Amount      `json:"price"`
+	CurrentInventory int64             `json:"current_inventory"`
+	ImageUrl         string            `json:"image_url"`
+	Options          map[string]string `json:"options"`
+	CreatedAt        string            `json:"created_at"`
+
+	// Price taxed in the class of the product
+	Tax *Tax `json:"tax,omitempty"`
+}
+
+// VariantAxis is an axis a product varies on, with the values its variants use in order of appearance
+type VariantAxis struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+type VariantMatrix struct {
+	Axes  []VariantAxis    `json:"axes"`
+	Items []ProductVariant `json:"items"`
+}
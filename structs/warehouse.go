@@ -0,0 +1,45 @@
+package structs
+
+type Warehouse struct {
+	Id        int64      `json:"id"`
+	Name      string     `json:"name"`
+	CreatedAt string     `json:"created_at"`
+	Locations []Location `json:"locations"`
+}
+
+// A place stock sits in within a warehouse, stock changes that don't name a location go to the default one
+type Location struct {
+	Id          int64  `json:"id"`
+	WarehouseId int64  `json:"warehouse_id"`
+	Name        string `json:"name"`
+	Default     bool   `json:"default"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// Stock of a product at one location
+type LocationStock struct {
+	LocationId    int64  `json:"location_id"`
+	LocationName  string `json:"location_name"`
+	WarehouseId   int64  `json:"warehouse_id"`
+	WarehouseName string `json:"warehouse_name"`
+	Quantity      int64  `json:"quantity"`
+}
+
+// Stock of a product, Total is its current_inventory and Available what's left of it once active reservations are held back
+type ProductStock struct {
+	ProductId int64           `json:"product_id"`
+	Total     int64           `json:"total"`
+	Reserved  int64           `json:"reserved"`
+	Available int64           `json:"available"`
+	Locations []LocationStock `json:"locations"`
+}
+
+// Stock moved between two locations, recorded as a pair of transfer movements
+type StockTransfer struct {
+	ProductId      int64  `json:"product_id"`
+	FromLocationId int64  `json:"from_location_id"`
+	ToLocationId   int64  `json:"to_location_id"`
+	Quantity       int64  `json:"quantity"`
+	User           string `json:"user"`
+	Reference      string `json:"reference"`
+}
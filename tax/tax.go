@@ -0,0 +1,157 @@
+package tax
+
+import (
+	"strings"
+
+	"vayer-electric-backend/money"
+	"vayer-electric-backend/structs"
+)
+
+// Region of the rates used where a class has no rate for the region asked for
+const AnyRegion = "*"
+
+const maxRegionLength = 16
+
+// Upper cases a region like "de" or "es-cn", ok is false when it isn't letters, digits and dashes or AnyRegion
+func NormalizeRegion(region string) (string, bool) {
+	region = strings.ToUpper(strings.TrimSpace(region))
+
+	if region == AnyRegion {
+		return region, true
+	}
+
+	if region == "" || len(region) > maxRegionLength {
+		return "", false
+	}
+
+	for _, c := range region {
+		if !(c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+			return "", false
+		}
+	}
+
+	return region, true
+}
+
+// Tax classes set on a subcategory and on its category, 0 where none is
+type Assignment struct {
+	SubcategoryId      int64
+	SubcategoryClassId int64
+	CategoryClassId    int64
+}
+
+// Table finds the class products are taxed in and the rate of a class in a region.
+// It's a snapshot of the tax tables, loaded for the request or transaction that taxes something.
+type Table struct {
+	defaultClass int64
+	names        map[int64]string
+	rates        map[int64]map[string]money.Rate
+	subcategory  map[int64]int64
+}
+
+func NewTable(classes []structs.TaxClass, assignments []Assignment) Table {
+	t := Table{
+		names:       make(map[int64]string, len(classes)),
+		rates:       make(map[int64]map[string]money.Rate, len(classes)),
+		subcategory: make(map[int64]int64, len(assignments)),
+	}
+
+	for _, c := range classes {
+		if c.Default {
+			t.defaultClass = c.Id
+		}
+
+		t.names[c.Id] = c.Name
+		t.rates[c.Id] = make(map[string]money.Rate, len(c.Rates))
+
+		for _, r := range c.Rates {
+			t.rates[c.Id][r.Region] = r.Rate
+		}
+	}
+
+	for _, a := range assignments {
+		if a.SubcategoryClassId != 0 {
+			t.subcategory[a.SubcategoryId] = a.SubcategoryClassId
+		} else if a.CategoryClassId != 0 {
+			t.subcategory[a.SubcategoryId] = a.CategoryClassId
+		}
+	}
+
+	return t
+}
+
+// Class of a product: its own, else its subcategory's, else its category's, else the default class
+func (t Table) ProductClass(taxClassId int64, subcategoryId int64) int64 {
+	if taxClassId != 0 {
+		return taxClassId
+	}
+
+	if class, ok := t.subcategory[subcategoryId]; ok {
+		return class
+	}
+
+	return t.defaultClass
+}
+
+// Rate of a class in a region, its AnyRegion rate when it has none for the region and 0 when it has neither
+func (t Table) Rate(class int64, region string) money.Rate {
+	if rate, ok := t.rates[class][region]; ok {
+		return rate
+	}
+
+	return t.rates[class][AnyRegion]
+}
+
+// Taxes net, the price of a product or a line of it, sold in region
+func (t Table) Tax(taxClassId int64, subcategoryId int64, region string, net money.Amount) (structs.Tax, error) {
+	class := t.ProductClass(taxClassId, subcategoryId)
+	return Apply(t.names[class], region, t.Rate(class, region), net)
+}
+
+// Taxes products in region, with their variants when they're loaded
+func (t Table) TaxProducts(products []structs.Product, region string) error {
+	for i := range products {
+		p := &products[i]
+
+		tax, err := t.Tax(p.TaxClassId, p.SubcategoryId, region, p.Price)
+		if err != nil {
+			return err
+		}
+		p.Tax = &tax
+
+		if p.Variants == nil {
+			continue
+		}
+
+		// Variants have no class of their own, a variant is the same goods as its product
+		for j := range p.Variants.Items {
+			v := &p.Variants.Items[j]
+			tax, err := t.Tax(p.TaxClassId, p.SubcategoryId, region, v.Price)
+			if err != nil {
+				return err
+			}
+			v.Tax = &tax
+		}
+	}
+
+	return nil
+}
+
+// Taxes net at rate, the tax is rounded half up. The gross of a net near the int64 limit is money.ErrOverflow.
+func Apply(class string, region string, rate money.Rate, net money.Amount) (structs.Tax, error) {
+	tax := rate.Of(net, money.HalfUp)
+	gross, err := net.Add(tax)
+
+	if err != nil {
+		return structs.Tax{}, err
+	}
+
+	return structs.Tax{
+		TaxClass: class,
+		Region:   region,
+		Rate:     rate,
+		Net:      net,
+		Tax:      tax,
+		Gross:    gross,
+	}, nil
+}
@@ -0,0 +1,162 @@
+package tax
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"vayer-electric-backend/money"
+	"vayer-electric-backend/structs"
+)
+
+// Standard is the default class at 21% in ES and 19% anywhere else, Reduced is 10% in ES only
+// and Books has no rates. Subcategory 1 is Reduced, 2 is Books through its category, 3 has
+// Reduced over its category's Books and 4 has no class.
+func newTestTable() Table {
+	classes := []structs.TaxClass{
+		{Id: 1, Name: "Standard", Default: true, Rates: []structs.TaxRate{
+			{Region: "ES", Rate: 2100},
+			{Region: AnyRegion, Rate: 1900},
+		}},
+		{Id: 2, Name: "Reduced", Rates: []structs.TaxRate{
+			{Region: "ES", Rate: 1000},
+		}},
+		{Id: 3, Name: "Books"},
+	}
+
+	assignments := []Assignment{
+		{SubcategoryId: 1, SubcategoryClassId: 2},
+		{SubcategoryId: 2, CategoryClassId: 3},
+		{SubcategoryId: 3, SubcategoryClassId: 2, CategoryClassId: 3},
+		{SubcategoryId: 4},
+	}
+
+	return NewTable(classes, assignments)
+}
+
+func TestNormalizeRegion(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"es", "ES", true},
+		{" es-cn ", "ES-CN", true},
+		{"*", AnyRegion, true},
+		{"US-12", "US-12", true},
+		{"", "", false},
+		{"e s", "", false},
+		{"es_cn", "", false},
+		{"ES*", "", false},
+		{"ABCDEFGHIJKLMNOPQ", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := NormalizeRegion(tt.in)
+
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("NormalizeRegion(%q) = %q, %v, want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// The product's own class comes first, then its subcategory's, its category's and the default class
+func TestProductClass(t *testing.T) {
+	table := newTestTable()
+
+	tests := []struct {
+		name          string
+		taxClassId    int64
+		subcategoryId int64
+		want          int64
+	}{
+		{"own class", 3, 1, 3},
+		{"subcategory", 0, 1, 2},
+		{"category", 0, 2, 3},
+		{"subcategory over category", 0, 3, 2},
+		{"default for an unassigned subcategory", 0, 4, 1},
+		{"default for an unknown subcategory", 0, 99, 1},
+	}
+
+	for _, tt := range tests {
+		if got := table.ProductClass(tt.taxClassId, tt.subcategoryId); got != tt.want {
+			t.Errorf("%s: ProductClass(%d, %d) = %d, want %d", tt.name, tt.taxClassId, tt.subcategoryId, got, tt.want)
+		}
+	}
+
+	if got := NewTable(nil, nil).ProductClass(0, 1); got != 0 {
+		t.Errorf("without a default class: ProductClass = %d, want 0", got)
+	}
+}
+
+func TestRate(t *testing.T) {
+	table := newTestTable()
+
+	tests := []struct {
+		class  int64
+		region string
+		want   money.Rate
+	}{
+		{1, "ES", 2100},
+		{1, "DE", 1900},
+		{1, AnyRegion, 1900},
+		{2, "ES", 1000},
+		// No rate for the region and no AnyRegion rate
+		{2, "DE", 0},
+		{3, "ES", 0},
+		{99, "ES", 0},
+	}
+
+	for _, tt := range tests {
+		if got := table.Rate(tt.class, tt.region); got != tt.want {
+			t.Errorf("Rate(%d, %q) = %s, want %s", tt.class, tt.region, got, tt.want)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	tax, err := Apply("Standard", "ES", 2100, money.FromMinor(1099))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := structs.Tax{TaxClass: "Standard", Region: "ES", Rate: 2100, Net: 1099, Tax: 231, Gross: 1330}
+	if tax != want {
+		t.Errorf("Apply = %+v, want %+v", tax, want)
+	}
+
+	// Halves round up: 10% of 0.05 is 0.005
+	if tax, err := Apply("Reduced", "ES", 1000, money.FromMinor(5)); err != nil || tax.Tax != 1 {
+		t.Errorf("Apply = %+v, %v, want a tax of 0.01", tax, err)
+	}
+
+	if _, err := Apply("Standard", "ES", 2100, money.Amount(math.MaxInt64-1)); !errors.Is(err, money.ErrOverflow) {
+		t.Errorf("Apply near the int64 limit: error = %v, want ErrOverflow", err)
+	}
+}
+
+func TestTaxProducts(t *testing.T) {
+	table := newTestTable()
+
+	products := []structs.Product{
+		{Id: 1, SubcategoryId: 1, Price: 1000},
+		{Id: 2, SubcategoryId: 4, Price: 1000, Variants: &structs.VariantMatrix{Items: []structs.ProductVariant{{Id: 1, Price: 2000}}}},
+	}
+
+	if err := table.TaxProducts(products, "ES"); err != nil {
+		t.Fatal(err)
+	}
+
+	if tax := products[0].Tax; tax == nil || tax.TaxClass != "Reduced" || tax.Gross != 1100 {
+		t.Errorf("product 1 taxed %+v, want Reduced to 11.00", tax)
+	}
+
+	if tax := products[1].Tax; tax == nil || tax.TaxClass != "Standard" || tax.Gross != 1210 {
+		t.Errorf("product 2 taxed %+v, want Standard to 12.10", tax)
+	}
+
+	// Variants are taxed in their product's class
+	if tax := products[1].Variants.Items[0].Tax; tax == nil || tax.TaxClass != "Standard" || tax.Gross != 2420 {
+		t.Errorf("variant taxed %+v, want Standard to 24.20", tax)
+	}
+}
@@ -0,0 +1,71 @@
+package units
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// American Wire Gauge is logarithmic: the diameter of gauge n is 0.127 mm * 92^((36-n)/39).
+// Aught sizes continue below 1: 1/0 is gauge 0, 2/0 is -1, 3/0 is -2 and 4/0 is -3.
+
+func awgDiameter(gauge float64) float64 {
+	return 0.127e-3 * math.Pow(92, (36-gauge)/39)
+}
+
+func awgToArea(gauge float64) float64 {
+	d := awgDiameter(gauge)
+	return math.Pi / 4 * d * d
+}
+
+// Returns the exact, possibly fractional, gauge of a cross section area in m2, NaN when there's no cross section
+func areaToAwg(area float64) float64 {
+	if area <= 0 {
+		return math.NaN()
+	}
+
+	d := math.Sqrt(4 * area / math.Pi)
+	gauge := 36 - 39*math.Log(d/0.127e-3)/math.Log(92)
+	return math.Round(gauge*1e9) / 1e9
+}
+
+// Parses "12", "1/0".."4/0" or "00".."0000" into a gauge number
+func ParseGauge(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+
+	if strings.HasSuffix(s, "/0") {
+		aughts, err := strconv.Atoi(strings.TrimSuffix(s, "/0"))
+		if err != nil || aughts < 1 {
+			return 0, fmt.Errorf("invalid wire gauge %q", s)
+		}
+		return float64(1 - aughts), nil
+	}
+
+	if len(s) > 1 && strings.Trim(s, "0") == "" {
+		return float64(1 - len(s)), nil
+	}
+
+	gauge, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid wire gauge %q", s)
+	}
+
+	return gauge, nil
+}
+
+// Formats a gauge as it's written on cables, rounding to the nearest standard size. NaN and infinities
+// aren't a size and format empty.
+func FormatGauge(gauge float64) string {
+	if math.IsNaN(gauge) || math.IsInf(gauge, 0) {
+		return ""
+	}
+
+	n := int(math.Round(gauge))
+
+	if n <= 0 {
+		return fmt.Sprintf("%d/0", 1-n)
+	}
+
+	return strconv.Itoa(n)
+}
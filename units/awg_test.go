@@ -0,0 +1,33 @@
+package units
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAreaToAwg(t *testing.T) {
+	tests := []struct {
+		area float64
+		want string
+	}{
+		{awgToArea(12), "12"},
+		{awgToArea(0), "1/0"},
+		{awgToArea(-3), "4/0"},
+		{0, ""},
+		{-1e-6, ""},
+	}
+
+	for _, test := range tests {
+		if got := FormatGauge(areaToAwg(test.area)); got != test.want {
+			t.Errorf("FormatGauge(areaToAwg(%v)) = %q, want %q", test.area, got, test.want)
+		}
+	}
+}
+
+func TestFormatGaugeNotANumber(t *testing.T) {
+	for _, gauge := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		if got := FormatGauge(gauge); got != "" {
+			t.Errorf("FormatGauge(%v) = %q, want empty", gauge, got)
+		}
+	}
+}
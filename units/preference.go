@@ -0,0 +1,61 @@
+package units
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Preference tells which unit values are returned in, per dimension
+type Preference struct {
+	si    bool
+	units map[Dimension]Unit
+}
+
+// Parses the ?units= query parameter: "si" for canonical units and/or a comma separated list of
+// unit symbols, like "AWG,kW". Dimensions without a preference keep the unit they come in.
+func ParsePreference(s string) (Preference, error) {
+	p := Preference{units: make(map[Dimension]Unit)}
+
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+
+		if item == "" {
+			continue
+		}
+
+		if strings.EqualFold(item, "si") {
+			p.si = true
+			continue
+		}
+
+		u, err := Lookup(item)
+		if err != nil {
+			return Preference{}, err
+		}
+
+		if existing, ok := p.units[u.Dimension]; ok && existing.Symbol != u.Symbol {
+			return Preference{}, fmt.Errorf("both %s and %s were asked for %s", existing.Symbol, u.Symbol, u.Dimension)
+		}
+
+		p.units[u.Dimension] = u
+	}
+
+	return p, nil
+}
+
+// Returns the unit a value given in current should be returned in
+func (p Preference) For(current Unit) Unit {
+	if u, ok := p.units[current.Dimension]; ok {
+		return u
+	}
+
+	if p.si {
+		return Canonical(current.Dimension)
+	}
+
+	return current
+}
+
+func (p Preference) IsEmpty() bool {
+	return !p.si && len(p.units) == 0
+}
@@ -0,0 +1,257 @@
+package units
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUnknownUnit  = errors.New("unknown unit")
+	ErrIncompatible = errors.New("incompatible units")
+)
+
+// Dimension is the physical quantity a unit measures, only units of the same dimension convert into each other.
+// Luminous flux (lm) and illuminance (lx) are different quantities, converting them needs the lit area.
+type Dimension string
+
+const (
+	Length       Dimension = "length"
+	Area         Dimension = "area"
+	Voltage      Dimension = "voltage"
+	Current      Dimension = "current"
+	Power        Dimension = "power"
+	Energy       Dimension = "energy"
+	Charge       Dimension = "charge"
+	Frequency    Dimension = "frequency"
+	Resistance   Dimension = "resistance"
+	Capacitance  Dimension = "capacitance"
+	LuminousFlux Dimension = "luminous_flux"
+	Illuminance  Dimension = "illuminance"
+	Temperature  Dimension = "temperature"
+	Mass         Dimension = "mass"
+)
+
+// Unit converts values to and from the canonical SI unit of its dimension.
+// Linear units use scale and offset, non linear ones like AWG provide their own functions.
+type Unit struct {
+	Symbol    string
+	Dimension Dimension
+
+	scale  float64
+	offset float64
+	toSI   func(float64) float64
+	fromSI func(float64) float64
+}
+
+func (u Unit) ToCanonical(v float64) float64 {
+	if u.toSI != nil {
+		return Round(u.toSI(v))
+	}
+	return Round(v*u.scale + u.offset)
+}
+
+func (u Unit) FromCanonical(v float64) float64 {
+	if u.fromSI != nil {
+		return Round(u.fromSI(v))
+	}
+	return Round((v - u.offset) / u.scale)
+}
+
+func (u Unit) IsCanonical() bool {
+	return Canonical(u.Dimension).Symbol == u.Symbol
+}
+
+func linear(symbol string, dimension Dimension, scale float64, aliases ...string) unitEntry {
+	return unitEntry{Unit{Symbol: symbol, Dimension: dimension, scale: scale}, aliases}
+}
+
+type unitEntry struct {
+	unit    Unit
+	aliases []string
+}
+
+// The first unit of each dimension is its canonical SI unit
+var table = []unitEntry{
+	linear("m", Length, 1, "meter", "meters", "metre"),
+	linear("mm", Length, 1e-3),
+	linear("cm", Length, 1e-2),
+	linear("km", Length, 1e3),
+	linear("in", Length, 0.0254, "inch", "inches", "\""),
+	linear("ft", Length, 0.3048, "feet", "foot", "'"),
+
+	linear("m2", Area, 1, "m²", "m^2"),
+	linear("mm2", Area, 1e-6, "mm²", "mm^2", "sqmm"),
+	linear("cm2", Area, 1e-4, "cm²", "cm^2"),
+	linear("kcmil", Area, 0.5067074790974977e-6, "MCM"),
+	{Unit{Symbol: "AWG", Dimension: Area, toSI: awgToArea, fromSI: areaToAwg}, []string{"awg", "ga"}},
+
+	linear("V", Voltage, 1, "volt", "volts", "Vac", "Vdc"),
+	linear("mV", Voltage, 1e-3),
+	linear("kV", Voltage, 1e3),
+
+	linear("A", Current, 1, "amp", "amps", "ampere", "amperes"),
+	linear("mA", Current, 1e-3),
+	linear("kA", Current, 1e3),
+
+	linear("W", Power, 1, "watt", "watts"),
+	linear("mW", Power, 1e-3),
+	linear("kW", Power, 1e3),
+	linear("MW", Power, 1e6),
+	linear("hp", Power, 745.6998715822702, "HP"),
+
+	linear("J", Energy, 1, "joule", "joules"),
+	linear("Wh", Energy, 3600),
+	linear("kWh", Energy, 3.6e6),
+
+	linear("C", Charge, 1, "coulomb", "coulombs"),
+	linear("Ah", Charge, 3600),
+	linear("mAh", Charge, 3.6),
+
+	linear("Hz", Frequency, 1, "hertz"),
+	linear("kHz", Frequency, 1e3),
+	linear("MHz", Frequency, 1e6),
+
+	linear("ohm", Resistance, 1, "Ω", "ohms"),
+	linear("kohm", Resistance, 1e3, "kΩ"),
+	linear("Mohm", Resistance, 1e6, "MΩ"),
+
+	linear("F", Capacitance, 1, "farad"),
+	linear("uF", Capacitance, 1e-6, "µF", "μF"),
+	linear("nF", Capacitance, 1e-9),
+	linear("pF", Capacitance, 1e-12),
+
+	linear("lm", LuminousFlux, 1, "lumen", "lumens"),
+
+	linear("lx", Illuminance, 1, "lux"),
+	linear("fc", Illuminance, 10.763910416709722, "footcandle", "footcandles"),
+
+	linear("K", Temperature, 1, "kelvin"),
+	{Unit{Symbol: "°C", Dimension: Temperature, scale: 1, offset: 273.15}, []string{"degC", "celsius", "ºC"}},
+	{Unit{Symbol: "°F", Dimension: Temperature, scale: 5.0 / 9, offset: 273.15 - 32*5.0/9}, []string{"degF", "fahrenheit", "ºF"}},
+
+	linear("kg", Mass, 1),
+	linear("g", Mass, 1e-3),
+	linear("lb", Mass, 0.45359237, "lbs"),
+}
+
+var (
+	bySymbol    = make(map[string]Unit)
+	byLower     = make(map[string]Unit)
+	canonical   = make(map[Dimension]Unit)
+	ambiguous   = make(map[string]bool)
+	valueFormat = regexp.MustCompile(`^\s*(\d+/0|0{2,}|[-+]?(?:\d+(?:[.,]\d*)?|[.,]\d+)(?:[eE][-+]?\d+)?)\s*(.*?)\s*$`)
+)
+
+func init() {
+	for _, entry := range table {
+		if _, ok := canonical[entry.unit.Dimension]; !ok {
+			canonical[entry.unit.Dimension] = entry.unit
+		}
+
+		for _, symbol := range append([]string{entry.unit.Symbol}, entry.aliases...) {
+			bySymbol[symbol] = entry.unit
+
+			// mW and MW only differ in case, those have to be written exactly
+			lower := strings.ToLower(symbol)
+			if existing, ok := byLower[lower]; ok && existing.Symbol != entry.unit.Symbol {
+				ambiguous[lower] = true
+			}
+			byLower[lower] = entry.unit
+		}
+	}
+}
+
+// Returns the unit for a symbol or alias, matching case only when it's needed to tell units apart
+func Lookup(symbol string) (Unit, error) {
+	symbol = strings.TrimSpace(symbol)
+
+	if u, ok := bySymbol[symbol]; ok {
+		return u, nil
+	}
+
+	lower := strings.ToLower(symbol)
+	if u, ok := byLower[lower]; ok && !ambiguous[lower] {
+		return u, nil
+	}
+
+	return Unit{}, fmt.Errorf("%w: %s", ErrUnknownUnit, symbol)
+}
+
+// Returns the canonical SI unit of a dimension
+func Canonical(d Dimension) Unit {
+	return canonical[d]
+}
+
+func Convert(v float64, from Unit, to Unit) (float64, error) {
+	if from.Dimension != to.Dimension {
+		return 0, fmt.Errorf("%w: %s to %s", ErrIncompatible, from.Symbol, to.Symbol)
+	}
+
+	if from.Symbol == to.Symbol {
+		return v, nil
+	}
+
+	return to.FromCanonical(from.ToCanonical(v)), nil
+}
+
+// Parses a value with an optional unit like "2.5 mm2", "12AWG", "4/0 AWG", "1,5kW" or "230".
+// The returned unit is nil when the value carries none.
+func Parse(s string) (float64, *Unit, error) {
+	match := valueFormat.FindStringSubmatch(s)
+	if match == nil {
+		return 0, nil, fmt.Errorf("invalid value %q", s)
+	}
+
+	number, symbol := match[1], match[2]
+
+	var unit *Unit
+	if symbol != "" {
+		u, err := Lookup(symbol)
+		if err != nil {
+			return 0, nil, err
+		}
+		unit = &u
+	}
+
+	// Aught sizes only make sense as wire gauges
+	if strings.Contains(number, "/") || (len(number) > 1 && strings.Trim(number, "0") == "") {
+		if unit == nil || unit.Symbol != "AWG" {
+			return 0, nil, fmt.Errorf("invalid value %q", s)
+		}
+		gauge, err := ParseGauge(number)
+		return gauge, unit, err
+	}
+
+	v, err := strconv.ParseFloat(strings.Replace(number, ",", ".", 1), 64)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid value %q", s)
+	}
+
+	return v, unit, nil
+}
+
+// Rounds to 12 significant digits, hiding the float noise conversions leave behind
+func Round(v float64) float64 {
+	if v == 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+		return v
+	}
+
+	rounded, err := strconv.ParseFloat(strconv.FormatFloat(v, 'g', 12, 64), 64)
+	if err != nil {
+		return v
+	}
+
+	return rounded
+}
+
+// Returns v the way it's presented in u, wire gauges as the size written on the cable like "12" or "4/0"
+func Present(v float64, u Unit) interface{} {
+	if u.Symbol == "AWG" {
+		return FormatGauge(v)
+	}
+	return v
+}